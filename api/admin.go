@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"project/apperr"
+	"project/config"
+	"project/repository"
+)
+
+// queryStatJSON is a query statistic with its derived figures
+type queryStatJSON struct {
+	config.QueryStat
+	MeanTime  int64   `json:"mean_time_ns"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// queryStatsBody is the response of GET /admin/queries
+type queryStatsBody struct {
+	Queries []queryStatJSON `json:"queries"`
+	// Dropped counts executions of fingerprints past the tracking cap
+	Dropped int64 `json:"dropped"`
+}
+
+// queryStatOrders sort query statistics, the largest first
+var queryStatOrders = map[string]func(a, b config.QueryStat) bool{
+	"total":  func(a, b config.QueryStat) bool { return a.TotalTime > b.TotalTime },
+	"calls":  func(a, b config.QueryStat) bool { return a.Calls > b.Calls },
+	"mean":   func(a, b config.QueryStat) bool { return a.MeanTime() > b.MeanTime() },
+	"max":    func(a, b config.QueryStat) bool { return a.MaxTime > b.MaxTime },
+	"errors": func(a, b config.QueryStat) bool { return a.ErrorRate() > b.ErrorRate() },
+}
+
+// AdminHandler serves operational endpoints:
+//
+//	GET    /admin/queries?sort=total|calls|mean|max|errors&limit=N
+//	DELETE /admin/queries
+//	GET    /admin/dead-letters?topic=T&replayed=true&limit=N
+//	GET    /admin/dead-letters/{id}
+//	PUT    /admin/dead-letters/{id}
+//	DELETE /admin/dead-letters/{id}
+//	POST   /admin/dead-letters/{id}/replay
+//
+// The first lists the statistics stats collected per query fingerprint,
+// the second resets them. The others inspect, edit the payload of,
+// discard and replay the outbox messages in deadLetters; they are only
+// served when it isn't nil.
+func AdminHandler(stats *config.QueryStats, deadLetters *repository.DeadLetters) http.Handler {
+	mux := http.NewServeMux()
+	if deadLetters != nil {
+		mux.HandleFunc("/admin/dead-letters", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				methodNotAllowed(w, http.MethodGet)
+				return
+			}
+			serveDeadLetters(w, r, deadLetters)
+		})
+		mux.HandleFunc("/admin/dead-letters/", func(w http.ResponseWriter, r *http.Request) {
+			serveDeadLetter(w, r, deadLetters)
+		})
+	}
+	mux.HandleFunc("/admin/queries", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			serveQueryStats(w, r, stats)
+		case http.MethodDelete:
+			stats.Reset()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	})
+	return mux
+}
+
+// serveQueryStats writes the sorted, limited query statistics
+func serveQueryStats(w http.ResponseWriter, r *http.Request, stats *config.QueryStats) {
+	order := "total"
+	if s := r.URL.Query().Get("sort"); s != "" {
+		order = s
+	}
+	less, ok := queryStatOrders[order]
+	if !ok {
+		writeError(w, apperr.New(apperr.InvalidArgument, "sort must be total, calls, mean, max or errors"))
+		return
+	}
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			writeError(w, apperr.New(apperr.InvalidArgument, "limit must be a positive integer"))
+			return
+		}
+		limit = n
+	}
+
+	all, dropped := stats.Snapshot()
+	sort.SliceStable(all, func(i, j int) bool { return less(all[i], all[j]) })
+	if len(all) > limit {
+		all = all[:limit]
+	}
+
+	body := queryStatsBody{Queries: make([]queryStatJSON, len(all)), Dropped: dropped}
+	for i, s := range all {
+		body.Queries[i] = queryStatJSON{QueryStat: s, MeanTime: int64(s.MeanTime()), ErrorRate: s.ErrorRate()}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// deadLetterJSON is the response form of a dead letter: JSON payloads are
+// inlined, others base64-encoded
+type deadLetterJSON struct {
+	ID            int64           `json:"id"`
+	OutboxID      int64           `json:"outbox_id"`
+	Topic         string          `json:"topic"`
+	ContentType   string          `json:"content_type"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	PayloadBase64 []byte          `json:"payload_base64,omitempty"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	FailedAt      time.Time       `json:"failed_at"`
+	ReplayedAt    *time.Time      `json:"replayed_at,omitempty"`
+}
+
+// toDeadLetterJSON converts a dead letter to its response form
+func toDeadLetterJSON(l repository.DeadLetter) deadLetterJSON {
+	out := deadLetterJSON{
+		ID:          l.ID,
+		OutboxID:    l.OutboxID,
+		Topic:       l.Topic,
+		ContentType: l.ContentType,
+		Attempts:    l.Attempts,
+		LastError:   l.LastError,
+		FailedAt:    l.FailedAt,
+		ReplayedAt:  l.ReplayedAt,
+	}
+	if l.ContentType == repository.JSONContentType {
+		out.Payload = l.Payload
+	} else {
+		out.PayloadBase64 = l.Payload
+	}
+	return out
+}
+
+// serveDeadLetters lists dead letters, the oldest first
+func serveDeadLetters(w http.ResponseWriter, r *http.Request, deadLetters *repository.DeadLetters) {
+	q := r.URL.Query()
+	f := repository.DeadLetterFilter{Topic: q.Get("topic"), Replayed: q.Get("replayed") == "true", Limit: 100}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			writeError(w, apperr.New(apperr.InvalidArgument, "limit must be a positive integer"))
+			return
+		}
+		f.Limit = n
+	}
+
+	letters, err := deadLetters.List(r.Context(), f)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body := make([]deadLetterJSON, len(letters))
+	for i, l := range letters {
+		body[i] = toDeadLetterJSON(l)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"dead_letters": body})
+}
+
+// serveDeadLetter handles /admin/dead-letters/{id} and its replay action
+func serveDeadLetter(w http.ResponseWriter, r *http.Request, deadLetters *repository.DeadLetters) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/dead-letters/")
+	idPart, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		writeError(w, apperr.New(apperr.InvalidArgument, "dead letter id must be an integer"))
+		return
+	}
+	ctx := r.Context()
+
+	switch {
+	case action == "replay":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		if err := deadLetters.Replay(ctx, id); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case action != "":
+		writeError(w, apperr.New(apperr.NotFound, "unknown dead letter action"))
+	case r.Method == http.MethodGet:
+		l, err := deadLetters.Get(ctx, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toDeadLetterJSON(l))
+	case r.Method == http.MethodPut:
+		payload, err := readBody(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := deadLetters.Edit(ctx, id, payload); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		if err := deadLetters.Delete(ctx, id); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
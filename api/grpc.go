@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"project/apperr"
+	"project/mapping"
+	"project/proto/userpb"
+	"project/proto/userv2pb"
+	"project/service"
+)
+
+// RegisterGRPC registers every API version on s
+func RegisterGRPC(s grpc.ServiceRegistrar, users *service.UserService) {
+	userpb.RegisterUsersServer(s, &UsersV1{users: users})
+	userv2pb.RegisterUsersServer(s, &UsersV2{users: users})
+}
+
+// grpcError converts err to a status with its code. Like writeError, only
+// the messages of client errors are shown.
+func grpcError(err error) error {
+	code := apperr.CodeOf(err)
+	msg := "internal error"
+	if apperr.HTTPStatus(code) < 500 {
+		msg = err.Error()
+	}
+	return status.Error(apperr.GRPCCode(code), msg)
+}
+
+// UsersV1 serves users.v1.Users
+type UsersV1 struct {
+	userpb.UnimplementedUsersServer
+	users *service.UserService
+}
+
+// CreateUser implements userpb.UsersServer
+func (s *UsersV1) CreateUser(ctx context.Context, req *userpb.CreateUserRequest) (*emptypb.Empty, error) {
+	if err := s.users.WithContext(ctx).RegisterUserWithEmail(req.GetName(), req.GetEmail()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// GetUser implements userpb.UsersServer
+func (s *UsersV1) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	u, err := s.users.WithContext(ctx).GetUser(int(req.GetId()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	p, err := mapping.UserToProto(u)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return p, nil
+}
+
+// ListUsers implements userpb.UsersServer
+func (s *UsersV1) ListUsers(ctx context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	users, err := s.users.WithContext(ctx).ListUsers()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &userpb.ListUsersResponse{}
+	for _, u := range users {
+		p, err := mapping.UserToProto(u)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		resp.Users = append(resp.Users, p)
+	}
+	return resp, nil
+}
+
+// UpdateUser implements userpb.UsersServer
+func (s *UsersV1) UpdateUser(ctx context.Context, req *userpb.UpdateUserRequest) (*emptypb.Empty, error) {
+	if err := s.users.WithContext(ctx).UpdateUser(int(req.GetId()), req.GetName()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteUser implements userpb.UsersServer
+func (s *UsersV1) DeleteUser(ctx context.Context, req *userpb.DeleteUserRequest) (*emptypb.Empty, error) {
+	if err := s.users.WithContext(ctx).DeleteUser(int(req.GetId())); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UsersV2 serves users.v2.Users
+type UsersV2 struct {
+	userv2pb.UnimplementedUsersServer
+	users *service.UserService
+}
+
+// CreateUser implements userv2pb.UsersServer
+func (s *UsersV2) CreateUser(ctx context.Context, req *userv2pb.CreateUserRequest) (*emptypb.Empty, error) {
+	name := mapping.JoinName(req.GetFirstName(), req.GetLastName())
+	if err := s.users.WithContext(ctx).RegisterUserWithEmail(name, req.GetEmail()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// GetUser implements userv2pb.UsersServer
+func (s *UsersV2) GetUser(ctx context.Context, req *userv2pb.GetUserRequest) (*userv2pb.User, error) {
+	u, err := s.users.WithContext(ctx).GetUser(int(req.GetId()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	p, err := mapping.UserToProtoV2(u)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return p, nil
+}
+
+// ListUsers implements userv2pb.UsersServer
+func (s *UsersV2) ListUsers(ctx context.Context, req *userv2pb.ListUsersRequest) (*userv2pb.ListUsersResponse, error) {
+	users, err := s.users.WithContext(ctx).ListUsers()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &userv2pb.ListUsersResponse{}
+	for _, u := range users {
+		p, err := mapping.UserToProtoV2(u)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		resp.Users = append(resp.Users, p)
+	}
+	return resp, nil
+}
+
+// UpdateUser implements userv2pb.UsersServer
+func (s *UsersV2) UpdateUser(ctx context.Context, req *userv2pb.UpdateUserRequest) (*emptypb.Empty, error) {
+	name := mapping.JoinName(req.GetFirstName(), req.GetLastName())
+	if err := s.users.WithContext(ctx).UpdateUser(int(req.GetId()), name); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteUser implements userv2pb.UsersServer
+func (s *UsersV2) DeleteUser(ctx context.Context, req *userv2pb.DeleteUserRequest) (*emptypb.Empty, error) {
+	if err := s.users.WithContext(ctx).DeleteUser(int(req.GetId())); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"project/apperr"
+	"project/service"
+)
+
+// maxBodyBytes bounds request bodies
+const maxBodyBytes = 1 << 20
+
+// Handler serves every API version under its path prefix:
+//
+//	GET    /{version}/users
+//	POST   /{version}/users, answering the user and its Location
+//	GET    /{version}/users/{id}
+//	PUT    /{version}/users/{id}
+//	DELETE /{version}/users/{id}
+func Handler(users *service.UserService) http.Handler {
+	mux := http.NewServeMux()
+	for name, v := range versions {
+		h := usersHandler{users: users, name: name, version: v}
+		mux.Handle("/"+name+"/users", h)
+		mux.Handle("/"+name+"/users/", h)
+	}
+	return mux
+}
+
+// usersHandler serves the users resource of one version
+type usersHandler struct {
+	users   *service.UserService
+	name    string
+	version version
+}
+
+// ServeHTTP implements http.Handler
+func (h usersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	users := h.users.WithContext(r.Context())
+
+	// /{version}/users or /{version}/users/{id}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 2 {
+		switch r.Method {
+		case http.MethodGet:
+			list, err := users.ListUsers()
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			out := make([]any, len(list))
+			for i, u := range list {
+				out[i] = h.version.encode(u)
+			}
+			writeJSON(w, http.StatusOK, out)
+		case http.MethodPost:
+			body, err := readBody(r)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			name, email, err := h.version.create(body)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			u, err := users.CreateUser(name, email)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			w.Header().Set("Location", "/"+h.name+"/users/"+strconv.Itoa(u.ID))
+			writeJSON(w, http.StatusCreated, h.version.encode(u))
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(parts[len(parts)-1])
+	if len(parts) != 3 || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		u, err := users.GetUser(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.version.encode(u))
+	case http.MethodPut:
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		name, err := h.version.update(body)
+		if err == nil {
+			err = users.UpdateUser(id, name)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := users.DeleteUser(id); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// readBody reads a bounded request body
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	if err != nil {
+		return nil, apperr.Wrap(apperr.InvalidArgument, err)
+	}
+	return body, nil
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// errorBody is the JSON body of an error response
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// writeError responds with the status of err's code. Only the messages of
+// client errors are shown; other failures are reported generically.
+func writeError(w http.ResponseWriter, err error) {
+	code := apperr.CodeOf(err)
+	status := apperr.HTTPStatus(code)
+	msg := http.StatusText(status)
+	if status < http.StatusInternalServerError {
+		msg = err.Error()
+	}
+	writeJSON(w, status, errorBody{Error: msg})
+}
+
+// methodNotAllowed responds 405 listing the allowed methods
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, errorBody{Error: http.StatusText(http.StatusMethodNotAllowed)})
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"project/models"
+	"project/repository"
+	"project/service"
+)
+
+// memoryRepo is a UserRepository over a map, enough to serve the handler
+type memoryRepo struct {
+	mu    sync.Mutex
+	users map[int]models.User
+	next  int
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{users: map[int]models.User{}}
+}
+
+func (r *memoryRepo) Create(u models.User) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	u.ID = r.next
+	u.CreatedAt = time.Now().UTC()
+	u.UpdatedAt = u.CreatedAt
+	r.users[u.ID] = u
+	return u, nil
+}
+
+func (r *memoryRepo) GetAll() ([]models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := make([]models.User, 0, len(r.users))
+	for id := 1; id <= r.next; id++ {
+		if u, ok := r.users[id]; ok {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryRepo) GetByID(id int) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+	return u, nil
+}
+
+func (r *memoryRepo) ListCreatedBetween(from, to time.Time, opts repository.ListOptions) ([]models.User, error) {
+	return nil, nil
+}
+
+func (r *memoryRepo) Update(u models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.users[u.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	old.Name = u.Name
+	r.users[u.ID] = old
+	return nil
+}
+
+func (r *memoryRepo) Delete(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+// call sends a request to srv and returns the status, Location and body
+func call(t *testing.T, srv *httptest.Server, method, path, body string) (int, string, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header.Get("Location"), string(b)
+}
+
+func TestHandlerServesVersions(t *testing.T) {
+	srv := httptest.NewServer(Handler(service.NewUserService(newMemoryRepo())))
+	defer srv.Close()
+
+	status, location, body := call(t, srv, http.MethodPost, "/v1/users", `{"name":"Ada Lovelace","email":"ada@example.com"}`)
+	if status != http.StatusCreated || location != "/v1/users/1" {
+		t.Fatalf("POST /v1/users = %d at %q: %s", status, location, body)
+	}
+
+	status, location, body = call(t, srv, http.MethodPost, "/v2/users", `{"first_name":"Alan","last_name":"Turing"}`)
+	if status != http.StatusCreated || location != "/v2/users/2" {
+		t.Fatalf("POST /v2/users = %d at %q: %s", status, location, body)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		// want is the expected JSON, compared field by field when set
+		want map[string]any
+	}{
+		{
+			name: "v1 get answers the whole name", method: http.MethodGet, path: "/v1/users/2",
+			wantStatus: http.StatusOK, want: map[string]any{"id": float64(2), "name": "Alan Turing"},
+		},
+		{
+			name: "v2 get splits the name", method: http.MethodGet, path: "/v2/users/1",
+			wantStatus: http.StatusOK, want: map[string]any{"id": float64(1), "first_name": "Ada", "last_name": "Lovelace", "email": "ada@example.com"},
+		},
+		{
+			name: "v2 update joins the name", method: http.MethodPut, path: "/v2/users/1",
+			body: `{"first_name":"Augusta","last_name":"King"}`, wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "v1 sees the v2 update", method: http.MethodGet, path: "/v1/users/1",
+			wantStatus: http.StatusOK, want: map[string]any{"name": "Augusta King"},
+		},
+		{
+			name: "v1 create needs a name", method: http.MethodPost, path: "/v1/users",
+			body: `{"email":"x@example.com"}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "v2 rejects v1 fields", method: http.MethodPost, path: "/v2/users",
+			body: `{"name":"Ada"}`, wantStatus: http.StatusBadRequest,
+		},
+		{name: "v1 delete", method: http.MethodDelete, path: "/v1/users/2", wantStatus: http.StatusNoContent},
+		{name: "v2 get of a deleted user", method: http.MethodGet, path: "/v2/users/2", wantStatus: http.StatusNotFound},
+		{name: "unknown version", method: http.MethodGet, path: "/v3/users", wantStatus: http.StatusNotFound},
+		{name: "non-numeric id", method: http.MethodGet, path: "/v1/users/ada", wantStatus: http.StatusNotFound},
+		{name: "method not allowed", method: http.MethodPatch, path: "/v2/users/1", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, body := call(t, srv, tt.method, tt.path, tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.path, status, tt.wantStatus, body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var got map[string]any
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatalf("body %s: %v", body, err)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+
+	status, _, body = call(t, srv, http.MethodGet, "/v2/users", "")
+	var list []map[string]any
+	if err := json.Unmarshal([]byte(body), &list); status != http.StatusOK || err != nil {
+		t.Fatalf("GET /v2/users = %d: %s", status, body)
+	}
+	if len(list) != 1 || list[0]["first_name"] != "Augusta" {
+		t.Errorf("GET /v2/users = %v, want only Augusta", list)
+	}
+}
@@ -0,0 +1,90 @@
+// Package api serves the versioned users API over HTTP and gRPC. Each
+// version has its own DTOs, converted to the same service calls, so the
+// wire shape can evolve without breaking older clients: v2 splits the name
+// into first and last names.
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"project/apperr"
+	"project/mapping"
+	"project/models"
+)
+
+// CreateUserV1 is the body of a v1 create request
+type CreateUserV1 struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+// UpdateUserV1 is the body of a v1 update request
+type UpdateUserV1 struct {
+	Name string `json:"name"`
+}
+
+// CreateUserV2 is the body of a v2 create request
+type CreateUserV2 struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Email     string `json:"email,omitempty"`
+}
+
+// UpdateUserV2 is the body of a v2 update request
+type UpdateUserV2 struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
+// version converts between the DTOs of one API version and the arguments
+// of the service calls
+type version struct {
+	encode func(u models.User) any
+	// create decodes a create body into the name and email to register
+	create func(body []byte) (name, email string, err error)
+	// update decodes an update body into the new name
+	update func(body []byte) (name string, err error)
+}
+
+// versions are the served API versions by path prefix
+var versions = map[string]version{
+	"v1": {
+		encode: func(u models.User) any { return mapping.UserToJSON(u) },
+		create: func(body []byte) (string, string, error) {
+			var req CreateUserV1
+			err := decode(body, &req)
+			return req.Name, req.Email, err
+		},
+		update: func(body []byte) (string, error) {
+			var req UpdateUserV1
+			err := decode(body, &req)
+			return req.Name, err
+		},
+	},
+	"v2": {
+		encode: func(u models.User) any { return mapping.UserToV2JSON(u) },
+		create: func(body []byte) (string, string, error) {
+			var req CreateUserV2
+			err := decode(body, &req)
+			return mapping.JoinName(req.FirstName, req.LastName), req.Email, err
+		},
+		update: func(body []byte) (string, error) {
+			var req UpdateUserV2
+			err := decode(body, &req)
+			return mapping.JoinName(req.FirstName, req.LastName), err
+		},
+	},
+}
+
+// decode unmarshals a JSON request body, rejecting unknown fields so a v2
+// body sent to v1 fails instead of losing the name
+func decode(body []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return apperr.Wrap(apperr.InvalidArgument, fmt.Errorf("invalid request body: %w", err))
+	}
+	return nil
+}
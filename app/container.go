@@ -0,0 +1,256 @@
+// Package app wires the components of the application, e.g. connections,
+// workers and servers, as lazily built singletons with lifecycle hooks:
+// components start in dependency order and stop in reverse.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hook is a start or stop hook of a component
+type Hook func(ctx context.Context) error
+
+// Lifecycle collects the hooks of one component while it is built
+type Lifecycle struct {
+	start []Hook
+	stop  []Hook
+}
+
+// OnStart adds a hook run when the container starts, after the hooks of
+// the component's dependencies
+func (l *Lifecycle) OnStart(h Hook) {
+	l.start = append(l.start, h)
+}
+
+// OnStop adds a hook run when the container stops, before the hooks of
+// the component's dependencies; a component's hooks run in reverse
+func (l *Lifecycle) OnStop(h Hook) {
+	l.stop = append(l.stop, h)
+}
+
+// Go runs fn in its own goroutine from start, cancelling its context on
+// stop and waiting for it to return, e.g. for workers
+func (l *Lifecycle) Go(fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	l.OnStart(func(context.Context) error {
+		go func() {
+			defer close(done)
+			fn(ctx)
+		}()
+		return nil
+	})
+	l.OnStop(func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	})
+}
+
+// component is one provided singleton
+type component struct {
+	name  string
+	build func(ctx context.Context, lc *Lifecycle) (any, error)
+
+	// mu is held while building, so concurrent Gets build once
+	mu    sync.Mutex
+	built bool
+	value any
+	lc    Lifecycle
+}
+
+// Container holds the components of the application. Components are built
+// on first Get, each at most once, and started by Start in the order they
+// finished building, which puts dependencies first since a component Gets
+// its dependencies while it is built.
+type Container struct {
+	mu         sync.Mutex
+	components map[string]*component
+	names      []string
+	// built and started are in build and start order
+	built   []*component
+	started []*component
+	running bool
+
+	// StopTimeout bounds the stop hooks Run calls; 30s by default
+	StopTimeout time.Duration
+	// Logf, when set, reports components as they start and stop
+	Logf func(format string, args ...any)
+}
+
+// New creates an empty container
+func New() *Container {
+	return &Container{components: make(map[string]*component), StopTimeout: 30 * time.Second}
+}
+
+// Provide registers the component name, built by build on first Get. It
+// panics if name is already provided, since components are wired once at
+// startup.
+func Provide[T any](c *Container, name string, build func(ctx context.Context, lc *Lifecycle) (T, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.components[name]; ok {
+		panic("app: component " + name + " provided twice")
+	}
+	c.components[name] = &component{name: name, build: func(ctx context.Context, lc *Lifecycle) (any, error) {
+		return build(ctx, lc)
+	}}
+	c.names = append(c.names, name)
+}
+
+// Get returns the component name, building it first if needed. Components
+// call it from their build function to declare their dependencies; a
+// dependency cycle is an error. Getting a component after Start runs its
+// start hooks before Get returns.
+func Get[T any](ctx context.Context, c *Container, name string) (T, error) {
+	var zero T
+	v, err := c.resolve(ctx, name)
+	if err != nil {
+		return zero, err
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("component %s is %T, not %T", name, v, zero)
+	}
+	return t, nil
+}
+
+// pathKey carries the components being built by the current Get chain
+type pathKey struct{}
+
+// resolve returns the value of component name, building it if needed
+func (c *Container) resolve(ctx context.Context, name string) (any, error) {
+	c.mu.Lock()
+	comp, ok := c.components[name]
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown component %s", name)
+	}
+
+	path, _ := ctx.Value(pathKey{}).([]string)
+	if slices.Contains(path, name) {
+		return nil, fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+	}
+
+	comp.mu.Lock()
+	defer comp.mu.Unlock()
+	if comp.built {
+		return comp.value, nil
+	}
+
+	var lc Lifecycle
+	v, err := comp.build(context.WithValue(ctx, pathKey{}, append(slices.Clip(path), name)), &lc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s: %w", name, err)
+	}
+	comp.value, comp.lc, comp.built = v, lc, true
+
+	c.mu.Lock()
+	c.built = append(c.built, comp)
+	running := c.running
+	c.mu.Unlock()
+	if running {
+		if err := c.start(ctx, comp); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Start builds every provided component not built yet and runs the start
+// hooks in dependency order. When a hook fails, the components already
+// started are stopped and the error is returned.
+func (c *Container) Start(ctx context.Context) error {
+	c.mu.Lock()
+	names := slices.Clone(c.names)
+	c.mu.Unlock()
+	for _, name := range names {
+		if _, err := c.resolve(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	c.running = true
+	pending := slices.Clone(c.built)
+	c.mu.Unlock()
+	for _, comp := range pending {
+		if err := c.start(ctx, comp); err != nil {
+			return errors.Join(err, c.Stop(ctx))
+		}
+	}
+	return nil
+}
+
+// start runs the start hooks of comp unless it has started
+func (c *Container) start(ctx context.Context, comp *component) error {
+	c.mu.Lock()
+	if slices.Contains(c.started, comp) {
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	for _, h := range comp.lc.start {
+		if err := h(ctx); err != nil {
+			return fmt.Errorf("failed to start %s: %w", comp.name, err)
+		}
+	}
+	c.mu.Lock()
+	c.started = append(c.started, comp)
+	c.mu.Unlock()
+	c.logf("Started %s", comp.name)
+	return nil
+}
+
+// Stop runs the stop hooks of the started components in reverse start
+// order, each bounded by ctx, and returns their errors joined. Components
+// stop even when others fail to.
+func (c *Container) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	started := c.started
+	c.started, c.running = nil, false
+	c.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		comp := started[i]
+		for j := len(comp.lc.stop) - 1; j >= 0; j-- {
+			if err := comp.lc.stop[j](ctx); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop %s: %w", comp.name, err))
+			}
+		}
+		c.logf("Stopped %s", comp.name)
+	}
+	return errors.Join(errs...)
+}
+
+// Run starts the container, waits for ctx to be done and stops it within
+// StopTimeout
+func (c *Container) Run(ctx context.Context) error {
+	if err := c.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.StopTimeout)
+	defer cancel()
+	return c.Stop(stopCtx)
+}
+
+// logf reports through Logf when it is set
+func (c *Container) logf(format string, args ...any) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
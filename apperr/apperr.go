@@ -0,0 +1,144 @@
+// Package apperr defines coded errors that the repository and service layers
+// produce and transports map onto HTTP status and gRPC codes.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code classifies an error independently of transport
+type Code string
+
+const (
+	NotFound        Code = "NOT_FOUND"
+	Conflict        Code = "CONFLICT"
+	InvalidArgument Code = "INVALID_ARGUMENT"
+	Unavailable     Code = "UNAVAILABLE"
+	Unimplemented   Code = "UNIMPLEMENTED"
+	// ResourceExhausted marks an exceeded quota or rate limit
+	ResourceExhausted Code = "RESOURCE_EXHAUSTED"
+	// Unauthenticated marks missing or invalid credentials
+	Unauthenticated Code = "UNAUTHENTICATED"
+	// PermissionDenied marks valid credentials lacking a permission
+	PermissionDenied Code = "PERMISSION_DENIED"
+	Internal         Code = "INTERNAL"
+)
+
+// Error is an error carrying a Code
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+// New creates a coded error
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap attaches code to err, keeping err in the chain. It returns nil for
+// a nil err.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	switch {
+	case e.Message != "" && e.Err != nil:
+		return e.Message + ": " + e.Err.Error()
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return string(e.Code)
+	}
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode implements Coder
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
+
+// Coder is implemented by errors that know their own Code, e.g. service
+// validation errors
+type Coder interface {
+	ErrorCode() Code
+}
+
+// CodeOf returns the code of the first Coder in err's chain, Internal when
+// there is none and "" for a nil err
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var c Coder
+	if errors.As(err, &c) {
+		return c.ErrorCode()
+	}
+	return Internal
+}
+
+// HTTPStatus maps code to an HTTP status
+func HTTPStatus(code Code) int {
+	switch code {
+	case "":
+		return http.StatusOK
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case InvalidArgument:
+		return http.StatusBadRequest
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case Unimplemented:
+		return http.StatusNotImplemented
+	case ResourceExhausted:
+		return http.StatusTooManyRequests
+	case Unauthenticated:
+		return http.StatusUnauthorized
+	case PermissionDenied:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GRPCCode maps code to a gRPC status code
+func GRPCCode(code Code) codes.Code {
+	switch code {
+	case "":
+		return codes.OK
+	case NotFound:
+		return codes.NotFound
+	case Conflict:
+		return codes.AlreadyExists
+	case InvalidArgument:
+		return codes.InvalidArgument
+	case Unavailable:
+		return codes.Unavailable
+	case Unimplemented:
+		return codes.Unimplemented
+	case ResourceExhausted:
+		return codes.ResourceExhausted
+	case Unauthenticated:
+		return codes.Unauthenticated
+	case PermissionDenied:
+		return codes.PermissionDenied
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,127 @@
+// Package auth authenticates transport requests and carries the
+// authenticated principal through context.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"project/apperr"
+	"project/tenant"
+)
+
+// APIKeyHeader is the HTTP header carrying an API key; a bearer token in
+// Authorization is accepted as well
+const APIKeyHeader = "X-API-Key"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID int
+	KeyID  int
+	Scopes []string
+}
+
+// HasScope reports whether p was granted scope, or every scope with "*"
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, "*")
+}
+
+// ctxKey stores the principal in a context
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying p, with p's user recorded as
+// the tenant actor
+func NewContext(ctx context.Context, p Principal) context.Context {
+	ctx = tenant.WithActor(ctx, strconv.Itoa(p.UserID))
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the principal in ctx, if any
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// Authenticator verifies API keys
+type Authenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator
+type AuthenticatorFunc func(ctx context.Context, key string) (Principal, error)
+
+// AuthenticateAPIKey implements Authenticator
+func (f AuthenticatorFunc) AuthenticateAPIKey(ctx context.Context, key string) (Principal, error) {
+	return f(ctx, key)
+}
+
+// ErrMissingCredentials is returned when a request carries no API key
+var ErrMissingCredentials = apperr.New(apperr.Unauthenticated, "missing api key")
+
+// APIKeyMiddleware authenticates every request by its API key, storing the
+// principal in the request context. Requests without a valid key are
+// rejected with the status of the authentication error.
+func APIKeyMiddleware(authn Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := requestKey(r)
+			if key == "" {
+				writeError(w, ErrMissingCredentials)
+				return
+			}
+
+			p, err := authn.AuthenticateAPIKey(r.Context(), key)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+		})
+	}
+}
+
+// RequireScope rejects requests whose principal lacks scope
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := FromContext(r.Context())
+		if !ok {
+			writeError(w, ErrMissingCredentials)
+			return
+		}
+		if !p.HasScope(scope) {
+			writeError(w, apperr.New(apperr.PermissionDenied, "api key lacks scope "+scope))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestKey returns the API key of r, or "" if none
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// writeError responds with the status of err's code. Only the messages of
+// auth errors are shown; other failures are reported generically.
+func writeError(w http.ResponseWriter, err error) {
+	code := apperr.CodeOf(err)
+	status := apperr.HTTPStatus(code)
+	switch code {
+	case apperr.Unauthenticated:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		http.Error(w, err.Error(), status)
+	case apperr.PermissionDenied:
+		http.Error(w, err.Error(), status)
+	default:
+		http.Error(w, http.StatusText(status), status)
+	}
+}
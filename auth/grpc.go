@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"project/apperr"
+)
+
+// metadataKey returns the API key of an incoming gRPC call, or "" if none:
+// x-api-key metadata, or a bearer token in authorization
+func metadataKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if keys := md.Get(strings.ToLower(APIKeyHeader)); len(keys) > 0 && keys[0] != "" {
+		return keys[0]
+	}
+	for _, v := range md.Get("authorization") {
+		if scheme, token, ok := strings.Cut(v, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// authenticateCall authenticates a gRPC call as APIKeyMiddleware does an
+// HTTP request, then checks the scope scopes requires for method, if any
+func authenticateCall(ctx context.Context, authn Authenticator, scopes map[string]string, method string) (context.Context, error) {
+	key := metadataKey(ctx)
+	if key == "" {
+		return nil, grpcError(ErrMissingCredentials)
+	}
+	p, err := authn.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if scope, ok := scopes[method]; ok && !p.HasScope(scope) {
+		return nil, grpcError(apperr.New(apperr.PermissionDenied, "api key lacks scope "+scope))
+	}
+	return NewContext(ctx, p), nil
+}
+
+// grpcError converts err to a status with its code. Like writeError, only
+// the messages of auth errors are shown.
+func grpcError(err error) error {
+	code := apperr.CodeOf(err)
+	switch code {
+	case apperr.Unauthenticated, apperr.PermissionDenied:
+		return status.Error(apperr.GRPCCode(code), err.Error())
+	default:
+		return status.Error(apperr.GRPCCode(code), "authentication failed")
+	}
+}
+
+// UnaryServerInterceptor authenticates every unary call by its API key,
+// storing the principal in the call context. scopes maps full method
+// names, e.g. "/users.v1.Users/DeleteUser", to the scope they require.
+func UnaryServerInterceptor(authn Authenticator, scopes map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticateCall(ctx, authn, scopes, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls
+func StreamServerInterceptor(authn Authenticator, scopes map[string]string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticateCall(ss.Context(), authn, scopes, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream replaces the context of a server stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"project/models"
+	"project/repository"
+)
+
+// benchRepo is what `adapter bench` needs from an adapter
+type benchRepo interface {
+	repository.UserRepository
+	SendBatch(ctx context.Context, b *repository.Batch) ([]repository.BatchResult, error)
+	Naming() repository.NamingStrategy
+}
+
+// benchResult is one measured operation
+type benchResult struct {
+	Op    string
+	Ops   int
+	Total time.Duration
+}
+
+// runBench handles `adapter bench`, timing Create, batch insert, GetAll and
+// pagination against each named database. Rows are written under a
+// throwaway tenant and removed afterwards.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	rows := fs.Int("rows", 1000, "users inserted by each insert benchmark")
+	pageSize := fs.Int("page-size", 100, "page size of the pagination benchmark")
+	dbs := fs.String("db", primaryDatabase, "comma-separated databases to benchmark")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conns, err := newConnectionManager()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	for _, name := range strings.Split(*dbs, ",") {
+		db, err := conns.Get(context.Background(), name)
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %w", name, err)
+		}
+
+		driver := conns.Driver(name)
+		results, err := benchAdapter(db, driver, *rows, *pageSize)
+		if err != nil {
+			return fmt.Errorf("failed to benchmark %s: %w", name, err)
+		}
+
+		fmt.Printf("%s (%s), %d rows\n", name, driver, *rows)
+		for _, r := range results {
+			per := r.Total / time.Duration(max(r.Ops, 1))
+			fmt.Printf("  %-12s %8d ops %12s %12s/op %10.0f ops/s\n",
+				r.Op, r.Ops, r.Total.Round(time.Microsecond), per, float64(r.Ops)/r.Total.Seconds())
+		}
+	}
+	return nil
+}
+
+// benchAdapter runs every benchmark on one database
+func benchAdapter(db *sql.DB, driver string, rows, pageSize int) ([]benchResult, error) {
+	ctx := context.Background()
+	tenantID := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+
+	var (
+		repo benchRepo
+		bind = "$1"
+	)
+	switch driver {
+	case "mysql":
+		repo, bind = repository.NewMySQLRepo(db), "?"
+	default:
+		pg, err := repository.NewPostgresRepo(db)
+		if err != nil {
+			return nil, err
+		}
+		repo = pg
+	}
+	users := repo.Naming().TableName(models.User{})
+	defer db.ExecContext(ctx, "DELETE FROM "+users+" WHERE tenant_id = "+bind, tenantID)
+
+	from := time.Now().Add(-time.Second)
+	var results []benchResult
+
+	timed := func(op string, ops int, fn func() error) error {
+		start := time.Now()
+		if err := fn(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		results = append(results, benchResult{Op: op, Ops: ops, Total: time.Since(start)})
+		return nil
+	}
+
+	err := timed("Create", rows, func() error {
+		for i := 0; i < rows; i++ {
+			if _, err := repo.Create(models.User{Name: fmt.Sprintf("bench %d", i), TenantID: tenantID}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	insert := "INSERT INTO " + users + " (name, tenant_id) VALUES ($1, $2)"
+	if driver == "mysql" {
+		insert = "INSERT INTO " + users + " (name, tenant_id) VALUES (?, ?)"
+	}
+	err = timed("BatchInsert", rows, func() error {
+		b := &repository.Batch{}
+		for i := 0; i < rows; i++ {
+			b.Queue(insert, fmt.Sprintf("bench batch %d", i), tenantID)
+		}
+		_, err := repo.SendBatch(ctx, b)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = timed("GetAll", 1, func() error {
+		_, err := repo.GetAll()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pages := 0
+	err = timed("Paginate", 0, func() error {
+		to := time.Now().Add(time.Second)
+		for offset := 0; ; offset += pageSize {
+			page, err := repo.ListCreatedBetween(from, to, repository.ListOptions{Limit: pageSize, Offset: offset})
+			if err != nil {
+				return err
+			}
+			pages++
+			if len(page) < pageSize {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	results[len(results)-1].Ops = pages
+
+	return results, nil
+}
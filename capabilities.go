@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"project/repository"
+)
+
+// runCapabilities handles `adapter capabilities [-database name]`,
+// printing which features each configured database and its adapter
+// support
+func runCapabilities(args []string) error {
+	fs := flag.NewFlagSet("capabilities", flag.ContinueOnError)
+	only := fs.String("database", "", "database to check; every configured database when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conns, err := newConnectionManager()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	names := conns.Names()
+	if *only != "" {
+		names = []string{*only}
+	}
+
+	ctx := context.Background()
+	unreachable := 0
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		driver := conns.Driver(name)
+		fmt.Printf("%s (%s)\n", name, driver)
+
+		db, err := conns.Get(ctx, name)
+		if err != nil {
+			unreachable++
+			fmt.Printf("  unreachable: %v\n", err)
+			continue
+		}
+		dialect := repository.PostgresDialect
+		if driver == "mysql" {
+			dialect = repository.MySQLDialect
+		}
+
+		fmt.Printf("  %-18s %-8s %-8s %s\n", "FEATURE", "ADAPTER", "SERVER", "DETAIL")
+		for _, f := range repository.ProbeFeatures(ctx, db, dialect) {
+			detail := f.Detail
+			if f.Err != nil {
+				detail = f.Err.Error()
+			}
+			fmt.Printf("  %-18s %-8s %-8s %s\n", f.Name, yesNo(f.Adapter), yesNo(f.Server), detail)
+		}
+	}
+
+	if unreachable > 0 {
+		return fmt.Errorf("%d database(s) unreachable", unreachable)
+	}
+	return nil
+}
+
+// yesNo renders a capability flag
+func yesNo(ok bool) string {
+	if ok {
+		return "yes"
+	}
+	return "no"
+}
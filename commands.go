@@ -0,0 +1,692 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"project/config"
+	"project/events"
+	"project/importer"
+	"project/migrations"
+	"project/models"
+	"project/projections"
+	"project/repository"
+	"project/service"
+	"project/snapshot"
+	"project/tenant"
+)
+
+// runCommand dispatches a CLI subcommand
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	case "schema":
+		return runSchema(args[1:])
+	case "archive":
+		return runArchive(args[1:])
+	case "retention":
+		return runRetention(args[1:])
+	case "projections":
+		return runProjections(args[1:])
+	case "rebuild-readmodel":
+		return runRebuildReadModel(args[1:])
+	case "doctor":
+		return runDoctor()
+	case "bench":
+		return runBench(args[1:])
+	case "loadtest":
+		return runLoadTest(args[1:])
+	case "verify":
+		return runVerify(args[1:])
+	case "export-raw":
+		return runExportRaw(args[1:])
+	case "advise-indexes":
+		return runAdviseIndexes(args[1:])
+	case "import":
+		return runImport(args[1:])
+	case "backup":
+		return runBackup(args[1:])
+	case "restore":
+		return runRestore(args[1:])
+	case "dead-letters":
+		return runDeadLetters(args[1:])
+	case "capabilities":
+		return runCapabilities(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// openDatabase connects to the primary database. Closing the returned
+// manager closes the handle.
+func openDatabase() (*config.ConnectionManager, *sql.DB, error) {
+	conns, err := newConnectionManager()
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := conns.Get(context.Background(), primaryDatabase)
+	if err != nil {
+		conns.Close()
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return conns, db, nil
+}
+
+// runMigrate handles `adapter migrate <subcommand>`
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: adapter migrate plan|up|squash")
+	}
+
+	switch args[0] {
+	case "plan":
+		return runMigratePlan()
+	case "up":
+		return runMigrateUp()
+	case "squash":
+		return runMigrateSquash(args[1:])
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// runMigratePlan prints the DDL that migrations would run, without executing it
+func runMigratePlan() error {
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	migrator := repository.NewMigrator(db).WithCollation(os.Getenv(nameCollationEnv))
+
+	stmts, err := migrator.Plan(models.All()...)
+	if err != nil {
+		return fmt.Errorf("failed to plan migration: %w", err)
+	}
+
+	fmt.Println("-- AutoMigrate")
+	for _, stmt := range stmts {
+		fmt.Println(stmt)
+	}
+
+	pending, err := migrator.PendingMigrations(migrations.FS)
+	if err != nil {
+		return fmt.Errorf("failed to plan migration: %w", err)
+	}
+
+	for _, mig := range pending {
+		fmt.Printf("\n-- %d_%s\n%s\n", mig.Version, mig.Name, strings.TrimSpace(mig.SQL))
+	}
+
+	// listed, not run, so they need no repository
+	data, err := migrator.PendingDataMigrations(context.Background(), migrations.Data(nil))
+	if err != nil {
+		return fmt.Errorf("failed to plan migration: %w", err)
+	}
+	for _, mig := range data {
+		fmt.Printf("\n-- data %d_%s (after schema %d)\n", mig.Version, mig.Name, mig.Schema)
+	}
+	return nil
+}
+
+// interruptContext returns a context cancelled on SIGINT or SIGTERM, so
+// long-running commands stop between batches instead of being killed
+// mid-batch
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// runMigrateUp applies pending versioned migrations
+func runMigrateUp() error {
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	// an interrupt rolls back the migration running and stops there
+	ctx, stop := interruptContext()
+	defer stop()
+
+	migrator := repository.NewMigrator(db)
+	if err := migrator.MigrateContext(ctx, migrations.FS); err != nil {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+
+	repo, err := repository.NewPostgresRepo(db)
+	if err != nil {
+		return err
+	}
+	n, err := migrator.MigrateData(ctx, migrations.Data(repo))
+	if err != nil {
+		return fmt.Errorf("failed to migrate data: %w", err)
+	}
+
+	fmt.Printf("Migrations applied, %d data migrations\n", n)
+	return nil
+}
+
+// runMigrateSquash handles `adapter migrate squash`, collapsing the applied
+// migrations in the source directory into a baseline. The binary embeds the
+// migrations, so rebuild it afterwards.
+func runMigrateSquash(args []string) error {
+	fs := flag.NewFlagSet("migrate squash", flag.ContinueOnError)
+	dir := fs.String("dir", "migrations", "migrations source directory")
+	through := fs.Int64("through", 0, "last version to squash (default: the latest applied)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	result, err := repository.NewMigrator(db).Squash(context.Background(), *dir, *through)
+	if err != nil {
+		return fmt.Errorf("failed to squash migrations: %w", err)
+	}
+
+	for _, f := range result.Removed {
+		fmt.Printf("Removed %s\n", f)
+	}
+	fmt.Printf("Baseline %s, %d rows pruned from schema_migrations\n", result.Baseline, result.Pruned)
+	return nil
+}
+
+// runSchema handles `adapter schema <subcommand>`
+func runSchema(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: adapter schema inspect|diff")
+	}
+
+	switch args[0] {
+	case "inspect":
+		return runSchemaInspect()
+	case "diff":
+		return runSchemaDiff()
+	default:
+		return fmt.Errorf("unknown schema command %q", args[0])
+	}
+}
+
+// runSchemaInspect prints the tables, columns, indexes and constraints of the
+// connected database
+func runSchemaInspect() error {
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	schema, err := repository.NewMigrator(db).WithDriver(conns.Driver(primaryDatabase)).InspectSchema(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+
+	for _, t := range schema.Tables {
+		fmt.Println(t.Name)
+		for _, c := range t.Columns {
+			null := "NOT NULL"
+			if c.Nullable {
+				null = "NULL"
+			}
+			fmt.Printf("  column     %s %s %s\n", c.Name, c.DataType, null)
+		}
+		for _, i := range t.Indexes {
+			kind := "index"
+			if i.Unique {
+				kind = "unique"
+			}
+			fmt.Printf("  %-10s %s (%s)\n", kind, i.Name, strings.Join(i.Columns, ", "))
+		}
+		for _, c := range t.Constraints {
+			fmt.Printf("  constraint %s %s (%s)", c.Name, c.Type, strings.Join(c.Columns, ", "))
+			if c.RefTable != "" {
+				fmt.Printf(" -> %s (%s)", c.RefTable, strings.Join(c.RefColumns, ", "))
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// runSchemaDiff reports drift between the models, migration history and the
+// live schema, failing when any is found
+func runSchemaDiff() error {
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	drift, err := repository.NewMigrator(db).DetectDrift(context.Background(), migrations.FS, models.All()...)
+	if err != nil {
+		return fmt.Errorf("failed to detect schema drift: %w", err)
+	}
+
+	if len(drift) == 0 {
+		fmt.Println("No schema drift detected")
+		return nil
+	}
+
+	for _, d := range drift {
+		fmt.Println(d)
+	}
+	return fmt.Errorf("schema drift detected: %d difference(s)", len(drift))
+}
+
+// runArchive handles `adapter archive`, moving users soft-deleted longer
+// than -older-than into users_archive
+func runArchive(args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 90*24*time.Hour, "archive users soft-deleted longer ago than this")
+	batchSize := fs.Int("batch-size", 500, "users moved per transaction")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	sink, err := repository.NewTableArchiveSink(db, repository.NamingStrategy{})
+	if err != nil {
+		return err
+	}
+
+	archiver := repository.NewArchiver(db, sink)
+	archiver.BatchSize = *batchSize
+
+	ctx, stop := interruptContext()
+	defer stop()
+	stats, err := archiver.Run(ctx, repository.SoftDeletedBefore(time.Now(), *olderThan))
+	fmt.Printf("Archived %d users in %d batches (%s)\n", stats.Archived, stats.Batches, stats.Duration)
+	if err != nil {
+		return fmt.Errorf("failed to archive users: %w", err)
+	}
+	return nil
+}
+
+// runExportRaw handles `adapter export-raw -table users`, streaming a
+// PostgreSQL table with COPY to -out or stdout
+func runExportRaw(args []string) error {
+	fs := flag.NewFlagSet("export-raw", flag.ContinueOnError)
+	table := fs.String("table", "users", "table to export")
+	columns := fs.String("columns", "", "comma-separated columns (default: all)")
+	format := fs.String("format", string(repository.CopyCSV), "csv or binary")
+	out := fs.String("out", "", "file to write (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conns, err := newConnectionManager()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+	if driver := conns.Driver(primaryDatabase); driver != "postgres" {
+		return fmt.Errorf("export-raw requires postgres, primary database is %s", driver)
+	}
+	connString, err := conns.ConnString(primaryDatabase)
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var cols []string
+	if *columns != "" {
+		cols = strings.Split(*columns, ",")
+	}
+	n, err := repository.NewCopyExporter(connString).ExportRaw(context.Background(), w, *table, repository.CopyFormat(*format), cols...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d rows from %s\n", n, *table)
+	return nil
+}
+
+// runAdviseIndexes handles `adapter advise-indexes`, suggesting indexes for
+// the slow queries in pg_stat_statements and, with -write, saving them as a
+// migration for review
+func runAdviseIndexes(args []string) error {
+	fs := flag.NewFlagSet("advise-indexes", flag.ContinueOnError)
+	minMean := fs.Duration("min-mean", 100*time.Millisecond, "mean execution time above which a query is slow")
+	limit := fs.Int("limit", 50, "slow queries analyzed, by total execution time")
+	write := fs.String("write", "", "migrations directory to write the suggestions to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	advisor := repository.NewIndexAdvisor(db)
+	advisor.MinMeanTime = *minMean
+	advisor.Limit = *limit
+
+	suggestions, err := advisor.Advise(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to advise indexes: %w", err)
+	}
+	if len(suggestions) == 0 {
+		fmt.Println("No missing indexes found")
+		return nil
+	}
+	for _, s := range suggestions {
+		fmt.Printf("%s -- %d calls across %d queries\n", s.Statement(), s.Calls, len(s.Queries))
+	}
+
+	if *write != "" {
+		path, err := repository.WriteIndexMigration(*write, suggestions)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return nil
+}
+
+// runImport handles `adapter import <file>`, registering the users in a
+// CSV or JSONL file in checkpointed batches. An interrupted import resumes
+// from its last batch when run again with the same -job.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "csv or jsonl (default: from the file extension)")
+	job := fs.String("job", "", "checkpoint name (default: the file path)")
+	batch := fs.Int("batch", 1000, "rows written between checkpoints")
+	tenantID := fs.String("tenant", "", "tenant the users are registered under")
+	restart := fs.Bool("restart", false, "discard the checkpoint and start from the top")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: adapter import [flags] <file>")
+	}
+	path := fs.Arg(0)
+	if *job == "" {
+		*job = path
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer f.Close()
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	repo, err := repository.NewPostgresRepo(db)
+	if err != nil {
+		return err
+	}
+	users := service.NewUserService(repository.Decorate(repo, repository.ClassifyErrors))
+
+	im := importer.New(importer.NewPostgresStore(db), importer.ServiceSink(users))
+	im.BatchSize = *batch
+	im.Logf = log.Printf
+
+	// an interrupt stops between batches; the next run resumes from the
+	// checkpoint
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = tenant.NewContext(ctx, *tenantID)
+	if *restart {
+		if err := im.Reset(ctx, *job); err != nil {
+			return err
+		}
+	}
+
+	var cp importer.Checkpoint
+	switch *format {
+	case "csv":
+		cp, err = im.ImportCSV(ctx, *job, f)
+	case "jsonl", "ndjson":
+		cp, err = im.ImportJSONL(ctx, *job, f)
+	default:
+		return fmt.Errorf("unknown import format %q", *format)
+	}
+	if err != nil {
+		return fmt.Errorf("import stopped after batch %d, run it again to resume: %w", cp.Batch, err)
+	}
+	fmt.Printf("Imported %s: %d created, %d duplicates skipped, %d invalid\n", path, cp.Created, cp.Duplicates, cp.Invalid)
+	return nil
+}
+
+// runBackup handles `adapter backup`, writing every data table of the
+// primary database to a compressed, checksummed snapshot
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("out", "", "snapshot file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("usage: adapter backup -out <file>")
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+	connString, err := conns.ConnString(primaryDatabase)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	schema, err := repository.NewMigrator(db).WithDriver(conns.Driver(primaryDatabase)).InspectSchema(ctx)
+	if err != nil {
+		return err
+	}
+	tables := repository.BackupTables(schema)
+
+	// write beside the target and rename, so a failed backup never
+	// replaces a good one
+	tmp := *out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp)
+	err = repository.NewCopyExporter(connString).Backup(ctx, f, tables, map[string]string{"database": primaryDatabase})
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to write snapshot file: %w", cerr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, *out); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	fmt.Printf("Backed up %d tables to %s\n", len(tables), *out)
+	return nil
+}
+
+// runRestore handles `adapter restore <file>`, loading a snapshot into the
+// migrated, empty primary database; with -verify it only checks the
+// snapshot
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	verify := fs.Bool("verify", false, "check the snapshot without restoring it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: adapter restore [-verify] <file>")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	snap, err := snapshot.Open(f, info.Size())
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	manifest := snap.Manifest()
+	if *verify {
+		if err := snap.Verify(); err != nil {
+			return err
+		}
+		for _, t := range manifest.Tables {
+			fmt.Printf("%s: %d rows, %d bytes in %d chunks\n", t.Name, t.Rows, t.Size, len(t.Chunks))
+		}
+		fmt.Printf("Snapshot of %s is intact\n", manifest.CreatedAt.Format(time.RFC3339))
+		return nil
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+	connString, err := conns.ConnString(primaryDatabase)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	schema, err := repository.NewMigrator(db).WithDriver(conns.Driver(primaryDatabase)).InspectSchema(ctx)
+	if err != nil {
+		return err
+	}
+	if err := repository.NewCopyExporter(connString).Restore(ctx, snap, schema); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d tables from %s\n", len(manifest.Tables), fs.Arg(0))
+	return nil
+}
+
+// runRetention handles `adapter retention`, deleting rows that outlived
+// their retention rules
+func runRetention(args []string) error {
+	fs := flag.NewFlagSet("retention", flag.ContinueOnError)
+	rulesPath := fs.String("rules", "", "JSON file of retention rules (default: built-in rules)")
+	dryRun := fs.Bool("dry-run", false, "report matching rows without deleting them")
+	batchSize := fs.Int("batch-size", 500, "rows deleted per transaction")
+	rate := fs.Int("rate", 1000, "maximum rows deleted per second, 0 for unlimited")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rules := repository.DefaultRetentionRules()
+	if *rulesPath != "" {
+		var err error
+		if rules, err = repository.LoadRetentionRules(*rulesPath); err != nil {
+			return err
+		}
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	engine := repository.NewRetentionEngine(db, rules...)
+	engine.BatchSize = *batchSize
+	engine.RowsPerSecond = *rate
+	engine.DryRun = *dryRun
+
+	ctx, stop := interruptContext()
+	defer stop()
+	reports, err := engine.Run(ctx)
+	for _, r := range reports {
+		if r.DryRun {
+			fmt.Printf("%s: %d rows would be deleted\n", r.Rule, r.Matched)
+			continue
+		}
+		fmt.Printf("%s: deleted %d of %d rows in %d batches (%s)\n", r.Rule, r.Deleted, r.Matched, r.Batches, r.Duration)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply retention rules: %w", err)
+	}
+	return nil
+}
+
+// runProjections handles `adapter projections rebuild [name]`, rebuilding
+// one read model, or all of them, from the source tables
+func runProjections(args []string) error {
+	if len(args) == 0 || args[0] != "rebuild" || len(args) > 2 {
+		return fmt.Errorf("usage: adapter projections rebuild [name]")
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	builder := newProjections(db, events.NewBus())
+	if len(args) == 2 {
+		return builder.Rebuild(context.Background(), args[1])
+	}
+	return builder.RebuildAll(context.Background())
+}
+
+// runRebuildReadModel handles `adapter rebuild-readmodel`, regenerating
+// users_search from the users table, e.g. after a migration changes its
+// columns
+func runRebuildReadModel(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: adapter rebuild-readmodel")
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	return newProjections(db, events.NewBus()).Rebuild(context.Background(), "users_search")
+}
+
+// newProjections registers the application's read models on bus
+func newProjections(db *sql.DB, bus *events.Bus) *projections.Builder {
+	return projections.NewBuilder(bus).
+		Register(projections.NewPostgresUserSummaries(db)).
+		Register(projections.NewPostgresUsersSearch(db))
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
+
+	"project/api"
+	"project/app"
+	"project/auth"
+	"project/config"
+	"project/degrade"
+	"project/events"
+	"project/flags"
+	"project/leader"
+	"project/middleware"
+	"project/migrations"
+	"project/models"
+	"project/notifications"
+	"project/presence"
+	"project/repository"
+	"project/saga"
+	"project/serializer"
+	"project/service"
+	"project/startup"
+)
+
+// newContainer wires the application's components. They are built on
+// first use and started in dependency order by app.Container.Start.
+func newContainer(logger *slog.Logger) *app.Container {
+	c := app.New()
+	c.Logf = log.Printf
+
+	// Database connections; handles open lazily
+	app.Provide(c, "connections", func(ctx context.Context, lc *app.Lifecycle) (*config.ConnectionManager, error) {
+		conns, err := newConnectionManager()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+		lc.OnStop(func(context.Context) error { return conns.Close() })
+
+		// Reload the config file so rotated credentials apply without a restart
+		if path := os.Getenv(configPathEnv); path != "" {
+			lc.Go(func(ctx context.Context) { config.WatchFile(ctx, path, 10*time.Second, conns) })
+		}
+		dashboardMetrics.Register(conns)
+		return conns, nil
+	})
+
+	// Optional dependencies degrade under their policies instead of
+	// failing requests
+	app.Provide(c, "dependencies", func(ctx context.Context, lc *app.Lifecycle) (*degrade.Monitor, error) {
+		policies, err := degrade.ParsePolicies(os.Getenv(degradeEnv))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", degradeEnv, err)
+		}
+		monitor := degrade.NewMonitor(policies)
+		monitor.Logf = log.Printf
+		dashboardMetrics.Register(monitor)
+		return monitor, nil
+	})
+
+	// Serve the dashboard metrics, pool gauges included, and the health of
+	// the optional dependencies
+	if addr := os.Getenv(metricsAddrEnv); addr != "" {
+		app.Provide(c, "metrics server", func(ctx context.Context, lc *app.Lifecycle) (*http.Server, error) {
+			// the pool gauges are registered with the connections
+			if _, err := app.Get[*config.ConnectionManager](ctx, c, "connections"); err != nil {
+				return nil, err
+			}
+			monitor, err := app.Get[*degrade.Monitor](ctx, c, "dependencies")
+			if err != nil {
+				return nil, err
+			}
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", dashboardMetrics.Handler())
+			mux.Handle("/healthz", monitor.Handler())
+			srv := &http.Server{Addr: addr, Handler: mux}
+			serve(lc, srv)
+			return srv, nil
+		})
+	}
+
+	// The primary database, once it passes its health check
+	app.Provide(c, "database", func(ctx context.Context, lc *app.Lifecycle) (*sql.DB, error) {
+		conns, err := app.Get[*config.ConnectionManager](ctx, c, "connections")
+		if err != nil {
+			return nil, err
+		}
+
+		// Wait for the dependencies before serving anything
+		if timeout := os.Getenv(startupTimeoutEnv); timeout != "" {
+			deadline, err := time.ParseDuration(timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", startupTimeoutEnv, err)
+			}
+			gate := startup.NewGate().Require("database "+primaryDatabase, func(ctx context.Context) error {
+				_, err := conns.Get(ctx, primaryDatabase)
+				return err
+			})
+			if addr := os.Getenv(smtpAddrEnv); addr != "" {
+				gate.Require("smtp", startup.Dial(addr))
+			}
+			gate.Deadline = deadline
+			gate.Logf = log.Printf
+			if err := gate.Wait(ctx); err != nil {
+				return nil, fmt.Errorf("startup aborted: %w", err)
+			}
+		}
+
+		db, err := conns.Get(ctx, primaryDatabase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		return db, nil
+	})
+
+	// Creates and deletes keep a sharded user total, reconciled hourly
+	app.Provide(c, "user counter", func(ctx context.Context, lc *app.Lifecycle) (*repository.UserCounter, error) {
+		db, err := app.Get[*sql.DB](ctx, c, "database")
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewUserCounter(db, repository.PostgresDialect), nil
+	})
+
+	app.Provide(c, "repository", func(ctx context.Context, lc *app.Lifecycle) (*repository.PostgresRepo, error) {
+		conns, err := app.Get[*config.ConnectionManager](ctx, c, "connections")
+		if err != nil {
+			return nil, err
+		}
+		db, err := app.Get[*sql.DB](ctx, c, "database")
+		if err != nil {
+			return nil, err
+		}
+		userCounter, err := app.Get[*repository.UserCounter](ctx, c, "user counter")
+		if err != nil {
+			return nil, err
+		}
+
+		repo, err := repository.NewPostgresRepoMigrated(db,
+			repository.NewMigrator(db).WithCollation(os.Getenv(nameCollationEnv)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+
+		// Unpaged reads fail instead of loading an unbounded table
+		repo.SetMaxRows(10000)
+		repo.SetUserCounter(userCounter)
+
+		// Batches are pipelined when asked for
+		if on, _ := strconv.ParseBool(os.Getenv(batchPipelineEnv)); on {
+			connString, err := conns.ConnString(primaryDatabase)
+			if err != nil {
+				return nil, err
+			}
+			pool, err := pgxpool.New(ctx, connString)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create batch pipeline: %w", err)
+			}
+			lc.OnStop(func(context.Context) error { pool.Close(); return nil })
+			repo.WithPipeline(pool)
+		}
+
+		// Reads go to the replica unless it lags too far behind
+		if conns.Driver(replicaDatabase) != "" {
+			var opts repository.ReplicaOptions
+			if lag := os.Getenv(maxReplicaLagEnv); lag != "" {
+				if opts.MaxLag, err = time.ParseDuration(lag); err != nil {
+					return nil, fmt.Errorf("invalid %s: %w", maxReplicaLagEnv, err)
+				}
+			}
+			replica, err := conns.Get(ctx, replicaDatabase)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to replica: %w", err)
+			}
+			repo.SetReplica(replica, opts)
+		}
+
+		// Report schema drift at startup
+		drift, err := repository.NewMigrator(db).DetectDrift(ctx, migrations.FS, models.All()...)
+		if err != nil {
+			log.Printf("Failed to check schema drift: %v", err)
+		}
+		for _, d := range drift {
+			log.Printf("Schema drift: %s", d)
+		}
+
+		// Uncomment to use MySQL instead (register it in newConnectionManager):
+		// mysqlDB, err := conns.Get(ctx, "mysql")
+		// if err != nil {
+		// 	return nil, fmt.Errorf("failed to connect to MySQL: %w", err)
+		// }
+		// repo = repository.NewMySQLRepo(mysqlDB)
+
+		// Or uncomment to keep users as event streams instead of rows:
+		// repo = repository.NewEventSourcedRepo(repository.NewPostgresEventStore(db)).WithSnapshots(100)
+		return repo, nil
+	})
+
+	// Welcome emails are queued in the outbox with the user
+	app.Provide(c, "outbox", func(ctx context.Context, lc *app.Lifecycle) (*repository.Outbox, error) {
+		db, err := app.Get[*sql.DB](ctx, c, "database")
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewPostgresOutbox(db), nil
+	})
+
+	// Serve the query statistics and the outbox dead letters to operators
+	if addr := os.Getenv(adminAddrEnv); addr != "" {
+		app.Provide(c, "admin server", func(ctx context.Context, lc *app.Lifecycle) (*http.Server, error) {
+			outbox, err := app.Get[*repository.Outbox](ctx, c, "outbox")
+			if err != nil {
+				return nil, err
+			}
+			srv := &http.Server{Addr: addr, Handler: api.AdminHandler(queryStats, repository.NewDeadLetters(outbox))}
+			serve(lc, srv)
+			return srv, nil
+		})
+	}
+
+	// Logins mark users online; sightings are written in batches to the
+	// online set and user_last_seen. Use presence.NewRedisStore to share the
+	// set across instances.
+	app.Provide(c, "presence", func(ctx context.Context, lc *app.Lifecycle) (*presence.Tracker, error) {
+		repo, err := app.Get[*repository.PostgresRepo](ctx, c, "repository")
+		if err != nil {
+			return nil, err
+		}
+		tracker := presence.NewTracker(presence.NewMemoryStore(), repo)
+		tracker.Logf = log.Printf
+		lc.Go(tracker.Run)
+		return tracker, nil
+	})
+
+	app.Provide(c, "user service", func(ctx context.Context, lc *app.Lifecycle) (*service.UserService, error) {
+		conns, err := app.Get[*config.ConnectionManager](ctx, c, "connections")
+		if err != nil {
+			return nil, err
+		}
+		db, err := app.Get[*sql.DB](ctx, c, "database")
+		if err != nil {
+			return nil, err
+		}
+		repo, err := app.Get[*repository.PostgresRepo](ctx, c, "repository")
+		if err != nil {
+			return nil, err
+		}
+		outbox, err := app.Get[*repository.Outbox](ctx, c, "outbox")
+		if err != nil {
+			return nil, err
+		}
+		tracker, err := app.Get[*presence.Tracker](ctx, c, "presence")
+		if err != nil {
+			return nil, err
+		}
+		monitor, err := app.Get[*degrade.Monitor](ctx, c, "dependencies")
+		if err != nil {
+			return nil, err
+		}
+
+		// Trace and meter repository calls through the global OTel providers
+		otelMiddleware, err := repository.NewOTelMiddleware(nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up instrumentation: %w", err)
+		}
+
+		// A panicking call fails with an error instead of taking the process down
+		recoverPanics := repository.RecoverWith(func(ctx context.Context, p *repository.PanicError) {
+			logger.ErrorContext(ctx, "Repository call panicked", "method", p.Method, "panic", p.Value, "stack", string(p.Stack))
+		})
+
+		userService := service.NewUserService(repository.Decorate(repo,
+			repository.Label(conns.Labels(primaryDatabase)), otelMiddleware,
+			repository.NewMetricsMiddleware(dashboardMetrics.CallDuration, dashboardMetrics.CallErrors), repository.ClassifyErrors, repository.TagRequestID, recoverPanics))
+
+		// Feature flags: environment overrides the database, defaults last
+		userService.WithFlags(flags.New(
+			map[string]bool{flags.FullTextSearch: true},
+			flags.NewEnvProvider(),
+			flags.NewPostgresProvider(db),
+		))
+
+		// Domain events keep the read models in step with writes
+		bus := events.NewBus()
+		newProjections(db, bus)
+		dashboardMetrics.Register(bus)
+		lc.OnStop(bus.Close)
+		userService.WithEvents(bus)
+		userService.WithOutbox(outbox)
+
+		// Domain events are relayed through the outbox when a format is set
+		if format := os.Getenv(eventFormatEnv); format != "" {
+			var registry serializer.Registry
+			if url := os.Getenv(schemaRegistryEnv); url != "" {
+				registry = serializer.MonitoredRegistry{Registry: serializer.NewRegistryClient(url), Monitor: monitor}
+			}
+			s, err := serializer.New(format, registry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", eventFormatEnv, err)
+			}
+			opts := events.BufferOptions{Name: "outbox_relay", BlockTimeout: time.Second}
+			if size := os.Getenv(eventBufferEnv); size != "" {
+				if opts.Size, err = strconv.Atoi(size); err != nil {
+					return nil, fmt.Errorf("invalid %s: %w", eventBufferEnv, err)
+				}
+			}
+			if overflow := os.Getenv(eventOverflowEnv); overflow != "" {
+				if opts.Overflow, err = events.ParseOverflow(overflow); err != nil {
+					return nil, fmt.Errorf("invalid %s: %w", eventOverflowEnv, err)
+				}
+			}
+			// the relay writes to the database, so it runs behind a bounded
+			// buffer rather than in the request
+			bus.SubscribeBuffered("", serializer.OutboxRelay(outbox, s), opts)
+		}
+
+		userService.WithPresence(tracker)
+
+		// Passwords must be long enough, and unbreached when checking is on
+		if on, _ := strconv.ParseBool(os.Getenv(breachCheckEnv)); on {
+			userService.WithPasswordPolicy(service.PasswordPolicies{
+				service.DefaultStrengthPolicy,
+				service.BreachCheck{Client: &http.Client{Timeout: 5 * time.Second}, Monitor: monitor},
+			})
+		}
+		return userService, nil
+	})
+
+	// Serve the users API over HTTP and gRPC behind the standard middleware
+	if os.Getenv(httpAddrEnv) != "" || os.Getenv(grpcAddrEnv) != "" {
+		app.Provide(c, "api servers", func(ctx context.Context, lc *app.Lifecycle) (*grpc.Server, error) {
+			userService, err := app.Get[*service.UserService](ctx, c, "user service")
+			if err != nil {
+				return nil, err
+			}
+			apiMetrics, err := middleware.NewMetrics(nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to set up api metrics: %w", err)
+			}
+			// API keys are required when asked for; the principal's user
+			// is recorded on the calls it makes
+			var authn auth.Authenticator
+			if on, _ := strconv.ParseBool(os.Getenv(apiAuthEnv)); on {
+				authn = apiKeyAuthenticator(userService)
+			}
+
+			if addr := os.Getenv(httpAddrEnv); addr != "" {
+				var cors middleware.CORSConfig
+				if origins := os.Getenv(corsOriginsEnv); origins != "" {
+					cors.AllowedOrigins = strings.Split(origins, ",")
+				}
+				handler := api.Handler(userService)
+				if authn != nil {
+					handler = auth.APIKeyMiddleware(authn)(handler)
+				}
+				handler = middleware.Chain(handler, middleware.Stack(logger, apiMetrics, cors)...)
+				serve(lc, &http.Server{Addr: addr, Handler: handler})
+			}
+
+			srv := grpc.NewServer(middleware.GRPCServerOptions(logger, apiMetrics, authn, nil)...)
+			api.RegisterGRPC(srv, userService)
+			if addr := os.Getenv(grpcAddrEnv); addr != "" {
+				serveGRPC(lc, addr, srv)
+			}
+			return srv, nil
+		})
+	}
+
+	// Multi-step workflows resume where a crash left them
+	app.Provide(c, "sagas", func(ctx context.Context, lc *app.Lifecycle) (*saga.Coordinator, error) {
+		db, err := app.Get[*sql.DB](ctx, c, "database")
+		if err != nil {
+			return nil, err
+		}
+		userService, err := app.Get[*service.UserService](ctx, c, "user service")
+		if err != nil {
+			return nil, err
+		}
+		sagas := saga.NewCoordinator(saga.NewPostgresStore(db)).Register(userService.OnboardOrg())
+		lc.OnStart(func(ctx context.Context) error {
+			if err := sagas.ResumePending(ctx); err != nil {
+				log.Printf("Failed to resume sagas: %v", err)
+			}
+			return nil
+		})
+		return sagas, nil
+	})
+
+	// Background jobs run on the elected leader only and move to another
+	// instance when it dies
+	app.Provide(c, "background jobs", func(ctx context.Context, lc *app.Lifecycle) (*leader.Elector, error) {
+		db, err := app.Get[*sql.DB](ctx, c, "database")
+		if err != nil {
+			return nil, err
+		}
+		repo, err := app.Get[*repository.PostgresRepo](ctx, c, "repository")
+		if err != nil {
+			return nil, err
+		}
+		userCounter, err := app.Get[*repository.UserCounter](ctx, c, "user counter")
+		if err != nil {
+			return nil, err
+		}
+		outbox, err := app.Get[*repository.Outbox](ctx, c, "outbox")
+		if err != nil {
+			return nil, err
+		}
+
+		elector, err := leader.NewElector(leader.NewPostgresBackend(db), "background_jobs")
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up leader election: %w", err)
+		}
+		jobs := []func(ctx context.Context){
+			// reporting reads materialized views
+			func(ctx context.Context) {
+				repository.NewViewRefresher(db, repository.DefaultMaterializedViews()...).Run(ctx, log.Printf)
+			},
+			func(ctx context.Context) {
+				repository.NewRetentionEngine(db, repository.DefaultRetentionRules()...).Schedule(ctx, time.Hour, log.Printf)
+			},
+			func(ctx context.Context) {
+				userCounter.Schedule(ctx, time.Hour, log.Printf)
+			},
+			func(ctx context.Context) {
+				sink, err := repository.NewTableArchiveSink(db, repository.NamingStrategy{})
+				if err != nil {
+					log.Printf("Failed to set up archiving: %v", err)
+					return
+				}
+				repository.NewArchiver(db, sink).Schedule(ctx, 24*time.Hour, 90*24*time.Hour, log.Printf)
+			},
+		}
+		// welcome emails are queued with the user and relayed from the outbox
+		if addr := os.Getenv(smtpAddrEnv); addr != "" {
+			mailer := notifications.NewSMTPMailer(addr, "noreply@localhost", "", "")
+			dispatcher := notifications.NewDispatcher(notifications.DefaultTemplates(), repo,
+				notifications.NewEmailProvider(mailer))
+			worker := notifications.NewWorker(outbox.ForTopics(notifications.WelcomeTopic, notifications.NotifyTopic), dispatcher)
+			jobs = append(jobs, func(ctx context.Context) { worker.Run(ctx) })
+		}
+		lc.Go(func(ctx context.Context) { elector.Run(ctx, jobs...) })
+		return elector, nil
+	})
+
+	return c
+}
+
+// serve starts srv with lc, listening on start so a taken address fails
+// startup. On stop it shuts srv down gracefully and waits for Serve to
+// return.
+func serve(lc *app.Lifecycle, srv *http.Server) {
+	served := make(chan struct{})
+	lc.OnStart(func(ctx context.Context) error {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer close(served)
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Server on %s stopped: %v", srv.Addr, err)
+			}
+		}()
+		return nil
+	})
+	lc.OnStop(func(ctx context.Context) error {
+		if err := srv.Shutdown(ctx); err != nil {
+			srv.Close()
+			return err
+		}
+		select {
+		case <-served:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// serveGRPC starts srv on addr with lc like serve, stopping it gracefully
+// until the stop context ends and then forcibly
+func serveGRPC(lc *app.Lifecycle, addr string, srv *grpc.Server) {
+	lc.OnStart(func(ctx context.Context) error {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := srv.Serve(ln); err != nil {
+				log.Printf("gRPC server on %s stopped: %v", addr, err)
+			}
+		}()
+		return nil
+	})
+	lc.OnStop(func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			srv.Stop()
+			return ctx.Err()
+		}
+	})
+}
+
+// apiKeyAuthenticator authenticates API keys against users
+func apiKeyAuthenticator(users *service.UserService) auth.Authenticator {
+	return auth.AuthenticatorFunc(func(ctx context.Context, key string) (auth.Principal, error) {
+		k, err := users.WithContext(ctx).AuthenticateAPIKey(key)
+		if err != nil {
+			return auth.Principal{}, err
+		}
+		return auth.Principal{UserID: k.UserID, KeyID: k.ID, Scopes: k.Scopes}, nil
+	})
+}
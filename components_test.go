@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"project/api"
+	"project/app"
+	"project/config"
+)
+
+// freeAddr returns a loopback address nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// runContainer runs c until the returned stop is called, which returns
+// the error of Run
+func runContainer(t *testing.T, c *app.Container) (stop func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	return func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(10 * time.Second):
+			t.Fatal("Run didn't return after its context was cancelled")
+			return nil
+		}
+	}
+}
+
+// waitStatus polls url until it answers, returning the status
+func waitStatus(t *testing.T, url string) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeUntilStopped(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		path    string
+	}{
+		{name: "admin server", handler: api.AdminHandler(config.NewQueryStats(), nil), path: "/admin/queries"},
+		{name: "metrics server", handler: dashboardMetrics.Handler(), path: "/metrics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := freeAddr(t)
+			c := app.New()
+			app.Provide(c, tt.name, func(ctx context.Context, lc *app.Lifecycle) (*http.Server, error) {
+				srv := &http.Server{Addr: addr, Handler: tt.handler}
+				serve(lc, srv)
+				return srv, nil
+			})
+			stop := runContainer(t, c)
+
+			url := "http://" + addr + tt.path
+			if status := waitStatus(t, url); status != http.StatusOK {
+				t.Fatalf("GET %s = %d, want %d", url, status, http.StatusOK)
+			}
+			// still serving later, not only while starting
+			time.Sleep(50 * time.Millisecond)
+			if status := waitStatus(t, url); status != http.StatusOK {
+				t.Fatalf("GET %s = %d, want %d", url, status, http.StatusOK)
+			}
+
+			if err := stop(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if resp, err := http.Get(url); err == nil {
+				resp.Body.Close()
+				t.Fatalf("GET %s answered %d after the container stopped", url, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// newConnector builds a driver connector for cfg, applying its session
+// settings to each connection it opens
+func newConnector(cfg DatabaseConfig) (driver.Connector, error) {
+	c, err := driverConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := sessionStatements(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sessionConnector{Connector: c, stmts: stmts}, nil
+}
+
+// driverConnector builds the connector of cfg's driver
+func driverConnector(cfg DatabaseConfig) (driver.Connector, error) {
+	switch cfg.Driver {
+	case "", "postgres":
+		c, err := pq.NewConnector(postgresDSN(cfg))
+		if err != nil {
+			return nil, cfg.sanitize(fmt.Errorf("failed to create postgres connector: %w", err))
+		}
+		d, err := cfg.dialer()
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			c.Dialer(pqDialer{d})
+		}
+		return c, nil
+	case "mysql":
+		mc, err := mysql.ParseDSN(mysqlDSN(cfg))
+		if err != nil {
+			return nil, cfg.sanitize(fmt.Errorf("failed to parse mysql config: %w", err))
+		}
+		d, err := cfg.dialer()
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			network := "tcp"
+			if cfg.socket() != "" {
+				network = "unix"
+			}
+			mc.Net = registerMySQLDialer(d, network)
+		}
+		c, err := mysql.NewConnector(mc)
+		if err != nil {
+			return nil, cfg.sanitize(fmt.Errorf("failed to create mysql connector: %w", err))
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unsupported driver %q", cfg.Driver)
+	}
+}
+
+// swapConnector is a driver.Connector whose target can be replaced while the
+// *sql.DB built on it stays in use. New connections use the current target;
+// connections already open are unaffected.
+type swapConnector struct {
+	mu    sync.RWMutex
+	inner driver.Connector
+}
+
+// Connect implements driver.Connector
+func (s *swapConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	s.mu.RLock()
+	inner := s.inner
+	s.mu.RUnlock()
+	return inner.Connect(ctx)
+}
+
+// Driver implements driver.Connector
+func (s *swapConnector) Driver() driver.Driver {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inner.Driver()
+}
+
+func (s *swapConnector) swap(inner driver.Connector) {
+	s.mu.Lock()
+	s.inner = inner
+	s.mu.Unlock()
+}
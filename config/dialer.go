@@ -0,0 +1,212 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+)
+
+// Dialer opens the network connections of a database, e.g. through an SSH
+// bastion or a SOCKS proxy for databases only reachable via jump hosts
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// dialer returns the Dialer cfg connects through, or nil to dial directly.
+// An explicit Dialer wins over a managed instance, which wins over Proxy.
+func (cfg DatabaseConfig) dialer() (Dialer, error) {
+	if cfg.Dialer != nil {
+		return cfg.Dialer, nil
+	}
+	if cfg.managed() {
+		return cfg.gcpDialer()
+	}
+	if cfg.Proxy == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(cfg.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %s", RedactDSN(cfg.Proxy))
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return nil, cfg.sanitize(fmt.Errorf("failed to create socks dialer: %w", err))
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("socks dialer does not support contexts")
+		}
+		return cd, nil
+	case "ssh":
+		return sshDialerFromURL(u)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, want socks5 or ssh", u.Scheme)
+	}
+}
+
+// sshDialerFromURL builds an SSHDialer from
+// ssh://user@bastion:22?key_file=...&known_hosts=...; known_hosts defaults
+// to ~/.ssh/known_hosts
+func sshDialerFromURL(u *url.URL) (*SSHDialer, error) {
+	keyFile := u.Query().Get("key_file")
+	if keyFile == "" {
+		return nil, fmt.Errorf("ssh proxy requires key_file")
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh key: %w", err)
+	}
+
+	hostsFile := u.Query().Get("known_hosts")
+	if hostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		hostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeys, err := knownhosts.New(hostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	return NewSSHDialer(addr, &ssh.ClientConfig{
+		User:            u.User.Username(),
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeys,
+		Timeout:         10 * time.Second,
+	}), nil
+}
+
+// SSHDialer tunnels connections through an SSH bastion. The SSH session is
+// opened on first dial and reopened if it drops.
+type SSHDialer struct {
+	addr string
+	cfg  *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// NewSSHDialer creates a dialer tunnelling through the SSH server at addr
+func NewSSHDialer(addr string, cfg *ssh.ClientConfig) *SSHDialer {
+	return &SSHDialer{addr: addr, cfg: cfg}
+}
+
+// DialContext implements Dialer
+func (d *SSHDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, address)
+	if err == nil {
+		return conn, nil
+	}
+
+	// the session may have dropped; retry once on a fresh one
+	d.reset(client)
+	if client, err = d.connect(ctx); err != nil {
+		return nil, err
+	}
+	conn, err = client.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s through ssh: %w", address, err)
+	}
+	return conn, nil
+}
+
+// Close closes the SSH session; connections tunnelled through it fail
+func (d *SSHDialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client == nil {
+		return nil
+	}
+	err := d.client.Close()
+	d.client = nil
+	return err
+}
+
+func (d *SSHDialer) connect(ctx context.Context) (*ssh.Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach ssh bastion: %w", err)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, d.addr, d.cfg)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open ssh session: %w", err)
+	}
+	d.client = ssh.NewClient(c, chans, reqs)
+	return d.client, nil
+}
+
+func (d *SSHDialer) reset(client *ssh.Client) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client == client {
+		d.client.Close()
+		d.client = nil
+	}
+}
+
+// pqDialer adapts a Dialer to lib/pq
+type pqDialer struct {
+	Dialer
+}
+
+// Dial implements pq.Dialer
+func (d pqDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialTimeout implements pq.Dialer
+func (d pqDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+// mysqlDialNets counts the networks registered with the MySQL driver, which
+// only accepts custom dialers by network name
+var mysqlDialNets atomic.Int64
+
+// registerMySQLDialer registers d with the MySQL driver and returns the
+// network name to connect with; network is the one d dials
+func registerMySQLDialer(d Dialer, network string) string {
+	name := fmt.Sprintf("adapter-dial-%d", mysqlDialNets.Add(1))
+	mysql.RegisterDialContext(name, func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, network, addr)
+	})
+	return name
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DatabaseURLEnv names the environment variable holding a full DSN
+const DatabaseURLEnv = "DATABASE_URL"
+
+// ParseDSN parses a postgres:// (or postgresql://) URL, a mysql:// URL or a
+// go-sql-driver/mysql DSN such as user:pass@tcp(host:3306)/db into a
+// DatabaseConfig. Query parameters without a dedicated field are kept in
+// Params.
+func ParseDSN(dsn string) (DatabaseConfig, error) {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return parseURL(dsn, "postgres")
+	case strings.HasPrefix(dsn, "mysql://"):
+		return parseURL(dsn, "mysql")
+	default:
+		return parseMySQLDSN(dsn)
+	}
+}
+
+// parseURL parses a URL style DSN. Parse errors are not wrapped, as they
+// quote the input, password included.
+func parseURL(dsn, driver string) (DatabaseConfig, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return DatabaseConfig{}, fmt.Errorf("failed to parse DSN %s", RedactDSN(dsn))
+	}
+
+	cfg := DatabaseConfig{
+		Driver: driver,
+		Host:   u.Hostname(),
+		DBName: strings.TrimPrefix(u.Path, "/"),
+	}
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+	if port := u.Port(); port != "" {
+		if cfg.Port, err = strconv.Atoi(port); err != nil {
+			return DatabaseConfig{}, fmt.Errorf("failed to parse DSN %s: invalid port %q", RedactDSN(dsn), port)
+		}
+	}
+
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+		switch key {
+		case "sslmode":
+			cfg.SSLMode = value
+		case "host", "socket":
+			cfg.Socket = value
+		default:
+			if cfg.Params == nil {
+				cfg.Params = make(map[string]string)
+			}
+			cfg.Params[key] = value
+		}
+	}
+	return cfg, nil
+}
+
+// parseMySQLDSN parses a go-sql-driver/mysql DSN
+func parseMySQLDSN(dsn string) (DatabaseConfig, error) {
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return DatabaseConfig{}, fmt.Errorf("failed to parse DSN %s", RedactDSN(dsn))
+	}
+
+	cfg := DatabaseConfig{
+		Driver:   "mysql",
+		User:     mc.User,
+		Password: mc.Passwd,
+		DBName:   mc.DBName,
+		Params:   mc.Params,
+	}
+
+	if mc.Net == "unix" {
+		cfg.Socket = mc.Addr
+		return cfg, nil
+	}
+
+	host, port, err := net.SplitHostPort(mc.Addr)
+	if err != nil {
+		return DatabaseConfig{}, fmt.Errorf("failed to parse DSN %s: invalid address %q", RedactDSN(dsn), mc.Addr)
+	}
+	cfg.Host = host
+	if cfg.Port, err = strconv.Atoi(port); err != nil {
+		return DatabaseConfig{}, fmt.Errorf("failed to parse DSN %s: invalid port %q", RedactDSN(dsn), port)
+	}
+	return cfg, nil
+}
+
+// overlay returns base with every field set on cfg taking precedence, so
+// discrete settings override those of a DSN
+func (cfg DatabaseConfig) overlay(base DatabaseConfig) DatabaseConfig {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&base.Driver, cfg.Driver)
+	set(&base.Host, cfg.Host)
+	set(&base.User, cfg.User)
+	set(&base.Password, cfg.Password)
+	set(&base.PasswordFile, cfg.PasswordFile)
+	set(&base.DBName, cfg.DBName)
+	set(&base.SSLMode, cfg.SSLMode)
+	set(&base.Socket, cfg.Socket)
+	set(&base.Proxy, cfg.Proxy)
+	if cfg.Dialer != nil {
+		base.Dialer = cfg.Dialer
+	}
+	set(&base.CloudSQLInstance, cfg.CloudSQLInstance)
+	set(&base.AlloyDBInstance, cfg.AlloyDBInstance)
+	set(&base.CredentialsFile, cfg.CredentialsFile)
+	base.IAMAuth = base.IAMAuth || cfg.IAMAuth
+	base.PrivateIP = base.PrivateIP || cfg.PrivateIP
+	set(&base.StatementTimeout, cfg.StatementTimeout)
+	set(&base.LockTimeout, cfg.LockTimeout)
+	set(&base.SearchPath, cfg.SearchPath)
+	set(&base.TimeZone, cfg.TimeZone)
+	if cfg.SessionVariables != nil {
+		base.SessionVariables = cfg.SessionVariables
+	}
+	if cfg.Labels != nil {
+		base.Labels = cfg.Labels
+	}
+	if cfg.Port != 0 {
+		base.Port = cfg.Port
+	}
+	if cfg.MaxOpenConns != 0 {
+		base.MaxOpenConns = cfg.MaxOpenConns
+	}
+	if cfg.MaxIdleConns != 0 {
+		base.MaxIdleConns = cfg.MaxIdleConns
+	}
+
+	if len(cfg.Params) > 0 {
+		params := make(map[string]string, len(base.Params)+len(cfg.Params))
+		for k, v := range base.Params {
+			params[k] = v
+		}
+		for k, v := range cfg.Params {
+			params[k] = v
+		}
+		base.Params = params
+	}
+	base.URL = ""
+	return base
+}
+
+// sortedParams returns Params as key-sorted pairs, for stable DSNs
+func (cfg DatabaseConfig) sortedParams() [][2]string {
+	return sortedPairs(cfg.Params)
+}
+
+// sortedPairs returns m as key-sorted pairs
+func sortedPairs(m map[string]string) [][2]string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([][2]string, len(keys))
+	for i, k := range keys {
+		out[i] = [2]string{k, m[k]}
+	}
+	return out
+}
+
+var (
+	// urlPassword matches the password of scheme://user:password@
+	urlPassword = regexp.MustCompile(`(://[^:/@\s]*:)[^@\s]*@`)
+	// mysqlPassword matches the password of user:password@proto(
+	mysqlPassword = regexp.MustCompile(`^([^:/@\s]*:)[^@\s]*(@[a-z]*\()`)
+	// kvPassword matches password=... in a key/value connection string
+	kvPassword = regexp.MustCompile(`(password=)('(?:[^'\\]|\\.)*'|\S*)`)
+)
+
+// RedactDSN replaces the password in any supported DSN format with "xxxxx"
+func RedactDSN(dsn string) string {
+	dsn = urlPassword.ReplaceAllString(dsn, "${1}xxxxx@")
+	dsn = mysqlPassword.ReplaceAllString(dsn, "${1}xxxxx${2}")
+	return kvPassword.ReplaceAllString(dsn, "${1}xxxxx")
+}
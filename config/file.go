@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// FileConfig is the on-disk configuration file
+type FileConfig struct {
+	Databases map[string]DatabaseConfig `json:"databases"`
+}
+
+// LoadFile reads and validates a JSON configuration file. Password files
+// are read when a connection is opened.
+func LoadFile(path string) (FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FileConfig{}, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	var cfg FileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return FileConfig{}, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return FileConfig{}, fmt.Errorf("invalid config %s:\n%w", path, err)
+	}
+	return cfg, nil
+}
+
+// resolve parses URL, with the other fields overriding it, and reads
+// PasswordFile into Password
+func (cfg DatabaseConfig) resolve() (DatabaseConfig, error) {
+	if cfg.URL != "" {
+		base, err := ParseDSN(cfg.URL)
+		if err != nil {
+			return cfg, cfg.sanitize(err)
+		}
+		cfg = cfg.overlay(base)
+	}
+
+	if cfg.PasswordFile == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(cfg.PasswordFile)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read password file: %w", err)
+	}
+	cfg.Password = strings.TrimSpace(string(data))
+	return cfg, nil
+}
+
+// WatchFile reloads path every interval and applies it to m, so changed
+// credentials, including rotated password files, take effect without a
+// restart. It blocks until ctx is done; reload failures are logged and the
+// current configuration kept.
+func WatchFile(ctx context.Context, path string, interval time.Duration, m *ConnectionManager) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg, err := LoadFile(path)
+			if err != nil {
+				log.Printf("Failed to reload config: %v", SanitizeError(err))
+				continue
+			}
+			if err := m.Apply(ctx, cfg); err != nil {
+				log.Printf("Failed to apply config: %v", SanitizeError(err))
+			}
+		}
+	}
+}
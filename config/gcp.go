@@ -0,0 +1,129 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/cloudsqlconn"
+)
+
+// gcpDialerKey identifies a shared Cloud SQL or AlloyDB dialer
+type gcpDialerKey struct {
+	iamAuth         bool
+	credentialsFile string
+}
+
+// The connector dialers are shared for the life of the process: each
+// refreshes certificates in the background, so one per rotated config
+// would leak
+var (
+	gcpDialersMu    sync.Mutex
+	cloudSQLDialers = make(map[gcpDialerKey]*cloudsqlconn.Dialer)
+	alloyDBDialers  = make(map[gcpDialerKey]*alloydbconn.Dialer)
+)
+
+// managed reports whether cfg connects to a GCP-managed instance through
+// the Cloud SQL or AlloyDB connector instead of host and port
+func (cfg DatabaseConfig) managed() bool {
+	return cfg.CloudSQLInstance != "" || cfg.AlloyDBInstance != ""
+}
+
+// gcpDialer returns the dialer for cfg's Cloud SQL or AlloyDB instance. The
+// connector handles TLS and, with IAMAuth, logs in as the IAM principal
+// named by User.
+func (cfg DatabaseConfig) gcpDialer() (Dialer, error) {
+	if cfg.CloudSQLInstance != "" && cfg.AlloyDBInstance != "" {
+		return nil, fmt.Errorf("set only one of cloudsql_instance and alloydb_instance")
+	}
+	if cfg.Proxy != "" {
+		return nil, fmt.Errorf("proxy can't be combined with a managed instance")
+	}
+
+	key := gcpDialerKey{iamAuth: cfg.IAMAuth, credentialsFile: cfg.CredentialsFile}
+	gcpDialersMu.Lock()
+	defer gcpDialersMu.Unlock()
+
+	if cfg.AlloyDBInstance != "" {
+		if cfg.Driver == "mysql" {
+			return nil, fmt.Errorf("alloydb_instance requires the postgres driver")
+		}
+		d, ok := alloyDBDialers[key]
+		if !ok {
+			var opts []alloydbconn.Option
+			if key.iamAuth {
+				opts = append(opts, alloydbconn.WithIAMAuthN())
+			}
+			if key.credentialsFile != "" {
+				opts = append(opts, alloydbconn.WithCredentialsFile(key.credentialsFile))
+			}
+			var err error
+			if d, err = alloydbconn.NewDialer(context.Background(), opts...); err != nil {
+				return nil, fmt.Errorf("failed to create alloydb dialer: %w", err)
+			}
+			alloyDBDialers[key] = d
+		}
+		// AlloyDB dials the private IP by default
+		var opts []alloydbconn.DialOption
+		if !cfg.PrivateIP {
+			opts = append(opts, alloydbconn.WithPublicIP())
+		}
+		return alloyDBInstance{d: d, instance: cfg.AlloyDBInstance, opts: opts}, nil
+	}
+
+	d, ok := cloudSQLDialers[key]
+	if !ok {
+		var opts []cloudsqlconn.Option
+		if key.iamAuth {
+			opts = append(opts, cloudsqlconn.WithIAMAuthN())
+		}
+		if key.credentialsFile != "" {
+			opts = append(opts, cloudsqlconn.WithCredentialsFile(key.credentialsFile))
+		}
+		var err error
+		if d, err = cloudsqlconn.NewDialer(context.Background(), opts...); err != nil {
+			return nil, fmt.Errorf("failed to create cloud sql dialer: %w", err)
+		}
+		cloudSQLDialers[key] = d
+	}
+	var opts []cloudsqlconn.DialOption
+	if cfg.PrivateIP {
+		opts = append(opts, cloudsqlconn.WithPrivateIP())
+	}
+	return cloudSQLInstance{d: d, instance: cfg.CloudSQLInstance, opts: opts}, nil
+}
+
+// cloudSQLInstance dials one Cloud SQL instance, ignoring the address the
+// driver derived from the unused host and port
+type cloudSQLInstance struct {
+	d        *cloudsqlconn.Dialer
+	instance string
+	opts     []cloudsqlconn.DialOption
+}
+
+// DialContext implements Dialer
+func (c cloudSQLInstance) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	conn, err := c.d.Dial(ctx, c.instance, c.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial cloud sql instance %s: %w", c.instance, err)
+	}
+	return conn, nil
+}
+
+// alloyDBInstance dials one AlloyDB instance, like cloudSQLInstance
+type alloyDBInstance struct {
+	d        *alloydbconn.Dialer
+	instance string
+	opts     []alloydbconn.DialOption
+}
+
+// DialContext implements Dialer
+func (a alloyDBInstance) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	conn, err := a.d.Dial(ctx, a.instance, a.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial alloydb instance %s: %w", a.instance, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,408 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"project/labels"
+	"project/metrics"
+)
+
+// defaultMaxIdleConns mirrors database/sql's default idle pool size, used
+// when DatabaseConfig.MaxIdleConns is unset
+const defaultMaxIdleConns = 2
+
+// defaultDrainTimeout bounds how long connections opened with rotated
+// credentials are retired for
+const defaultDrainTimeout = 30 * time.Second
+
+// ConnectionManager owns named database handles. Handles are opened lazily
+// on first Get and health-checked in the background; a handle that fails its
+// check is reconnected in place, so callers may keep the *sql.DB they got.
+type ConnectionManager struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	// DrainTimeout is how long Rotate keeps retiring connections as they
+	// are released
+	DrainTimeout time.Duration
+	// Saturation degrades Health when a pool crosses its limits
+	Saturation SaturationThresholds
+	// QueryLog, when set before the first Get, logs every statement
+	QueryLog *QueryLogger
+	// QueryStats, when set before the first Get, collects statistics per
+	// query fingerprint
+	QueryStats *QueryStats
+	// QueryDuration, when set before the first Get, records the duration
+	// of every statement by database and operation, with the trace of the
+	// context as exemplar; see metrics.Bundle
+	QueryDuration *metrics.HistogramVec
+
+	mu    sync.Mutex
+	conns map[string]*managedConn
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// managedConn is one named handle and its health state
+type managedConn struct {
+	mu        sync.Mutex
+	cfg       DatabaseConfig
+	active    DatabaseConfig
+	db        *sql.DB
+	connector *swapConnector
+	lastErr   error
+	draining  *time.Timer
+	stats     PoolStats
+}
+
+// NewConnectionManager creates a manager that health-checks open handles
+// every interval
+func NewConnectionManager(interval time.Duration) *ConnectionManager {
+	m := &ConnectionManager{
+		interval: interval,
+		timeout:  5 * time.Second,
+
+		DrainTimeout: defaultDrainTimeout,
+		Saturation:   DefaultSaturationThresholds,
+		conns:        make(map[string]*managedConn),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go m.healthLoop()
+	return m
+}
+
+// Register declares a named database. Nothing is opened until Get.
+func (m *ConnectionManager) Register(name string, cfg DatabaseConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conns[name] = &managedConn{cfg: cfg}
+}
+
+// Names returns the registered databases, sorted
+func (m *ConnectionManager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.conns))
+	for name := range m.conns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Driver returns the driver of the named database, "postgres" or "mysql",
+// or "" if name isn't registered
+func (m *ConnectionManager) Driver(name string) string {
+	m.mu.Lock()
+	c, ok := m.conns[name]
+	m.mu.Unlock()
+	if !ok {
+		return ""
+	}
+
+	c.mu.Lock()
+	cfg := c.cfg
+	c.mu.Unlock()
+
+	if resolved, err := cfg.resolve(); err == nil {
+		cfg = resolved
+	}
+	if cfg.Driver == "" {
+		return "postgres"
+	}
+	return cfg.Driver
+}
+
+// Labels returns the labels of the named database, or nil if name isn't
+// registered
+func (m *ConnectionManager) Labels(name string) labels.Labels {
+	m.mu.Lock()
+	c, ok := m.conns[name]
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg.Labels
+}
+
+// ConnString returns the resolved connection string of the named database,
+// for clients that connect outside database/sql such as COPY exports
+func (m *ConnectionManager) ConnString(name string) (string, error) {
+	m.mu.Lock()
+	c, ok := m.conns[name]
+	m.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown database %q", name)
+	}
+
+	c.mu.Lock()
+	cfg := c.cfg
+	c.mu.Unlock()
+
+	cfg, err := cfg.resolve()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Driver == "mysql" {
+		return mysqlDSN(cfg), nil
+	}
+	return postgresDSN(cfg), nil
+}
+
+// Get returns the handle for name, opening and pinging it on first use
+func (m *ConnectionManager) Get(ctx context.Context, name string) (*sql.DB, error) {
+	m.mu.Lock()
+	c, ok := m.conns[name]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db != nil {
+		return c.db, nil
+	}
+
+	if err := c.cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config for database %q:\n%w", name, err)
+	}
+	active, err := c.cfg.resolve()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure database %q: %w", name, err)
+	}
+	connector, err := newConnector(active)
+	if err != nil {
+		return nil, err
+	}
+	sc := &swapConnector{inner: connector}
+	var root driver.Connector = sc
+	if m.QueryLog != nil || m.QueryStats != nil || m.QueryDuration != nil {
+		root = loggingConnector{Connector: sc, log: m.QueryLog, stats: m.QueryStats, duration: m.QueryDuration,
+			db: name, attrs: active.Labels.LogAttrs()}
+	}
+	db := sql.OpenDB(root)
+	db.SetMaxOpenConns(active.MaxOpenConns)
+	db.SetMaxIdleConns(active.maxIdleConns())
+
+	pingCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		db.Close()
+		return nil, active.sanitize(fmt.Errorf("failed to ping database %q: %w", name, err))
+	}
+
+	c.db, c.connector, c.active, c.lastErr = db, sc, active, nil
+	return db, nil
+}
+
+// Apply registers databases new in cfg and rotates those whose settings
+// changed
+func (m *ConnectionManager) Apply(ctx context.Context, cfg FileConfig) error {
+	var errs []error
+	for name, dbCfg := range cfg.Databases {
+		m.mu.Lock()
+		c, ok := m.conns[name]
+		if !ok {
+			m.conns[name] = &managedConn{cfg: dbCfg}
+		}
+		m.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		c.mu.Lock()
+		changed := !reflect.DeepEqual(c.cfg, dbCfg)
+		if !changed && c.db != nil {
+			// Picks up a rotated password file
+			resolved, err := dbCfg.resolve()
+			changed = err == nil && !reflect.DeepEqual(resolved, c.active)
+		}
+		c.mu.Unlock()
+
+		if changed {
+			if err := m.Rotate(ctx, name, dbCfg); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Rotate switches name to cfg, e.g. new credentials, without replacing the
+// *sql.DB callers hold. The new settings are verified on a fresh connection
+// first; on success new connections use them, idle connections are closed,
+// and for DrainTimeout every connection is closed as it is released, so
+// in-flight queries finish on their old connections before those retire.
+func (m *ConnectionManager) Rotate(ctx context.Context, name string, cfg DatabaseConfig) error {
+	m.mu.Lock()
+	c, ok := m.conns[name]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown database %q", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db == nil {
+		c.cfg = cfg
+		return nil
+	}
+
+	active, err := cfg.resolve()
+	if err != nil {
+		return fmt.Errorf("failed to rotate %q: %w", name, err)
+	}
+	connector, err := newConnector(active)
+	if err != nil {
+		return fmt.Errorf("failed to rotate %q: %w", name, err)
+	}
+
+	verifyCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	conn, err := connector.Connect(verifyCtx)
+	if err != nil {
+		return active.sanitize(fmt.Errorf("failed to rotate %q: %w", name, err))
+	}
+	conn.Close()
+
+	c.cfg, c.active = cfg, active
+	c.connector.swap(connector)
+
+	db := c.db
+	db.SetMaxOpenConns(active.MaxOpenConns)
+	db.SetMaxIdleConns(0)
+	if c.draining != nil {
+		c.draining.Stop()
+	}
+	c.draining = time.AfterFunc(m.DrainTimeout, func() {
+		db.SetMaxIdleConns(active.maxIdleConns())
+	})
+	return nil
+}
+
+// Health returns the last health check error of every open handle, nil
+// meaning healthy. Errors matching ErrPoolSaturated mean reachable but
+// degraded.
+func (m *ConnectionManager) Health() map[string]error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]error)
+	for name, c := range m.conns {
+		c.mu.Lock()
+		if c.db != nil {
+			out[name] = c.lastErr
+		}
+		c.mu.Unlock()
+	}
+	return out
+}
+
+// Close stops health checks and closes every open handle
+func (m *ConnectionManager) Close() error {
+	close(m.stop)
+	<-m.done
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for name, c := range m.conns {
+		c.mu.Lock()
+		if c.draining != nil {
+			c.draining.Stop()
+		}
+		if c.db != nil {
+			if err := c.db.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close %q: %w", name, err))
+			}
+			c.db = nil
+		}
+		c.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+func (m *ConnectionManager) healthLoop() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.checkAll()
+		}
+	}
+}
+
+func (m *ConnectionManager) checkAll() {
+	m.mu.Lock()
+	conns := make([]*managedConn, 0, len(m.conns))
+	names := make([]string, 0, len(m.conns))
+	for name, c := range m.conns {
+		conns = append(conns, c)
+		names = append(names, name)
+	}
+	m.mu.Unlock()
+
+	for i, c := range conns {
+		m.check(names[i], c)
+	}
+}
+
+// check samples pool stats and pings an open handle. A saturated pool is
+// reported through lastErr as ErrPoolSaturated. On ping failure it rebuilds the connector, so new
+// connections re-resolve the host, and drops idle connections, which may be
+// broken; in-flight queries keep their connections.
+func (m *ConnectionManager) check(name string, c *managedConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db == nil {
+		return
+	}
+
+	c.stats = derivePoolStats(c.db.Stats(), c.stats.DBStats)
+	publishPoolStats(name, c.stats)
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	err := c.db.PingContext(ctx)
+	if err == nil {
+		c.lastErr = m.Saturation.check(c.stats)
+		return
+	}
+
+	c.lastErr = c.active.sanitize(err)
+	connector, cerr := newConnector(c.active)
+	if cerr != nil {
+		c.lastErr = c.active.sanitize(errors.Join(err, cerr))
+		return
+	}
+	c.connector.swap(connector)
+	c.db.SetMaxIdleConns(0)
+	c.db.SetMaxIdleConns(c.active.maxIdleConns())
+}
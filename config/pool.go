@@ -0,0 +1,169 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"project/labels"
+	"project/metrics"
+)
+
+// poolMetrics publishes PoolStats per database name
+var poolMetrics = expvar.NewMap("pools")
+
+// ErrPoolSaturated marks a health check that passed its ping but crossed a
+// SaturationThresholds limit
+var ErrPoolSaturated = errors.New("connection pool saturated")
+
+// PoolStats is a DBStats sample with gauges derived from it
+type PoolStats struct {
+	sql.DBStats
+	// Utilization is InUse over MaxOpenConnections, or over
+	// OpenConnections when the pool is unbounded, in 0..1
+	Utilization float64
+	// AvgWait is the mean wait for a connection since the previous sample
+	AvgWait time.Duration
+}
+
+// SaturationThresholds degrade a handle's health when exceeded; zero
+// disables a limit
+type SaturationThresholds struct {
+	Utilization float64
+	AvgWait     time.Duration
+}
+
+// DefaultSaturationThresholds flag pools over 90% busy or making callers
+// wait 100ms on average
+var DefaultSaturationThresholds = SaturationThresholds{
+	Utilization: 0.9,
+	AvgWait:     100 * time.Millisecond,
+}
+
+// derivePoolStats computes PoolStats from the current sample and the one
+// before it
+func derivePoolStats(cur, prev sql.DBStats) PoolStats {
+	stats := PoolStats{DBStats: cur}
+
+	capacity := cur.MaxOpenConnections
+	if capacity == 0 {
+		capacity = cur.OpenConnections
+	}
+	if capacity > 0 {
+		stats.Utilization = float64(cur.InUse) / float64(capacity)
+	}
+
+	if waits := cur.WaitCount - prev.WaitCount; waits > 0 {
+		stats.AvgWait = (cur.WaitDuration - prev.WaitDuration) / time.Duration(waits)
+	}
+	return stats
+}
+
+// check returns an ErrPoolSaturated error when stats crosses a limit
+func (t SaturationThresholds) check(stats PoolStats) error {
+	if t.Utilization > 0 && stats.Utilization >= t.Utilization {
+		return fmt.Errorf("%w: utilization %.0f%%", ErrPoolSaturated, stats.Utilization*100)
+	}
+	if t.AvgWait > 0 && stats.AvgWait >= t.AvgWait {
+		return fmt.Errorf("%w: average wait %s", ErrPoolSaturated, stats.AvgWait)
+	}
+	return nil
+}
+
+// PoolStats returns the latest sample of every open handle, taken at each
+// health check
+func (m *ConnectionManager) PoolStats() map[string]PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]PoolStats)
+	for name, c := range m.conns {
+		c.mu.Lock()
+		if c.db != nil {
+			out[name] = c.stats
+		}
+		c.mu.Unlock()
+	}
+	return out
+}
+
+// WritePrometheus writes the pool gauges in the Prometheus text exposition
+// format, labelled by database name
+func (m *ConnectionManager) WritePrometheus(w io.Writer) error {
+	return metrics.WriteText(w, m.Collect(), false)
+}
+
+// Collect implements metrics.Collector, reporting the pool gauges labelled
+// by database name, for metrics.NewBundle
+func (m *ConnectionManager) Collect() []metrics.Family {
+	stats := m.PoolStats()
+	health := m.Health()
+
+	names := make([]string, 0, len(stats))
+	selectors := make(map[string][]metrics.Label, len(stats))
+	for name := range stats {
+		names = append(names, name)
+		selectors[name] = promLabels(name, m.Labels(name))
+	}
+	sort.Strings(names)
+
+	pool := []struct {
+		name, unit string
+		kind       metrics.Type
+		help       string
+		value      func(PoolStats, error) float64
+	}{
+		{"open_connections", "", metrics.Gauge, "Open connections.", func(s PoolStats, _ error) float64 { return float64(s.OpenConnections) }},
+		{"in_use_connections", "", metrics.Gauge, "Connections in use.", func(s PoolStats, _ error) float64 { return float64(s.InUse) }},
+		{"idle_connections", "", metrics.Gauge, "Idle connections.", func(s PoolStats, _ error) float64 { return float64(s.Idle) }},
+		{"max_open_connections", "", metrics.Gauge, "Pool size limit, 0 for unbounded.", func(s PoolStats, _ error) float64 { return float64(s.MaxOpenConnections) }},
+		{"wait_count", "", metrics.Counter, "Waits for a connection.", func(s PoolStats, _ error) float64 { return float64(s.WaitCount) }},
+		{"wait", "seconds", metrics.Counter, "Time spent waiting for a connection.", func(s PoolStats, _ error) float64 { return s.WaitDuration.Seconds() }},
+		{"utilization", "ratio", metrics.Gauge, "Connections in use over pool capacity.", func(s PoolStats, _ error) float64 { return s.Utilization }},
+		{"avg_wait", "seconds", metrics.Gauge, "Mean connection wait since the previous health check.", func(s PoolStats, _ error) float64 { return s.AvgWait.Seconds() }},
+		{"saturated", "", metrics.Gauge, "1 when a saturation threshold is exceeded.", func(_ PoolStats, err error) float64 {
+			if errors.Is(err, ErrPoolSaturated) {
+				return 1
+			}
+			return 0
+		}},
+	}
+
+	families := make([]metrics.Family, 0, len(pool))
+	for _, metric := range pool {
+		f := metrics.Family{Name: metrics.Name("db", "pool_"+metric.name, metric.unit), Help: metric.help, Type: metric.kind, Unit: metric.unit}
+		for _, name := range names {
+			f.Samples = append(f.Samples, metrics.Sample{Labels: selectors[name], Value: metric.value(stats[name], health[name])})
+		}
+		families = append(families, f)
+	}
+	return families
+}
+
+// promLabels returns the label set of a database's series: db and its
+// configured labels
+func promLabels(name string, l labels.Labels) []metrics.Label {
+	set := []metrics.Label{{Name: "db", Value: name}}
+	for _, k := range l.Keys() {
+		set = append(set, metrics.Label{Name: k, Value: l[k]})
+	}
+	return set
+}
+
+// publishPoolStats exposes stats under the pools expvar
+func publishPoolStats(name string, stats PoolStats) {
+	m := new(expvar.Map).Init()
+	m.Add("open", int64(stats.OpenConnections))
+	m.Add("in_use", int64(stats.InUse))
+	m.Add("idle", int64(stats.Idle))
+	m.Add("wait_count", stats.WaitCount)
+	u := new(expvar.Float)
+	u.Set(stats.Utilization)
+	m.Set("utilization", u)
+	m.Add("avg_wait_ms", stats.AvgWait.Milliseconds())
+	poolMetrics.Set(name, m)
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"project/metrics"
+)
+
+// QueryLogger logs every statement run on the handles of a
+// ConnectionManager at debug level, with its duration and, for a sampled
+// share of statements, its parameters. It can be switched on and off at
+// runtime.
+type QueryLogger struct {
+	logger  *slog.Logger
+	enabled atomic.Bool
+
+	mu sync.RWMutex
+	// paramSample is the share of statements, 0..1, logged with parameters
+	paramSample float64
+	// redact logs parameter types instead of values
+	redact bool
+}
+
+// NewQueryLogger creates a disabled QueryLogger writing to logger
+func NewQueryLogger(logger *slog.Logger) *QueryLogger {
+	return &QueryLogger{logger: logger, redact: true}
+}
+
+// SetEnabled switches statement logging on or off
+func (l *QueryLogger) SetEnabled(enabled bool) {
+	l.enabled.Store(enabled)
+}
+
+// Enabled reports whether statements are being logged
+func (l *QueryLogger) Enabled() bool {
+	return l.enabled.Load()
+}
+
+// SetParams logs parameters for a share sample (0..1) of statements, as
+// their types when redact is set
+func (l *QueryLogger) SetParams(sample float64, redact bool) {
+	l.mu.Lock()
+	l.paramSample, l.redact = sample, redact
+	l.mu.Unlock()
+}
+
+// log records one statement
+func (l *QueryLogger) log(ctx context.Context, labels []slog.Attr, query string, args []driver.NamedValue, start time.Time, err error) {
+	if l == nil || !l.Enabled() || !l.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := append([]slog.Attr{
+		slog.String("sql", query),
+		slog.Duration("duration", time.Since(start)),
+	}, labels...)
+
+	l.mu.RLock()
+	sample, redact := l.paramSample, l.redact
+	l.mu.RUnlock()
+
+	if len(args) > 0 && sample > 0 && rand.Float64() < sample {
+		params := make([]string, len(args))
+		for i, a := range args {
+			if redact {
+				params[i] = fmt.Sprintf("%T", a.Value)
+			} else {
+				params[i] = fmt.Sprintf("%v", a.Value)
+			}
+		}
+		attrs = append(attrs, slog.Any("params", params))
+	}
+	if err != nil && err != driver.ErrSkip {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "query", attrs...)
+}
+
+// loggingConnector wraps the connections of a connector to log statements
+// and collect their statistics
+type loggingConnector struct {
+	driver.Connector
+	log      *QueryLogger
+	stats    *QueryStats
+	duration *metrics.HistogramVec
+	// db names the database in duration's series
+	db string
+	// attrs label every statement, from the database's labels
+	attrs []slog.Attr
+}
+
+// Connect implements driver.Connector
+func (c loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &loggingConn{Conn: conn, log: c.log, stats: c.stats, duration: c.duration, db: c.db, attrs: c.attrs}, nil
+}
+
+// loggingConn logs the statements run on a driver connection, forwarding
+// the optional driver interfaces of the connection it wraps
+type loggingConn struct {
+	driver.Conn
+	log      *QueryLogger
+	stats    *QueryStats
+	duration *metrics.HistogramVec
+	db       string
+	attrs    []slog.Attr
+}
+
+// observe logs and records one statement; either sink may be nil
+func (c *loggingConn) observe(ctx context.Context, query string, args []driver.NamedValue, start time.Time, err error) {
+	c.log.log(ctx, c.attrs, query, args, start, err)
+	if err == driver.ErrSkip {
+		return
+	}
+	if c.stats != nil {
+		c.stats.record(query, time.Since(start), err)
+	}
+	if c.duration != nil {
+		c.duration.Observe(ctx, time.Since(start).Seconds(), c.db, queryOperation(query))
+	}
+}
+
+// queryOperations are the statement kinds queryOperation reports; others
+// are "other", keeping the operation label's values few
+var queryOperations = map[string]bool{
+	"select": true, "insert": true, "update": true, "delete": true, "with": true,
+	"begin": true, "commit": true, "rollback": true, "copy": true,
+}
+
+// queryOperation returns the lower-cased leading keyword of query
+func queryOperation(query string) string {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	end := strings.IndexFunc(query, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(query)
+	}
+	if op := strings.ToLower(query[:end]); queryOperations[op] {
+		return op
+	}
+	return "other"
+}
+
+// PrepareContext implements driver.ConnPrepareContext
+func (c *loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &loggingStmt{Stmt: stmt, query: query, conn: c}, nil
+}
+
+// ExecContext implements driver.ExecerContext
+func (c *loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	c.observe(ctx, query, args, start, err)
+	return res, err
+}
+
+// QueryContext implements driver.QueryerContext
+func (c *loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	c.observe(ctx, query, args, start, err)
+	return rows, err
+}
+
+// BeginTx implements driver.ConnBeginTx
+func (c *loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without BeginTx
+}
+
+// Ping implements driver.Pinger
+func (c *loggingConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter
+func (c *loggingConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid implements driver.Validator
+func (c *loggingConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue implements driver.NamedValueChecker
+func (c *loggingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// loggingStmt logs and records executions of a prepared statement
+type loggingStmt struct {
+	driver.Stmt
+	query string
+	conn  *loggingConn
+}
+
+// ExecContext implements driver.StmtExecContext
+func (s *loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(namedValues(args))
+	}
+	s.conn.observe(ctx, s.query, args, start, err)
+	return res, err
+}
+
+// QueryContext implements driver.StmtQueryContext
+func (s *loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+	s.conn.observe(ctx, s.query, args, start, err)
+	return rows, err
+}
+
+// CheckNamedValue implements driver.NamedValueChecker
+func (s *loggingStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// namedValues drops the names of args for drivers without context support
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	return values
+}
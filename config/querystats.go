@@ -0,0 +1,252 @@
+package config
+
+import (
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryStat aggregates the executions of one query fingerprint
+type QueryStat struct {
+	Fingerprint string `json:"fingerprint"`
+	// Query is the normalized statement, literals and parameters replaced
+	// by ?
+	Query     string        `json:"query"`
+	Calls     int64         `json:"calls"`
+	Errors    int64         `json:"errors"`
+	TotalTime time.Duration `json:"total_time_ns"`
+	MaxTime   time.Duration `json:"max_time_ns"`
+	LastSeen  time.Time     `json:"last_seen"`
+}
+
+// MeanTime returns the mean execution time
+func (s QueryStat) MeanTime() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalTime / time.Duration(s.Calls)
+}
+
+// ErrorRate returns the share of failed executions, 0..1
+func (s QueryStat) ErrorRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Calls)
+}
+
+// QueryStats collects call counts, latency and errors per query
+// fingerprint for the statements run on a ConnectionManager's handles, to
+// spot hot or regressed queries
+type QueryStats struct {
+	mu    sync.Mutex
+	stats map[string]*QueryStat
+	// fingerprints caches the fingerprint of each raw statement seen
+	fingerprints map[string][2]string
+	dropped      int64
+
+	// MaxFingerprints caps the fingerprints tracked; executions of new
+	// ones past it are only counted as dropped. 1000 by default.
+	MaxFingerprints int
+}
+
+// NewQueryStats creates an empty collector
+func NewQueryStats() *QueryStats {
+	return &QueryStats{
+		stats:           make(map[string]*QueryStat),
+		fingerprints:    make(map[string][2]string),
+		MaxFingerprints: 1000,
+	}
+}
+
+// record adds one execution of query
+func (s *QueryStats) record(query string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fp, ok := s.fingerprints[query]
+	if !ok {
+		id, normalized := Fingerprint(query)
+		fp = [2]string{id, normalized}
+		if len(s.fingerprints) < 4*s.MaxFingerprints {
+			s.fingerprints[query] = fp
+		}
+	}
+
+	stat, ok := s.stats[fp[0]]
+	if !ok {
+		if len(s.stats) >= s.MaxFingerprints {
+			s.dropped++
+			return
+		}
+		stat = &QueryStat{Fingerprint: fp[0], Query: fp[1]}
+		s.stats[fp[0]] = stat
+	}
+	stat.Calls++
+	if err != nil {
+		stat.Errors++
+	}
+	stat.TotalTime += d
+	stat.MaxTime = max(stat.MaxTime, d)
+	stat.LastSeen = time.Now()
+}
+
+// Snapshot returns the statistics, by total time descending, and the
+// number of executions dropped past MaxFingerprints
+func (s *QueryStats) Snapshot() ([]QueryStat, int64) {
+	s.mu.Lock()
+	out := make([]QueryStat, 0, len(s.stats))
+	for _, stat := range s.stats {
+		out = append(out, *stat)
+	}
+	dropped := s.dropped
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TotalTime != out[j].TotalTime {
+			return out[i].TotalTime > out[j].TotalTime
+		}
+		return out[i].Fingerprint < out[j].Fingerprint
+	})
+	return out, dropped
+}
+
+// Reset discards the collected statistics, e.g. to measure a release from
+// a clean slate
+func (s *QueryStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = make(map[string]*QueryStat)
+	s.fingerprints = make(map[string][2]string)
+	s.dropped = 0
+}
+
+var (
+	// valueListPattern matches a parenthesized list of placeholders
+	valueListPattern = regexp.MustCompile(`\(\?(?:, \?)+\)`)
+	// rowListPattern matches repeated rows of a multi-row VALUES
+	rowListPattern = regexp.MustCompile(`\(\?\.\.\.\)(?:, \(\?\.\.\.\))+`)
+)
+
+// Fingerprint normalizes query, replacing literals and placeholders with ?,
+// collapsing IN lists and multi-row VALUES, and dropping comments and
+// extra whitespace, so executions differing only in their values share a
+// fingerprint. It returns a short hash of the normalized query and the
+// normalized query itself.
+func Fingerprint(query string) (string, string) {
+	var b strings.Builder
+	space := false
+	emit := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+			space = true
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+			space = true
+		case c == '\'':
+			// a quoted literal, '' escaping a quote
+			j := i + 1
+			for j < len(query) {
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			emit("?")
+			i = j + 1
+		case c == '"' || c == '`':
+			// a quoted identifier, kept
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				end = len(query) - i - 1
+			}
+			emit(query[i : i+end+2])
+			i += end + 2
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			emit("?")
+			i = j
+		case isDigit(c) && !identChar(b.String(), space):
+			j := i
+			for j < len(query) && (isDigit(query[j]) || query[j] == '.') {
+				j++
+			}
+			emit("?")
+			i = j
+		case c == ',':
+			space = false
+			b.WriteString(", ")
+			i++
+			// the separator carries its own space
+			for i < len(query) && (query[i] == ' ' || query[i] == '\t' || query[i] == '\n' || query[i] == '\r') {
+				i++
+			}
+		case c == '(' || c == ')':
+			if c == ')' {
+				space = false
+			}
+			emit(string(c))
+			if c == '(' {
+				for i+1 < len(query) && strings.ContainsRune(" \t\n\r", rune(query[i+1])) {
+					i++
+				}
+			}
+			i++
+		default:
+			emit(string(c))
+			i++
+		}
+	}
+
+	normalized := valueListPattern.ReplaceAllString(b.String(), "(?...)")
+	normalized = rowListPattern.ReplaceAllString(normalized, "(?...)...")
+
+	h := fnv.New64a()
+	h.Write([]byte(normalized))
+	return strconv.FormatUint(h.Sum64(), 16), normalized
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// identChar reports whether the output so far ends inside an identifier,
+// so a digit continues it, e.g. the 2 of t2
+func identChar(s string, space bool) bool {
+	if space || s == "" {
+		return false
+	}
+	last := s[len(s)-1]
+	return last == '_' || isDigit(last) || (last|0x20 >= 'a' && last|0x20 <= 'z')
+}
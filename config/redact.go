@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// redactedPassword replaces passwords in redacted output
+const redactedPassword = "xxxxx"
+
+// redactedError hides passwords in the message of the error it wraps while
+// keeping it available to errors.Is and errors.As
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+// SanitizeError wraps err so its message has DSN passwords, and each of
+// secrets, replaced. It returns nil for a nil err.
+func SanitizeError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err, secrets: secrets}
+}
+
+// Error implements error
+func (e *redactedError) Error() string {
+	msg := RedactDSN(e.err.Error())
+	for _, s := range e.secrets {
+		if s != "" {
+			msg = strings.ReplaceAll(msg, s, redactedPassword)
+		}
+	}
+	return msg
+}
+
+// Unwrap returns the wrapped error
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// sanitize wraps err to hide cfg's credentials
+func (cfg DatabaseConfig) sanitize(err error) error {
+	return SanitizeError(err, cfg.Password)
+}
+
+// Redacted returns a copy of cfg safe to log
+func (cfg DatabaseConfig) Redacted() DatabaseConfig {
+	if cfg.Password != "" {
+		cfg.Password = redactedPassword
+	}
+	cfg.URL = RedactDSN(cfg.URL)
+	cfg.Proxy = RedactDSN(cfg.Proxy)
+	return cfg
+}
+
+// String implements fmt.Stringer, so printing a config never shows its
+// password
+func (cfg DatabaseConfig) String() string {
+	type plain DatabaseConfig
+	return fmt.Sprintf("%+v", plain(cfg.Redacted()))
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// sessionVariablePattern matches the setting names accepted in
+// SessionVariables, which can't be passed as query parameters
+var sessionVariablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// sessionStatement is one statement run on a new connection
+type sessionStatement struct {
+	query string
+	args  []driver.NamedValue
+}
+
+// sessionStatements returns the statements applying cfg's session settings,
+// the time zone always among them.
+// PostgreSQL settings go through set_config so values are never spliced into
+// SQL; MySQL variable values are bound the same way.
+func sessionStatements(cfg DatabaseConfig) ([]sessionStatement, error) {
+	mysql := cfg.Driver == "mysql"
+	if mysql && (cfg.StatementTimeout != "" || cfg.LockTimeout != "" || cfg.SearchPath != "") {
+		return nil, fmt.Errorf("statement_timeout, lock_timeout and search_path are postgres settings, use session_variables for mysql")
+	}
+
+	settings := [][2]string{
+		{"statement_timeout", cfg.StatementTimeout},
+		{"lock_timeout", cfg.LockTimeout},
+		{"search_path", cfg.SearchPath},
+	}
+	for _, kv := range sortedPairs(cfg.SessionVariables) {
+		if !sessionVariablePattern.MatchString(kv[0]) {
+			return nil, fmt.Errorf("invalid session variable %q", kv[0])
+		}
+		settings = append(settings, kv)
+	}
+
+	// the time zone is always pinned, so results don't follow the server's
+	if mysql {
+		settings = append(settings, [2]string{"time_zone", mysqlTimeZone(cfg.timeZone())})
+	} else {
+		settings = append(settings, [2]string{"TimeZone", postgresTimeZone(cfg.timeZone())})
+	}
+
+	var stmts []sessionStatement
+	for _, kv := range settings {
+		if kv[1] == "" {
+			continue
+		}
+		if mysql {
+			stmts = append(stmts, sessionStatement{
+				query: fmt.Sprintf("SET SESSION %s = ?", kv[0]),
+				args:  []driver.NamedValue{{Ordinal: 1, Value: mysqlSessionValue(kv[1])}},
+			})
+			continue
+		}
+		stmts = append(stmts, sessionStatement{
+			query: "SELECT set_config($1, $2, false)",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: kv[0]}, {Ordinal: 2, Value: kv[1]}},
+		})
+	}
+	return stmts, nil
+}
+
+// timeZonePattern matches UTC offsets, e.g. "+02:00"
+var timeZonePattern = regexp.MustCompile(`^[+-](0\d|1[0-4]):[0-5]\d$`)
+
+// timeZone returns the session time zone of cfg
+func (cfg DatabaseConfig) timeZone() string {
+	if cfg.TimeZone == "" {
+		return "UTC"
+	}
+	return cfg.TimeZone
+}
+
+// location returns the session time zone of cfg as a Location, UTC when
+// it is invalid, which Validate reports
+func (cfg DatabaseConfig) location() *time.Location {
+	tz := cfg.timeZone()
+	if timeZonePattern.MatchString(tz) {
+		hours, _ := strconv.Atoi(tz[1:3])
+		minutes, _ := strconv.Atoi(tz[4:6])
+		offset := (hours*60 + minutes) * 60
+		if tz[0] == '-' {
+			offset = -offset
+		}
+		return time.FixedZone(tz, offset)
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "Local" {
+		return time.UTC
+	}
+	return loc
+}
+
+// validTimeZone reports whether tz is a known IANA name or a UTC offset
+func validTimeZone(tz string) bool {
+	if timeZonePattern.MatchString(tz) {
+		return true
+	}
+	_, err := time.LoadLocation(tz)
+	return err == nil && tz != "Local"
+}
+
+// postgresTimeZone turns a UTC offset into the POSIX spec Postgres
+// expects, whose sign is inverted: "+02:00" becomes "<+02:00>-02:00"
+func postgresTimeZone(tz string) string {
+	if !timeZonePattern.MatchString(tz) {
+		return tz
+	}
+	sign := "-"
+	if tz[0] == '-' {
+		sign = "+"
+	}
+	return "<" + tz + ">" + sign + tz[1:]
+}
+
+// mysqlTimeZone spells UTC as an offset, which MySQL accepts without its
+// time zone tables
+func mysqlTimeZone(tz string) string {
+	if tz == "UTC" {
+		return "+00:00"
+	}
+	return tz
+}
+
+// mysqlSessionValue binds numeric variables as integers, since MySQL rejects
+// strings for them
+func mysqlSessionValue(v string) driver.Value {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n
+	}
+	return v
+}
+
+// sessionConnector runs session statements on every connection it opens
+type sessionConnector struct {
+	driver.Connector
+	stmts []sessionStatement
+}
+
+// Connect implements driver.Connector
+func (c sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range c.stmts {
+		if err := execConn(ctx, conn, stmt); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to apply session settings: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// execConn runs stmt on a driver connection
+func execConn(ctx context.Context, conn driver.Conn, stmt sessionStatement) error {
+	if e, ok := conn.(driver.ExecerContext); ok {
+		_, err := e.ExecContext(ctx, stmt.query, stmt.args)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	var (
+		s   driver.Stmt
+		err error
+	)
+	if p, ok := conn.(driver.ConnPrepareContext); ok {
+		s, err = p.PrepareContext(ctx, stmt.query)
+	} else {
+		s, err = conn.Prepare(stmt.query)
+	}
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	se, ok := s.(driver.StmtExecContext)
+	if !ok {
+		return fmt.Errorf("driver does not support ExecContext")
+	}
+	_, err = se.ExecContext(ctx, stmt.args)
+	return err
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+
+	"project/labels"
+)
+
+// postgresSSLModes are the sslmode values lib/pq accepts
+var postgresSSLModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// Validate checks cfg without connecting and returns every problem found,
+// joined, so a bad configuration is fixed in one go rather than discovered
+// at the first ping. URL is parsed and overlaid the way a connection would.
+func (cfg DatabaseConfig) Validate() error {
+	var errs []error
+	problem := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if cfg.Password != "" && cfg.PasswordFile != "" {
+		problem("set only one of password and password_file")
+	}
+	if cfg.URL != "" {
+		base, err := ParseDSN(cfg.URL)
+		if err != nil {
+			errs = append(errs, cfg.sanitize(err))
+		} else {
+			cfg = cfg.overlay(base)
+		}
+	}
+
+	driver := cfg.Driver
+	if driver == "" {
+		driver = "postgres"
+	}
+	if driver != "postgres" && driver != "mysql" {
+		problem("driver %q is not supported, want postgres or mysql", cfg.Driver)
+	}
+
+	if cfg.managed() {
+		if cfg.CloudSQLInstance != "" && cfg.AlloyDBInstance != "" {
+			problem("set only one of cloudsql_instance and alloydb_instance")
+		}
+		if cfg.AlloyDBInstance != "" && driver == "mysql" {
+			problem("alloydb_instance requires the postgres driver")
+		}
+		if cfg.Proxy != "" {
+			problem("proxy can't be combined with a managed instance")
+		}
+		if cfg.socket() != "" {
+			problem("socket can't be combined with a managed instance, the connector dials it")
+		}
+	} else {
+		if cfg.Host == "" && cfg.socket() == "" {
+			problem("host is required, or set socket, url, cloudsql_instance or alloydb_instance")
+		}
+		if cfg.IAMAuth {
+			problem("iam_auth requires cloudsql_instance or alloydb_instance")
+		}
+		if driver == "mysql" && cfg.socket() == "" && cfg.Port == 0 {
+			problem("port is required for mysql over TCP, e.g. 3306")
+		}
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		problem("port %d is out of range 1-65535", cfg.Port)
+	}
+	if cfg.MaxOpenConns < 0 || cfg.MaxIdleConns < 0 {
+		problem("max_open_conns and max_idle_conns can't be negative")
+	}
+	if cfg.IAMAuth && cfg.Password != "" {
+		problem("password can't be combined with iam_auth, the IAM principal logs in without one")
+	}
+	if cfg.IAMAuth && cfg.User == "" {
+		problem("user is required with iam_auth, set it to the IAM principal")
+	}
+
+	switch {
+	case driver == "mysql" && cfg.SSLMode != "":
+		problem("sslmode is a postgres setting, use params.tls for mysql")
+	case driver == "postgres" && cfg.SSLMode != "" && !postgresSSLModes[cfg.SSLMode]:
+		problem("sslmode %q is not supported, want one of disable, allow, prefer, require, verify-ca or verify-full", cfg.SSLMode)
+	}
+	if driver == "mysql" && (cfg.StatementTimeout != "" || cfg.LockTimeout != "" || cfg.SearchPath != "") {
+		problem("statement_timeout, lock_timeout and search_path are postgres settings, use session_variables for mysql")
+	}
+	for _, kv := range sortedPairs(cfg.SessionVariables) {
+		if !sessionVariablePattern.MatchString(kv[0]) {
+			problem("invalid session variable %q", kv[0])
+		}
+	}
+	if cfg.TimeZone != "" && !validTimeZone(cfg.TimeZone) {
+		problem("time_zone %q is not a time zone name or a UTC offset such as +02:00", cfg.TimeZone)
+	}
+
+	for _, key := range cfg.Labels.Keys() {
+		switch {
+		case !labels.ValidKey(key):
+			problem("invalid label %q, want letters, digits and underscores", key)
+		case key == "db":
+			problem("label db is reserved for the database name")
+		}
+	}
+
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		switch {
+		case err != nil:
+			problem("invalid proxy URL %s", RedactDSN(cfg.Proxy))
+		case u.Scheme != "socks5" && u.Scheme != "socks5h" && u.Scheme != "ssh":
+			problem("unsupported proxy scheme %q, want socks5 or ssh", u.Scheme)
+		}
+	}
+
+	for _, f := range []struct{ field, path string }{
+		{"password_file", cfg.PasswordFile},
+		{"credentials_file", cfg.CredentialsFile},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			problem("%s %s is not readable: %w", f.field, f.path, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks every database of cfg, see DatabaseConfig.Validate. The
+// problems are prefixed with the database name.
+func (cfg FileConfig) Validate() error {
+	names := make([]string, 0, len(cfg.Databases))
+	for name := range cfg.Databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		err := cfg.Databases[name].Validate()
+		if err == nil {
+			continue
+		}
+		for _, problem := range err.(interface{ Unwrap() []error }).Unwrap() {
+			errs = append(errs, fmt.Errorf("databases.%s: %w", name, problem))
+		}
+	}
+	return errors.Join(errs...)
+}
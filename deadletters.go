@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"project/repository"
+)
+
+// runDeadLetters handles `adapter dead-letters <subcommand>`, inspecting,
+// editing, replaying and discarding the outbox messages that exhausted
+// their delivery attempts
+func runDeadLetters(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: adapter dead-letters list|show|edit|replay|delete")
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+	dead := repository.NewDeadLetters(repository.NewPostgresOutbox(db))
+	ctx := context.Background()
+
+	switch args[0] {
+	case "list":
+		return runDeadLettersList(ctx, dead, args[1:])
+	case "show":
+		id, err := deadLetterID(args[1:])
+		if err != nil {
+			return err
+		}
+		return runDeadLettersShow(ctx, dead, id)
+	case "edit":
+		return runDeadLettersEdit(ctx, dead, args[1:])
+	case "replay":
+		ids, err := deadLetterIDs(args[1:])
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := dead.Replay(ctx, id); err != nil {
+				return fmt.Errorf("failed to replay dead letter %d: %w", id, err)
+			}
+			fmt.Printf("Replayed dead letter %d\n", id)
+		}
+		return nil
+	case "delete":
+		ids, err := deadLetterIDs(args[1:])
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := dead.Delete(ctx, id); err != nil {
+				return fmt.Errorf("failed to delete dead letter %d: %w", id, err)
+			}
+			fmt.Printf("Deleted dead letter %d\n", id)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown dead-letters command %q", args[0])
+	}
+}
+
+// runDeadLettersList prints the dead letters, the oldest first
+func runDeadLettersList(ctx context.Context, dead *repository.DeadLetters, args []string) error {
+	fs := flag.NewFlagSet("dead-letters list", flag.ContinueOnError)
+	topic := fs.String("topic", "", "only list this topic")
+	replayed := fs.Bool("replayed", false, "include replayed letters")
+	limit := fs.Int("limit", 100, "letters listed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	letters, err := dead.List(ctx, repository.DeadLetterFilter{Topic: *topic, Replayed: *replayed, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	if len(letters) == 0 {
+		fmt.Println("No dead letters")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTOPIC\tATTEMPTS\tFAILED AT\tREPLAYED\tLAST ERROR")
+	for _, l := range letters {
+		replayedAt := "-"
+		if l.ReplayedAt != nil {
+			replayedAt = l.ReplayedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n", l.ID, l.Topic, l.Attempts, l.FailedAt.Format(time.RFC3339), replayedAt, l.LastError)
+	}
+	return tw.Flush()
+}
+
+// runDeadLettersShow prints one dead letter with its payload; binary
+// payloads are printed base64-encoded
+func runDeadLettersShow(ctx context.Context, dead *repository.DeadLetters, id int64) error {
+	l, err := dead.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ID:           %d\n", l.ID)
+	fmt.Printf("Outbox ID:    %d\n", l.OutboxID)
+	fmt.Printf("Topic:        %s\n", l.Topic)
+	fmt.Printf("Content type: %s\n", l.ContentType)
+	fmt.Printf("Attempts:     %d\n", l.Attempts)
+	fmt.Printf("Failed at:    %s\n", l.FailedAt.Format(time.RFC3339))
+	if l.ReplayedAt != nil {
+		fmt.Printf("Replayed at:  %s\n", l.ReplayedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("Last error:   %s\n", l.LastError)
+	if l.ContentType == repository.JSONContentType {
+		fmt.Printf("Payload:\n%s\n", l.Payload)
+	} else {
+		fmt.Printf("Payload (base64):\n%s\n", base64.StdEncoding.EncodeToString(l.Payload))
+	}
+	return nil
+}
+
+// runDeadLettersEdit replaces a dead letter's payload with a file's
+// contents, e.g. after fixing what its consumer rejected
+func runDeadLettersEdit(ctx context.Context, dead *repository.DeadLetters, args []string) error {
+	fs := flag.NewFlagSet("dead-letters edit", flag.ContinueOnError)
+	file := fs.String("payload", "", "file holding the new payload")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	id, err := deadLetterID(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("usage: adapter dead-letters edit -payload <file> <id>")
+	}
+
+	payload, err := os.ReadFile(*file)
+	if err != nil {
+		return fmt.Errorf("failed to read payload: %w", err)
+	}
+	if err := dead.Edit(ctx, id, payload); err != nil {
+		return err
+	}
+	fmt.Printf("Updated dead letter %d; replay it with `adapter dead-letters replay %d`\n", id, id)
+	return nil
+}
+
+// deadLetterID parses the single dead letter ID in args
+func deadLetterID(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected one dead letter ID")
+	}
+	ids, err := deadLetterIDs(args)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// deadLetterIDs parses the dead letter IDs in args
+func deadLetterIDs(args []string) ([]int64, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected dead letter IDs")
+	}
+	ids := make([]int64, len(args))
+	for i, a := range args {
+		id, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dead letter ID %q", a)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
@@ -0,0 +1,316 @@
+// Package degrade keeps optional dependencies, e.g. the password breach
+// API, from failing user requests. Under the Bypass policy a call to a
+// failing dependency takes its fallback instead, e.g. skipping the check,
+// and the dependency is reported degraded until a retry succeeds.
+package degrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"project/clock"
+	"project/metrics"
+)
+
+// Policy is what a call does when its dependency fails
+type Policy int
+
+// Policies
+const (
+	// Fail returns the dependency's error to the caller
+	Fail Policy = iota
+	// Bypass runs the call's fallback and skips the dependency until
+	// Monitor.RetryAfter has passed
+	Bypass
+)
+
+// ParsePolicy parses "fail" or "bypass"
+func ParsePolicy(s string) (Policy, error) {
+	switch s {
+	case "fail":
+		return Fail, nil
+	case "bypass":
+		return Bypass, nil
+	}
+	return 0, fmt.Errorf("unknown degradation policy %q", s)
+}
+
+// String returns the name ParsePolicy accepts
+func (p Policy) String() string {
+	switch p {
+	case Fail:
+		return "fail"
+	case Bypass:
+		return "bypass"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicies parses comma separated dependency=policy pairs, e.g.
+// "breach_check=bypass,schema_registry=fail"
+func ParsePolicies(s string) (map[string]Policy, error) {
+	policies := make(map[string]Policy)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid policy %q, want dependency=policy", pair)
+		}
+		p, err := ParsePolicy(value)
+		if err != nil {
+			return nil, err
+		}
+		policies[name] = p
+	}
+	return policies, nil
+}
+
+// Monitor applies the policies of dependencies to calls and tracks their
+// health. Dependencies without a policy Fail.
+type Monitor struct {
+	mu       sync.Mutex
+	policies map[string]Policy
+	deps     map[string]*dependency
+
+	// RetryAfter is how long a bypassed dependency is skipped before a call
+	// tries it again
+	RetryAfter time.Duration
+	// Clock times failures and retries; the system clock when nil
+	Clock clock.Clock
+	// Logf reports dependencies failing and recovering when set
+	Logf func(format string, args ...any)
+}
+
+// dependency is the health of one dependency
+type dependency struct {
+	calls, failures, bypassed uint64
+	err                       error
+	since, retryAt            time.Time
+}
+
+// NewMonitor creates a monitor applying policies, retrying bypassed
+// dependencies every five seconds
+func NewMonitor(policies map[string]Policy) *Monitor {
+	if policies == nil {
+		policies = make(map[string]Policy)
+	}
+	return &Monitor{policies: policies, deps: make(map[string]*dependency), RetryAfter: 5 * time.Second}
+}
+
+// Policy returns the policy of the dependency name
+func (m *Monitor) Policy(name string) Policy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.policies[name]
+}
+
+// Call runs primary against the dependency name. When it fails under
+// Bypass, fallback runs instead and its error, if any, is returned; until
+// RetryAfter has passed later calls run fallback without trying primary. A
+// nil fallback just skips the dependency. Under Fail the error of primary
+// is returned. Errors from ctx ending don't count as failures.
+func (m *Monitor) Call(ctx context.Context, name string, primary, fallback func(ctx context.Context) error) error {
+	now := clock.Or(m.Clock).Now()
+	m.mu.Lock()
+	policy := m.policies[name]
+	d := m.dep(name)
+	d.calls++
+	skip := policy == Bypass && d.err != nil && now.Before(d.retryAt)
+	if policy == Bypass && d.err != nil && !skip {
+		// one caller retries; the others keep bypassing meanwhile
+		d.retryAt = now.Add(m.RetryAfter)
+	}
+	if skip {
+		d.bypassed++
+	}
+	m.mu.Unlock()
+
+	if skip {
+		return runFallback(ctx, fallback)
+	}
+
+	err := primary(ctx)
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+	m.record(name, err, now)
+	if err == nil || policy == Fail {
+		return err
+	}
+
+	m.mu.Lock()
+	d.bypassed++
+	m.mu.Unlock()
+	return runFallback(ctx, fallback)
+}
+
+func runFallback(ctx context.Context, fallback func(ctx context.Context) error) error {
+	if fallback == nil {
+		return nil
+	}
+	return fallback(ctx)
+}
+
+// Report records the outcome of a call made without Call, e.g. a health
+// check, nil meaning the dependency works
+func (m *Monitor) Report(name string, err error) {
+	m.record(name, err, clock.Or(m.Clock).Now())
+}
+
+// record updates the health of name after a call at now
+func (m *Monitor) record(name string, err error, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.dep(name)
+	switch {
+	case err != nil:
+		d.failures++
+		if d.err == nil {
+			d.since = now
+			m.logf("Dependency %s failed, policy %s: %v", name, m.policies[name], err)
+		}
+		d.err = err
+		d.retryAt = now.Add(m.RetryAfter)
+	case d.err != nil:
+		m.logf("Dependency %s recovered after %s", name, now.Sub(d.since).Round(time.Second))
+		d.err, d.since = nil, now
+	}
+}
+
+// dep returns the state of name, creating it; m.mu is held
+func (m *Monitor) dep(name string) *dependency {
+	d, ok := m.deps[name]
+	if !ok {
+		d = &dependency{}
+		m.deps[name] = d
+	}
+	return d
+}
+
+func (m *Monitor) logf(format string, args ...any) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+	}
+}
+
+// Status is the health of a dependency
+type Status struct {
+	Name    string `json:"name"`
+	Policy  string `json:"policy"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	// Since is when the dependency last changed health
+	Since    time.Time `json:"since"`
+	Bypassed uint64    `json:"bypassed"`
+}
+
+// Status returns the health of every dependency called so far, by name
+func (m *Monitor) Status() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Status, 0, len(m.deps))
+	for name, d := range m.deps {
+		s := Status{Name: name, Policy: m.policies[name].String(), Healthy: d.err == nil, Since: d.since, Bypassed: d.bypassed}
+		if d.err != nil {
+			s.Error = d.err.Error()
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Overall health states
+const (
+	StateOK       = "ok"
+	StateDegraded = "degraded"
+	StateDown     = "down"
+)
+
+// State summarizes the statuses: down if a dependency under Fail is
+// failing, degraded if only bypassed ones are, ok otherwise
+func State(statuses []Status) string {
+	state := StateOK
+	for _, s := range statuses {
+		switch {
+		case s.Healthy:
+		case s.Policy == Bypass.String():
+			state = StateDegraded
+		default:
+			return StateDown
+		}
+	}
+	return state
+}
+
+// Handler serves the health of the dependencies as JSON, with status 503
+// when they're down; a degraded instance still serves requests, so it
+// answers 200
+func (m *Monitor) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		statuses := m.Status()
+		state := State(statuses)
+
+		w.Header().Set("Content-Type", "application/json")
+		if state == StateDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(struct {
+			Status       string   `json:"status"`
+			Dependencies []Status `json:"dependencies"`
+		}{state, statuses})
+	})
+}
+
+// Collect implements metrics.Collector, reporting each dependency labelled
+// with its name and policy
+func (m *Monitor) Collect() []metrics.Family {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.deps))
+	for name := range m.deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dep := []struct {
+		name  string
+		kind  metrics.Type
+		help  string
+		value func(*dependency) float64
+	}{
+		{"up", metrics.Gauge, "1 when the dependency's last call succeeded.", func(d *dependency) float64 {
+			if d.err != nil {
+				return 0
+			}
+			return 1
+		}},
+		{"calls", metrics.Counter, "Calls to the dependency.", func(d *dependency) float64 { return float64(d.calls) }},
+		{"failures", metrics.Counter, "Calls the dependency failed.", func(d *dependency) float64 { return float64(d.failures) }},
+		{"bypassed", metrics.Counter, "Calls served by the fallback instead.", func(d *dependency) float64 { return float64(d.bypassed) }},
+	}
+
+	families := make([]metrics.Family, 0, len(dep))
+	for _, metric := range dep {
+		f := metrics.Family{Name: metrics.Name("dependency", metric.name, ""), Help: metric.help, Type: metric.kind}
+		for _, name := range names {
+			f.Samples = append(f.Samples, metrics.Sample{
+				Labels: []metrics.Label{{Name: "dependency", Value: name}, {Name: "policy", Value: m.policies[name].String()}},
+				Value:  metric.value(m.deps[name]),
+			})
+		}
+		families = append(families, f)
+	}
+	return families
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"project/config"
+	"project/migrations"
+	"project/models"
+	"project/repository"
+)
+
+// maxClockSkew is the largest app/database clock difference doctor accepts
+const maxClockSkew = 2 * time.Second
+
+// doctorCheck is one line of the `adapter doctor` report
+type doctorCheck struct {
+	Name   string
+	Err    error
+	Detail string
+	// Hint tells the operator how to fix a failed check
+	Hint string
+}
+
+// runDoctor handles `adapter doctor`, verifying config, connectivity,
+// permissions, migration status and clock skew
+func runDoctor() error {
+	ctx := context.Background()
+	var checks []doctorCheck
+
+	source := "built-in defaults"
+	switch {
+	case os.Getenv(configPathEnv) != "":
+		source = os.Getenv(configPathEnv)
+	case os.Getenv(config.DatabaseURLEnv) != "":
+		source = config.DatabaseURLEnv
+	}
+
+	conns, err := newConnectionManager()
+	checks = append(checks, doctorCheck{
+		Name:   "config",
+		Err:    err,
+		Detail: "loaded from " + source,
+		Hint:   fmt.Sprintf("check %s or %s", configPathEnv, config.DatabaseURLEnv),
+	})
+	if err != nil {
+		return printDoctorReport(checks)
+	}
+	defer conns.Close()
+
+	checks = append(checks, doctorCheck{
+		Name:   "models",
+		Err:    repository.ValidateModels(models.All()...),
+		Detail: "db tags valid",
+		Hint:   "fix the db struct tags named above",
+	})
+
+	db, err := conns.Get(ctx, primaryDatabase)
+	checks = append(checks, doctorCheck{
+		Name:   "connectivity",
+		Err:    err,
+		Detail: "database reachable",
+		Hint:   "check host, port, socket, credentials and that the database is running",
+	})
+	if err != nil {
+		return printDoctorReport(checks)
+	}
+
+	checks = append(checks,
+		doctorCheck{
+			Name:   "permissions",
+			Err:    checkWritePermissions(ctx, db),
+			Detail: "CREATE TABLE and INSERT allowed",
+			Hint:   "grant the user CREATE on the schema and INSERT on its tables",
+		},
+		checkMigrations(db),
+		checkClockSkew(ctx, db),
+	)
+	return printDoctorReport(checks)
+}
+
+// checkWritePermissions creates, fills and drops a scratch table inside a
+// transaction that is always rolled back. MySQL commits DDL implicitly, so
+// the table is also dropped outside the transaction in case a statement
+// after its CREATE failed.
+func checkWritePermissions(ctx context.Context, db *sql.DB) error {
+	defer db.ExecContext(context.WithoutCancel(ctx), "DROP TABLE IF EXISTS adapter_doctor_check")
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		"CREATE TABLE adapter_doctor_check (id INTEGER)",
+		"INSERT INTO adapter_doctor_check (id) VALUES (1)",
+		"DROP TABLE adapter_doctor_check",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to run %q: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// checkMigrations reports versioned migrations not yet applied
+func checkMigrations(db *sql.DB) doctorCheck {
+	check := doctorCheck{Name: "migrations", Hint: "run `adapter migrate up`"}
+
+	pending, err := repository.NewMigrator(db).PendingMigrations(migrations.FS)
+	switch {
+	case err != nil:
+		check.Err = err
+	case len(pending) > 0:
+		check.Err = fmt.Errorf("%d pending, first %d_%s", len(pending), pending[0].Version, pending[0].Name)
+	default:
+		check.Detail = "up to date"
+	}
+	return check
+}
+
+// checkClockSkew compares the database clock against the local one,
+// allowing for the query's round trip
+func checkClockSkew(ctx context.Context, db *sql.DB) doctorCheck {
+	check := doctorCheck{Name: "clock skew", Hint: "enable NTP on the app and database hosts"}
+
+	before := time.Now()
+	var dbNow time.Time
+	if err := db.QueryRowContext(ctx, "SELECT CURRENT_TIMESTAMP").Scan(&dbNow); err != nil {
+		check.Err = fmt.Errorf("failed to read database clock: %w", err)
+		return check
+	}
+	rtt := time.Since(before)
+
+	skew := dbNow.Sub(before.Add(rtt / 2))
+	if skew < 0 {
+		skew = -skew
+	}
+	skew = skew.Round(time.Millisecond)
+
+	if skew > maxClockSkew+rtt/2 {
+		check.Err = fmt.Errorf("database clock is %s off", skew)
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s (round trip %s)", skew, rtt.Round(time.Millisecond))
+	return check
+}
+
+// printDoctorReport prints checks, failing when any failed
+func printDoctorReport(checks []doctorCheck) error {
+	failed := 0
+	for _, c := range checks {
+		if c.Err == nil {
+			fmt.Printf("[ OK ] %-12s %s\n", c.Name, c.Detail)
+			continue
+		}
+		failed++
+		fmt.Printf("[FAIL] %-12s %v\n", c.Name, c.Err)
+		fmt.Printf("       %-12s fix: %s\n", "", c.Hint)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("doctor found %d problem(s)", failed)
+	}
+	fmt.Println("All checks passed")
+	return nil
+}
@@ -0,0 +1,274 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"project/metrics"
+)
+
+// Overflow is what a buffered subscription does with an event published
+// while its buffer is full
+type Overflow int
+
+// Overflow policies
+const (
+	// Block makes Publish wait for room, up to BufferOptions.BlockTimeout,
+	// so a slow subscriber slows its publishers down instead of growing
+	Block Overflow = iota
+	// DropOldest discards the oldest buffered event to make room
+	DropOldest
+	// Reject discards the new event and returns ErrBufferFull from Publish
+	Reject
+)
+
+// ParseOverflow parses "block", "drop-oldest" or "error"
+func ParseOverflow(s string) (Overflow, error) {
+	switch s {
+	case "block":
+		return Block, nil
+	case "drop-oldest":
+		return DropOldest, nil
+	case "error":
+		return Reject, nil
+	}
+	return 0, fmt.Errorf("unknown overflow policy %q", s)
+}
+
+// String returns the name ParseOverflow accepts
+func (o Overflow) String() string {
+	switch o {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	case Reject:
+		return "error"
+	}
+	return fmt.Sprintf("Overflow(%d)", int(o))
+}
+
+// ErrBufferFull is returned by Publish, and reported through Bus.OnError,
+// for each event a buffered subscription couldn't take
+var ErrBufferFull = errors.New("subscriber buffer full")
+
+// ErrSubscriptionClosed is reported for events published to a closed
+// subscription
+var ErrSubscriptionClosed = errors.New("subscription closed")
+
+// DefaultBufferSize is the buffer of a subscription without a size
+const DefaultBufferSize = 1024
+
+// BufferOptions configures a buffered subscription
+type BufferOptions struct {
+	// Name labels the subscription's metrics; it should be unique on the bus
+	Name string
+	// Size is how many events wait for the handler; DefaultBufferSize when 0
+	Size     int
+	Overflow Overflow
+	// BlockTimeout bounds the wait of Block, after which the event is
+	// rejected as under Reject; 0 waits as long as the publisher's context
+	// allows
+	BlockTimeout time.Duration
+}
+
+// Subscription is a handler fed from a bounded buffer by its own
+// goroutine, so it runs behind its publishers instead of in their call.
+// Events reach it in publish order.
+type Subscription struct {
+	name    string
+	handler Handler
+	opts    BufferOptions
+	bus     *Bus
+
+	// mu guards closing queue against publishers sending on it
+	mu     sync.RWMutex
+	closed bool
+	queue  chan queued
+	done   chan struct{}
+	// closing releases publishers blocked on a full queue, so Close gets mu
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	delivered, failed, dropped, rejected atomic.Uint64
+	blocked                              atomic.Int64
+}
+
+// queued is an event waiting in a buffer
+type queued struct {
+	ctx context.Context
+	ev  Event
+}
+
+// SubscribeBuffered registers h for events of eventType, or every event
+// when eventType is "", behind a buffer of opts.Size events. The handler
+// runs on its own goroutine with the publisher's context values but not
+// its cancellation, since the publisher has usually returned by then.
+func (b *Bus) SubscribeBuffered(eventType string, h Handler, opts BufferOptions) *Subscription {
+	if opts.Size <= 0 {
+		opts.Size = DefaultBufferSize
+	}
+	s := &Subscription{
+		name:    opts.Name,
+		handler: h,
+		opts:    opts,
+		bus:     b,
+		queue:   make(chan queued, opts.Size),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+	go s.run()
+
+	b.mu.Lock()
+	b.buffered = append(b.buffered, s)
+	b.mu.Unlock()
+	b.Subscribe(eventType, s.enqueue)
+	return s
+}
+
+// run delivers buffered events until the subscription is closed and drained
+func (s *Subscription) run() {
+	defer close(s.done)
+	for q := range s.queue {
+		if err := s.handler(q.ctx, q.ev); err != nil {
+			s.failed.Add(1)
+			s.bus.fail(q.ev, err)
+			continue
+		}
+		s.delivered.Add(1)
+	}
+}
+
+// enqueue is the subscription's handler on the bus, buffering ev under the
+// overflow policy
+func (s *Subscription) enqueue(ctx context.Context, ev Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrSubscriptionClosed
+	}
+
+	q := queued{ctx: context.WithoutCancel(ctx), ev: ev}
+	select {
+	case s.queue <- q:
+		return nil
+	default:
+	}
+
+	switch s.opts.Overflow {
+	case DropOldest:
+		for {
+			select {
+			case s.queue <- q:
+				return nil
+			default:
+			}
+			select {
+			case <-s.queue:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	case Block:
+		start := time.Now()
+		defer func() { s.blocked.Add(int64(time.Since(start))) }()
+
+		var timeout <-chan time.Time
+		if s.opts.BlockTimeout > 0 {
+			t := time.NewTimer(s.opts.BlockTimeout)
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case s.queue <- q:
+			return nil
+		case <-timeout:
+		case <-ctx.Done():
+		case <-s.closing:
+			return ErrSubscriptionClosed
+		}
+	}
+	s.rejected.Add(1)
+	return fmt.Errorf("%s: %w", s.name, ErrBufferFull)
+}
+
+// Len returns how many events are waiting in the buffer
+func (s *Subscription) Len() int {
+	return len(s.queue)
+}
+
+// Close stops taking events and waits, until ctx is done, for the handler
+// to finish the buffered ones. Publishers blocked on a full buffer give up
+// first, so Close doesn't wait on them.
+func (s *Subscription) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closing) })
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d events of %s left undelivered: %w", s.Len(), s.name, ctx.Err())
+	}
+}
+
+// Close closes the bus's buffered subscriptions, draining them until ctx
+// is done. Synchronous handlers are unaffected.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.RLock()
+	subs := append([]*Subscription(nil), b.buffered...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, s := range subs {
+		errs = append(errs, s.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Collect implements metrics.Collector, reporting the buffer of each
+// buffered subscription labelled with its name
+func (b *Bus) Collect() []metrics.Family {
+	b.mu.RLock()
+	subs := append([]*Subscription(nil), b.buffered...)
+	b.mu.RUnlock()
+
+	buffer := []struct {
+		name, unit string
+		kind       metrics.Type
+		help       string
+		value      func(*Subscription) float64
+	}{
+		{"buffer_length", "", metrics.Gauge, "Events waiting in the subscriber buffer.", func(s *Subscription) float64 { return float64(s.Len()) }},
+		{"buffer_capacity", "", metrics.Gauge, "Size of the subscriber buffer.", func(s *Subscription) float64 { return float64(s.opts.Size) }},
+		{"delivered", "", metrics.Counter, "Events the subscriber handled.", func(s *Subscription) float64 { return float64(s.delivered.Load()) }},
+		{"failed", "", metrics.Counter, "Events the subscriber returned an error for.", func(s *Subscription) float64 { return float64(s.failed.Load()) }},
+		{"dropped", "", metrics.Counter, "Buffered events discarded for newer ones.", func(s *Subscription) float64 { return float64(s.dropped.Load()) }},
+		{"rejected", "", metrics.Counter, "Events discarded because the buffer was full.", func(s *Subscription) float64 { return float64(s.rejected.Load()) }},
+		{"blocked", "seconds", metrics.Counter, "Time publishers waited for buffer room.", func(s *Subscription) float64 {
+			return time.Duration(s.blocked.Load()).Seconds()
+		}},
+	}
+
+	families := make([]metrics.Family, 0, len(buffer))
+	for _, metric := range buffer {
+		f := metrics.Family{Name: metrics.Name("events", metric.name, metric.unit), Help: metric.help, Type: metric.kind, Unit: metric.unit}
+		for _, s := range subs {
+			f.Samples = append(f.Samples, metrics.Sample{
+				Labels: []metrics.Label{{Name: "subscriber", Value: s.name}, {Name: "overflow", Value: s.opts.Overflow.String()}},
+				Value:  metric.value(s),
+			})
+		}
+		families = append(families, f)
+	}
+	return families
+}
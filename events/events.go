@@ -0,0 +1,90 @@
+// Package events is an in-process bus for domain events, e.g. a user being
+// renamed, that read models and integrations subscribe to
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types published by the service
+const (
+	UserRegistered = "user.registered"
+	UserUpdated    = "user.updated"
+	UserDeleted    = "user.deleted"
+	UserErased     = "user.erased"
+	MemberAdded    = "member.added"
+	MemberRemoved  = "member.removed"
+)
+
+// Event is something that happened to an entity
+type Event struct {
+	Type string
+	// Subject is the ID of the entity the event is about, 0 when it isn't
+	// known yet
+	Subject  int
+	TenantID string
+	Data     map[string]any
+	At       time.Time
+}
+
+// Handler reacts to an event
+type Handler func(ctx context.Context, ev Event) error
+
+// Bus delivers published events to its subscribers synchronously, in
+// subscription order. A failing handler doesn't stop the others. Slow
+// subscribers go behind a bounded buffer with SubscribeBuffered.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+	buffered []*Subscription
+
+	// OnError is called with each handler error; nil logs it
+	OnError func(ev Event, err error)
+}
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe registers h for events of eventType, or for every event when
+// eventType is ""
+func (b *Bus) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+// Publish delivers ev to the handlers subscribed to its type, then to
+// those subscribed to every event. Handler errors go to OnError; those of
+// buffered subscriptions that didn't take ev, ErrBufferFull or
+// ErrSubscriptionClosed, are also returned, so publishers learn the event
+// was lost.
+func (b *Bus) Publish(ctx context.Context, ev Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler(nil), b.handlers[ev.Type]...), b.handlers[""]...)
+	b.mu.RUnlock()
+
+	var lost []error
+	for _, h := range handlers {
+		if err := h(ctx, ev); err != nil {
+			b.fail(ev, err)
+			if errors.Is(err, ErrBufferFull) || errors.Is(err, ErrSubscriptionClosed) {
+				lost = append(lost, err)
+			}
+		}
+	}
+	return errors.Join(lost...)
+}
+
+func (b *Bus) fail(ev Event, err error) {
+	if b.OnError != nil {
+		b.OnError(ev, err)
+		return
+	}
+	log.Printf("Failed to handle %s event: %v", ev.Type, err)
+}
@@ -0,0 +1,96 @@
+// Package export streams the user table to bulk consumers over HTTP
+package export
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"project/apperr"
+	"project/models"
+	"project/service"
+)
+
+// CursorParam is the query parameter resuming an export
+const CursorParam = "cursor"
+
+// Record is one line of an export stream
+type Record struct {
+	// Cursor resumes the export after this record
+	Cursor string `json:"cursor,omitempty"`
+	User   *User  `json:"user,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Done   bool   `json:"done,omitempty"`
+}
+
+// User is the exported form of a user
+type User struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// flushEvery is how many records are written between flushes
+const flushEvery = 100
+
+// Handler streams every user as newline-delimited JSON records, in ID
+// order, flushing as it goes so consumers can process the export while it
+// runs. The stream ends with a record marked done; if it stops early, e.g.
+// on a database error, it ends with an error record instead and the
+// consumer resumes from the last cursor it saw with ?cursor=.
+func Handler(users *service.UserService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		started := false
+		n := 0
+
+		err := users.WithContext(r.Context()).ExportUsers(r.URL.Query().Get(CursorParam), func(u models.User, next string) error {
+			if !started {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				started = true
+			}
+			if err := enc.Encode(Record{Cursor: next, User: &User{
+				ID:        u.ID,
+				Name:      u.Name,
+				Email:     u.Email,
+				TenantID:  u.TenantID,
+				CreatedAt: u.CreatedAt,
+				UpdatedAt: u.UpdatedAt,
+			}}); err != nil {
+				return err
+			}
+			if n++; n%flushEvery == 0 && flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+
+		if err != nil && !started {
+			code := apperr.CodeOf(err)
+			msg := http.StatusText(apperr.HTTPStatus(code))
+			if code == apperr.InvalidArgument {
+				msg = err.Error()
+			}
+			http.Error(w, msg, apperr.HTTPStatus(code))
+			return
+		}
+		if r.Context().Err() != nil {
+			// the consumer went away
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		if err != nil {
+			enc.Encode(Record{Error: http.StatusText(apperr.HTTPStatus(apperr.CodeOf(err)))})
+		} else {
+			enc.Encode(Record{Done: true})
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+}
@@ -0,0 +1,62 @@
+// Package flags evaluates feature flags from pluggable providers with
+// per-tenant overrides.
+package flags
+
+import (
+	"context"
+	"log"
+
+	"project/tenant"
+)
+
+// Flag names used by the service layer
+const (
+	// FullTextSearch gates UserService.SearchUsers
+	FullTextSearch = "full_text_search"
+)
+
+// Provider looks up flag values. tenant is "" for the global value; ok is
+// false when the provider doesn't set the flag.
+type Provider interface {
+	Lookup(ctx context.Context, flag, tenant string) (enabled, ok bool, err error)
+}
+
+// Set evaluates flags against its providers in order, falling back to
+// defaults
+type Set struct {
+	providers []Provider
+	defaults  map[string]bool
+}
+
+// New creates a Set. Earlier providers take precedence over later ones.
+func New(defaults map[string]bool, providers ...Provider) *Set {
+	return &Set{providers: providers, defaults: defaults}
+}
+
+// Enabled reports whether flag is on for the tenant in ctx. Within each
+// provider a tenant override beats the global value. Provider errors are
+// logged and the next provider consulted.
+func (s *Set) Enabled(ctx context.Context, flag string) bool {
+	if s == nil {
+		return false
+	}
+
+	scopes := []string{""}
+	if t := tenant.FromContext(ctx); t != "" {
+		scopes = []string{t, ""}
+	}
+
+	for _, p := range s.providers {
+		for _, scope := range scopes {
+			enabled, ok, err := p.Lookup(ctx, flag, scope)
+			if err != nil {
+				log.Printf("Failed to look up flag %s: %v", flag, err)
+				break
+			}
+			if ok {
+				return enabled
+			}
+		}
+	}
+	return s.defaults[flag]
+}
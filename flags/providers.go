@@ -0,0 +1,110 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EnvProvider reads flags from environment variables: <Prefix><FLAG> for
+// the global value and <Prefix><FLAG>__<TENANT> for a tenant override,
+// upper-cased, e.g. FLAG_FULL_TEXT_SEARCH__ACME=false
+type EnvProvider struct {
+	Prefix string
+}
+
+// NewEnvProvider creates an EnvProvider with the FLAG_ prefix
+func NewEnvProvider() EnvProvider {
+	return EnvProvider{Prefix: "FLAG_"}
+}
+
+// Lookup implements Provider
+func (p EnvProvider) Lookup(ctx context.Context, flag, tenant string) (bool, bool, error) {
+	name := p.Prefix + flag
+	if tenant != "" {
+		name += "__" + tenant
+	}
+
+	value, ok := os.LookupEnv(strings.ToUpper(name))
+	if !ok {
+		return false, false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, false, fmt.Errorf("invalid value %q for %s", value, name)
+	}
+	return enabled, true, nil
+}
+
+// FlagConfig is one flag in a StaticProvider
+type FlagConfig struct {
+	Enabled bool            `json:"enabled"`
+	Tenants map[string]bool `json:"tenants"`
+}
+
+// StaticProvider serves flags from memory, typically loaded from a config
+// file. It is safe to Replace concurrently with lookups.
+type StaticProvider struct {
+	mu    sync.RWMutex
+	flags map[string]FlagConfig
+}
+
+// NewStaticProvider creates a StaticProvider serving flags
+func NewStaticProvider(flags map[string]FlagConfig) *StaticProvider {
+	return &StaticProvider{flags: flags}
+}
+
+// LoadFile reads a JSON file of the form
+// {"flags": {"name": {"enabled": true, "tenants": {"acme": false}}}}
+func LoadFile(path string) (*StaticProvider, error) {
+	p := NewStaticProvider(nil)
+	if err := p.Reload(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload replaces the flags with those in the JSON file at path
+func (p *StaticProvider) Reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read flags %s: %w", path, err)
+	}
+
+	var file struct {
+		Flags map[string]FlagConfig `json:"flags"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse flags %s: %w", path, err)
+	}
+
+	p.Replace(file.Flags)
+	return nil
+}
+
+// Replace swaps in a new set of flags
+func (p *StaticProvider) Replace(flags map[string]FlagConfig) {
+	p.mu.Lock()
+	p.flags = flags
+	p.mu.Unlock()
+}
+
+// Lookup implements Provider
+func (p *StaticProvider) Lookup(ctx context.Context, flag, tenant string) (bool, bool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	cfg, ok := p.flags[flag]
+	if !ok {
+		return false, false, nil
+	}
+	if tenant == "" {
+		return cfg.Enabled, true, nil
+	}
+	enabled, ok := cfg.Tenants[tenant]
+	return enabled, ok, nil
+}
@@ -0,0 +1,38 @@
+package flags
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SQLProvider reads flags from the feature_flags table, one row per flag
+// and tenant, with an empty tenant for the global value
+type SQLProvider struct {
+	db    *sql.DB
+	query string
+}
+
+// NewPostgresProvider creates a SQLProvider for PostgreSQL
+func NewPostgresProvider(db *sql.DB) *SQLProvider {
+	return &SQLProvider{db: db, query: "SELECT enabled FROM feature_flags WHERE name = $1 AND tenant = $2"}
+}
+
+// NewMySQLProvider creates a SQLProvider for MySQL
+func NewMySQLProvider(db *sql.DB) *SQLProvider {
+	return &SQLProvider{db: db, query: "SELECT enabled FROM feature_flags WHERE name = ? AND tenant = ?"}
+}
+
+// Lookup implements Provider
+func (p *SQLProvider) Lookup(ctx context.Context, flag, tenant string) (bool, bool, error) {
+	var enabled bool
+	err := p.db.QueryRowContext(ctx, p.query, flag, tenant).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to query feature flag: %w", err)
+	}
+	return enabled, true, nil
+}
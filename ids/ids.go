@@ -0,0 +1,55 @@
+// Package ids abstracts the generation of random identifiers and tokens so
+// they can be made deterministic in tests.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// Source generates unique string identifiers
+type Source interface {
+	NewID() (string, error)
+}
+
+// Random is the real Source: 128 random bits, hex encoded
+var Random Source = randomSource{}
+
+// randomSource reads crypto/rand
+type randomSource struct{}
+
+// NewID implements Source
+func (randomSource) NewID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// Or returns s, or Random when s is nil
+func Or(s Source) Source {
+	if s == nil {
+		return Random
+	}
+	return s
+}
+
+// Sequence is a deterministic Source returning Prefix followed by 1, 2, 3...
+// It is safe for concurrent use.
+type Sequence struct {
+	Prefix string
+
+	mu sync.Mutex
+	n  int
+}
+
+// NewID implements Source
+func (s *Sequence) NewID() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	return fmt.Sprintf("%s%d", s.Prefix, s.n), nil
+}
@@ -0,0 +1,97 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Checkpoint is the progress of an import after its last completed batch
+type Checkpoint struct {
+	Job string
+	// Offset is the byte offset in the input after the batch
+	Offset int64
+	// Batch is the number of batches completed
+	Batch int
+	// Created, Duplicates and Invalid total the batches' results
+	Created    int64
+	Duplicates int64
+	Invalid    int64
+	// UpdatedAt is when the batch completed; users created since then may
+	// come from the next batch of an interrupted run
+	UpdatedAt time.Time
+}
+
+// CheckpointStore persists checkpoints by job
+type CheckpointStore interface {
+	// Load returns the job's checkpoint, and false if it has none
+	Load(ctx context.Context, job string) (Checkpoint, bool, error)
+	Save(ctx context.Context, cp Checkpoint) error
+	Delete(ctx context.Context, job string) error
+}
+
+// SQLStore keeps checkpoints in the import_checkpoints table
+type SQLStore struct {
+	db      *sql.DB
+	queries sqlQueries
+}
+
+// sqlQueries are the dialect's statements
+type sqlQueries struct {
+	load, save, delete string
+}
+
+// NewPostgresStore creates a SQLStore for PostgreSQL
+func NewPostgresStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, queries: sqlQueries{
+		load: `SELECT job, "offset", batch, created, duplicates, invalid, updated_at FROM import_checkpoints WHERE job = $1`,
+		save: `INSERT INTO import_checkpoints (job, "offset", batch, created, duplicates, invalid, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (job) DO UPDATE SET "offset" = EXCLUDED."offset", batch = EXCLUDED.batch, created = EXCLUDED.created,
+			duplicates = EXCLUDED.duplicates, invalid = EXCLUDED.invalid, updated_at = EXCLUDED.updated_at`,
+		delete: "DELETE FROM import_checkpoints WHERE job = $1",
+	}}
+}
+
+// NewMySQLStore creates a SQLStore for MySQL
+func NewMySQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, queries: sqlQueries{
+		load: "SELECT job, `offset`, batch, created, duplicates, invalid, updated_at FROM import_checkpoints WHERE job = ?",
+		save: "INSERT INTO import_checkpoints (job, `offset`, batch, created, duplicates, invalid, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)" +
+			" ON DUPLICATE KEY UPDATE `offset` = VALUES(`offset`), batch = VALUES(batch), created = VALUES(created)," +
+			" duplicates = VALUES(duplicates), invalid = VALUES(invalid), updated_at = VALUES(updated_at)",
+		delete: "DELETE FROM import_checkpoints WHERE job = ?",
+	}}
+}
+
+// Load implements CheckpointStore
+func (s *SQLStore) Load(ctx context.Context, job string) (Checkpoint, bool, error) {
+	var cp Checkpoint
+	err := s.db.QueryRowContext(ctx, s.queries.load, job).Scan(
+		&cp.Job, &cp.Offset, &cp.Batch, &cp.Created, &cp.Duplicates, &cp.Invalid, &cp.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to load import checkpoint: %w", err)
+	}
+	return cp, true, nil
+}
+
+// Save implements CheckpointStore
+func (s *SQLStore) Save(ctx context.Context, cp Checkpoint) error {
+	if _, err := s.db.ExecContext(ctx, s.queries.save, cp.Job, cp.Offset, cp.Batch, cp.Created, cp.Duplicates, cp.Invalid,
+		cp.UpdatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save import checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Delete implements CheckpointStore
+func (s *SQLStore) Delete(ctx context.Context, job string) error {
+	if _, err := s.db.ExecContext(ctx, s.queries.delete, job); err != nil {
+		return fmt.Errorf("failed to delete import checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+// Package importer loads users in bulk from CSV and JSONL files, saving a
+// checkpoint after every batch so an interrupted import resumes where it
+// stopped instead of starting over.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"project/clock"
+	"project/models"
+	"project/service"
+)
+
+// Sink writes a batch of users. With a non-zero dedupeSince the batch may
+// have been partly written by an interrupted run, and users created since
+// then must not be created again.
+type Sink func(ctx context.Context, users []models.User, dedupeSince time.Time) (service.ImportResult, error)
+
+// ServiceSink writes batches through users.ImportUsers
+func ServiceSink(users *service.UserService) Sink {
+	return func(ctx context.Context, batch []models.User, dedupeSince time.Time) (service.ImportResult, error) {
+		return users.WithContext(ctx).ImportUsers(batch, dedupeSince)
+	}
+}
+
+// Importer runs imports by job name, resuming each from its checkpoint
+type Importer struct {
+	store CheckpointStore
+	sink  Sink
+
+	// BatchSize is the number of rows written between checkpoints
+	BatchSize int
+	// ClockSkew widens the window in which a replayed batch looks for
+	// users it already created, for a database clock behind Clock
+	ClockSkew time.Duration
+	// Clock stamps checkpoints; the system clock when nil
+	Clock clock.Clock
+	// Logf reports progress after every batch when set
+	Logf func(format string, args ...any)
+}
+
+// New creates an importer writing to sink with checkpoints in store
+func New(store CheckpointStore, sink Sink) *Importer {
+	return &Importer{store: store, sink: sink, BatchSize: 1000, ClockSkew: time.Minute}
+}
+
+// rowReader reads users from an input
+type rowReader interface {
+	// next returns the next user and the input offset after it, or io.EOF
+	next() (models.User, int64, error)
+}
+
+// ImportCSV imports r as CSV with a header row naming the name and,
+// optionally, email columns. A completed job keeps its checkpoint, so
+// running it again imports nothing; Reset starts it over.
+func (im *Importer) ImportCSV(ctx context.Context, job string, r io.ReadSeeker) (Checkpoint, error) {
+	return im.run(ctx, job, r, newCSVReader)
+}
+
+// ImportJSONL imports r as one JSON object per line, either a user with
+// name and email or an export record carrying one
+func (im *Importer) ImportJSONL(ctx context.Context, job string, r io.ReadSeeker) (Checkpoint, error) {
+	return im.run(ctx, job, r, newJSONLReader)
+}
+
+// Reset drops the job's checkpoint, so its next run starts from the top
+func (im *Importer) Reset(ctx context.Context, job string) error {
+	return im.store.Delete(ctx, job)
+}
+
+// run imports the rows open reads from r, starting at the job's checkpoint
+func (im *Importer) run(ctx context.Context, job string, r io.ReadSeeker, open func(io.ReadSeeker, int64) (rowReader, error)) (Checkpoint, error) {
+	now := clock.Or(im.Clock).Now
+	cp, resumed, err := im.store.Load(ctx, job)
+	if err != nil {
+		return cp, err
+	}
+
+	var dedupeSince time.Time
+	if resumed {
+		// the batch after the checkpoint may be partly written
+		dedupeSince = cp.UpdatedAt.Add(-im.ClockSkew)
+	} else {
+		// checkpoint before the first batch, so it is deduplicated too if
+		// this run is interrupted
+		cp = Checkpoint{Job: job, UpdatedAt: now()}
+		if err := im.store.Save(ctx, cp); err != nil {
+			return cp, err
+		}
+	}
+
+	rows, err := open(r, cp.Offset)
+	if err != nil {
+		return cp, err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return cp, err
+		}
+		batch, end, err := readBatch(rows, max(im.BatchSize, 1))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return cp, err
+		}
+		if len(batch) == 0 {
+			return cp, nil
+		}
+
+		result, werr := im.sink(ctx, batch, dedupeSince)
+		if werr != nil {
+			return cp, fmt.Errorf("failed to import batch %d: %w", cp.Batch+1, werr)
+		}
+		cp.Offset = end
+		cp.Batch++
+		cp.Created += int64(result.Created)
+		cp.Duplicates += int64(result.Duplicates)
+		cp.Invalid += int64(result.Invalid)
+		cp.UpdatedAt = now()
+		if err := im.store.Save(ctx, cp); err != nil {
+			return cp, err
+		}
+		dedupeSince = time.Time{}
+
+		if im.Logf != nil {
+			im.Logf("Import %s: batch %d done, %d created, %d duplicates, %d invalid", job, cp.Batch, cp.Created, cp.Duplicates, cp.Invalid)
+		}
+		if errors.Is(err, io.EOF) {
+			return cp, nil
+		}
+	}
+}
+
+// readBatch reads up to n users and returns them with the offset after the
+// last; the error is io.EOF when the input ended
+func readBatch(rows rowReader, n int) ([]models.User, int64, error) {
+	var (
+		batch []models.User
+		end   int64
+	)
+	for len(batch) < n {
+		u, off, err := rows.next()
+		if err != nil {
+			return batch, end, err
+		}
+		batch = append(batch, u)
+		end = off
+	}
+	return batch, end, nil
+}
+
+// csvRows reads users from CSV records
+type csvRows struct {
+	r           *csv.Reader
+	base        int64
+	name, email int
+}
+
+// newCSVReader reads the header of r, then the records from offset, or
+// from after the header when offset is before its end
+func newCSVReader(r io.ReadSeeker, offset int64) (rowReader, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek import: %w", err)
+	}
+	hr := csv.NewReader(r)
+	header, err := hr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	rows := &csvRows{name: -1, email: -1}
+	for i, col := range header {
+		switch strings.ToLower(strings.TrimSpace(col)) {
+		case "name":
+			rows.name = i
+		case "email":
+			rows.email = i
+		}
+	}
+	if rows.name < 0 {
+		return nil, fmt.Errorf("CSV header has no name column")
+	}
+
+	rows.base = max(offset, hr.InputOffset())
+	if _, err := r.Seek(rows.base, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek import: %w", err)
+	}
+	rows.r = csv.NewReader(r)
+	rows.r.FieldsPerRecord = len(header)
+	return rows, nil
+}
+
+// next implements rowReader
+func (c *csvRows) next() (models.User, int64, error) {
+	rec, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return models.User{}, 0, io.EOF
+	}
+	if err != nil {
+		return models.User{}, 0, fmt.Errorf("failed to read CSV at offset %d: %w", c.base+c.r.InputOffset(), err)
+	}
+	u := models.User{Name: rec[c.name]}
+	if c.email >= 0 {
+		u.Email = rec[c.email]
+	}
+	return u, c.base + c.r.InputOffset(), nil
+}
+
+// jsonlRows reads users from JSON lines
+type jsonlRows struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+// jsonlUser is a JSONL line: a user, or an export record with one
+type jsonlUser struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	User  *struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"user"`
+	Cursor string `json:"cursor"`
+	Done   bool   `json:"done"`
+	Error  string `json:"error"`
+}
+
+// newJSONLReader reads the lines of r from offset
+func newJSONLReader(r io.ReadSeeker, offset int64) (rowReader, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek import: %w", err)
+	}
+	return &jsonlRows{r: bufio.NewReader(r), offset: offset}, nil
+}
+
+// next implements rowReader; blank lines and export records without a
+// user, e.g. the final done record, are skipped
+func (j *jsonlRows) next() (models.User, int64, error) {
+	for {
+		start := j.offset
+		line, err := j.r.ReadBytes('\n')
+		j.offset += int64(len(line))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return models.User{}, 0, fmt.Errorf("failed to read JSONL at offset %d: %w", start, err)
+		}
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			if err != nil {
+				return models.User{}, 0, io.EOF
+			}
+			continue
+		}
+
+		var l jsonlUser
+		if err := json.Unmarshal(line, &l); err != nil {
+			return models.User{}, 0, fmt.Errorf("invalid JSONL at offset %d: %w", start, err)
+		}
+		switch {
+		case l.User != nil:
+			return models.User{Name: l.User.Name, Email: l.User.Email}, j.offset, nil
+		case l.Cursor != "" || l.Done || l.Error != "":
+			continue
+		default:
+			return models.User{Name: l.Name, Email: l.Email}, j.offset, nil
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package labels tags work with the deployment labels of the database it
+// runs against, e.g. env, region, shard and replica role, so the metrics,
+// traces and logs of multi-database deployments can be told apart.
+package labels
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Labels are key/value tags, e.g. {"env": "prod", "role": "replica"}
+type Labels map[string]string
+
+// keyPattern matches keys usable as metric label names
+var keyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidKey reports whether key can be used as a label name
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// Keys returns the label keys, sorted
+func (l Labels) Keys() []string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Merge returns l with the labels of other added, other winning on
+// conflicting keys
+func (l Labels) Merge(other Labels) Labels {
+	if len(other) == 0 {
+		return l
+	}
+	merged := make(Labels, len(l)+len(other))
+	for k, v := range l {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Attributes returns the labels as OpenTelemetry attributes, sorted by key
+func (l Labels) Attributes() []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(l))
+	for _, k := range l.Keys() {
+		attrs = append(attrs, attribute.String(k, l[k]))
+	}
+	return attrs
+}
+
+// LogAttrs returns the labels as slog attributes, sorted by key
+func (l Labels) LogAttrs() []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(l))
+	for _, k := range l.Keys() {
+		attrs = append(attrs, slog.String(k, l[k]))
+	}
+	return attrs
+}
+
+type ctxKey struct{}
+
+// NewContext returns ctx carrying l on top of the labels it already has
+func NewContext(ctx context.Context, l Labels) context.Context {
+	return context.WithValue(ctx, ctxKey{}, FromContext(ctx).Merge(l))
+}
+
+// FromContext returns the labels carried by ctx, or nil
+func FromContext(ctx context.Context) Labels {
+	l, _ := ctx.Value(ctxKey{}).(Labels)
+	return l
+}
+
+// logHandler adds the context's labels to every record
+type logHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps h so records logged with a context carrying labels
+// get an attribute per label
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return logHandler{h}
+}
+
+// Handle implements slog.Handler
+func (h logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if l := FromContext(ctx); len(l) > 0 {
+		r.AddAttrs(l.LogAttrs()...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
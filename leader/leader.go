@@ -0,0 +1,159 @@
+// Package leader elects one instance of the application to run singleton
+// background work, e.g. the outbox relay. Leadership is a lease the leader
+// keeps renewing; when it dies the lease expires and another instance
+// takes over.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"project/clock"
+	"project/ids"
+)
+
+// Backend stores leases
+type Backend interface {
+	// Acquire takes the lease name for holder until now+ttl if it is free,
+	// expired or already held by holder, and reports whether holder has it
+	Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder has it
+	Release(ctx context.Context, name, holder string) error
+}
+
+// Elector campaigns for a lease and runs jobs while it holds it
+type Elector struct {
+	backend Backend
+	name    string
+	holder  string
+
+	// TTL is how long the lease lasts unrenewed, and so how long takeover
+	// takes after the leader dies; 15s by default
+	TTL time.Duration
+	// RenewInterval is how often the lease is renewed, or retried by
+	// followers; TTL/3 by default
+	RenewInterval time.Duration
+	// Clock times the lease; nil means the system clock
+	Clock clock.Clock
+
+	leading atomic.Bool
+}
+
+// NewElector creates an elector for the lease name, identifying this
+// instance by its hostname and a random suffix
+func NewElector(backend Backend, name string) (*Elector, error) {
+	host, _ := os.Hostname()
+	id, err := ids.Random.NewID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate holder id: %w", err)
+	}
+	return &Elector{backend: backend, name: name, holder: host + "-" + id[:8], TTL: 15 * time.Second}, nil
+}
+
+// Holder returns the identity this instance campaigns under
+func (e *Elector) Holder() string {
+	return e.holder
+}
+
+// IsLeader reports whether this instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// Run campaigns until ctx is cancelled. Whenever this instance becomes
+// leader it starts jobs, each in its own goroutine, with a context that is
+// cancelled as soon as the lease can't be renewed, or when it expires while
+// a renewal hangs; after they return it campaigns again. Each acquire or
+// renewal is bounded by TTL/2. On exit the lease is released.
+func (e *Elector) Run(ctx context.Context, jobs ...func(ctx context.Context)) {
+	interval := e.RenewInterval
+	if interval == 0 {
+		interval = e.TTL / 3
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+		// expiry is when the lease runs out unless renewed, and expire the
+		// timer cancelling the jobs then
+		expiry time.Time
+		expire *time.Timer
+	)
+	stop := func() {
+		if expire != nil {
+			expire.Stop()
+			expire = nil
+		}
+		if cancel != nil {
+			cancel()
+			wg.Wait()
+			cancel = nil
+		}
+		e.leading.Store(false)
+	}
+	defer func() {
+		stop()
+		if err := e.backend.Release(context.Background(), e.name, e.holder); err != nil {
+			log.Printf("Failed to release %s lease: %v", e.name, err)
+		}
+	}()
+
+	for {
+		now := clock.Or(e.Clock).Now()
+		acquireCtx, acquireCancel := context.WithTimeout(ctx, e.TTL/2)
+		ok, err := e.backend.Acquire(acquireCtx, e.name, e.holder, now, e.TTL)
+		acquireCancel()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to renew %s lease: %v", e.name, err)
+		}
+
+		if cancel != nil && !clock.Or(e.Clock).Now().Before(expiry) {
+			log.Printf("%s lease expired before it was renewed", e.name)
+			stop()
+		}
+		switch {
+		case ok && cancel == nil:
+			e.leading.Store(true)
+			cancel = start(ctx, &wg, jobs)
+		case !ok && cancel != nil:
+			log.Printf("Lost %s leadership", e.name)
+			stop()
+		}
+		if ok {
+			// the lease counts from before the acquire, as the backend does,
+			// so the jobs stop by the time another instance may take over
+			expiry = now.Add(e.TTL)
+			if expire != nil {
+				expire.Stop()
+			}
+			expire = time.AfterFunc(expiry.Sub(clock.Or(e.Clock).Now()), cancel)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// start runs jobs in goroutines tracked by wg, returning the function that
+// cancels them
+func start(ctx context.Context, wg *sync.WaitGroup, jobs []func(ctx context.Context)) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job func(ctx context.Context)) {
+			defer wg.Done()
+			job(ctx)
+		}(job)
+	}
+	return cancel
+}
@@ -0,0 +1,48 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RedisClient is the subset of a Redis client RedisBackend needs, so no
+// Redis driver is pulled into this module. SetNX is SET key value NX PX
+// ttl; ExpireIfEqual and DelIfEqual only touch key while it holds value,
+// e.g. with Lua scripts, and report whether they did.
+type RedisClient interface {
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	ExpireIfEqual(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	DelIfEqual(ctx context.Context, key string, value []byte) (bool, error)
+}
+
+// RedisBackend keeps leases as expiring Redis keys under "leader:<name>"
+type RedisBackend struct {
+	client RedisClient
+}
+
+// NewRedisBackend creates a lease backend on Redis
+func NewRedisBackend(client RedisClient) *RedisBackend {
+	return &RedisBackend{client: client}
+}
+
+// Acquire implements Backend; expiry is left to Redis, so now is unused
+func (b *RedisBackend) Acquire(ctx context.Context, name, holder string, _ time.Time, ttl time.Duration) (bool, error) {
+	key := "leader:" + name
+	ok, err := b.client.ExpireIfEqual(ctx, key, []byte(holder), ttl)
+	if err == nil && !ok {
+		ok, err = b.client.SetNX(ctx, key, []byte(holder), ttl)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	return ok, nil
+}
+
+// Release implements Backend
+func (b *RedisBackend) Release(ctx context.Context, name, holder string) error {
+	if _, err := b.client.DelIfEqual(ctx, "leader:"+name, []byte(holder)); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLBackend keeps leases as rows of the leader_leases table
+type SQLBackend struct {
+	db      *sql.DB
+	queries sqlQueries
+}
+
+// sqlQueries are the dialect's statements
+type sqlQueries struct {
+	acquire, holder, release string
+}
+
+// NewPostgresBackend creates a lease backend on a PostgreSQL db
+func NewPostgresBackend(db *sql.DB) *SQLBackend {
+	return &SQLBackend{db: db, queries: sqlQueries{
+		acquire: `INSERT INTO leader_leases (name, holder, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+			WHERE leader_leases.holder = EXCLUDED.holder OR leader_leases.expires_at < $4`,
+		holder:  "SELECT holder FROM leader_leases WHERE name = $1",
+		release: "DELETE FROM leader_leases WHERE name = $1 AND holder = $2",
+	}}
+}
+
+// NewMySQLBackend creates a lease backend on a MySQL db
+func NewMySQLBackend(db *sql.DB) *SQLBackend {
+	return &SQLBackend{db: db, queries: sqlQueries{
+		// holder is assigned first, so expires_at only moves when the lease
+		// went to, or stayed with, the new holder
+		acquire: `INSERT INTO leader_leases (name, holder, expires_at) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+			holder = IF(holder = VALUES(holder) OR expires_at < ?, VALUES(holder), holder),
+			expires_at = IF(holder = VALUES(holder), VALUES(expires_at), expires_at)`,
+		holder:  "SELECT holder FROM leader_leases WHERE name = ?",
+		release: "DELETE FROM leader_leases WHERE name = ? AND holder = ?",
+	}}
+}
+
+// Acquire implements Backend
+func (b *SQLBackend) Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	now = now.UTC()
+	if _, err := b.db.ExecContext(ctx, b.queries.acquire, name, holder, now.Add(ttl), now); err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+
+	var current string
+	err := b.db.QueryRowContext(ctx, b.queries.holder, name).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read lease: %w", err)
+	}
+	return current == holder, nil
+}
+
+// Release implements Backend
+func (b *SQLBackend) Release(ctx context.Context, name, holder string) error {
+	if _, err := b.db.ExecContext(ctx, b.queries.release, name, holder); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"project/apperr"
+	"project/repository"
+	"project/service"
+	"project/tenant"
+)
+
+// loadSample is the outcome of one load-test call
+type loadSample struct {
+	op      string
+	latency time.Duration
+	err     error
+}
+
+// runLoadTest handles `adapter loadtest`, driving concurrent service calls
+// for a fixed duration and reporting latency percentiles and error rates.
+// Users are written under a throwaway tenant and removed afterwards.
+func runLoadTest(args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	concurrency := fs.Int("concurrency", 16, "concurrent workers")
+	duration := fs.Duration("duration", 30*time.Second, "how long to generate load")
+	writes := fs.Float64("writes", 0.2, "share of calls that register a user, 0..1")
+	timeout := fs.Duration("timeout", 5*time.Second, "per-call timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conns, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer conns.Close()
+
+	repo, err := repository.NewPostgresRepo(db)
+	if err != nil {
+		return err
+	}
+	users := service.NewUserService(repository.Decorate(repo, repository.ClassifyErrors))
+
+	tenantID := fmt.Sprintf("loadtest-%d", time.Now().UnixNano())
+	defer db.Exec("DELETE FROM users WHERE tenant_id = $1", tenantID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	samples := make(chan loadSample, *concurrency*64)
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(w)))
+			for i := 0; ctx.Err() == nil; i++ {
+				callCtx, cancelCall := context.WithTimeout(tenant.NewContext(context.Background(), tenantID), *timeout)
+				svc := users.WithContext(callCtx)
+
+				var err error
+				op, start := "ListUsers", time.Now()
+				if rng.Float64() < *writes {
+					op = "RegisterUser"
+					err = svc.RegisterUser(fmt.Sprintf("load %d-%d", w, i))
+				} else {
+					_, err = svc.ListUsers()
+				}
+				samples <- loadSample{op: op, latency: time.Since(start), err: err}
+				cancelCall()
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	byOp := make(map[string][]loadSample)
+	for s := range samples {
+		byOp[s.op] = append(byOp[s.op], s)
+	}
+
+	fmt.Printf("%d workers for %s\n", *concurrency, *duration)
+	fmt.Printf("  %-13s %8s %8s %10s %10s %10s %8s\n", "op", "calls", "rps", "p50", "p95", "p99", "errors")
+	for _, op := range []string{"ListUsers", "RegisterUser"} {
+		printLoadReport(op, byOp[op], *duration)
+	}
+	return nil
+}
+
+// printLoadReport prints percentiles and the error rate of one operation,
+// with errors broken down by apperr code
+func printLoadReport(op string, samples []loadSample, duration time.Duration) {
+	if len(samples) == 0 {
+		return
+	}
+
+	latencies := make([]time.Duration, len(samples))
+	codes := make(map[apperr.Code]int)
+	failed := 0
+	for i, s := range samples {
+		latencies[i] = s.latency
+		if s.err != nil {
+			failed++
+			codes[apperr.CodeOf(s.err)]++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	pct := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))].Round(time.Microsecond)
+	}
+
+	fmt.Printf("  %-13s %8d %8.0f %10s %10s %10s %7.2f%%\n",
+		op, len(samples), float64(len(samples))/duration.Seconds(),
+		pct(0.50), pct(0.95), pct(0.99), 100*float64(failed)/float64(len(samples)))
+	for code, n := range codes {
+		fmt.Printf("  %-13s %d x %s\n", "", n, code)
+	}
+}
@@ -0,0 +1,72 @@
+// Package locks provides named locks shared by every instance of the
+// application, e.g. so a scheduled job or a migration runs on one
+// instance at a time
+package locks
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"time"
+)
+
+// ErrNotHeld is returned by Unlock when the lock isn't held, e.g. because
+// it expired
+var ErrNotHeld = errors.New("lock not held")
+
+// Lock is a named lock across instances. A Lock value is held by at most
+// one goroutine at a time; create one per user.
+type Lock interface {
+	// Lock blocks until the lock is held or ctx is done
+	Lock(ctx context.Context) error
+	// TryLock acquires the lock if it is free, without waiting
+	TryLock(ctx context.Context) (bool, error)
+	Unlock(ctx context.Context) error
+}
+
+// RetryInterval is how often Lock polls on backends without a blocking
+// acquire
+const RetryInterval = 250 * time.Millisecond
+
+// poll calls try every RetryInterval until it acquires the lock
+func poll(ctx context.Context, try func(ctx context.Context) (bool, error)) error {
+	ticker := time.NewTicker(RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := try(ctx)
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// key hashes a lock name to a 64-bit advisory lock key
+func key(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// Run runs fn while holding l if it is free, and reports whether it ran.
+// Use it for jobs any one instance may run, skipping a round when another
+// instance has it.
+func Run(ctx context.Context, l Lock, fn func(ctx context.Context) error) (bool, error) {
+	ok, err := l.TryLock(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	fnErr := fn(ctx)
+
+	// release with a fresh context so a cancelled ctx doesn't leak the lock
+	if err := l.Unlock(context.Background()); err != nil && fnErr == nil {
+		return true, err
+	}
+	return true, fnErr
+}
@@ -0,0 +1,87 @@
+package locks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"project/ids"
+)
+
+// RedisClient is the subset of a Redis client RedisLock needs, so no Redis
+// driver is pulled into this module. SetNX is SET key value NX PX ttl;
+// DelIfEqual deletes key only if it holds value, e.g. with a Lua script,
+// and reports whether it did.
+type RedisClient interface {
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	DelIfEqual(ctx context.Context, key string, value []byte) (bool, error)
+}
+
+// RedisLock is a lock held as a Redis key set with SETNX. The key expires
+// after TTL so a crashed holder doesn't keep it forever; work under the
+// lock must finish within TTL.
+type RedisLock struct {
+	client RedisClient
+	key    string
+	// TTL bounds how long the lock is held, 30s by default
+	TTL time.Duration
+	// IDs generates the token identifying the holder; nil means random
+	IDs ids.Source
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewRedisLock creates a lock on the key "lock:<name>"
+func NewRedisLock(client RedisClient, name string) *RedisLock {
+	return &RedisLock{client: client, key: "lock:" + name, TTL: 30 * time.Second}
+}
+
+// Lock implements Lock, polling every RetryInterval
+func (l *RedisLock) Lock(ctx context.Context) error {
+	return poll(ctx, l.TryLock)
+}
+
+// TryLock implements Lock
+func (l *RedisLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token != "" {
+		return false, fmt.Errorf("lock %q is already held by this instance", l.key)
+	}
+
+	token, err := ids.Or(l.IDs).NewID()
+	if err != nil {
+		return false, err
+	}
+	ok, err := l.client.SetNX(ctx, l.key, []byte(token), l.TTL)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock %q: %w", l.key, err)
+	}
+	if ok {
+		l.token = token
+	}
+	return ok, nil
+}
+
+// Unlock implements Lock. It returns ErrNotHeld if the key expired, and
+// never deletes a key another holder has since set.
+func (l *RedisLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token == "" {
+		return ErrNotHeld
+	}
+
+	token := l.token
+	l.token = ""
+	ok, err := l.client.DelIfEqual(ctx, l.key, []byte(token))
+	if err != nil {
+		return fmt.Errorf("failed to release lock %q: %w", l.key, err)
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package locks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// sessionLock is a lock held by a database session, so it lives on a
+// dedicated connection until Unlock and is released by the server if the
+// connection drops
+type sessionLock struct {
+	db   *sql.DB
+	name string
+	// lock, try and unlock run on the connection; lock and try return
+	// whether the lock was acquired
+	lock, try, unlock func(ctx context.Context, conn *sql.Conn) (bool, error)
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewPostgresLock creates a lock held with a session-level advisory lock
+// keyed on a hash of name
+func NewPostgresLock(db *sql.DB, name string) Lock {
+	k := key(name)
+	return &sessionLock{db: db, name: name,
+		lock: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", k)
+			return err == nil, err
+		},
+		try: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+			var ok bool
+			err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", k).Scan(&ok)
+			return ok, err
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+			var ok bool
+			err := conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", k).Scan(&ok)
+			return ok, err
+		},
+	}
+}
+
+// NewMySQLLock creates a lock held with GET_LOCK. MySQL limits lock names
+// to 64 characters.
+func NewMySQLLock(db *sql.DB, name string) Lock {
+	getLock := func(timeout int) func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		return func(ctx context.Context, conn *sql.Conn) (bool, error) {
+			var got sql.NullInt64
+			err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout).Scan(&got)
+			return got.Valid && got.Int64 == 1, err
+		}
+	}
+	return &sessionLock{db: db, name: name,
+		// GET_LOCK can't be interrupted by ctx, so wait in short rounds
+		lock: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+			err := poll(ctx, func(ctx context.Context) (bool, error) { return getLock(1)(ctx, conn) })
+			return err == nil, err
+		},
+		try: getLock(0),
+		unlock: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+			var released sql.NullInt64
+			err := conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", name).Scan(&released)
+			return released.Valid && released.Int64 == 1, err
+		},
+	}
+}
+
+// Lock implements Lock
+func (l *sessionLock) Lock(ctx context.Context) error {
+	_, err := l.acquire(ctx, l.lock)
+	return err
+}
+
+// TryLock implements Lock
+func (l *sessionLock) TryLock(ctx context.Context) (bool, error) {
+	return l.acquire(ctx, l.try)
+}
+
+func (l *sessionLock) acquire(ctx context.Context, fn func(ctx context.Context, conn *sql.Conn) (bool, error)) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		return false, fmt.Errorf("lock %q is already held by this instance", l.name)
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection: %w", err)
+	}
+	ok, err := fn(ctx, conn)
+	if err != nil || !ok {
+		conn.Close()
+		if err != nil {
+			return false, fmt.Errorf("failed to acquire lock %q: %w", l.name, err)
+		}
+		return false, nil
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Unlock implements Lock
+func (l *sessionLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return ErrNotHeld
+	}
+
+	conn := l.conn
+	l.conn = nil
+	defer conn.Close()
+
+	ok, err := l.unlock(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to release lock %q: %w", l.name, err)
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
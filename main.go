@@ -1,17 +1,28 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
 
 	"project/config"
+	"project/labels"
+	"project/metrics"
+	"project/models"
 	"project/repository"
-	"project/service"
+	"project/requestid"
 )
 
-func main() {
-	// Configure database connection
-	dbConfig := config.DatabaseConfig{
+// defaultDatabaseConfig returns the connection settings from DATABASE_URL,
+// falling back to the local docker-compose PostgreSQL instance
+func defaultDatabaseConfig() config.DatabaseConfig {
+	if url := os.Getenv(config.DatabaseURLEnv); url != "" {
+		return config.DatabaseConfig{URL: url}
+	}
+	return config.DatabaseConfig{
 		Host:     "localhost",
 		Port:     5433,
 		User:     "postgres",
@@ -19,51 +30,178 @@ func main() {
 		DBName:   "appdb",
 		SSLMode:  "disable",
 	}
+}
+
+// primaryDatabase names the main database in the connection manager
+const primaryDatabase = "primary"
+
+// replicaDatabase names the read replica in the connection manager; reads
+// go to it when the config file declares it
+const replicaDatabase = "replica"
+
+// configPathEnv names the environment variable pointing at a JSON config
+// file; when unset the defaults are used
+const configPathEnv = "ADAPTER_CONFIG"
+
+// queryLogEnv names the environment variable that turns on statement
+// logging, e.g. ADAPTER_QUERY_LOG=true
+const queryLogEnv = "ADAPTER_QUERY_LOG"
+
+// smtpAddrEnv names the environment variable holding the SMTP relay
+// welcome emails are sent through, e.g. ADAPTER_SMTP_ADDR=localhost:25;
+// when unset queued emails wait in the outbox
+const smtpAddrEnv = "ADAPTER_SMTP_ADDR"
+
+// startupTimeoutEnv names the environment variable that holds startup
+// until the dependencies pass their health checks, for at most the given
+// duration, e.g. ADAPTER_STARTUP_TIMEOUT=60s
+const startupTimeoutEnv = "ADAPTER_STARTUP_TIMEOUT"
+
+// eventFormatEnv names the environment variable that relays domain events
+// to the outbox for downstream consumers, encoded as json, protobuf or
+// avro, e.g. ADAPTER_EVENT_FORMAT=protobuf
+const eventFormatEnv = "ADAPTER_EVENT_FORMAT"
+
+// schemaRegistryEnv names the environment variable pointing at a schema
+// registry that Protobuf and Avro events are registered with, e.g.
+// ADAPTER_SCHEMA_REGISTRY_URL=http://localhost:8081
+const schemaRegistryEnv = "ADAPTER_SCHEMA_REGISTRY_URL"
+
+// eventBufferEnv names the environment variable sizing the buffer events
+// wait in for the outbox relay, e.g. ADAPTER_EVENT_BUFFER=4096
+const eventBufferEnv = "ADAPTER_EVENT_BUFFER"
+
+// eventOverflowEnv names the environment variable choosing what happens to
+// events when the relay's buffer is full: block, drop-oldest or error, e.g.
+// ADAPTER_EVENT_OVERFLOW=error; block, for at most a second, when unset
+const eventOverflowEnv = "ADAPTER_EVENT_OVERFLOW"
+
+// degradeEnv names the environment variable setting what happens when an
+// optional dependency fails, as dependency=policy pairs with the policy
+// fail or bypass, e.g. ADAPTER_DEGRADE=breach_check=bypass; the
+// dependencies are breach_check and schema_registry, and those without a
+// policy fail their callers
+const degradeEnv = "ADAPTER_DEGRADE"
+
+// metricsAddrEnv names the environment variable that serves the dashboard
+// metrics at /metrics on the given address, e.g. ADAPTER_METRICS_ADDR=:9090
+const metricsAddrEnv = "ADAPTER_METRICS_ADDR"
+
+// httpAddrEnv and grpcAddrEnv name the environment variables that serve the
+// users API over HTTP and gRPC on the given addresses, e.g.
+// ADAPTER_HTTP_ADDR=:8080 and ADAPTER_GRPC_ADDR=:9000
+const (
+	httpAddrEnv = "ADAPTER_HTTP_ADDR"
+	grpcAddrEnv = "ADAPTER_GRPC_ADDR"
+)
 
-	// Create database connection
-	db, err := config.NewPostgresConnection(dbConfig)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+// apiAuthEnv names the environment variable that requires an API key on
+// every API request and call, e.g. ADAPTER_API_AUTH=true
+const apiAuthEnv = "ADAPTER_API_AUTH"
+
+// corsOriginsEnv names the environment variable listing the origins
+// browsers may call the HTTP API from, comma separated, e.g.
+// ADAPTER_CORS_ORIGINS=https://app.example.com; none when unset
+const corsOriginsEnv = "ADAPTER_CORS_ORIGINS"
+
+// adminAddrEnv names the environment variable that serves the /admin/
+// endpoints of api.AdminHandler on the given address, e.g.
+// ADAPTER_ADMIN_ADDR=127.0.0.1:9091; they are unauthenticated, so bind it
+// to a private interface
+const adminAddrEnv = "ADAPTER_ADMIN_ADDR"
+
+// batchPipelineEnv names the environment variable that sends repository
+// batches to Postgres in one round trip over a pgx pool, e.g.
+// ADAPTER_BATCH_PIPELINE=true; the pool dials directly, so leave it off
+// behind a proxy or managed-instance connector
+const batchPipelineEnv = "ADAPTER_BATCH_PIPELINE"
+
+// maxReplicaLagEnv names the environment variable bounding how far behind
+// the replica may be before reads fall back to the primary, e.g.
+// ADAPTER_MAX_REPLICA_LAG=5s; when unset any lag is accepted
+const maxReplicaLagEnv = "ADAPTER_MAX_REPLICA_LAG"
+
+// breachCheckEnv names the environment variable that rejects passwords
+// found in known breaches, e.g. ADAPTER_PASSWORD_BREACH_CHECK=true; the
+// Have I Been Pwned API sees only a five digit hash prefix
+const breachCheckEnv = "ADAPTER_PASSWORD_BREACH_CHECK"
+
+// nameCollationEnv names the environment variable holding the collation
+// new name columns are created with, e.g. ADAPTER_NAME_COLLATION=de-x-icu;
+// when unset the database default applies
+const nameCollationEnv = "ADAPTER_NAME_COLLATION"
+
+// dashboardMetrics records the statement and repository call metrics the
+// dashboard plots, with trace exemplars
+var dashboardMetrics = metrics.NewBundle()
+
+// queryLog logs the statements run on managed connections when enabled
+var queryLog = config.NewQueryLogger(slog.New(requestid.NewLogHandler(
+	slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}),
+)))
+
+// queryStats collects per-fingerprint statistics of the statements run on
+// managed connections, served by api.AdminHandler on adminAddrEnv
+var queryStats = config.NewQueryStats()
+
+// newConnectionManager registers the application's databases, from the
+// config file when one is set
+func newConnectionManager() (*config.ConnectionManager, error) {
+	conns := config.NewConnectionManager(30 * time.Second)
+	conns.QueryLog = queryLog
+	conns.QueryStats = queryStats
+	conns.QueryDuration = dashboardMetrics.QueryDuration
+	if on, _ := strconv.ParseBool(os.Getenv(queryLogEnv)); on {
+		queryLog.SetEnabled(true)
 	}
-	defer db.Close()
+	conns.Register(primaryDatabase, defaultDatabaseConfig())
 
-	// Initialize repository
-	repo, err := repository.NewPostgresRepo(db)
-	if err != nil {
-		fmt.Print("Error migrating Database")
+	if path := os.Getenv(configPathEnv); path != "" {
+		cfg, err := config.LoadFile(path)
+		if err != nil {
+			conns.Close()
+			return nil, err
+		}
+		if err := conns.Apply(context.Background(), cfg); err != nil {
+			conns.Close()
+			return nil, err
+		}
 	}
 
-	// Uncomment to use MySQL instead:
-	// mysqlConfig := config.DatabaseConfig{
+	// Uncomment to register MySQL as well:
+	// conns.Register("mysql", config.DatabaseConfig{
+	// 	Driver:   "mysql",
 	// 	Host:     "localhost",
 	// 	Port:     3306,
 	// 	User:     "root",
 	// 	Password: "password",
 	// 	DBName:   "appdb",
-	// }
-	// mysqlDB, err := config.NewMySQLConnection(mysqlConfig)
-	// if err != nil {
-	// 	log.Fatalf("Failed to connect to MySQL: %v", err)
-	// }
-	// defer mysqlDB.Close()
-	// repo = repository.NewMySQLRepo(mysqlDB)
-
-	// Initialize service
-	userService := service.NewUserService(repo)
-
-	// Register users (uncomment to use)
-	// if err := userService.RegisterUser("Kushal"); err != nil {
-	// 	log.Printf("Failed to register user: %v", err)
-	// }
-	// if err := userService.RegisterUser("DevOps"); err != nil {
-	// 	log.Printf("Failed to register user: %v", err)
-	// }
-	//
-	// List all users
-	users, err := userService.ListUsers()
-	if err != nil {
-		log.Fatalf("Failed to list users: %v", err)
+	// })
+	return conns, nil
+}
+
+func main() {
+	// Subcommands, e.g. `adapter migrate plan`
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	fmt.Println("Registered Users:", users)
+	// Fail fast on malformed model tags
+	if err := repository.ValidateModels(models.All()...); err != nil {
+		log.Fatalf("Invalid model definitions: %v", err)
+	}
+
+	// Structured logs carry the request ID of the context they are logged with
+	logger := slog.New(labels.NewLogHandler(requestid.NewLogHandler(slog.NewTextHandler(os.Stderr, nil))))
+
+	// Components start in dependency order, serve until SIGINT or SIGTERM and
+	// stop in reverse
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := newContainer(logger).Run(ctx); err != nil {
+		log.Fatalf("Failed to run: %v", err)
+	}
 }
@@ -0,0 +1,96 @@
+package mapping
+
+import (
+	"database/sql"
+	"time"
+
+	"project/models"
+)
+
+// UserJSON is the JSON form of a user
+type UserJSON struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Email      string         `json:"email,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	TenantID   string         `json:"tenant_id,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  *time.Time     `json:"deleted_at,omitempty"`
+	Posts      []PostJSON     `json:"posts,omitempty"`
+}
+
+// PostJSON is the JSON form of a post
+type PostJSON struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserToJSON converts u to its JSON DTO; Settings aren't part of the wire
+// form
+func UserToJSON(u models.User) UserJSON {
+	j := UserJSON{
+		ID:         u.ID,
+		Name:       u.Name,
+		Email:      u.Email,
+		Tags:       u.Tags,
+		Attributes: u.Attributes,
+		TenantID:   u.TenantID,
+		CreatedAt:  u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+	}
+	if u.DeletedAt.Valid {
+		deleted := u.DeletedAt.Time
+		j.DeletedAt = &deleted
+	}
+	for _, post := range u.Posts {
+		j.Posts = append(j.Posts, PostToJSON(post))
+	}
+	return j
+}
+
+// UserFromJSON converts a JSON DTO back to the model
+func UserFromJSON(j UserJSON) models.User {
+	u := models.User{
+		Base:       models.Base{ID: j.ID, CreatedAt: j.CreatedAt, UpdatedAt: j.UpdatedAt},
+		Name:       j.Name,
+		Email:      j.Email,
+		Tags:       j.Tags,
+		Attributes: models.JSONMap(j.Attributes),
+		TenantID:   j.TenantID,
+	}
+	if j.DeletedAt != nil {
+		u.DeletedAt = sql.NullTime{Time: *j.DeletedAt, Valid: true}
+	}
+	for _, p := range j.Posts {
+		u.Posts = append(u.Posts, PostFromJSON(p))
+	}
+	return u
+}
+
+// PostToJSON converts post to its JSON DTO
+func PostToJSON(post models.Post) PostJSON {
+	return PostJSON{
+		ID:        post.ID,
+		UserID:    post.UserID,
+		Title:     post.Title,
+		Body:      post.Body,
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+	}
+}
+
+// PostFromJSON converts a JSON DTO back to the model
+func PostFromJSON(j PostJSON) models.Post {
+	return models.Post{
+		Base:   models.Base{ID: j.ID, CreatedAt: j.CreatedAt, UpdatedAt: j.UpdatedAt},
+		UserID: j.UserID,
+		Title:  j.Title,
+		Body:   j.Body,
+	}
+}
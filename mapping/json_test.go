@@ -0,0 +1,224 @@
+package mapping
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"project/models"
+)
+
+// fullJSON is fullUser as a JSON DTO
+func fullJSON() UserJSON {
+	d := deleted
+	return UserJSON{
+		ID:    42,
+		Name:  "José Núñez",
+		Email: "jose@example.com",
+		Tags:  []string{"admin", "beta"},
+		Attributes: map[string]any{
+			"plan":   "pro",
+			"seats":  float64(3),
+			"trial":  false,
+			"limits": map[string]any{"api": float64(100)},
+			"labels": []any{"a", "b"},
+			"note":   nil,
+		},
+		TenantID:  "acme",
+		CreatedAt: created,
+		UpdatedAt: updated,
+		DeletedAt: &d,
+		Posts: []PostJSON{{
+			ID:        7,
+			UserID:    42,
+			Title:     "Hello",
+			Body:      "First post",
+			CreatedAt: created,
+			UpdatedAt: updated,
+		}},
+	}
+}
+
+func TestUserToJSON(t *testing.T) {
+	zero := time.Time{}
+	tests := []struct {
+		name string
+		user models.User
+		want UserJSON
+	}{
+		{name: "zero user", user: models.User{}, want: UserJSON{}},
+		{name: "every field", user: fullUser(), want: fullJSON()},
+		{
+			name: "deleted at without Valid is nil",
+			user: models.User{DeletedAt: sql.NullTime{Time: deleted}},
+			want: UserJSON{},
+		},
+		{
+			name: "valid deleted at of the zero time is kept",
+			user: models.User{DeletedAt: sql.NullTime{Valid: true}},
+			want: UserJSON{DeletedAt: &zero},
+		},
+		{
+			name: "empty tags and attributes stay empty",
+			user: models.User{Tags: []string{}, Attributes: models.JSONMap{}},
+			want: UserJSON{Tags: []string{}, Attributes: map[string]any{}},
+		},
+		{
+			name: "times keep their zone",
+			user: models.User{Base: models.Base{CreatedAt: created.In(kolkata), UpdatedAt: updated.In(kolkata)}},
+			want: UserJSON{CreatedAt: created.In(kolkata), UpdatedAt: updated.In(kolkata)},
+		},
+		{
+			name: "posts with zero fields",
+			user: models.User{Posts: []models.Post{{}}},
+			want: UserJSON{Posts: []PostJSON{{}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UserToJSON(tt.user); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UserToJSON = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserFromJSON(t *testing.T) {
+	zero := time.Time{}
+	tests := []struct {
+		name string
+		dto  UserJSON
+		want models.User
+	}{
+		{name: "zero DTO", dto: UserJSON{}, want: models.User{}},
+		{name: "every field", dto: fullJSON(), want: func() models.User {
+			u := fullUser()
+			u.Settings = nil
+			return u
+		}()},
+		{
+			name: "deleted at of the zero time is valid",
+			dto:  UserJSON{DeletedAt: &zero},
+			want: models.User{DeletedAt: sql.NullTime{Valid: true}},
+		},
+		{
+			name: "empty attributes stay empty",
+			dto:  UserJSON{Attributes: map[string]any{}},
+			want: models.User{Attributes: models.JSONMap{}},
+		},
+		{
+			name: "times keep their zone",
+			dto:  UserJSON{CreatedAt: created.In(kolkata)},
+			want: models.User{Base: models.Base{CreatedAt: created.In(kolkata)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UserFromJSON(tt.dto); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UserFromJSON = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserJSONEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		want string
+	}{
+		{
+			name: "zero user omits the optional fields",
+			user: models.User{},
+			want: `{"id":0,"name":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			name: "empty tags and attributes are omitted",
+			user: models.User{Tags: []string{}, Attributes: models.JSONMap{}},
+			want: `{"id":0,"name":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			name: "times keep their offset and nanoseconds",
+			user: models.User{
+				Base:      models.Base{ID: 1, CreatedAt: created.In(kolkata), UpdatedAt: updated},
+				Name:      "a",
+				DeletedAt: sql.NullTime{Time: preEpoch, Valid: true},
+			},
+			want: `{"id":1,"name":"a","created_at":"2024-03-10T14:00:00.123456789+05:30",` +
+				`"updated_at":"2024-03-11T09:00:00Z","deleted_at":"1969-12-31T23:59:59.5Z"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(UserToJSON(tt.user))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("json = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		// want is user after the round trip, when it differs
+		want *models.User
+	}{
+		{name: "zero user", user: models.User{}},
+		{name: "every field", user: fullUser(), want: func() *models.User {
+			u := fullUser()
+			u.Settings = nil
+			return &u
+		}()},
+		{name: "empty tags and attributes are dropped", user: models.User{Tags: []string{}, Attributes: models.JSONMap{}}, want: &models.User{}},
+		{name: "integer attributes come back as numbers", user: models.User{Attributes: models.JSONMap{"seats": 3}},
+			want: &models.User{Attributes: models.JSONMap{"seats": float64(3)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(UserToJSON(tt.user))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var dto UserJSON
+			if err := json.Unmarshal(b, &dto); err != nil {
+				t.Fatal(err)
+			}
+			got := UserFromJSON(dto)
+
+			want := tt.user
+			if tt.want != nil {
+				want = *tt.want
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestUserJSONRoundTripKeepsOffset(t *testing.T) {
+	u := models.User{Base: models.Base{CreatedAt: created.In(kolkata)}}
+	b, err := json.Marshal(UserToJSON(u))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dto UserJSON
+	if err := json.Unmarshal(b, &dto); err != nil {
+		t.Fatal(err)
+	}
+	got := UserFromJSON(dto).CreatedAt
+	if _, offset := got.Zone(); !got.Equal(created) || offset != 5*3600+1800 {
+		t.Errorf("CreatedAt = %v, want %v at +05:30", got, created)
+	}
+}
@@ -0,0 +1,136 @@
+// Package mapping converts models to and from their wire formats, the
+// protobuf messages of package userpb and the JSON DTOs, so persistence
+// models can change without breaking clients and vice versa
+package mapping
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"project/models"
+	"project/proto/userpb"
+)
+
+// UserToProto converts u to its protobuf message; Settings aren't part of
+// the wire form
+func UserToProto(u models.User) (*userpb.User, error) {
+	p := &userpb.User{
+		Id:        int64(u.ID),
+		Name:      u.Name,
+		Email:     u.Email,
+		Tags:      u.Tags,
+		TenantId:  u.TenantID,
+		CreatedAt: timestampToProto(u.CreatedAt),
+		UpdatedAt: timestampToProto(u.UpdatedAt),
+	}
+	if u.DeletedAt.Valid {
+		p.DeletedAt = timestamppb.New(u.DeletedAt.Time)
+	}
+	if u.Attributes != nil {
+		attrs, err := structpb.NewStruct(u.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert attributes of user %d: %w", u.ID, err)
+		}
+		p.Attributes = attrs
+	}
+	for _, post := range u.Posts {
+		p.Posts = append(p.Posts, PostToProto(post))
+	}
+	return p, nil
+}
+
+// UserFromProto converts a protobuf user back to the model
+func UserFromProto(p *userpb.User) (models.User, error) {
+	id, err := intID(p.GetId())
+	if err != nil {
+		return models.User{}, err
+	}
+	u := models.User{
+		Base: models.Base{
+			ID:        id,
+			CreatedAt: timestampFromProto(p.GetCreatedAt()),
+			UpdatedAt: timestampFromProto(p.GetUpdatedAt()),
+		},
+		Name:     p.GetName(),
+		Email:    p.GetEmail(),
+		Tags:     p.GetTags(),
+		TenantID: p.GetTenantId(),
+	}
+	if p.GetDeletedAt() != nil {
+		u.DeletedAt = sql.NullTime{Time: p.GetDeletedAt().AsTime(), Valid: true}
+	}
+	if p.GetAttributes() != nil {
+		u.Attributes = models.JSONMap(p.GetAttributes().AsMap())
+	}
+	for _, pp := range p.GetPosts() {
+		post, err := PostFromProto(pp)
+		if err != nil {
+			return models.User{}, err
+		}
+		u.Posts = append(u.Posts, post)
+	}
+	return u, nil
+}
+
+// PostToProto converts post to its protobuf message
+func PostToProto(post models.Post) *userpb.Post {
+	return &userpb.Post{
+		Id:        int64(post.ID),
+		UserId:    int64(post.UserID),
+		Title:     post.Title,
+		Body:      post.Body,
+		CreatedAt: timestampToProto(post.CreatedAt),
+		UpdatedAt: timestampToProto(post.UpdatedAt),
+	}
+}
+
+// PostFromProto converts a protobuf post back to the model
+func PostFromProto(p *userpb.Post) (models.Post, error) {
+	id, err := intID(p.GetId())
+	if err != nil {
+		return models.Post{}, err
+	}
+	userID, err := intID(p.GetUserId())
+	if err != nil {
+		return models.Post{}, err
+	}
+	return models.Post{
+		Base: models.Base{
+			ID:        id,
+			CreatedAt: timestampFromProto(p.GetCreatedAt()),
+			UpdatedAt: timestampFromProto(p.GetUpdatedAt()),
+		},
+		UserID: userID,
+		Title:  p.GetTitle(),
+		Body:   p.GetBody(),
+	}, nil
+}
+
+// intID converts a wire ID to a model ID, failing where int is narrower
+func intID(id int64) (int, error) {
+	if id > math.MaxInt || id < math.MinInt {
+		return 0, fmt.Errorf("id %d out of range", id)
+	}
+	return int(id), nil
+}
+
+// timestampToProto converts t, leaving the zero time unset
+func timestampToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timestampFromProto converts ts, an unset timestamp to the zero time
+func timestampFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
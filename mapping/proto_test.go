@@ -0,0 +1,330 @@
+package mapping
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"project/models"
+	"project/proto/userpb"
+)
+
+var (
+	kolkata = time.FixedZone("IST", 5*3600+1800)
+
+	created = time.Date(2024, 3, 10, 8, 30, 0, 123456789, time.UTC)
+	updated = time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)
+	deleted = time.Date(2024, 3, 12, 10, 15, 0, 1, time.UTC)
+	// preEpoch has negative Unix seconds, which timestamppb stores with
+	// positive nanos
+	preEpoch = time.Date(1969, 12, 31, 23, 59, 59, 500000000, time.UTC)
+)
+
+// fullUser sets every field of models.User, including Settings, which the
+// wire forms leave out
+func fullUser() models.User {
+	return models.User{
+		Base:  models.Base{ID: 42, CreatedAt: created, UpdatedAt: updated},
+		Name:  "José Núñez",
+		Email: "jose@example.com",
+		Tags:  []string{"admin", "beta"},
+		Attributes: models.JSONMap{
+			"plan":   "pro",
+			"seats":  float64(3),
+			"trial":  false,
+			"limits": map[string]any{"api": float64(100)},
+			"labels": []any{"a", "b"},
+			"note":   nil,
+		},
+		TenantID:  "acme",
+		DeletedAt: sql.NullTime{Time: deleted, Valid: true},
+		Posts: []models.Post{{
+			Base:   models.Base{ID: 7, CreatedAt: created, UpdatedAt: updated},
+			UserID: 42,
+			Title:  "Hello",
+			Body:   "First post",
+		}},
+		Settings: &models.UserSettings{UserID: 42},
+	}
+}
+
+// fullProto is fullUser as a protobuf message
+func fullProto(t *testing.T) *userpb.User {
+	t.Helper()
+	attrs, err := structpb.NewStruct(map[string]any{
+		"plan":   "pro",
+		"seats":  float64(3),
+		"trial":  false,
+		"limits": map[string]any{"api": float64(100)},
+		"labels": []any{"a", "b"},
+		"note":   nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &userpb.User{
+		Id:         42,
+		Name:       "José Núñez",
+		Email:      "jose@example.com",
+		Tags:       []string{"admin", "beta"},
+		Attributes: attrs,
+		TenantId:   "acme",
+		CreatedAt:  timestamppb.New(created),
+		UpdatedAt:  timestamppb.New(updated),
+		DeletedAt:  timestamppb.New(deleted),
+		Posts: []*userpb.Post{{
+			Id:        7,
+			UserId:    42,
+			Title:     "Hello",
+			Body:      "First post",
+			CreatedAt: timestamppb.New(created),
+			UpdatedAt: timestamppb.New(updated),
+		}},
+	}
+}
+
+func TestUserToProto(t *testing.T) {
+	emptyAttrs, _ := structpb.NewStruct(map[string]any{})
+	intAttrs, _ := structpb.NewStruct(map[string]any{"seats": float64(3)})
+
+	tests := []struct {
+		name string
+		user func() models.User
+		want func(t *testing.T) *userpb.User
+	}{
+		{
+			name: "zero user leaves every field unset",
+			user: func() models.User { return models.User{} },
+			want: func(*testing.T) *userpb.User { return &userpb.User{} },
+		},
+		{
+			name: "every field",
+			user: fullUser,
+			want: fullProto,
+		},
+		{
+			name: "deleted at without Valid is unset",
+			user: func() models.User {
+				return models.User{DeletedAt: sql.NullTime{Time: deleted}}
+			},
+			want: func(*testing.T) *userpb.User { return &userpb.User{} },
+		},
+		{
+			name: "valid deleted at of the zero time is kept",
+			user: func() models.User {
+				return models.User{DeletedAt: sql.NullTime{Valid: true}}
+			},
+			want: func(*testing.T) *userpb.User {
+				return &userpb.User{DeletedAt: timestamppb.New(time.Time{})}
+			},
+		},
+		{
+			name: "empty tags stay empty",
+			user: func() models.User { return models.User{Tags: []string{}} },
+			want: func(*testing.T) *userpb.User { return &userpb.User{Tags: []string{}} },
+		},
+		{
+			name: "empty attributes are an empty struct",
+			user: func() models.User { return models.User{Attributes: models.JSONMap{}} },
+			want: func(*testing.T) *userpb.User { return &userpb.User{Attributes: emptyAttrs} },
+		},
+		{
+			name: "integer attributes become numbers",
+			user: func() models.User { return models.User{Attributes: models.JSONMap{"seats": 3}} },
+			want: func(*testing.T) *userpb.User { return &userpb.User{Attributes: intAttrs} },
+		},
+		{
+			name: "times in other zones keep their instant",
+			user: func() models.User {
+				return models.User{Base: models.Base{CreatedAt: created.In(kolkata), UpdatedAt: updated.In(kolkata)}}
+			},
+			want: func(*testing.T) *userpb.User {
+				return &userpb.User{CreatedAt: timestamppb.New(created), UpdatedAt: timestamppb.New(updated)}
+			},
+		},
+		{
+			name: "times before the epoch",
+			user: func() models.User { return models.User{Base: models.Base{CreatedAt: preEpoch}} },
+			want: func(*testing.T) *userpb.User {
+				return &userpb.User{CreatedAt: &timestamppb.Timestamp{Seconds: -1, Nanos: 500000000}}
+			},
+		},
+		{
+			name: "posts with zero fields",
+			user: func() models.User { return models.User{Posts: []models.Post{{}}} },
+			want: func(*testing.T) *userpb.User { return &userpb.User{Posts: []*userpb.Post{{}}} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UserToProto(tt.user())
+			if err != nil {
+				t.Fatalf("UserToProto: %v", err)
+			}
+			if want := tt.want(t); !proto.Equal(got, want) {
+				t.Errorf("UserToProto = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUserToProtoInvalidAttributes(t *testing.T) {
+	u := models.User{Base: models.Base{ID: 1}, Attributes: models.JSONMap{"ch": make(chan int)}}
+	if _, err := UserToProto(u); err == nil {
+		t.Fatal("UserToProto accepted an attribute protobuf can't hold")
+	}
+}
+
+func TestUserFromProto(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  func(t *testing.T) *userpb.User
+		want func() models.User
+	}{
+		{
+			name: "nil message is the zero user",
+			msg:  func(*testing.T) *userpb.User { return nil },
+			want: func() models.User { return models.User{} },
+		},
+		{
+			name: "empty message is the zero user",
+			msg:  func(*testing.T) *userpb.User { return &userpb.User{} },
+			want: func() models.User { return models.User{} },
+		},
+		{
+			name: "every field",
+			msg:  fullProto,
+			want: func() models.User {
+				u := fullUser()
+				u.Settings = nil
+				return u
+			},
+		},
+		{
+			name: "empty struct is an empty attributes map",
+			msg: func(*testing.T) *userpb.User {
+				return &userpb.User{Attributes: &structpb.Struct{}}
+			},
+			want: func() models.User { return models.User{Attributes: models.JSONMap{}} },
+		},
+		{
+			name: "deleted at of the zero time is valid",
+			msg: func(*testing.T) *userpb.User {
+				return &userpb.User{DeletedAt: timestamppb.New(time.Time{})}
+			},
+			want: func() models.User { return models.User{DeletedAt: sql.NullTime{Valid: true}} },
+		},
+		{
+			name: "times before the epoch",
+			msg: func(*testing.T) *userpb.User {
+				return &userpb.User{CreatedAt: &timestamppb.Timestamp{Seconds: -1, Nanos: 500000000}}
+			},
+			want: func() models.User { return models.User{Base: models.Base{CreatedAt: preEpoch}} },
+		},
+		{
+			name: "nil post is a zero post",
+			msg: func(*testing.T) *userpb.User {
+				return &userpb.User{Posts: []*userpb.Post{nil}}
+			},
+			want: func() models.User { return models.User{Posts: []models.Post{{}}} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UserFromProto(tt.msg(t))
+			if err != nil {
+				t.Fatalf("UserFromProto: %v", err)
+			}
+			if want := tt.want(); !reflect.DeepEqual(got, want) {
+				t.Errorf("UserFromProto = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestUserFromProtoTimesAreUTC(t *testing.T) {
+	msg := &userpb.User{
+		CreatedAt: timestamppb.New(created.In(kolkata)),
+		UpdatedAt: timestamppb.New(updated.In(kolkata)),
+		DeletedAt: timestamppb.New(deleted.In(kolkata)),
+	}
+	u, err := UserFromProto(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		field     string
+		got, want time.Time
+	}{
+		{"CreatedAt", u.CreatedAt, created},
+		{"UpdatedAt", u.UpdatedAt, updated},
+		{"DeletedAt", u.DeletedAt.Time, deleted},
+	} {
+		if !tt.got.Equal(tt.want) || tt.got.Location() != time.UTC {
+			t.Errorf("%s = %v, want %v in UTC", tt.field, tt.got, tt.want)
+		}
+	}
+}
+
+func TestUserProtoRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		// want is user after the round trip, when it differs
+		want *models.User
+	}{
+		{name: "zero user", user: models.User{}},
+		{name: "every field", user: fullUser(), want: func() *models.User {
+			u := fullUser()
+			u.Settings = nil
+			return &u
+		}()},
+		{name: "times in other zones come back in UTC",
+			user: models.User{
+				Base:      models.Base{ID: 1, CreatedAt: created.In(kolkata), UpdatedAt: updated.In(kolkata)},
+				DeletedAt: sql.NullTime{Time: deleted.In(kolkata), Valid: true},
+			},
+			want: &models.User{
+				Base:      models.Base{ID: 1, CreatedAt: created, UpdatedAt: updated},
+				DeletedAt: sql.NullTime{Time: deleted, Valid: true},
+			},
+		},
+		{name: "empty tags are dropped on the wire", user: models.User{Tags: []string{}}, want: &models.User{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := UserToProto(tt.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := proto.Marshal(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded userpb.User
+			if err := proto.Unmarshal(b, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			got, err := UserFromProto(&decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := tt.user
+			if tt.want != nil {
+				want = *tt.want
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
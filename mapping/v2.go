@@ -0,0 +1,86 @@
+package mapping
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"project/models"
+	"project/proto/userv2pb"
+)
+
+// SplitName splits a stored name into the first and last names of v2 of
+// the API at its first space; a single word is a first name
+func SplitName(name string) (first, last string) {
+	first, last, _ = strings.Cut(strings.TrimSpace(name), " ")
+	return first, strings.TrimSpace(last)
+}
+
+// JoinName joins v2 first and last names into the stored name
+func JoinName(first, last string) string {
+	return strings.TrimSpace(strings.TrimSpace(first) + " " + strings.TrimSpace(last))
+}
+
+// UserV2JSON is the JSON form of a user in v2 of the API
+type UserV2JSON struct {
+	ID         int            `json:"id"`
+	FirstName  string         `json:"first_name"`
+	LastName   string         `json:"last_name,omitempty"`
+	Email      string         `json:"email,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	TenantID   string         `json:"tenant_id,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  *time.Time     `json:"deleted_at,omitempty"`
+}
+
+// UserToV2JSON converts u to its v2 JSON DTO
+func UserToV2JSON(u models.User) UserV2JSON {
+	first, last := SplitName(u.Name)
+	j := UserV2JSON{
+		ID:         u.ID,
+		FirstName:  first,
+		LastName:   last,
+		Email:      u.Email,
+		Tags:       u.Tags,
+		Attributes: u.Attributes,
+		TenantID:   u.TenantID,
+		CreatedAt:  u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+	}
+	if u.DeletedAt.Valid {
+		deleted := u.DeletedAt.Time
+		j.DeletedAt = &deleted
+	}
+	return j
+}
+
+// UserToProtoV2 converts u to its v2 protobuf message
+func UserToProtoV2(u models.User) (*userv2pb.User, error) {
+	first, last := SplitName(u.Name)
+	p := &userv2pb.User{
+		Id:        int64(u.ID),
+		FirstName: first,
+		LastName:  last,
+		Email:     u.Email,
+		Tags:      u.Tags,
+		TenantId:  u.TenantID,
+		CreatedAt: timestampToProto(u.CreatedAt),
+		UpdatedAt: timestampToProto(u.UpdatedAt),
+	}
+	if u.DeletedAt.Valid {
+		p.DeletedAt = timestamppb.New(u.DeletedAt.Time)
+	}
+	if u.Attributes != nil {
+		attrs, err := structpb.NewStruct(u.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert attributes of user %d: %w", u.ID, err)
+		}
+		p.Attributes = attrs
+	}
+	return p, nil
+}
@@ -0,0 +1,39 @@
+package metrics
+
+// Bundle is the registry behind the adapter's dashboard: the instruments
+// the packages record into and the collectors they report, registered at
+// once. Its series are
+//
+//	adapter_db_query_duration_seconds{db,operation}  histogram, per statement
+//	adapter_repository_call_duration_seconds{method} histogram, per repository call
+//	adapter_repository_call_errors{method}           counter, failed repository calls
+//
+// plus those of the collectors passed to NewBundle, e.g. the adapter_db_pool_*
+// gauges of config.ConnectionManager.
+type Bundle struct {
+	*Registry
+	// QueryDuration is the duration of SQL statements, see
+	// config.ConnectionManager.QueryDuration
+	QueryDuration *HistogramVec
+	// CallDuration and CallErrors are recorded by
+	// repository.NewMetricsMiddleware
+	CallDuration *HistogramVec
+	CallErrors   *CounterVec
+}
+
+// NewBundle creates the dashboard instruments and registers them with
+// collectors in a new registry
+func NewBundle(collectors ...Collector) *Bundle {
+	b := &Bundle{
+		Registry: NewRegistry(),
+		QueryDuration: NewHistogram(Name("db", "query_duration", "seconds"),
+			"Duration of SQL statements.", "seconds", nil, "db", "operation"),
+		CallDuration: NewHistogram(Name("repository", "call_duration", "seconds"),
+			"Duration of repository calls.", "seconds", nil, "method"),
+		CallErrors: NewCounter(Name("repository", "call_errors", ""),
+			"Repository calls that returned an error.", "", "method"),
+	}
+	b.Register(b.QueryDuration, b.CallDuration, b.CallErrors)
+	b.Register(collectors...)
+	return b
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 1ms to 10s
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// exemplarFrom returns an exemplar of value for the sampled span in ctx,
+// or nil when there is none
+func exemplarFrom(ctx context.Context, value float64) *Exemplar {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return nil
+	}
+	return &Exemplar{TraceID: sc.TraceID().String(), SpanID: sc.SpanID().String(), Value: value, At: time.Now()}
+}
+
+// seriesKey joins label values into a map key
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs pairs names with values
+func labelPairs(names, values []string) []Label {
+	pairs := make([]Label, len(names))
+	for i, n := range names {
+		pairs[i] = Label{Name: n, Value: values[i]}
+	}
+	return pairs
+}
+
+// HistogramVec is a histogram partitioned by label values
+type HistogramVec struct {
+	name, help, unit string
+	buckets          []float64
+	labelNames       []string
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// histogramSeries is the state of one label combination
+type histogramSeries struct {
+	values []string
+	// counts are per bucket, not cumulative; the last is +Inf
+	counts    []uint64
+	exemplars []*Exemplar
+	sum       float64
+	count     uint64
+}
+
+// NewHistogram creates a histogram named name, see Name, with buckets in
+// increasing order, DefaultBuckets when nil, partitioned by labelNames
+func NewHistogram(name, help, unit string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &HistogramVec{
+		name: name, help: help, unit: unit,
+		buckets:    append([]float64(nil), buckets...),
+		labelNames: labelNames,
+		series:     make(map[string]*histogramSeries),
+	}
+}
+
+// Observe records v for labelValues, given in the order of the label
+// names. The sampled span in ctx, if any, becomes the exemplar of v's
+// bucket.
+func (h *HistogramVec) Observe(ctx context.Context, v float64, labelValues ...string) {
+	if len(labelValues) != len(h.labelNames) {
+		panic("metrics: " + h.name + " observed with " + strconv.Itoa(len(labelValues)) + " label values, want " + strconv.Itoa(len(h.labelNames)))
+	}
+	i := sort.SearchFloat64s(h.buckets, v)
+	ex := exemplarFrom(ctx, v)
+
+	key := seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			values:    append([]string(nil), labelValues...),
+			counts:    make([]uint64, len(h.buckets)+1),
+			exemplars: make([]*Exemplar, len(h.buckets)+1),
+		}
+		h.series[key] = s
+	}
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	if ex != nil {
+		s.exemplars[i] = ex
+	}
+}
+
+// Collect implements Collector
+func (h *HistogramVec) Collect() []Family {
+	f := Family{Name: h.name, Help: h.help, Type: Histogram, Unit: h.unit}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		base := labelPairs(h.labelNames, s.values)
+		var cumulative uint64
+		for i, c := range s.counts {
+			cumulative += c
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			labels := append(append([]Label(nil), base...), Label{Name: "le", Value: formatFloat(le)})
+			f.Samples = append(f.Samples, Sample{Suffix: "_bucket", Labels: labels, Value: float64(cumulative), Exemplar: s.exemplars[i]})
+		}
+		f.Samples = append(f.Samples,
+			Sample{Suffix: "_sum", Labels: base, Value: s.sum},
+			Sample{Suffix: "_count", Labels: base, Value: float64(s.count)})
+	}
+	return []Family{f}
+}
+
+// CounterVec is a counter partitioned by label values
+type CounterVec struct {
+	name, help, unit string
+	labelNames       []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+// counterSeries is the state of one label combination
+type counterSeries struct {
+	values   []string
+	value    float64
+	exemplar *Exemplar
+}
+
+// NewCounter creates a counter named name, see Name, partitioned by
+// labelNames
+func NewCounter(name, help, unit string, labelNames ...string) *CounterVec {
+	return &CounterVec{name: name, help: help, unit: unit, labelNames: labelNames, series: make(map[string]*counterSeries)}
+}
+
+// Add adds v, which must not be negative, for labelValues; the sampled
+// span in ctx, if any, becomes the exemplar
+func (c *CounterVec) Add(ctx context.Context, v float64, labelValues ...string) {
+	if len(labelValues) != len(c.labelNames) {
+		panic("metrics: " + c.name + " added with " + strconv.Itoa(len(labelValues)) + " label values, want " + strconv.Itoa(len(c.labelNames)))
+	}
+	if v < 0 {
+		panic("metrics: " + c.name + " can't decrease")
+	}
+	ex := exemplarFrom(ctx, v)
+
+	key := seriesKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), labelValues...)}
+		c.series[key] = s
+	}
+	s.value += v
+	if ex != nil {
+		s.exemplar = ex
+	}
+}
+
+// Collect implements Collector
+func (c *CounterVec) Collect() []Family {
+	f := Family{Name: c.name, Help: c.help, Type: Counter, Unit: c.unit}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		f.Samples = append(f.Samples, Sample{Labels: labelPairs(c.labelNames, s.values), Value: s.value, Exemplar: s.exemplar})
+	}
+	return []Family{f}
+}
+
+// sortedKeys returns the keys of m, sorted, so output is stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,94 @@
+// Package metrics exposes the adapter's metrics in the Prometheus and
+// OpenMetrics text formats under one naming scheme. Histograms and counters
+// keep the trace of their latest observation per bucket as an exemplar, so
+// a latency spike on a dashboard links to a trace of the offending call.
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Namespace prefixes every metric name
+const Namespace = "adapter"
+
+// namePattern matches the parts of a metric name
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Name returns the metric name adapter_<subsystem>_<name>_<unit>, e.g.
+// Name("db", "query_duration", "seconds"). Units are base units, seconds,
+// bytes or ratio, and empty for counts; counters get their _total suffix
+// when written. It panics on parts that aren't lower snake case, since
+// names are fixed when the program is written.
+func Name(subsystem, name, unit string) string {
+	full := Namespace
+	for _, part := range []string{subsystem, name, unit} {
+		if part == "" {
+			continue
+		}
+		if !namePattern.MatchString(part) {
+			panic(fmt.Sprintf("metrics: invalid name part %q", part))
+		}
+		full += "_" + part
+	}
+	return full
+}
+
+// Type is the type of a metric family
+type Type string
+
+// Metric types
+const (
+	Counter   Type = "counter"
+	Gauge     Type = "gauge"
+	Histogram Type = "histogram"
+)
+
+// Family is a metric and its samples
+type Family struct {
+	// Name excludes the _total suffix of counters
+	Name string
+	Help string
+	Type Type
+	// Unit is the unit Name ends with, if any
+	Unit    string
+	Samples []Sample
+}
+
+// Sample is one value of a family
+type Sample struct {
+	// Suffix extends the family name, e.g. _bucket for histograms
+	Suffix string
+	Labels []Label
+	Value  float64
+	// Exemplar, when set, is written in the OpenMetrics format only
+	Exemplar *Exemplar
+}
+
+// Label is a label name and value
+type Label struct {
+	Name, Value string
+}
+
+// Exemplar is an observation made while tracing, linking a sample to the
+// trace it was made in
+type Exemplar struct {
+	TraceID string
+	SpanID  string
+	Value   float64
+	At      time.Time
+}
+
+// Collector reports metric families when metrics are gathered
+type Collector interface {
+	Collect() []Family
+}
+
+// CollectorFunc is a Collector function
+type CollectorFunc func() []Family
+
+// Collect implements Collector
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
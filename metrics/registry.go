@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Content types of the exposition formats
+const (
+	PrometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Registry gathers the families of its collectors
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds collectors to the registry
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, collectors...)
+	r.mu.Unlock()
+}
+
+// Gather returns the families of every collector, sorted by name. Two
+// collectors reporting the same family name is an error, since the
+// scraper would reject the output.
+func (r *Registry) Gather() ([]Family, error) {
+	r.mu.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.RUnlock()
+
+	var families []Family
+	seen := make(map[string]bool)
+	for _, c := range collectors {
+		for _, f := range c.Collect() {
+			if seen[f.Name] {
+				return nil, fmt.Errorf("metric %s is reported twice", f.Name)
+			}
+			seen[f.Name] = true
+			families = append(families, f)
+		}
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families, nil
+}
+
+// Write writes the gathered families to w, in the OpenMetrics format with
+// exemplars when openMetrics is set, in the Prometheus text format otherwise
+func (r *Registry) Write(w io.Writer, openMetrics bool) error {
+	families, err := r.Gather()
+	if err != nil {
+		return err
+	}
+	return WriteText(w, families, openMetrics)
+}
+
+// Handler serves the registry's metrics, in the OpenMetrics format to
+// scrapers that accept it, so their exemplars reach the dashboards
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		openMetrics := strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text")
+		families, err := r.Gather()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if openMetrics {
+			w.Header().Set("Content-Type", OpenMetricsContentType)
+		} else {
+			w.Header().Set("Content-Type", PrometheusContentType)
+		}
+		WriteText(w, families, openMetrics)
+	})
+}
+
+// WriteText writes families in the OpenMetrics format when openMetrics is
+// set, in the Prometheus text format otherwise
+func WriteText(w io.Writer, families []Family, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		// the Prometheus format names counters with their _total suffix
+		name, suffix := f.Name, ""
+		if f.Type == Counter {
+			suffix = "_total"
+			if !openMetrics {
+				name += suffix
+			}
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(f.Help), name, f.Type)
+		if openMetrics && f.Unit != "" {
+			fmt.Fprintf(bw, "# UNIT %s %s\n", name, f.Unit)
+		}
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name + suffix + s.Suffix)
+			writeLabels(bw, s.Labels)
+			bw.WriteString(" " + formatFloat(s.Value))
+			if openMetrics && s.Exemplar != nil {
+				ex := s.Exemplar
+				bw.WriteString(" # ")
+				writeLabels(bw, []Label{{Name: "trace_id", Value: ex.TraceID}, {Name: "span_id", Value: ex.SpanID}})
+				fmt.Fprintf(bw, " %s %.3f", formatFloat(ex.Value), float64(ex.At.UnixMilli())/1000)
+			}
+			bw.WriteByte('\n')
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// writeLabels writes a label set in braces, nothing when it is empty
+func writeLabels(w *bufio.Writer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	w.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(l.Name + `="` + labelValueEscaper.Replace(l.Value) + `"`)
+	}
+	w.WriteByte('}')
+}
+
+// labelValueEscaper escapes label values for both formats
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeHelp escapes help texts for both formats
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// formatFloat formats a sample value, with the infinities both formats
+// spell the same way
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
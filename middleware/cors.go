@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures cross-origin requests
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to call the API, e.g.
+	// "https://app.example.com", or "*" for any; none disables CORS
+	AllowedOrigins []string `json:"allowed_origins"`
+	// AllowedMethods defaults to GET, POST, PUT, PATCH and DELETE
+	AllowedMethods []string `json:"allowed_methods"`
+	// AllowedHeaders are the request headers clients may send, beyond the
+	// CORS-safelisted ones
+	AllowedHeaders []string `json:"allowed_headers"`
+	// ExposedHeaders are the response headers scripts may read
+	ExposedHeaders []string `json:"exposed_headers"`
+	// AllowCredentials allows cookies and the Authorization header; the
+	// matching origin is echoed instead of "*"
+	AllowCredentials bool `json:"allow_credentials"`
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration `json:"max_age"`
+}
+
+// defaultCORSMethods are allowed when CORSConfig.AllowedMethods is empty
+var defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// allowsOrigin reports whether origin may call the API
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	return slices.Contains(c.AllowedOrigins, "*") || slices.Contains(c.AllowedOrigins, origin)
+}
+
+// CORS answers preflight requests and adds the CORS headers for allowed
+// origins; requests from other origins pass through without them, so the
+// browser blocks the response
+func CORS(cfg CORSConfig) Middleware {
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || !cfg.allowsOrigin(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if cfg.AllowCredentials || !slices.Contains(cfg.AllowedOrigins, "*") {
+				h.Set("Access-Control-Allow-Origin", origin)
+			} else {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+				if len(cfg.AllowedHeaders) > 0 {
+					h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+				}
+				if cfg.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if len(cfg.ExposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/apperr"
+	"project/auth"
+	"project/requestid"
+)
+
+// GRPCServerOptions returns the interceptor chains matching Stack for a
+// gRPC server: request IDs, logging, metrics when set, recovery and, when
+// authn is set, API key authentication with the scopes of auth.
+// UnaryServerInterceptor
+func GRPCServerOptions(logger *slog.Logger, metrics *Metrics, authn auth.Authenticator, scopes map[string]string) []grpc.ServerOption {
+	unary := []grpc.UnaryServerInterceptor{requestid.UnaryServerInterceptor, UnaryLogging(logger)}
+	stream := []grpc.StreamServerInterceptor{requestid.StreamServerInterceptor, StreamLogging(logger)}
+	if metrics != nil {
+		unary = append(unary, metrics.Unary)
+		stream = append(stream, metrics.Stream)
+	}
+	unary = append(unary, UnaryRecovery(logger))
+	stream = append(stream, StreamRecovery(logger))
+	if authn != nil {
+		unary = append(unary, auth.UnaryServerInterceptor(authn, scopes))
+		stream = append(stream, auth.StreamServerInterceptor(authn, scopes))
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+}
+
+// grpcCode returns the status code a call ended with; errors that aren't
+// statuses are mapped by their apperr code
+func grpcCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	return apperr.GRPCCode(apperr.CodeOf(err))
+}
+
+// serverFault reports whether code is the server's fault, the gRPC
+// counterpart of a 5xx status
+func serverFault(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// logCall logs one gRPC call like Logging logs an HTTP request
+func logCall(ctx context.Context, logger *slog.Logger, method string, err error, start time.Time) {
+	code := grpcCode(err)
+	level := slog.LevelInfo
+	if serverFault(code) {
+		level = slog.LevelError
+	}
+	logger.LogAttrs(ctx, level, "rpc",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+	)
+}
+
+// UnaryLogging logs every unary call with its status code and latency
+func UnaryLogging(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, err, start)
+		return resp, err
+	}
+}
+
+// StreamLogging is UnaryLogging for streaming calls
+func StreamLogging(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), logger, info.FullMethod, err, start)
+		return err
+	}
+}
+
+// Unary records every unary call; the method attribute is the full gRPC
+// method name
+func (m *Metrics) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	code := grpcCode(err)
+	m.record(ctx, "grpc", info.FullMethod, code.String(), serverFault(code), time.Since(start))
+	return resp, err
+}
+
+// Stream is Unary for streaming calls
+func (m *Metrics) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	code := grpcCode(err)
+	m.record(ss.Context(), "grpc", info.FullMethod, code.String(), serverFault(code), time.Since(start))
+	return err
+}
+
+// errPanic is sent to clients whose call panicked
+var errPanic = status.Error(codes.Internal, "internal error")
+
+// recoverCall turns a panic into errPanic, logging it and its stack
+func recoverCall(ctx context.Context, logger *slog.Logger, err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	logger.ErrorContext(ctx, "Handler panicked", "panic", v, "stack", string(debug.Stack()))
+	*err = errPanic
+}
+
+// UnaryRecovery answers a unary call whose handler panicked with Internal,
+// as Recovery answers HTTP requests with 500
+func UnaryRecovery(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer recoverCall(ctx, logger, &err)
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecovery is UnaryRecovery for streaming calls
+func StreamRecovery(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverCall(ss.Context(), logger, &err)
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// instrumentationName identifies this package to OpenTelemetry
+const instrumentationName = "project/middleware"
+
+// Metrics records every HTTP request and gRPC call as OpenTelemetry
+// metrics: server.request.duration (s) and server.request.errors, both with
+// transport, method and status attributes, so the two transports can be
+// compared on one dashboard
+type Metrics struct {
+	duration metric.Float64Histogram
+	failures metric.Int64Counter
+}
+
+// NewMetrics creates the instruments on mp, the global provider when nil
+func NewMetrics(mp metric.MeterProvider) (*Metrics, error) {
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+
+	duration, err := meter.Float64Histogram("server.request.duration",
+		metric.WithDescription("Duration of served requests"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create duration histogram: %w", err)
+	}
+
+	failures, err := meter.Int64Counter("server.request.errors",
+		metric.WithDescription("Served requests that failed on the server side"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create error counter: %w", err)
+	}
+	return &Metrics{duration: duration, failures: failures}, nil
+}
+
+// record records one request
+func (m *Metrics) record(ctx context.Context, transport, method, status string, failed bool, elapsed time.Duration) {
+	attrs := metric.WithAttributes(
+		attribute.String("transport", transport),
+		attribute.String("method", method),
+		attribute.String("status", status),
+	)
+	m.duration.Record(ctx, elapsed.Seconds(), attrs)
+	if failed {
+		m.failures.Add(ctx, 1, attrs)
+	}
+}
+
+// HTTP is a Middleware recording every request; the method attribute is
+// the HTTP method, keeping paths with IDs out of the metric labels
+func (m *Metrics) HTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.record(r.Context(), "http", r.Method, strconv.Itoa(status), status >= http.StatusInternalServerError, time.Since(start))
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,73 @@
+// Package middleware is the standard handler chain of the REST layer:
+// request IDs, request logging, metrics, panic recovery, CORS and gzip;
+// and the matching gRPC interceptors
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"project/requestid"
+)
+
+// Middleware wraps an http.Handler
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in mws, the first outermost
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Stack returns the standard chain: request IDs, logging, metrics when
+// set, recovery, CORS and gzip, in that order, so logs and metrics carry
+// the request ID and the status a recovered panic was answered with
+func Stack(logger *slog.Logger, metrics *Metrics, cors CORSConfig) []Middleware {
+	mws := []Middleware{requestid.Middleware, Logging(logger)}
+	if metrics != nil {
+		mws = append(mws, metrics.HTTP)
+	}
+	return append(mws, Recovery(logger), CORS(cors), Gzip)
+}
+
+// responseRecorder records the status and size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, so streaming handlers keep streaming
+func (w *responseRecorder) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name TEXT NOT NULL,
+    tenant TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (name, tenant)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS quota_counters (
+    tenant TEXT NOT NULL,
+    counter TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    value BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant, counter, window_start)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BIGINT NOT NULL,
+    channel TEXT NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, channel),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
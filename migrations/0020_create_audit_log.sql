@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT,
+    action TEXT,
+    subject TEXT,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_subject_idx ON audit_log (subject);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
@@ -0,0 +1,15 @@
+CREATE MATERIALIZED VIEW IF NOT EXISTS user_counts_by_day AS
+    SELECT tenant_id, CAST(created_at AS DATE) AS day, COUNT(*) AS users
+    FROM users
+    WHERE deleted_at IS NULL
+    GROUP BY tenant_id, CAST(created_at AS DATE);
+
+-- REFRESH ... CONCURRENTLY requires a unique index
+CREATE UNIQUE INDEX IF NOT EXISTS user_counts_by_day_key ON user_counts_by_day (tenant_id, day);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS organization_member_counts AS
+    SELECT o.id AS organization_id, o.tenant_id, COUNT(m.user_id) AS members
+    FROM organizations o LEFT JOIN memberships m ON m.organization_id = o.id
+    GROUP BY o.id, o.tenant_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS organization_member_counts_key ON organization_member_counts (organization_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS user_summary (
+    user_id BIGINT PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT '',
+    name TEXT,
+    email TEXT NOT NULL DEFAULT '',
+    organizations BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ,
+    refreshed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_summary_tenant_id_idx ON user_summary (tenant_id);
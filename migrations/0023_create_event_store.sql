@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS event_streams (
+    id BIGSERIAL PRIMARY KEY,
+    aggregate TEXT NOT NULL,
+    version BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS event_streams_aggregate_idx ON event_streams (aggregate);
+
+CREATE TABLE IF NOT EXISTS events (
+    id BIGSERIAL PRIMARY KEY,
+    stream_id BIGINT NOT NULL,
+    version BIGINT NOT NULL,
+    type TEXT NOT NULL,
+    data JSONB NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (stream_id, version),
+    FOREIGN KEY (stream_id) REFERENCES event_streams (id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS snapshots (
+    stream_id BIGINT PRIMARY KEY,
+    version BIGINT NOT NULL,
+    schema_version INTEGER NOT NULL,
+    state JSONB NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (stream_id) REFERENCES event_streams (id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_count_shards (
+    shard INT PRIMARY KEY,
+    value BIGINT NOT NULL DEFAULT 0
+);
+
+-- seed with the live users so far; reconciliation keeps it honest after
+INSERT INTO user_count_shards (shard, value)
+SELECT s, CASE WHEN s = 0 THEN (SELECT COUNT(*) FROM users WHERE deleted_at IS NULL) ELSE 0 END
+FROM generate_series(0, 15) AS s
+ON CONFLICT (shard) DO NOTHING;
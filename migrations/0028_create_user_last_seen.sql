@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS user_last_seen (
+    user_id BIGINT PRIMARY KEY,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS import_checkpoints (
+    job TEXT PRIMARY KEY,
+    "offset" BIGINT NOT NULL,
+    batch INT NOT NULL,
+    created BIGINT NOT NULL DEFAULT 0,
+    duplicates BIGINT NOT NULL DEFAULT 0,
+    invalid BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,5 @@
+-- binary payloads, e.g. Protobuf or Avro events, go in body; JSON stays in
+-- payload so it remains queryable
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT 'application/json';
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS body BYTEA;
+ALTER TABLE outbox ALTER COLUMN payload DROP NOT NULL;
@@ -0,0 +1,16 @@
+-- outbox messages that exhausted their delivery attempts, kept for
+-- inspection and replay
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    outbox_id BIGINT NOT NULL,
+    topic TEXT NOT NULL,
+    content_type TEXT NOT NULL DEFAULT 'application/json',
+    payload JSONB,
+    body BYTEA,
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    replayed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS dead_letters_topic_idx ON dead_letters (topic, failed_at) WHERE replayed_at IS NULL;
@@ -0,0 +1,7 @@
+-- bcrypt hashes of user passwords, set through the password policy
+CREATE TABLE IF NOT EXISTS user_passwords (
+    user_id BIGINT PRIMARY KEY,
+    password_hash TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,23 @@
+-- search ignores diacritics: "jose" finds "José". unaccent is only STABLE,
+-- so the generated column needs an IMMUTABLE wrapper.
+--
+-- Replacing search_vector rewrites users under an ACCESS EXCLUSIVE lock:
+-- reads and writes of users wait until every row is recomputed, which
+-- takes minutes on large tables, and the GIN index build after it holds a
+-- SHARE lock that blocks writes. Apply it in a maintenance window, with
+-- the API stopped or read-only. A stored generated column can't be
+-- backfilled in batches, so the expand/contract steps of
+-- repository.MigrationStep don't apply here.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+    AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$;
+
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE users
+    ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', immutable_unaccent(coalesce(name, '')))) STORED;
+
+CREATE INDEX IF NOT EXISTS users_search_vector_idx ON users USING GIN (search_vector);
@@ -0,0 +1,23 @@
+-- users_search flattens each live user, with its memberships, password
+-- and last sighting, into one row so filters don't join the write tables.
+-- It is maintained from domain events; `adapter rebuild-readmodel`
+-- regenerates it.
+CREATE TABLE IF NOT EXISTS users_search (
+    user_id BIGINT PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL DEFAULT '',
+    name_folded TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    email_domain TEXT NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    organizations BIGINT NOT NULL DEFAULT 0,
+    has_password BOOLEAN NOT NULL DEFAULT FALSE,
+    last_seen_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    refreshed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS users_search_tenant_created_idx ON users_search (tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS users_search_name_folded_idx ON users_search (tenant_id, name_folded text_pattern_ops);
+CREATE INDEX IF NOT EXISTS users_search_email_domain_idx ON users_search (tenant_id, email_domain);
+CREATE INDEX IF NOT EXISTS users_search_tags_idx ON users_search USING GIN (tags);
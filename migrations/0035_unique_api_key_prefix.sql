@@ -0,0 +1,11 @@
+-- API keys are looked up by prefix, so a prefix must name one key.
+-- 0017 now creates api_keys_prefix_idx unique; this replaces the plain
+-- index on databases that applied 0017 before. It fails while duplicate
+-- prefixes exist; find them with
+--
+--   SELECT prefix FROM api_keys GROUP BY prefix HAVING count(*) > 1;
+--
+-- and revoke and delete all but one key of each before applying it.
+DROP INDEX IF EXISTS api_keys_prefix_idx;
+
+CREATE UNIQUE INDEX api_keys_prefix_idx ON api_keys (prefix);
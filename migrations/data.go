@@ -0,0 +1,20 @@
+package migrations
+
+import "project/repository"
+
+// Data returns the versioned data migrations, run by `adapter migrate up`
+// after the schema migrations. They call users with the context they are
+// given, so their reads and writes share the migration's transaction, e.g.
+//
+//	{
+//		Version: 1,
+//		Name:    "backfill_user_email",
+//		Schema:  12,
+//		Up: func(ctx context.Context, tx *sql.Tx) error {
+//			_, err := tx.ExecContext(ctx, "UPDATE users SET email = ... WHERE email = ''")
+//			return err
+//		},
+//	}
+func Data(users repository.UserRepository) []repository.DataMigration {
+	return nil
+}
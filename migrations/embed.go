@@ -0,0 +1,9 @@
+// Package migrations embeds the versioned SQL migrations into the binary
+package migrations
+
+import "embed"
+
+// FS holds the versioned migration files, named <version>_<name>.sql
+//
+//go:embed *.sql
+var FS embed.FS
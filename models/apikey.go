@@ -0,0 +1,30 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// APIKey authenticates requests on behalf of a user. Only a hash of the
+// secret is stored; the full key is shown once, when it is minted.
+type APIKey struct {
+	Base
+	UserID int    `db:"user_id,fk=users.id,ondelete=cascade,index"`
+	Name   string `db:"name" validate:"max=100"`
+
+	// Prefix is the public part of the key, used to look it up
+	Prefix     string `db:"prefix,unique"`
+	SecretHash string `db:"secret_hash"`
+
+	// Scopes limit what the key may do, e.g. "users:read"
+	Scopes []string `db:"scopes,default='{}'" validate:"max=20,dive,required,max=50"`
+
+	ExpiresAt  sql.NullTime `db:"expires_at"`
+	RevokedAt  sql.NullTime `db:"revoked_at"`
+	LastUsedAt sql.NullTime `db:"last_used_at"`
+}
+
+// Active reports whether the key is neither revoked nor expired at now
+func (k APIKey) Active(now time.Time) bool {
+	return !k.RevokedAt.Valid && (!k.ExpiresAt.Valid || now.Before(k.ExpiresAt.Time))
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AuditEntry records an action taken on a subject, e.g. "user:42". Entries
+// outlive their subject, so they must not hold personal data.
+type AuditEntry struct {
+	ID        int       `db:"id,primary"`
+	Actor     string    `db:"actor"`
+	Action    string    `db:"action"`
+	Subject   string    `db:"subject,index"`
+	Details   JSONMap   `db:"details,default='{}'"`
+	CreatedAt time.Time `db:"created_at,default=CURRENT_TIMESTAMP,index"`
+}
+
+// TableName implements repository.Tabler
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
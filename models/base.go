@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Base holds the columns shared by every model. Embed it by value so
+// AutoMigrate flattens its fields into the model's table.
+type Base struct {
+	ID        int       `db:"id,primary"`
+	CreatedAt time.Time `db:"created_at,default=CURRENT_TIMESTAMP,index"`
+	UpdatedAt time.Time `db:"updated_at,default=CURRENT_TIMESTAMP"`
+}
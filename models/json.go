@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONMap is a free-form JSON object column (JSONB on Postgres, JSON on MySQL)
+type JSONMap map[string]any
+
+// Value encodes the map as JSON for storage; nil is stored as {}
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	b, err := json.Marshal(map[string]any(m))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode json: %w", err)
+	}
+	return b, nil
+}
+
+// Scan decodes a JSON column into the map
+func (m *JSONMap) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONMap", src)
+	}
+
+	out := make(JSONMap)
+	if err := json.Unmarshal(b, &out); err != nil {
+		return fmt.Errorf("failed to decode json: %w", err)
+	}
+	*m = out
+	return nil
+}
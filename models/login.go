@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginAttempt records one attempt to log in as a user
+type LoginAttempt struct {
+	ID          int       `db:"id,primary"`
+	UserID      int       `db:"user_id,fk=users.id,ondelete=cascade,index"`
+	IP          string    `db:"ip"`
+	Succeeded   bool      `db:"succeeded"`
+	AttemptedAt time.Time `db:"attempted_at,default=CURRENT_TIMESTAMP,index"`
+}
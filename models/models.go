@@ -0,0 +1,6 @@
+package models
+
+// All returns every model managed by AutoMigrate, in dependency order
+func All() []any {
+	return []any{User{}, UserSettings{}, Post{}, Organization{}, Membership{}, APIKey{}, LoginAttempt{}, AuditEntry{}}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Organization groups users, e.g. a company or a team
+type Organization struct {
+	Base
+	Name string `db:"name" validate:"required,max=100"`
+
+	// TenantID scopes the organization like User.TenantID
+	TenantID string `db:"tenant_id,tenant,default='',index"`
+}
+
+// Membership roles
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Membership makes a user a member of an organization. Deleting either
+// side deletes the membership.
+type Membership struct {
+	OrganizationID int       `db:"organization_id,primary,fk=organizations.id,ondelete=cascade"`
+	UserID         int       `db:"user_id,primary,fk=users.id,ondelete=cascade,index"`
+	Role           string    `db:"role,default='member'" validate:"omitempty,oneof=owner admin member"`
+	CreatedAt      time.Time `db:"created_at,default=CURRENT_TIMESTAMP"`
+
+	// User is populated only by ListMembers
+	User *User `db:"-"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// Post represents a post written by a user
+type Post struct {
+	Base
+	UserID int    `db:"user_id,fk=users.id,ondelete=cascade"`
+	Title  string `db:"title"`
+	Body   string `db:"body"`
+}
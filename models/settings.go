@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// UserSettings holds a user's preferences, one row per user
+type UserSettings struct {
+	UserID     int       `db:"user_id,primary,manual,fk=users.id,ondelete=cascade"`
+	Locale     string    `db:"locale,default='en'" validate:"omitempty,bcp47_language_tag"`
+	Timezone   string    `db:"timezone,default='UTC'" validate:"omitempty,timezone"`
+	Theme      string    `db:"theme,default='system'" validate:"omitempty,oneof=light dark system"`
+	EmailOptIn bool      `db:"email_opt_in,default=TRUE"`
+	UpdatedAt  time.Time `db:"updated_at,default=CURRENT_TIMESTAMP"`
+}
+
+// DefaultUserSettings returns the settings of a user who never saved any,
+// matching the column defaults
+func DefaultUserSettings(userID int) UserSettings {
+	return UserSettings{UserID: userID, Locale: "en", Timezone: "UTC", Theme: "system", EmailOptIn: true}
+}
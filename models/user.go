@@ -1,7 +1,31 @@
 package models
 
+import "database/sql"
+
 // User represents a user entity in the system
 type User struct {
-	ID   int    `db:id, primary`
-	Name string `db:"name"`
+	Base
+	Name string `db:"name,search,collate" validate:"required,max=100"`
+
+	// Email is optional; registration sends a welcome email when set
+	Email string `db:"email,default=''" validate:"omitempty,email,max=254"`
+
+	// Tags are free-form labels, stored as TEXT[] on Postgres and JSON on MySQL
+	Tags []string `db:"tags,default='{}'" validate:"max=20,dive,required,max=50"`
+
+	// Attributes holds deployment-specific metadata without schema changes
+	Attributes JSONMap `db:"attributes,default='{}'"`
+
+	// TenantID scopes the user to a tenant; Postgres row-level security
+	// policies filter on it when enabled
+	TenantID string `db:"tenant_id,tenant,default='',index"`
+
+	// DeletedAt is set when the user is soft-deleted
+	DeletedAt sql.NullTime `db:"deleted_at,index"`
+
+	// Posts is populated only by the preloading repository methods
+	Posts []Post `db:"-"`
+
+	// Settings is populated only by GetUserWithSettings
+	Settings *UserSettings `db:"-"`
 }
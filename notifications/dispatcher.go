@@ -0,0 +1,78 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"project/repository"
+)
+
+// NotifyTopic is the outbox topic of notifications sent to a user on each
+// channel they enabled
+const NotifyTopic = "notify"
+
+// Notification is the outbox payload of a NotifyTopic message
+type Notification struct {
+	UserID   int            `json:"user_id"`
+	Template string         `json:"template"`
+	Data     map[string]any `json:"data"`
+}
+
+// Dispatcher renders notifications and routes them to the providers of
+// the channels a user has enabled
+type Dispatcher struct {
+	providers map[Channel]Provider
+	templates *Templates
+	prefs     repository.PreferenceRepository
+}
+
+// NewDispatcher creates a dispatcher reading preferences from prefs
+func NewDispatcher(templates *Templates, prefs repository.PreferenceRepository, providers ...Provider) *Dispatcher {
+	d := &Dispatcher{providers: make(map[Channel]Provider), templates: templates, prefs: prefs}
+	for _, p := range providers {
+		d.providers[p.Channel()] = p
+	}
+	return d
+}
+
+// Send renders template for channel and delivers it to the address to
+func (d *Dispatcher) Send(ctx context.Context, channel Channel, to, template string, data any) error {
+	provider, ok := d.providers[channel]
+	if !ok {
+		return fmt.Errorf("no provider for channel %s", channel)
+	}
+
+	msg, err := d.templates.Render(template, channel, to, data)
+	if err != nil {
+		return err
+	}
+	return provider.Send(ctx, msg)
+}
+
+// Notify sends template to the user on every enabled channel that has an
+// address, a provider and a template. Failures on some channels don't stop
+// the others; they are returned joined, and a retry of the whole
+// notification may repeat the channels that succeeded.
+func (d *Dispatcher) Notify(ctx context.Context, userID int, template string, data any) error {
+	if d.prefs == nil {
+		return fmt.Errorf("no channel preferences to notify user %d", userID)
+	}
+
+	prefs, err := d.prefs.ChannelPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pref := range prefs {
+		channel := Channel(pref.Channel)
+		if !pref.Enabled || pref.Address == "" || d.providers[channel] == nil || !d.templates.Has(template, channel) {
+			continue
+		}
+		if err := d.Send(ctx, channel, pref.Address, template, data); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// Email is a plain-text message to deliver
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// SMTPMailer sends email through an SMTP relay
+type SMTPMailer struct {
+	// Addr is the relay's host:port
+	Addr string
+	From string
+	// Auth authenticates with the relay; nil sends unauthenticated
+	Auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer using PLAIN auth when username is set
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	m := &SMTPMailer{Addr: addr, From: from}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		m.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send delivers email. net/smtp doesn't take a context, so ctx is only
+// checked before dialling.
+func (m *SMTPMailer) Send(ctx context.Context, email Email) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		m.From, email.To, email.Subject, email.Body)
+	if err := smtp.SendMail(m.Addr, m.Auth, m.From, []string{email.To}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email via SMTP: %w", err)
+	}
+	return nil
+}
+
+// SESClient is the subset of an Amazon SES client the SES mailer needs, so
+// the AWS SDK stays out of this module; adapt sesv2.Client.SendEmail to it.
+type SESClient interface {
+	SendEmail(ctx context.Context, from string, email Email) error
+}
+
+// SESMailer sends email through Amazon SES
+type SESMailer struct {
+	Client SESClient
+	From   string
+}
+
+// NewSESMailer creates a mailer sending from the verified address from
+func NewSESMailer(client SESClient, from string) *SESMailer {
+	return &SESMailer{Client: client, From: from}
+}
+
+// Send delivers email
+func (m *SESMailer) Send(ctx context.Context, email Email) error {
+	if err := m.Client.SendEmail(ctx, m.From, email); err != nil {
+		return fmt.Errorf("failed to send email via SES: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+package notifications
+
+// OrganizationReadyTemplate names the templates of the notification sent
+// to the owner of a newly provisioned organization; its data carries the
+// Organization name
+const OrganizationReadyTemplate = "organization_ready"
@@ -0,0 +1,50 @@
+package notifications
+
+import (
+	"context"
+)
+
+// Channel is a way of reaching a user
+type Channel string
+
+// Supported channels
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+	ChannelPush  Channel = "push"
+)
+
+// Message is a rendered notification for one recipient
+type Message struct {
+	// To is the channel's address: an email address, an E.164 phone
+	// number or a device token
+	To      string
+	Subject string
+	Body    string
+}
+
+// Provider delivers messages on one channel
+type Provider interface {
+	Channel() Channel
+	Send(ctx context.Context, msg Message) error
+}
+
+// emailProvider adapts a Mailer to a Provider
+type emailProvider struct {
+	mailer Mailer
+}
+
+// NewEmailProvider delivers the email channel with mailer
+func NewEmailProvider(mailer Mailer) Provider {
+	return emailProvider{mailer: mailer}
+}
+
+// Channel implements Provider
+func (p emailProvider) Channel() Channel {
+	return ChannelEmail
+}
+
+// Send implements Provider
+func (p emailProvider) Send(ctx context.Context, msg Message) error {
+	return p.mailer.Send(ctx, Email{To: msg.To, Subject: msg.Subject, Body: msg.Body})
+}
@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TwilioSMS sends text messages through the Twilio Messages API
+type TwilioSMS struct {
+	AccountSID string
+	AuthToken  string
+	// From is the sending phone number or messaging service SID
+	From string
+
+	// BaseURL defaults to the Twilio API
+	BaseURL string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// NewTwilioSMS creates a Twilio SMS provider
+func NewTwilioSMS(accountSID, authToken, from string) *TwilioSMS {
+	return &TwilioSMS{AccountSID: accountSID, AuthToken: authToken, From: from}
+}
+
+// Channel implements Provider
+func (t *TwilioSMS) Channel() Channel {
+	return ChannelSMS
+}
+
+// Send implements Provider; the subject is not part of a text message
+func (t *TwilioSMS) Send(ctx context.Context, msg Message) error {
+	base := t.BaseURL
+	if base == "" {
+		base = "https://api.twilio.com"
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", base, url.PathEscape(t.AccountSID))
+
+	form := url.Values{"To": {msg.To}, "From": {t.From}, "Body": {msg.Body}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build SMS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+
+	return send(httpClient(t.Client), req, "SMS")
+}
+
+// httpClient returns c, or the default client when nil
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+// send performs req, failing on a non-2xx response
+func send(client *http.Client, req *http.Request, what string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to send %s: %s: %s", what, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"text/template"
+)
+
+// Templates holds the notification templates, one per name and channel,
+// so each channel gets copy suited to it, e.g. a short text for SMS
+type Templates struct {
+	mu        sync.RWMutex
+	templates map[string]map[Channel]channelTemplate
+}
+
+// channelTemplate is the parsed subject and body of one template
+type channelTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewTemplates creates an empty template set
+func NewTemplates() *Templates {
+	return &Templates{templates: make(map[string]map[Channel]channelTemplate)}
+}
+
+// DefaultTemplates returns the templates of the built-in notifications
+func DefaultTemplates() *Templates {
+	t := NewTemplates()
+	t.must(WelcomeTemplate, ChannelEmail, "Welcome", "Hi {{.UserName}},\n\nWelcome aboard! Your account is ready to use.\n")
+	t.must(WelcomeTemplate, ChannelSMS, "", "Welcome aboard, {{.UserName}}! Your account is ready.")
+	t.must(WelcomeTemplate, ChannelPush, "Welcome", "Your account is ready, {{.UserName}}.")
+	t.must(OrganizationReadyTemplate, ChannelEmail, "{{.Organization}} is ready", "Your organization {{.Organization}} is set up and its members have been added.\n")
+	t.must(OrganizationReadyTemplate, ChannelPush, "Organization ready", "{{.Organization}} is set up.")
+	return t
+}
+
+// Register parses and adds the subject and body text/templates of name on
+// channel, replacing any already registered
+func (t *Templates) Register(name string, channel Channel, subject, body string) error {
+	st, err := template.New(name + ".subject").Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s subject template for %s: %w", name, channel, err)
+	}
+	bt, err := template.New(name + ".body").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s body template for %s: %w", name, channel, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.templates[name] == nil {
+		t.templates[name] = make(map[Channel]channelTemplate)
+	}
+	t.templates[name][channel] = channelTemplate{subject: st, body: bt}
+	return nil
+}
+
+func (t *Templates) must(name string, channel Channel, subject, body string) {
+	if err := t.Register(name, channel, subject, body); err != nil {
+		panic(err)
+	}
+}
+
+// Has reports whether name has a template for channel
+func (t *Templates) Has(name string, channel Channel) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, ok := t.templates[name][channel]
+	return ok
+}
+
+// Render renders name for channel with data into a message to to
+func (t *Templates) Render(name string, channel Channel, to string, data any) (Message, error) {
+	t.mu.RLock()
+	tmpl, ok := t.templates[name][channel]
+	t.mu.RUnlock()
+	if !ok {
+		return Message{}, fmt.Errorf("no %s template for channel %s", name, channel)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject for %s: %w", name, channel, err)
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s body for %s: %w", name, channel, err)
+	}
+	return Message{To: to, Subject: subject.String(), Body: body.String()}, nil
+}
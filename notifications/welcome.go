@@ -0,0 +1,13 @@
+package notifications
+
+// WelcomeTopic is the outbox topic of welcome emails
+const WelcomeTopic = "email.welcome"
+
+// WelcomeTemplate names the welcome notification's templates
+const WelcomeTemplate = "welcome"
+
+// Welcome is the outbox payload of a welcome email
+type Welcome struct {
+	UserName string `json:"user_name"`
+	Email    string `json:"email"`
+}
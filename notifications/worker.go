@@ -0,0 +1,74 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"project/repository"
+)
+
+// Worker delivers queued notifications from the outbox with a Dispatcher.
+// Failed sends are retried by the outbox with backoff until they are
+// marked failed.
+type Worker struct {
+	outbox     *repository.Outbox
+	dispatcher *Dispatcher
+
+	// Interval is how long Run waits when the outbox is drained
+	Interval time.Duration
+	// BatchSize is how many messages are claimed at once
+	BatchSize int
+}
+
+// NewWorker creates a worker polling outbox every five seconds
+func NewWorker(outbox *repository.Outbox, dispatcher *Dispatcher) *Worker {
+	return &Worker{outbox: outbox, dispatcher: dispatcher, Interval: 5 * time.Second, BatchSize: 50}
+}
+
+// Run delivers messages until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) error {
+	for {
+		n, err := w.RunOnce(ctx)
+		if err != nil {
+			log.Printf("Failed to process outbox: %v", err)
+		}
+
+		if n < w.BatchSize {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(w.Interval):
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// RunOnce delivers one batch, returning how many messages it handled
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	return w.outbox.Process(ctx, w.BatchSize, w.deliver)
+}
+
+// deliver dispatches one outbox message
+func (w *Worker) deliver(ctx context.Context, msg repository.OutboxMessage) error {
+	switch msg.Topic {
+	case WelcomeTopic:
+		var welcome Welcome
+		if err := json.Unmarshal(msg.Payload, &welcome); err != nil {
+			return fmt.Errorf("failed to decode welcome email: %w", err)
+		}
+		return w.dispatcher.Send(ctx, ChannelEmail, welcome.Email, WelcomeTemplate, welcome)
+	case NotifyTopic:
+		var n Notification
+		if err := json.Unmarshal(msg.Payload, &n); err != nil {
+			return fmt.Errorf("failed to decode notification: %w", err)
+		}
+		return w.dispatcher.Notify(ctx, n.UserID, n.Template, n.Data)
+	default:
+		return fmt.Errorf("unknown outbox topic %q", msg.Topic)
+	}
+}
@@ -0,0 +1,170 @@
+// Package presence tracks which users are online from the last time they
+// were seen, e.g. by a request, a login or a WebSocket heartbeat.
+package presence
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store keeps the online set: the users seen recently and when
+type Store interface {
+	// Touch records when each user was last seen; earlier times than the
+	// stored ones are ignored
+	Touch(ctx context.Context, seen map[int]time.Time) error
+	// Online returns up to limit users seen at or after since, the most
+	// recent first; limit <= 0 returns all of them
+	Online(ctx context.Context, since time.Time, limit int) ([]int, error)
+	// LastSeen returns when the user was last seen, and false if they are
+	// not in the set
+	LastSeen(ctx context.Context, userID int) (time.Time, bool, error)
+	// Prune drops the users last seen before before
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// MemoryStore is a Store in process memory, for a single instance or tests
+type MemoryStore struct {
+	mu   sync.Mutex
+	seen map[int]time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{seen: make(map[int]time.Time)}
+}
+
+// Touch implements Store
+func (m *MemoryStore) Touch(_ context.Context, seen map[int]time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, t := range seen {
+		if t.After(m.seen[id]) {
+			m.seen[id] = t
+		}
+	}
+	return nil
+}
+
+// Online implements Store
+func (m *MemoryStore) Online(_ context.Context, since time.Time, limit int) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []int
+	for id, t := range m.seen {
+		if !t.Before(since) {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b int) int {
+		if c := m.seen[b].Compare(m.seen[a]); c != 0 {
+			return c
+		}
+		return a - b
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// LastSeen implements Store
+func (m *MemoryStore) LastSeen(_ context.Context, userID int) (time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.seen[userID]
+	return t, ok, nil
+}
+
+// Prune implements Store
+func (m *MemoryStore) Prune(_ context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, t := range m.seen {
+		if t.Before(before) {
+			delete(m.seen, id)
+		}
+	}
+	return nil
+}
+
+// RedisClient is the subset of a Redis client RedisStore needs, so no Redis
+// driver is pulled into this module. ZAddGT is ZADD key GT with the members
+// and their scores; ZRevRangeByScore is ZREVRANGEBYSCORE key max min LIMIT 0
+// count, where count <= 0 means no LIMIT.
+type RedisClient interface {
+	ZAddGT(ctx context.Context, key string, members map[string]float64) error
+	ZRevRangeByScore(ctx context.Context, key string, max, min float64, count int) ([]string, error)
+	ZScore(ctx context.Context, key, member string) (float64, bool, error)
+	ZRemRangeByScore(ctx context.Context, key string, min, max float64) error
+}
+
+// RedisStore keeps the online set in the Redis sorted set "presence:online",
+// scored by the Unix time in milliseconds each user was last seen, so every
+// instance shares it
+type RedisStore struct {
+	client RedisClient
+	key    string
+}
+
+// NewRedisStore creates a store on Redis
+func NewRedisStore(client RedisClient) *RedisStore {
+	return &RedisStore{client: client, key: "presence:online"}
+}
+
+// Touch implements Store
+func (r *RedisStore) Touch(ctx context.Context, seen map[int]time.Time) error {
+	if len(seen) == 0 {
+		return nil
+	}
+	members := make(map[string]float64, len(seen))
+	for id, t := range seen {
+		members[strconv.Itoa(id)] = float64(t.UnixMilli())
+	}
+	if err := r.client.ZAddGT(ctx, r.key, members); err != nil {
+		return fmt.Errorf("failed to update online set: %w", err)
+	}
+	return nil
+}
+
+// Online implements Store
+func (r *RedisStore) Online(ctx context.Context, since time.Time, limit int) ([]int, error) {
+	members, err := r.client.ZRevRangeByScore(ctx, r.key, math.Inf(1), float64(since.UnixMilli()), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read online set: %w", err)
+	}
+	ids := make([]int, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.Atoi(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid online set member %q: %w", m, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// LastSeen implements Store
+func (r *RedisStore) LastSeen(ctx context.Context, userID int) (time.Time, bool, error) {
+	score, ok, err := r.client.ZScore(ctx, r.key, strconv.Itoa(userID))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read online set: %w", err)
+	}
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	return time.UnixMilli(int64(score)), true, nil
+}
+
+// Prune implements Store
+func (r *RedisStore) Prune(ctx context.Context, before time.Time) error {
+	// the max is exclusive so users seen exactly at before stay
+	if err := r.client.ZRemRangeByScore(ctx, r.key, math.Inf(-1), float64(before.UnixMilli()-1)); err != nil {
+		return fmt.Errorf("failed to prune online set: %w", err)
+	}
+	return nil
+}
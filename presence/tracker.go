@@ -0,0 +1,172 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"project/clock"
+)
+
+// LastSeenWriter persists when users were last seen, e.g. the repository,
+// so the time outlives the online set
+type LastSeenWriter interface {
+	SaveLastSeen(ctx context.Context, seen map[int]time.Time) error
+}
+
+// Tracker records sightings of users and writes them to the online set and
+// the LastSeenWriter in batches, so a heartbeat costs a map write rather
+// than a round trip. A user is online when seen within Window.
+type Tracker struct {
+	store Store
+	sink  LastSeenWriter
+
+	// Window is how long after a sighting a user stays online
+	Window time.Duration
+	// FlushInterval is how often pending sightings are written
+	FlushInterval time.Duration
+	// BatchSize caps the users per write; reaching it flushes early
+	BatchSize int
+	// Workers is the number of batches written concurrently
+	Workers int
+	// Clock tells the time of sightings; the system clock when nil
+	Clock clock.Clock
+	// Logf reports failed writes when set
+	Logf func(format string, args ...any)
+
+	mu      sync.Mutex
+	pending map[int]time.Time
+	full    chan struct{}
+}
+
+// NewTracker creates a tracker over store, persisting last-seen times to
+// sink when it isn't nil
+func NewTracker(store Store, sink LastSeenWriter) *Tracker {
+	return &Tracker{
+		store:         store,
+		sink:          sink,
+		Window:        5 * time.Minute,
+		FlushInterval: time.Second,
+		BatchSize:     500,
+		Workers:       2,
+		pending:       make(map[int]time.Time),
+		full:          make(chan struct{}, 1),
+	}
+}
+
+// Seen records that the user is active now. It never blocks on I/O; the
+// sighting is written by Run.
+func (t *Tracker) Seen(userID int) {
+	now := clock.Or(t.Clock).Now()
+	t.mu.Lock()
+	t.pending[userID] = now
+	n := len(t.pending)
+	t.mu.Unlock()
+
+	if t.BatchSize > 0 && n >= t.BatchSize {
+		select {
+		case t.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run writes pending sightings every FlushInterval, or sooner when a batch
+// fills, until ctx is cancelled, then writes what is left
+func (t *Tracker) Run(ctx context.Context) {
+	batches := make(chan map[int]time.Time)
+	var wg sync.WaitGroup
+	for i := 0; i < max(t.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				t.write(context.WithoutCancel(ctx), batch)
+			}
+		}()
+	}
+	defer func() {
+		close(batches)
+		wg.Wait()
+	}()
+
+	ticker := time.NewTicker(t.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.flush(batches)
+			return
+		case <-t.full:
+			t.flush(batches)
+		case <-ticker.C:
+			t.flush(batches)
+			if err := t.store.Prune(ctx, t.since()); err != nil && t.Logf != nil {
+				t.Logf("Presence prune failed: %v", err)
+			}
+		}
+	}
+}
+
+// flush hands the pending sightings to the workers in batches of BatchSize
+func (t *Tracker) flush(batches chan<- map[int]time.Time) {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[int]time.Time)
+	t.mu.Unlock()
+
+	batch := make(map[int]time.Time)
+	for id, seen := range pending {
+		batch[id] = seen
+		if t.BatchSize > 0 && len(batch) >= t.BatchSize {
+			batches <- batch
+			batch = make(map[int]time.Time)
+		}
+	}
+	if len(batch) > 0 {
+		batches <- batch
+	}
+}
+
+// write stores one batch in the online set and the sink
+func (t *Tracker) write(ctx context.Context, batch map[int]time.Time) {
+	if err := t.store.Touch(ctx, batch); err != nil && t.Logf != nil {
+		t.Logf("Presence update of %d users failed: %v", len(batch), err)
+	}
+	if t.sink == nil {
+		return
+	}
+	if err := t.sink.SaveLastSeen(ctx, batch); err != nil && t.Logf != nil {
+		t.Logf("Last seen update of %d users failed: %v", len(batch), err)
+	}
+}
+
+// since returns the earliest sighting that still counts as online
+func (t *Tracker) since() time.Time {
+	return clock.Or(t.Clock).Now().Add(-t.Window)
+}
+
+// IsOnline reports whether the user was seen within Window, including
+// sightings not written yet
+func (t *Tracker) IsOnline(ctx context.Context, userID int) (bool, error) {
+	since := t.since()
+	t.mu.Lock()
+	seen, ok := t.pending[userID]
+	t.mu.Unlock()
+	if ok && !seen.Before(since) {
+		return true, nil
+	}
+
+	seen, ok, err := t.store.LastSeen(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return ok && !seen.Before(since), nil
+}
+
+// Online returns up to limit users seen within Window, the most recent
+// first. Sightings since the last flush show up after the next one.
+func (t *Tracker) Online(ctx context.Context, limit int) ([]int, error) {
+	return t.store.Online(ctx, t.since(), limit)
+}
@@ -0,0 +1,65 @@
+// Package projections maintains denormalized read models from the events
+// on a bus, so reads are served without joining the write tables
+package projections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"project/events"
+)
+
+// Projection is a read model built from events. Rebuild recomputes it from
+// scratch out of the source tables, e.g. after a missed event or a change
+// to its shape.
+type Projection interface {
+	Name() string
+	// Events lists the event types the projection handles
+	Events() []string
+	Handle(ctx context.Context, ev events.Event) error
+	Rebuild(ctx context.Context) error
+}
+
+// Builder subscribes projections to a bus
+type Builder struct {
+	bus         *events.Bus
+	projections map[string]Projection
+	order       []string
+}
+
+// NewBuilder creates a builder feeding projections from bus
+func NewBuilder(bus *events.Bus) *Builder {
+	return &Builder{bus: bus, projections: make(map[string]Projection)}
+}
+
+// Register subscribes p to the events it handles
+func (b *Builder) Register(p Projection) *Builder {
+	b.projections[p.Name()] = p
+	b.order = append(b.order, p.Name())
+	for _, t := range p.Events() {
+		b.bus.Subscribe(t, p.Handle)
+	}
+	return b
+}
+
+// Rebuild rebuilds the named projection from scratch
+func (b *Builder) Rebuild(ctx context.Context, name string) error {
+	p, ok := b.projections[name]
+	if !ok {
+		return fmt.Errorf("unknown projection %q", name)
+	}
+	if err := p.Rebuild(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild %s: %w", name, err)
+	}
+	return nil
+}
+
+// RebuildAll rebuilds every registered projection, reporting all failures
+func (b *Builder) RebuildAll(ctx context.Context) error {
+	var errs []error
+	for _, name := range b.order {
+		errs = append(errs, b.Rebuild(ctx, name))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,162 @@
+package projections
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"project/clock"
+	"project/events"
+	"project/repository"
+)
+
+// UserSummary is the read model of a user with its counts precomputed
+type UserSummary struct {
+	UserID        int
+	TenantID      string
+	Name          string
+	Email         string
+	Organizations int
+	CreatedAt     time.Time
+	RefreshedAt   time.Time
+}
+
+// UserSummaries maintains the user_summary table. It is read-through: a
+// user without a row, e.g. one registered since the last rebuild, is
+// summarized on first read.
+type UserSummaries struct {
+	db      *sql.DB
+	queries summaryQueries
+
+	// Clock stamps refreshed rows; nil means the system clock
+	Clock clock.Clock
+}
+
+// summaryQueries are the dialect's statements
+type summaryQueries struct {
+	get, refresh, delete, clear, rebuild string
+}
+
+// summarySelect computes summary rows from the source tables; the
+// dialects append their own filter and bind the refresh time first
+const summarySelect = `SELECT u.id, u.tenant_id, u.name, u.email,
+	(SELECT COUNT(*) FROM memberships m WHERE m.user_id = u.id), u.created_at, %s
+	FROM users u WHERE u.deleted_at IS NULL`
+
+// NewPostgresUserSummaries creates the projection on a PostgreSQL db
+func NewPostgresUserSummaries(db *sql.DB) *UserSummaries {
+	return &UserSummaries{db: db, queries: summaryQueries{
+		get: "SELECT user_id, tenant_id, name, email, organizations, created_at, refreshed_at FROM user_summary WHERE user_id = $1",
+		refresh: "INSERT INTO user_summary (user_id, tenant_id, name, email, organizations, created_at, refreshed_at) " +
+			fmt.Sprintf(summarySelect, "CAST($1 AS TIMESTAMPTZ)") + " AND u.id = $2" +
+			` ON CONFLICT (user_id) DO UPDATE SET tenant_id = EXCLUDED.tenant_id, name = EXCLUDED.name, email = EXCLUDED.email,
+			organizations = EXCLUDED.organizations, refreshed_at = EXCLUDED.refreshed_at`,
+		delete: "DELETE FROM user_summary WHERE user_id = $1",
+		clear:  "DELETE FROM user_summary",
+		rebuild: "INSERT INTO user_summary (user_id, tenant_id, name, email, organizations, created_at, refreshed_at) " +
+			fmt.Sprintf(summarySelect, "CAST($1 AS TIMESTAMPTZ)"),
+	}}
+}
+
+// NewMySQLUserSummaries creates the projection on a MySQL db
+func NewMySQLUserSummaries(db *sql.DB) *UserSummaries {
+	return &UserSummaries{db: db, queries: summaryQueries{
+		get: "SELECT user_id, tenant_id, name, email, organizations, created_at, refreshed_at FROM user_summary WHERE user_id = ?",
+		refresh: "INSERT INTO user_summary (user_id, tenant_id, name, email, organizations, created_at, refreshed_at) " +
+			fmt.Sprintf(summarySelect, "CAST(? AS DATETIME)") + " AND u.id = ?" +
+			` ON DUPLICATE KEY UPDATE tenant_id = VALUES(tenant_id), name = VALUES(name), email = VALUES(email),
+			organizations = VALUES(organizations), refreshed_at = VALUES(refreshed_at)`,
+		delete: "DELETE FROM user_summary WHERE user_id = ?",
+		clear:  "DELETE FROM user_summary",
+		rebuild: "INSERT INTO user_summary (user_id, tenant_id, name, email, organizations, created_at, refreshed_at) " +
+			fmt.Sprintf(summarySelect, "CAST(? AS DATETIME)"),
+	}}
+}
+
+// Name implements Projection
+func (s *UserSummaries) Name() string {
+	return "user_summary"
+}
+
+// Events implements Projection
+func (s *UserSummaries) Events() []string {
+	return []string{events.UserUpdated, events.UserDeleted, events.UserErased, events.MemberAdded, events.MemberRemoved}
+}
+
+// Handle implements Projection, refreshing the row of the event's user
+func (s *UserSummaries) Handle(ctx context.Context, ev events.Event) error {
+	if ev.Subject == 0 {
+		return nil
+	}
+	if ev.Type == events.UserDeleted || ev.Type == events.UserErased {
+		if _, err := s.db.ExecContext(ctx, s.queries.delete, ev.Subject); err != nil {
+			return fmt.Errorf("failed to delete user summary: %w", err)
+		}
+		return nil
+	}
+	return s.Refresh(ctx, ev.Subject)
+}
+
+// Refresh recomputes the row of one user, removing it if the user is gone
+func (s *UserSummaries) Refresh(ctx context.Context, userID int) error {
+	res, err := s.db.ExecContext(ctx, s.queries.refresh, clock.Or(s.Clock).Now().UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to refresh user summary: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := s.db.ExecContext(ctx, s.queries.delete, userID); err != nil {
+			return fmt.Errorf("failed to delete user summary: %w", err)
+		}
+	}
+	return nil
+}
+
+// Rebuild implements Projection, replacing every row in one transaction
+func (s *UserSummaries) Rebuild(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.queries.clear); err != nil {
+		return fmt.Errorf("failed to clear user summaries: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, s.queries.rebuild, clock.Or(s.Clock).Now().UTC()); err != nil {
+		return fmt.Errorf("failed to rebuild user summaries: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Get returns the summary of a user, building it on a miss, and
+// repository.ErrNotFound if there is no such user
+func (s *UserSummaries) Get(ctx context.Context, userID int) (UserSummary, error) {
+	sum, err := s.get(ctx, userID)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return sum, err
+	}
+
+	if err := s.Refresh(ctx, userID); err != nil {
+		return UserSummary{}, err
+	}
+	return s.get(ctx, userID)
+}
+
+func (s *UserSummaries) get(ctx context.Context, userID int) (UserSummary, error) {
+	var (
+		sum     UserSummary
+		created sql.NullTime
+	)
+	err := s.db.QueryRowContext(ctx, s.queries.get, userID).Scan(
+		&sum.UserID, &sum.TenantID, &sum.Name, &sum.Email, &sum.Organizations, &created, &sum.RefreshedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return UserSummary{}, repository.ErrNotFound
+	}
+	if err != nil {
+		return UserSummary{}, fmt.Errorf("failed to get user summary: %w", err)
+	}
+	sum.CreatedAt = created.Time
+	return sum, nil
+}
@@ -0,0 +1,223 @@
+package projections
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"project/clock"
+	"project/events"
+)
+
+// UsersSearch maintains the users_search table: one flattened row per live
+// user, with its email domain, tags, organization count, whether it has a
+// password and when it was last seen, so users are filtered without
+// joining the write tables. Rows follow the user events; last sightings
+// aren't events, so last_seen_at is as fresh as the user's last change or
+// the last rebuild.
+type UsersSearch struct {
+	db      *sql.DB
+	queries searchQueries
+
+	// Clock stamps refreshed rows; nil means the system clock
+	Clock clock.Clock
+}
+
+// searchQueries are the dialect's statements and filter clauses
+type searchQueries struct {
+	refresh, delete, clear, rebuild string
+
+	bind func(n int) string
+	// prefix, domain and tag are the filter conditions, %s the placeholder
+	prefix, domain, tag string
+}
+
+// searchSelect computes users_search rows from the source tables; the
+// dialects fill in the folded name, email domain and refresh time, and
+// append their own filter
+const searchSelect = `SELECT u.id, u.tenant_id, COALESCE(u.name, ''), %s, u.email, %s, u.tags,
+	(SELECT COUNT(*) FROM memberships m WHERE m.user_id = u.id),
+	EXISTS (SELECT 1 FROM user_passwords p WHERE p.user_id = u.id),
+	(SELECT l.last_seen_at FROM user_last_seen l WHERE l.user_id = u.id), u.created_at, %s
+	FROM users u WHERE u.deleted_at IS NULL`
+
+const searchColumns = "user_id, tenant_id, name, name_folded, email, email_domain, tags, organizations, has_password, last_seen_at, created_at, refreshed_at"
+
+// NewPostgresUsersSearch creates the projection on a PostgreSQL db. Names
+// are folded with immutable_unaccent, installed by the migrations.
+func NewPostgresUsersSearch(db *sql.DB) *UsersSearch {
+	sel := fmt.Sprintf(searchSelect, "lower(immutable_unaccent(COALESCE(u.name, '')))",
+		"lower(split_part(u.email, '@', 2))", "CAST($1 AS TIMESTAMPTZ)")
+	return &UsersSearch{db: db, queries: searchQueries{
+		refresh: "INSERT INTO users_search (" + searchColumns + ") " + sel + " AND u.id = $2" +
+			` ON CONFLICT (user_id) DO UPDATE SET tenant_id = EXCLUDED.tenant_id, name = EXCLUDED.name,
+			name_folded = EXCLUDED.name_folded, email = EXCLUDED.email, email_domain = EXCLUDED.email_domain,
+			tags = EXCLUDED.tags, organizations = EXCLUDED.organizations, has_password = EXCLUDED.has_password,
+			last_seen_at = EXCLUDED.last_seen_at, refreshed_at = EXCLUDED.refreshed_at`,
+		delete:  "DELETE FROM users_search WHERE user_id = $1",
+		clear:   "DELETE FROM users_search",
+		rebuild: "INSERT INTO users_search (" + searchColumns + ") " + sel,
+		bind:    func(n int) string { return fmt.Sprintf("$%d", n) },
+		prefix:  "name_folded LIKE lower(immutable_unaccent(%s)) || '%%'",
+		domain:  "email_domain = lower(%s)",
+		tag:     "%s = ANY(tags)",
+	}}
+}
+
+// NewMySQLUsersSearch creates the projection on a MySQL db, where the
+// column collation already ignores case and accents
+func NewMySQLUsersSearch(db *sql.DB) *UsersSearch {
+	sel := fmt.Sprintf(searchSelect, "LOWER(COALESCE(u.name, ''))",
+		"IF(LOCATE('@', u.email) > 0, LOWER(SUBSTRING_INDEX(u.email, '@', -1)), '')", "CAST(? AS DATETIME)")
+	return &UsersSearch{db: db, queries: searchQueries{
+		refresh: "INSERT INTO users_search (" + searchColumns + ") " + sel + " AND u.id = ?" +
+			` ON DUPLICATE KEY UPDATE tenant_id = VALUES(tenant_id), name = VALUES(name),
+			name_folded = VALUES(name_folded), email = VALUES(email), email_domain = VALUES(email_domain),
+			tags = VALUES(tags), organizations = VALUES(organizations), has_password = VALUES(has_password),
+			last_seen_at = VALUES(last_seen_at), refreshed_at = VALUES(refreshed_at)`,
+		delete:  "DELETE FROM users_search WHERE user_id = ?",
+		clear:   "DELETE FROM users_search",
+		rebuild: "INSERT INTO users_search (" + searchColumns + ") " + sel,
+		bind:    func(int) string { return "?" },
+		prefix:  "name_folded LIKE CONCAT(LOWER(%s), '%%')",
+		domain:  "email_domain = LOWER(%s)",
+		tag:     "JSON_CONTAINS(tags, JSON_QUOTE(%s))",
+	}}
+}
+
+// Name implements Projection
+func (s *UsersSearch) Name() string {
+	return "users_search"
+}
+
+// Events implements Projection
+func (s *UsersSearch) Events() []string {
+	return []string{events.UserRegistered, events.UserUpdated, events.UserDeleted, events.UserErased,
+		events.MemberAdded, events.MemberRemoved}
+}
+
+// Handle implements Projection, refreshing the row of the event's user
+func (s *UsersSearch) Handle(ctx context.Context, ev events.Event) error {
+	if ev.Subject == 0 {
+		return nil
+	}
+	if ev.Type == events.UserDeleted || ev.Type == events.UserErased {
+		if _, err := s.db.ExecContext(ctx, s.queries.delete, ev.Subject); err != nil {
+			return fmt.Errorf("failed to delete users_search row: %w", err)
+		}
+		return nil
+	}
+	return s.Refresh(ctx, ev.Subject)
+}
+
+// Refresh recomputes the row of one user, removing it if the user is gone
+func (s *UsersSearch) Refresh(ctx context.Context, userID int) error {
+	res, err := s.db.ExecContext(ctx, s.queries.refresh, clock.Or(s.Clock).Now().UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to refresh users_search row: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := s.db.ExecContext(ctx, s.queries.delete, userID); err != nil {
+			return fmt.Errorf("failed to delete users_search row: %w", err)
+		}
+	}
+	return nil
+}
+
+// Rebuild implements Projection, replacing every row in one transaction
+func (s *UsersSearch) Rebuild(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.queries.clear); err != nil {
+		return fmt.Errorf("failed to clear users_search: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, s.queries.rebuild, clock.Or(s.Clock).Now().UTC()); err != nil {
+		return fmt.Errorf("failed to rebuild users_search: %w", err)
+	}
+	return tx.Commit()
+}
+
+// UserFilter selects users from users_search; zero fields don't filter
+type UserFilter struct {
+	TenantID string
+	// NamePrefix matches the start of the name, ignoring case and accents
+	NamePrefix  string
+	EmailDomain string
+	Tag         string
+	// MinOrganizations is the least number of memberships
+	MinOrganizations int
+	HasPassword      *bool
+	// SeenSince drops users not seen since, or never seen
+	SeenSince time.Time
+	// Limit caps the result; 0 means no limit
+	Limit int
+}
+
+// Filter returns the IDs of the users matching f, oldest first. Load the
+// users themselves with GetByIDs.
+func (s *UsersSearch) Filter(ctx context.Context, f UserFilter) ([]int, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, s.queries.bind(len(args))))
+	}
+	if f.TenantID != "" {
+		add("tenant_id = %s", f.TenantID)
+	}
+	if f.NamePrefix != "" {
+		add(s.queries.prefix, escapeLike(f.NamePrefix))
+	}
+	if f.EmailDomain != "" {
+		add(s.queries.domain, f.EmailDomain)
+	}
+	if f.Tag != "" {
+		add(s.queries.tag, f.Tag)
+	}
+	if f.MinOrganizations > 0 {
+		add("organizations >= %s", f.MinOrganizations)
+	}
+	if f.HasPassword != nil {
+		add("has_password = %s", *f.HasPassword)
+	}
+	if !f.SeenSince.IsZero() {
+		add("last_seen_at >= %s", f.SeenSince.UTC())
+	}
+
+	query := "SELECT user_id FROM users_search"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at, user_id"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter users: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan user id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in s, with the default escape
+// character of both dialects
+var escapeLike = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace
@@ -0,0 +1,12 @@
+// Package eventspb holds the protobuf form of domain events published to
+// downstream consumers, generated from events.proto
+package eventspb
+
+import _ "embed"
+
+//go:generate protoc --proto_path=.. --go_out=.. --go_opt=paths=source_relative eventspb/events.proto
+
+// Schema is the source of events.proto, registered with schema registries
+//
+//go:embed events.proto
+var Schema string
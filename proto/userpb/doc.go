@@ -0,0 +1,6 @@
+// Package userpb holds the protobuf messages and service of version 1 of
+// the users API, generated from user.proto; convert them to and from
+// models with package mapping
+package userpb
+
+//go:generate protoc --proto_path=.. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative userpb/user.proto
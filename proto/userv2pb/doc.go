@@ -0,0 +1,5 @@
+// Package userv2pb holds the protobuf messages and service of version 2
+// of the users API, generated from user.proto
+package userv2pb
+
+//go:generate protoc --proto_path=.. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative userv2pb/user.proto
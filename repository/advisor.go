@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SlowQuery is a normalized statement recorded by pg_stat_statements
+type SlowQuery struct {
+	Query    string
+	Calls    int64
+	MeanTime time.Duration
+}
+
+// IndexSuggestion is an index the advisor found missing for the predicates
+// of one or more slow queries
+type IndexSuggestion struct {
+	Table   string
+	Columns []string
+	// Queries are the slow queries the index would serve, and Calls their
+	// combined call count
+	Queries []SlowQuery
+	Calls   int64
+}
+
+// Name returns the index name, e.g. users_tenant_id_email_idx
+func (s IndexSuggestion) Name() string {
+	return bareTable(s.Table) + "_" + strings.Join(s.Columns, "_") + "_idx"
+}
+
+// Statement returns the CREATE INDEX statement for the suggestion
+func (s IndexSuggestion) Statement() string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s);", s.Name(), s.Table, strings.Join(s.Columns, ", "))
+}
+
+// IndexAdvisor suggests indexes for the slow queries PostgreSQL recorded in
+// pg_stat_statements, which must be installed on the database
+type IndexAdvisor struct {
+	db *sql.DB
+	// MinMeanTime is the mean execution time above which a query is slow
+	MinMeanTime time.Duration
+	// Limit caps the slow queries analyzed, by total execution time
+	Limit int
+	// MaxColumns caps the columns of a suggested index
+	MaxColumns int
+}
+
+// NewIndexAdvisor creates an advisor for db
+func NewIndexAdvisor(db *sql.DB) *IndexAdvisor {
+	return &IndexAdvisor{db: db, MinMeanTime: 100 * time.Millisecond, Limit: 50, MaxColumns: 3}
+}
+
+// SlowQueries returns the recorded queries of the current database slower
+// than MinMeanTime on average, the most expensive first
+func (a *IndexAdvisor) SlowQueries(ctx context.Context) ([]SlowQuery, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT query, calls, mean_exec_time
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+			AND mean_exec_time >= $1
+		ORDER BY total_exec_time DESC
+		LIMIT $2`, float64(a.MinMeanTime)/float64(time.Millisecond), a.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pg_stat_statements: %w", err)
+	}
+	defer rows.Close()
+
+	var queries []SlowQuery
+	for rows.Next() {
+		var (
+			q    SlowQuery
+			mean float64
+		)
+		if err := rows.Scan(&q.Query, &q.Calls, &mean); err != nil {
+			return nil, fmt.Errorf("failed to scan slow query: %w", err)
+		}
+		q.MeanTime = time.Duration(mean * float64(time.Millisecond))
+		queries = append(queries, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return queries, nil
+}
+
+// Advise analyzes the slow queries against the live schema and returns the
+// missing indexes, the most called first
+func (a *IndexAdvisor) Advise(ctx context.Context) ([]IndexSuggestion, error) {
+	queries, err := a.SlowQueries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := inspectSchema(ctx, a.db, postgresIntrospection)
+	if err != nil {
+		return nil, err
+	}
+	return SuggestIndexes(queries, schema, a.MaxColumns), nil
+}
+
+var (
+	// tableRefPattern matches the tables a statement reads or writes and
+	// their optional aliases
+	tableRefPattern = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|UPDATE)\s+"?([a-z_][a-z0-9_]*)"?(?:\s+(?:AS\s+)?([a-z_][a-z0-9_]*))?`)
+	// clausePattern splits a statement into clauses; WHERE and ON clauses
+	// hold the predicates
+	clausePattern = regexp.MustCompile(`(?i)\b(WHERE|ON|ORDER\s+BY|GROUP\s+BY|HAVING|LIMIT|OFFSET|RETURNING|JOIN|UNION|FOR|SET|VALUES)\b`)
+	// predicatePattern matches a column compared in a predicate; the
+	// operator tells equality from range predicates
+	predicatePattern = regexp.MustCompile(`(?i)(?:"?([a-z_][a-z0-9_]*)"?\.)?"?([a-z_][a-z0-9_]*)"?\s*(=|<>|!=|<=|>=|<|>|\bIN\b|\bIS\b|\bLIKE\b|\bILIKE\b|\bBETWEEN\b|\bANY\b)`)
+)
+
+// sqlKeywords can follow a table name without being its alias
+var sqlKeywords = map[string]bool{
+	"where": true, "join": true, "on": true, "left": true, "right": true, "inner": true,
+	"outer": true, "full": true, "cross": true, "order": true, "group": true, "limit": true,
+	"offset": true, "set": true, "returning": true, "for": true, "union": true, "having": true,
+	"using": true, "natural": true, "lateral": true, "as": true,
+}
+
+// predicate is a column compared in a WHERE or ON clause
+type predicate struct {
+	table, column string
+	equality      bool
+}
+
+// queryPredicates returns the columns a statement filters or joins on,
+// resolved to their tables in schema
+func queryPredicates(query string, schema *Schema) []predicate {
+	aliases := make(map[string]string)
+	var tables []string
+	for _, m := range tableRefPattern.FindAllStringSubmatch(query, -1) {
+		table, alias := strings.ToLower(m[1]), strings.ToLower(m[2])
+		if schema.Table(table) == nil {
+			continue
+		}
+		tables = append(tables, table)
+		aliases[table] = table
+		if alias != "" && !sqlKeywords[alias] {
+			aliases[alias] = table
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	var preds []predicate
+	bounds := clausePattern.FindAllStringSubmatchIndex(query, -1)
+	for i, b := range bounds {
+		keyword := strings.ToUpper(query[b[2]:b[3]])
+		if keyword != "WHERE" && keyword != "ON" {
+			continue
+		}
+		end := len(query)
+		if i+1 < len(bounds) {
+			end = bounds[i+1][0]
+		}
+		for _, m := range predicatePattern.FindAllStringSubmatch(query[b[1]:end], -1) {
+			table := resolveColumn(strings.ToLower(m[1]), strings.ToLower(m[2]), aliases, tables, schema)
+			if table == "" {
+				continue
+			}
+			op := strings.ToUpper(m[3])
+			preds = append(preds, predicate{
+				table:    table,
+				column:   strings.ToLower(m[2]),
+				equality: op == "=" || op == "IN" || op == "IS" || op == "ANY",
+			})
+		}
+	}
+	return preds
+}
+
+// resolveColumn returns the table a possibly qualified column belongs to,
+// or "" when it isn't a column of the statement's tables
+func resolveColumn(qualifier, column string, aliases map[string]string, tables []string, schema *Schema) string {
+	if qualifier != "" {
+		table, ok := aliases[qualifier]
+		if !ok || schema.Table(table).Column(column) == nil {
+			return ""
+		}
+		return table
+	}
+	var found string
+	for _, t := range tables {
+		if t != found && schema.Table(t).Column(column) != nil {
+			if found != "" {
+				// ambiguous without a qualifier
+				return ""
+			}
+			found = t
+		}
+	}
+	return found
+}
+
+// SuggestIndexes returns the indexes missing for the predicates of queries
+// in schema, equality columns before range columns and at most maxColumns
+// each. Suggestions shared by several queries are merged.
+func SuggestIndexes(queries []SlowQuery, schema *Schema, maxColumns int) []IndexSuggestion {
+	byName := make(map[string]*IndexSuggestion)
+	var order []string
+	for _, q := range queries {
+		byTable := make(map[string][]predicate)
+		var tables []string
+		for _, p := range queryPredicates(q.Query, schema) {
+			if _, ok := byTable[p.table]; !ok {
+				tables = append(tables, p.table)
+			}
+			byTable[p.table] = append(byTable[p.table], p)
+		}
+
+		for _, table := range tables {
+			cols := indexColumns(byTable[table], maxColumns)
+			if indexCovers(schema.Table(table), cols) {
+				continue
+			}
+			s := IndexSuggestion{Table: table, Columns: cols}
+			existing, ok := byName[s.Name()]
+			if !ok {
+				existing = &s
+				byName[s.Name()] = existing
+				order = append(order, s.Name())
+			}
+			existing.Queries = append(existing.Queries, q)
+			existing.Calls += q.Calls
+		}
+	}
+
+	suggestions := make([]IndexSuggestion, 0, len(order))
+	for _, name := range order {
+		suggestions = append(suggestions, *byName[name])
+	}
+	slices.SortStableFunc(suggestions, func(a, b IndexSuggestion) int {
+		return cmp.Compare(b.Calls, a.Calls)
+	})
+	return suggestions
+}
+
+// indexColumns orders the predicate columns of one table for an index:
+// equality columns first, since a range column ends the usable prefix
+func indexColumns(preds []predicate, maxColumns int) []string {
+	var eq, rng []string
+	for _, p := range preds {
+		if slices.Contains(eq, p.column) || slices.Contains(rng, p.column) {
+			continue
+		}
+		if p.equality {
+			eq = append(eq, p.column)
+		} else {
+			rng = append(rng, p.column)
+		}
+	}
+	cols := append(eq, rng...)
+	if maxColumns > 0 && len(cols) > maxColumns {
+		cols = cols[:maxColumns]
+	}
+	return cols
+}
+
+// indexCovers reports whether an index of table already leads with cols,
+// in any order
+func indexCovers(table *Table, cols []string) bool {
+	for _, idx := range table.Indexes {
+		if len(idx.Columns) < len(cols) {
+			continue
+		}
+		covered := true
+		for _, c := range cols {
+			if !slices.Contains(idx.Columns[:len(cols)], c) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteIndexMigration writes the suggestions to the next versioned
+// migration file in dir, for review before it is applied, and returns its
+// path
+func WriteIndexMigration(dir string, suggestions []IndexSuggestion) (string, error) {
+	if len(suggestions) == 0 {
+		return "", fmt.Errorf("no index suggestions to write")
+	}
+
+	existing, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", err
+	}
+	var version int64 = 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	var b strings.Builder
+	b.WriteString("-- Indexes suggested by the index advisor from pg_stat_statements.\n")
+	b.WriteString("-- Review before applying; on large tables consider CREATE INDEX CONCURRENTLY\n")
+	b.WriteString("-- outside a migration instead.\n")
+	for _, s := range suggestions {
+		fmt.Fprintf(&b, "\n-- %d calls across %d queries, e.g.\n--   %s\n%s\n",
+			s.Calls, len(s.Queries), strings.Join(strings.Fields(s.Queries[0].Query), " "), s.Statement())
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%04d_add_suggested_indexes.sql", version))
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write migration: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"project/models"
+)
+
+// aggregateDialect holds the per-dialect SQL fragments used by aggregates
+type aggregateDialect struct {
+	// day formats a timestamp column as YYYY-MM-DD
+	day func(column string) string
+	// text casts a column to a string
+	text func(column string) string
+}
+
+var postgresAggregates = aggregateDialect{
+	day:  func(c string) string { return "to_char(" + c + ", 'YYYY-MM-DD')" },
+	text: func(c string) string { return "CAST(" + c + " AS TEXT)" },
+}
+
+var mysqlAggregates = aggregateDialect{
+	day:  func(c string) string { return "DATE_FORMAT(" + c + ", '%Y-%m-%d')" },
+	text: func(c string) string { return "CAST(" + c + " AS CHAR)" },
+}
+
+// CountByCreatedDate returns the number of users created per day (YYYY-MM-DD)
+func (p *PostgresRepo) CountByCreatedDate() (map[string]int, error) {
+	return countGrouped(p.db, p.table(models.User{}), postgresAggregates.day("created_at"))
+}
+
+// GroupBy returns the number of users per distinct value of field
+func (p *PostgresRepo) GroupBy(field string) (map[string]int, error) {
+	return groupBy(p.db, p.table(models.User{}), postgresAggregates, field)
+}
+
+// CountByCreatedDate returns the number of users created per day (YYYY-MM-DD)
+func (m *MySQLRepo) CountByCreatedDate() (map[string]int, error) {
+	return countGrouped(m.db, m.table(models.User{}), mysqlAggregates.day("created_at"))
+}
+
+// GroupBy returns the number of users per distinct value of field
+func (m *MySQLRepo) GroupBy(field string) (map[string]int, error) {
+	return groupBy(m.db, m.table(models.User{}), mysqlAggregates, field)
+}
+
+// groupBy validates field against the User columns before building SQL from
+// it, since column names can't be bound as parameters
+func groupBy(db *sql.DB, table string, d aggregateDialect, field string) (map[string]int, error) {
+	if _, ok := columnFields(reflect.TypeOf(models.User{}))[field]; !ok {
+		return nil, fmt.Errorf("cannot group users by unknown field %q", field)
+	}
+	return countGrouped(db, table, d.text(field))
+}
+
+// countGrouped counts users per value of expr; NULL groups are keyed ""
+func countGrouped(db *sql.DB, table, expr string) (map[string]int, error) {
+	query := fmt.Sprintf("SELECT %s AS grp, COUNT(*) FROM %s WHERE deleted_at IS NULL GROUP BY grp", expr, table)
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate users: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var key sql.NullString
+		var n int
+		if err := rows.Scan(&key, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan aggregate: %w", err)
+		}
+		counts[key.String] += n
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"project/models"
+)
+
+// APIKeyRepository is implemented by adapters that store API keys
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error)
+	// GetAPIKeyByPrefix returns the key with prefix, revoked and expired
+	// ones included, or ErrNotFound
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	// RevokeAPIKey revokes a key of userID, returning ErrNotFound if the
+	// user has no such active key
+	RevokeAPIKey(ctx context.Context, userID, id int, at time.Time) error
+	// TouchAPIKey records that a key was used at at
+	TouchAPIKey(ctx context.Context, id int, at time.Time) error
+}
+
+// apiKeyColumns are the columns of api_keys in models.APIKey order
+const apiKeyColumns = "id, created_at, updated_at, user_id, name, prefix, secret_hash, scopes, expires_at, revoked_at, last_used_at"
+
+// CreateAPIKey inserts a key and returns it as stored
+func (s *SQLRepo) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	return createAPIKey(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.APIKey{}), s.dialect.Array, s.dialect.InsertID, key)
+}
+
+// GetAPIKeyByPrefix retrieves a key by its public prefix
+func (s *SQLRepo) GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	return getAPIKey(ctx, dbFrom(ctx, s.db), s.table(models.APIKey{}), "prefix = "+s.dialect.Bind(1), prefix)
+}
+
+// ListAPIKeys retrieves the keys of a user, newest first
+func (s *SQLRepo) ListAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	return listAPIKeys(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.APIKey{}), userID)
+}
+
+// RevokeAPIKey revokes a key
+func (s *SQLRepo) RevokeAPIKey(ctx context.Context, userID, id int, at time.Time) error {
+	return revokeAPIKey(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.APIKey{}), userID, id, at)
+}
+
+// TouchAPIKey records the last use of a key
+func (s *SQLRepo) TouchAPIKey(ctx context.Context, id int, at time.Time) error {
+	return touchAPIKey(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.APIKey{}), id, at)
+}
+
+func createAPIKey(ctx context.Context, db querier, bind func(int) string, table string, array func(any) driver.Valuer, insert insertID, key models.APIKey) (models.APIKey, error) {
+	id, err := insert(ctx, db, fmt.Sprintf(
+		"INSERT INTO %s (user_id, name, prefix, secret_hash, scopes, expires_at) VALUES (%s, %s, %s, %s, %s, %s)",
+		table, bind(1), bind(2), bind(3), bind(4), bind(5), bind(6)),
+		key.UserID, key.Name, key.Prefix, key.SecretHash, array(key.Scopes), key.ExpiresAt,
+	)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to insert api key: %w", err)
+	}
+	return getAPIKey(ctx, db, table, "id = "+bind(1), id)
+}
+
+func getAPIKey(ctx context.Context, db querier, table, cond string, arg any) (models.APIKey, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM "+table+" WHERE "+cond, arg)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to get api key: %w", err)
+	}
+	defer rows.Close()
+
+	keys, err := ScanAll[models.APIKey](rows)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	switch len(keys) {
+	case 0:
+		return models.APIKey{}, ErrNotFound
+	case 1:
+		return keys[0], nil
+	default:
+		// prefixes are unique; picking one of several would authenticate
+		// whichever key the database returned first
+		return models.APIKey{}, fmt.Errorf("failed to get api key: %d keys match", len(keys))
+	}
+}
+
+func listAPIKeys(ctx context.Context, db querier, bind func(int) string, table string, userID int) ([]models.APIKey, error) {
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf("SELECT %s FROM %s WHERE user_id = %s ORDER BY created_at DESC, id DESC", apiKeyColumns, table, bind(1)),
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	return ScanAll[models.APIKey](rows)
+}
+
+func revokeAPIKey(ctx context.Context, db querier, bind func(int) string, table string, userID, id int, at time.Time) error {
+	res, err := db.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET revoked_at = %s, updated_at = CURRENT_TIMESTAMP WHERE id = %s AND user_id = %s AND revoked_at IS NULL",
+		table, bind(1), bind(2), bind(3)),
+		at, id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return requireRow(res)
+}
+
+func touchAPIKey(ctx context.Context, db querier, bind func(int) string, table string, id int, at time.Time) error {
+	_, err := db.ExecContext(ctx,
+		fmt.Sprintf("UPDATE %s SET last_used_at = %s WHERE id = %s", table, bind(1), bind(2)),
+		at, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record api key use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"project/clock"
+	"project/models"
+)
+
+// archiveMetrics exposes archiver counters on /debug/vars
+var archiveMetrics = expvar.NewMap("archiver")
+
+// ArchivePolicy selects the users to archive
+type ArchivePolicy struct {
+	Name string
+	// Predicate is a trusted SQL condition on users using $1..$n for Args
+	Predicate string
+	Args      []any
+}
+
+// SoftDeletedBefore archives users soft-deleted more than age before now
+func SoftDeletedBefore(now time.Time, age time.Duration) ArchivePolicy {
+	return ArchivePolicy{
+		Name:      "soft_deleted",
+		Predicate: "deleted_at IS NOT NULL AND deleted_at < $1",
+		Args:      []any{now.Add(-age)},
+	}
+}
+
+// ArchiveSink receives each batch of archived users inside the transaction
+// that removes them from the users table. Sinks writing outside the database
+// get at-least-once delivery: a failed commit leaves the rows in place and
+// they are archived again on the next run.
+type ArchiveSink interface {
+	WriteArchive(ctx context.Context, tx *sql.Tx, users []models.User) error
+}
+
+// TableArchiveSink copies archived users from the Users table into an
+// archive table with the columns of the User model plus archived_at
+type TableArchiveSink struct {
+	Table, Users string
+}
+
+// NewTableArchiveSink creates the users_archive table next to the users
+// table named by naming if needed, and adds the model columns it lacks,
+// since LIKE users only copied the columns users had when the archive was
+// created
+func NewTableArchiveSink(db *sql.DB, naming NamingStrategy) (*TableArchiveSink, error) {
+	sink := &TableArchiveSink{Table: naming.usersCopy("_archive"), Users: naming.TableName(models.User{})}
+	_, err := db.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING DEFAULTS, archived_at TIMESTAMPTZ NOT NULL DEFAULT now())",
+		sink.Table, sink.Users,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive table: %w", err)
+	}
+
+	def, err := parseModel(models.User{}, NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range def.Columns {
+		// nullable, so rows archived before the column existed stay valid
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", sink.Table, col.Name, col.SQLType)); err != nil {
+			return nil, fmt.Errorf("failed to add %s to archive table: %w", col.Name, err)
+		}
+	}
+	return sink, nil
+}
+
+// WriteArchive implements ArchiveSink, naming the model columns on both
+// sides so columns only one of the tables has are left alone
+func (s *TableArchiveSink) WriteArchive(ctx context.Context, tx *sql.Tx, users []models.User) error {
+	_, err := tx.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %[1]s (%[2]s, archived_at) SELECT %[2]s, now() FROM %[3]s WHERE id = ANY($1)",
+			s.Table, strings.Join(Columns[models.User](), ", "), s.Users),
+		pq.Array(userIDs(users)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy users to archive: %w", err)
+	}
+	return nil
+}
+
+// ArchiveStats summarizes an archiver run
+type ArchiveStats struct {
+	Policy   string
+	Batches  int
+	Archived int
+	Duration time.Duration
+}
+
+// Archiver moves users matching a policy out of the users table in batches,
+// each batch in its own transaction
+type Archiver struct {
+	db        *sql.DB
+	sink      ArchiveSink
+	BatchSize int
+	// Clock times runs; nil means the system clock
+	Clock clock.Clock
+	// Naming names the users table, as AutoMigrate did
+	Naming NamingStrategy
+}
+
+// NewArchiver creates an archiver writing to sink
+func NewArchiver(db *sql.DB, sink ArchiveSink) *Archiver {
+	return &Archiver{db: db, sink: sink, BatchSize: 500}
+}
+
+// Run archives every user matching policy, stopping between batches if ctx
+// is cancelled. Rows locked by a concurrent run are skipped.
+func (a *Archiver) Run(ctx context.Context, policy ArchivePolicy) (ArchiveStats, error) {
+	stats := ArchiveStats{Policy: policy.Name}
+	start := clock.Or(a.Clock).Now()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			stats.Duration = clock.Or(a.Clock).Now().Sub(start)
+			return stats, err
+		}
+
+		n, err := a.archiveBatch(ctx, policy)
+		if err != nil {
+			archiveMetrics.Add("errors_total", 1)
+			stats.Duration = clock.Or(a.Clock).Now().Sub(start)
+			return stats, err
+		}
+		if n == 0 {
+			stats.Duration = clock.Or(a.Clock).Now().Sub(start)
+			return stats, nil
+		}
+
+		stats.Batches++
+		stats.Archived += n
+		archiveMetrics.Add("batches_total", 1)
+		archiveMetrics.Add("archived_total", int64(n))
+	}
+}
+
+func (a *Archiver) archiveBatch(ctx context.Context, policy ArchivePolicy) (int, error) {
+	def, err := parseModel(models.User{}, NamingStrategy{})
+	if err != nil {
+		return 0, err
+	}
+	columns := make([]string, len(def.Columns))
+	for i, col := range def.Columns {
+		columns[i] = col.Name
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin archive batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s ORDER BY id LIMIT $%s FOR UPDATE SKIP LOCKED",
+		strings.Join(columns, ", "),
+		a.Naming.TableName(models.User{}),
+		policy.Predicate,
+		strconv.Itoa(len(policy.Args)+1),
+	)
+	rows, err := tx.QueryContext(ctx, query, append(policy.Args, a.BatchSize)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select users to archive: %w", err)
+	}
+	users, err := ScanAll[models.User](rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	if err := a.sink.WriteArchive(ctx, tx, users); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+a.Naming.TableName(models.User{})+" WHERE id = ANY($1)", pq.Array(userIDs(users))); err != nil {
+		return 0, fmt.Errorf("failed to remove archived users: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit archive batch: %w", err)
+	}
+	return len(users), nil
+}
+
+func userIDs(users []models.User) []int64 {
+	ids := make([]int64, len(users))
+	for i, u := range users {
+		ids[i] = int64(u.ID)
+	}
+	return ids
+}
+
+// Schedule archives users soft-deleted more than age ago every interval
+// until ctx is cancelled, logging each run with logf
+func (a *Archiver) Schedule(ctx context.Context, interval, age time.Duration, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := a.Run(ctx, SoftDeletedBefore(clock.Or(a.Clock).Now(), age))
+			logf("Archived %d users in %d batches (%s)", stats.Archived, stats.Batches, stats.Duration)
+			if err != nil {
+				logf("Archive run failed: %v", err)
+			}
+		}
+	}
+}
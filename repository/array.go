@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/lib/pq"
+)
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isArrayField reports whether t is a []string or []int style slice mapped to
+// an array column (TEXT[]/BIGINT[] on Postgres, JSON on MySQL)
+func isArrayField(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice || reflect.PointerTo(t).Implements(scannerType) {
+		return false
+	}
+	switch t.Elem().Kind() {
+	case reflect.String, reflect.Int, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+// scanTarget returns the Scan destination for an addressable struct field,
+// wrapping array fields so they decode from either representation and time
+// fields so they read in UTC
+func scanTarget(field reflect.Value) any {
+	if isArrayField(field.Type()) {
+		return arrayScanner{dest: field}
+	}
+	if isTimeField(field.Type()) {
+		return utcScanner{dest: field}
+	}
+	return field.Addr().Interface()
+}
+
+// arrayScanner scans a Postgres array literal ({a,b}) or a JSON array
+// (["a","b"], as stored by MySQL) into a []string or []int field
+type arrayScanner struct {
+	dest reflect.Value
+}
+
+// Scan implements sql.Scanner
+func (a arrayScanner) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		a.dest.Set(reflect.Zero(a.dest.Type()))
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into %s", src, a.dest.Type())
+	}
+
+	if len(b) > 0 && b[0] == '[' {
+		return json.Unmarshal(b, a.dest.Addr().Interface())
+	}
+
+	if a.dest.Type().Elem().Kind() == reflect.String {
+		var s []string
+		if err := pq.Array(&s).Scan(b); err != nil {
+			return err
+		}
+		a.dest.Set(reflect.ValueOf(s).Convert(a.dest.Type()))
+		return nil
+	}
+
+	var ints []int64
+	if err := pq.Array(&ints).Scan(b); err != nil {
+		return err
+	}
+	out := reflect.MakeSlice(a.dest.Type(), len(ints), len(ints))
+	for i, n := range ints {
+		out.Index(i).SetInt(n)
+	}
+	a.dest.Set(out)
+	return nil
+}
+
+// postgresArray returns a Postgres array parameter for a []string or []int
+// value; nil slices are sent as empty arrays
+func postgresArray(v any) driver.Valuer {
+	rv := reflect.ValueOf(v)
+	if rv.Type().Elem().Kind() == reflect.String {
+		s := make([]string, rv.Len())
+		for i := range s {
+			s[i] = rv.Index(i).String()
+		}
+		return pq.StringArray(s)
+	}
+
+	ints := make([]int64, rv.Len())
+	for i := range ints {
+		ints[i] = rv.Index(i).Int()
+	}
+	return pq.Int64Array(ints)
+}
+
+// jsonArray returns a JSON array parameter, as stored by MySQL, for a
+// []string or []int value; nil slices are sent as []
+func jsonArray(v any) driver.Valuer {
+	return jsonArrayValue{v: v}
+}
+
+type jsonArrayValue struct {
+	v any
+}
+
+// Value implements driver.Valuer
+func (j jsonArrayValue) Value() (driver.Value, error) {
+	if reflect.ValueOf(j.v).Len() == 0 {
+		return "[]", nil
+	}
+	b, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"project/models"
+)
+
+// attributeDialect holds the per-dialect JSON predicates on users.attributes
+type attributeDialect struct {
+	bind func(int) string
+	// contains matches rows whose attributes contain the bound JSON object
+	contains string
+	// path compares the text at the bound path to the bound value
+	path string
+	// pathArg converts a key path into the dialect's path argument
+	pathArg func(path []string) any
+}
+
+var postgresAttributes = attributeDialect{
+	bind:     postgresBind,
+	contains: "attributes @> $1",
+	path:     "attributes #>> $1 = $2",
+	pathArg:  func(path []string) any { return pq.Array(path) },
+}
+
+var mysqlAttributes = attributeDialect{
+	bind:     mysqlBind,
+	contains: "JSON_CONTAINS(attributes, ?)",
+	path:     "JSON_UNQUOTE(JSON_EXTRACT(attributes, ?)) = ?",
+	pathArg:  mysqlJSONPath,
+}
+
+// mysqlJSONPath builds a $."a"."b" path with every key quoted, so keys are
+// never interpreted as path syntax
+func mysqlJSONPath(path []string) any {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, key := range path {
+		key = strings.ReplaceAll(key, `\`, `\\`)
+		key = strings.ReplaceAll(key, `"`, `\"`)
+		b.WriteString(`."` + key + `"`)
+	}
+	return b.String()
+}
+
+// SetAttributes replaces the attributes of a user
+func (p *PostgresRepo) SetAttributes(id int, attrs models.JSONMap) error {
+	return p.mutate(id, func(tx *sql.Tx) error {
+		return setAttributes(tx, p.table(models.User{}), postgresAttributes, id, attrs)
+	})
+}
+
+// FindByAttributes returns users whose attributes contain all of contains
+func (p *PostgresRepo) FindByAttributes(contains models.JSONMap) ([]models.User, error) {
+	return findByAttributes(p.db, p.table(models.User{}), postgresAttributes.contains, contains)
+}
+
+// FindByAttributePath returns users whose attribute at path equals value
+func (p *PostgresRepo) FindByAttributePath(path []string, value string) ([]models.User, error) {
+	return findByAttributes(p.db, p.table(models.User{}), postgresAttributes.path, postgresAttributes.pathArg(path), value)
+}
+
+// SetAttributes replaces the attributes of a user
+func (m *MySQLRepo) SetAttributes(id int, attrs models.JSONMap) error {
+	return setAttributes(m.db, m.table(models.User{}), mysqlAttributes, id, attrs)
+}
+
+// FindByAttributes returns users whose attributes contain all of contains
+func (m *MySQLRepo) FindByAttributes(contains models.JSONMap) ([]models.User, error) {
+	return findByAttributes(m.db, m.table(models.User{}), mysqlAttributes.contains, contains)
+}
+
+// FindByAttributePath returns users whose attribute at path equals value
+func (m *MySQLRepo) FindByAttributePath(path []string, value string) ([]models.User, error) {
+	return findByAttributes(m.db, m.table(models.User{}), mysqlAttributes.path, mysqlAttributes.pathArg(path), value)
+}
+
+func setAttributes(db execer, table string, d attributeDialect, id int, attrs models.JSONMap) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET attributes = %s, updated_at = CURRENT_TIMESTAMP WHERE id = %s AND deleted_at IS NULL",
+		table, d.bind(1), d.bind(2),
+	)
+	res, err := db.Exec(query, attrs, id)
+	if err != nil {
+		return fmt.Errorf("failed to update attributes: %w", err)
+	}
+	return requireRow(res)
+}
+
+func findByAttributes(db *sql.DB, table, predicate string, args ...any) ([]models.User, error) {
+	rows, err := db.Query(
+		"SELECT id, created_at, updated_at, name, attributes FROM "+table+" WHERE deleted_at IS NULL AND "+predicate+" ORDER BY id",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	return ScanAll[models.User](rows)
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"project/models"
+)
+
+// AuditRepository is implemented by adapters that keep an audit trail in
+// audit_log
+type AuditRepository interface {
+	RecordAudit(ctx context.Context, entry models.AuditEntry) error
+	// AuditEntries returns the entries of a subject, oldest first
+	AuditEntries(ctx context.Context, subject string) ([]models.AuditEntry, error)
+}
+
+// RecordAudit appends an entry to the audit trail
+func (s *SQLRepo) RecordAudit(ctx context.Context, entry models.AuditEntry) error {
+	return recordAudit(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.AuditEntry{}), entry)
+}
+
+// AuditEntries retrieves the audit trail of a subject
+func (s *SQLRepo) AuditEntries(ctx context.Context, subject string) ([]models.AuditEntry, error) {
+	return auditEntries(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.AuditEntry{}), subject)
+}
+
+func recordAudit(ctx context.Context, db querier, bind func(int) string, table string, e models.AuditEntry) error {
+	_, err := db.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (actor, action, subject, details, created_at) VALUES (%s, %s, %s, %s, %s)",
+			table, bind(1), bind(2), bind(3), bind(4), bind(5)),
+		e.Actor, e.Action, e.Subject, e.Details, e.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+func auditEntries(ctx context.Context, db querier, bind func(int) string, table, subject string) ([]models.AuditEntry, error) {
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf("SELECT id, actor, action, subject, details, created_at FROM %s WHERE subject = %s ORDER BY created_at, id", table, bind(1)),
+		subject,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	return ScanAll[models.AuditEntry](rows)
+}
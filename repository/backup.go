@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"project/snapshot"
+)
+
+// SnapshotFormat is the table encoding of the snapshots Backup writes
+const SnapshotFormat = "pgcopy-binary"
+
+// migrationTables record the schema rather than data; a restore target is
+// migrated to the same version first
+var migrationTables = []string{"schema_migrations", "data_migrations"}
+
+// BackupTables returns the data tables of schema with every table after
+// the tables its foreign keys reference, so they restore in order
+func BackupTables(schema *Schema) []string {
+	var order []string
+	visited := make(map[string]bool)
+	var visit func(t *Table)
+	visit = func(t *Table) {
+		if visited[t.Name] {
+			return
+		}
+		visited[t.Name] = true
+		for _, c := range t.Constraints {
+			if c.Type == "FOREIGN KEY" && c.RefTable != t.Name {
+				if ref := schema.Table(c.RefTable); ref != nil {
+					visit(ref)
+				}
+			}
+		}
+		if !slices.Contains(migrationTables, t.Name) {
+			order = append(order, t.Name)
+		}
+	}
+	for i := range schema.Tables {
+		visit(&schema.Tables[i])
+	}
+	return order
+}
+
+// Backup writes tables, in order, to w as a snapshot of binary COPY data
+func (e *CopyExporter) Backup(ctx context.Context, w io.Writer, tables []string, meta map[string]string) error {
+	sw, err := snapshot.NewWriter(w, SnapshotFormat)
+	if err != nil {
+		return err
+	}
+	for k, v := range meta {
+		sw.SetMeta(k, v)
+	}
+	for _, table := range tables {
+		err := sw.WriteTable(table, func(w io.Writer) (int64, error) {
+			return e.ExportRaw(ctx, w, table, CopyBinary)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return sw.Close()
+}
+
+// Restore loads every table of the snapshot, in order, into an empty
+// database migrated to the snapshot's schema, in one transaction. The
+// snapshot is verified before anything is written, and serial sequences
+// of the schema's tables with an id column are moved past the restored
+// rows.
+func (e *CopyExporter) Restore(ctx context.Context, r *snapshot.Reader, schema *Schema) error {
+	manifest := r.Manifest()
+	if manifest.Format != SnapshotFormat {
+		return fmt.Errorf("unsupported snapshot format %q", manifest.Format)
+	}
+	for _, t := range manifest.Tables {
+		if !identifierPattern.MatchString(t.Name) {
+			return fmt.Errorf("invalid identifier %q", t.Name)
+		}
+		if schema.Table(t.Name) == nil {
+			return fmt.Errorf("table %s of the snapshot doesn't exist, migrate the database first", t.Name)
+		}
+	}
+	if err := r.Verify(); err != nil {
+		return err
+	}
+
+	conn, err := pgconn.Connect(ctx, e.connString)
+	if err != nil {
+		return fmt.Errorf("failed to connect for copy: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if err := conn.Exec(ctx, "BEGIN").Close(); err != nil {
+		return fmt.Errorf("failed to begin restore: %w", err)
+	}
+	rollback := func(err error) error {
+		conn.Exec(context.Background(), "ROLLBACK").Close()
+		return err
+	}
+
+	for _, t := range manifest.Tables {
+		data, err := r.Table(t.Name)
+		if err != nil {
+			return rollback(err)
+		}
+		if _, err := conn.CopyFrom(ctx, data, fmt.Sprintf("COPY %s FROM STDIN (FORMAT binary)", t.Name)); err != nil {
+			return rollback(fmt.Errorf("failed to restore %s: %w", t.Name, err))
+		}
+		if schema.Table(t.Name).Column("id") == nil {
+			continue
+		}
+		sql := fmt.Sprintf("SELECT setval(seq, COALESCE((SELECT MAX(id) FROM %[1]s), 0) + 1, false) FROM (SELECT pg_get_serial_sequence('%[1]s', 'id') AS seq) s WHERE seq IS NOT NULL", t.Name)
+		if err := conn.Exec(ctx, sql).Close(); err != nil {
+			return rollback(fmt.Errorf("failed to reset %s id sequence: %w", t.Name, err))
+		}
+	}
+
+	if err := conn.Exec(ctx, "COMMIT").Close(); err != nil {
+		return fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return nil
+}
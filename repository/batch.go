@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrBatchAborted is reported for statements skipped after an earlier
+// statement in the same batch failed
+var ErrBatchAborted = errors.New("batch aborted by earlier statement")
+
+// Batch queues statements to be executed together by SendBatch. Queries use
+// the target dialect's placeholders ($1 for Postgres, ? for MySQL).
+type Batch struct {
+	stmts []batchStmt
+}
+
+type batchStmt struct {
+	query string
+	args  []any
+}
+
+// BatchResult reports the outcome of a single queued statement
+type BatchResult struct {
+	RowsAffected int64
+	Err          error
+}
+
+// Queue adds a statement to the batch
+func (b *Batch) Queue(query string, args ...any) {
+	b.stmts = append(b.stmts, batchStmt{query: query, args: args})
+}
+
+// Len returns the number of queued statements
+func (b *Batch) Len() int {
+	return len(b.stmts)
+}
+
+// SendBatch executes the batch on a single connection in one transaction
+func (s *SQLRepo) SendBatch(ctx context.Context, b *Batch) ([]BatchResult, error) {
+	return sendBatch(ctx, s.transactor(), b)
+}
+
+// transactor returns the dialect's transactor, so MySQL batches get its
+// isolation levels and deadlock retries
+func (s *SQLRepo) transactor() *Transactor {
+	if s.dialect.Name() == "mysql" {
+		return NewMySQLTransactor(s.db)
+	}
+	return NewTransactor(s.db)
+}
+
+// sendBatch runs every queued statement in one transaction, so the batch
+// pays for a single connection checkout and commit instead of one per
+// statement. database/sql drivers (lib/pq included) don't pipeline
+// parameterized statements, so each statement is still its own exchange;
+// PostgresRepo.WithPipeline and MySQLRepo.WithMultiStatements send the
+// batch in one round trip instead.
+// The batch is all-or-nothing: on the first failure the transaction is
+// rolled back, the failing statement carries its error and the remaining
+// ones carry ErrBatchAborted.
+//
+// Inside WithTransaction the batch runs in a savepoint of the ambient
+// transaction instead.
+func sendBatch(ctx context.Context, t *Transactor, b *Batch) ([]BatchResult, error) {
+	results := make([]BatchResult, len(b.stmts))
+	if len(b.stmts) == 0 {
+		return results, nil
+	}
+
+	err := t.WithTransaction(ctx, func(ctx context.Context) error {
+		tx, _ := TxFromContext(ctx)
+		for i, stmt := range b.stmts {
+			res, err := tx.ExecContext(ctx, stmt.query, stmt.args...)
+			if err == nil {
+				results[i].RowsAffected, err = res.RowsAffected()
+			}
+			if err != nil {
+				results[i].Err = err
+				for j := i + 1; j < len(results); j++ {
+					results[j].Err = ErrBatchAborted
+				}
+				return fmt.Errorf("batch statement %d failed: %w", i, err)
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+// abortAfter records err for statement i of results and ErrBatchAborted
+// for the ones after it
+func abortAfter(results []BatchResult, i int, err error) {
+	results[i].Err = err
+	for j := i + 1; j < len(results); j++ {
+		results[j].Err = ErrBatchAborted
+	}
+}
+
+// WithPipeline sends batches outside a transaction through pool, a pgx pool
+// on the same database, as one pgx batch: a single round trip, run by the
+// server as one implicit transaction
+func (p *PostgresRepo) WithPipeline(pool *pgxpool.Pool) *PostgresRepo {
+	p.pipeline = pool
+	return p
+}
+
+// SendBatch executes the batch in one round trip when a pipeline is set and
+// ctx carries no transaction, otherwise as SQLRepo.SendBatch does
+func (p *PostgresRepo) SendBatch(ctx context.Context, b *Batch) ([]BatchResult, error) {
+	if _, inTx := TxFromContext(ctx); inTx || p.pipeline == nil || p.rls {
+		return sendBatch(ctx, p.transactor(), b)
+	}
+
+	results := make([]BatchResult, len(b.stmts))
+	if len(b.stmts) == 0 {
+		return results, nil
+	}
+	batch := &pgx.Batch{}
+	for _, stmt := range b.stmts {
+		batch.Queue(stmt.query, stmt.args...)
+	}
+	br := p.pipeline.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := range b.stmts {
+		tag, err := br.Exec()
+		if err != nil {
+			abortAfter(results, i, err)
+			return results, fmt.Errorf("batch statement %d failed: %w", i, err)
+		}
+		results[i].RowsAffected = tag.RowsAffected()
+	}
+	if err := br.Close(); err != nil {
+		return results, fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return results, nil
+}
+
+// WithMultiStatements sends batches outside a transaction as one
+// multi-statement query, wrapped in START TRANSACTION and COMMIT. The db
+// must be opened with the multiStatements and interpolateParams driver
+// parameters, e.g. in DatabaseConfig.Params.
+func (m *MySQLRepo) WithMultiStatements() *MySQLRepo {
+	m.multiStatements = true
+	return m
+}
+
+// SendBatch executes the batch in one round trip when multi-statements are
+// on and ctx carries no transaction, otherwise as SQLRepo.SendBatch does.
+// MySQL doesn't report which statement of a multi-statement query failed,
+// so every statement of a failed one carries the error.
+func (m *MySQLRepo) SendBatch(ctx context.Context, b *Batch) ([]BatchResult, error) {
+	if _, inTx := TxFromContext(ctx); inTx || !m.multiStatements {
+		return m.SQLRepo.SendBatch(ctx, b)
+	}
+
+	results := make([]BatchResult, len(b.stmts))
+	if len(b.stmts) == 0 {
+		return results, nil
+	}
+
+	queries := make([]string, 0, len(b.stmts)+2)
+	queries = append(queries, "START TRANSACTION")
+	var args []any
+	for _, stmt := range b.stmts {
+		queries = append(queries, stmt.query)
+		args = append(args, stmt.args...)
+	}
+	queries = append(queries, "COMMIT")
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	var affected []int64
+	err = conn.Raw(func(dc any) error {
+		execer, ok := dc.(driver.ExecerContext)
+		if !ok {
+			return errors.New("driver can't execute statements directly")
+		}
+		named, err := namedValues(dc, args)
+		if err != nil {
+			return err
+		}
+
+		res, err := execer.ExecContext(ctx, strings.Join(queries, "; "), named)
+		if errors.Is(err, driver.ErrSkip) {
+			return errors.New("multi-statement batches need the interpolateParams driver parameter")
+		}
+		if err != nil {
+			// the server stops at the failing statement, leaving the
+			// transaction open; drop the connection if it won't roll back
+			if _, rbErr := execer.ExecContext(context.WithoutCancel(ctx), "ROLLBACK", nil); rbErr != nil {
+				return errors.Join(err, driver.ErrBadConn)
+			}
+			return err
+		}
+		all, ok := res.(mysql.Result)
+		if !ok {
+			return errors.New("driver didn't report per-statement results")
+		}
+		affected = all.AllRowsAffected()
+		return nil
+	})
+	if err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results, fmt.Errorf("batch failed: %w", err)
+	}
+
+	// skip the result of START TRANSACTION
+	for i := range results {
+		if i+1 < len(affected) {
+			results[i].RowsAffected = affected[i+1]
+		}
+	}
+	return results, nil
+}
+
+// namedValues converts args for a raw driver call the way database/sql
+// would, using the driver's own checker when it has one
+func namedValues(dc any, args []any) ([]driver.NamedValue, error) {
+	checker, _ := dc.(driver.NamedValueChecker)
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		nv := driver.NamedValue{Ordinal: i + 1, Value: arg}
+		err := driver.ErrSkip
+		if checker != nil {
+			err = checker.CheckNamedValue(&nv)
+		}
+		if errors.Is(err, driver.ErrSkip) {
+			nv.Value, err = driver.DefaultParameterConverter.ConvertValue(arg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch argument %d: %w", i+1, err)
+		}
+		named[i] = nv
+	}
+	return named, nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"project/models"
+)
+
+var (
+	benchRows     = flag.Int("bench.rows", 1000, "users the repository benchmarks insert per batch and read back")
+	benchPageSize = flag.Int("bench.page-size", 100, "page size of the pagination benchmark")
+)
+
+// benchPostgresRepo returns a PostgresRepo on a fresh schema of the
+// database at postgresTestDSNEnv, skipping b when it isn't set
+func benchPostgresRepo(b *testing.B) *PostgresRepo {
+	b.Helper()
+	dsn := os.Getenv(postgresTestDSNEnv)
+	if dsn == "" {
+		b.Skipf("%s is not set", postgresTestDSNEnv)
+	}
+	repo, _ := propertyRepo(b, dsn)
+	return repo
+}
+
+// batchInsert inserts n users into repo's users table in one batch
+func batchInsert(repo *PostgresRepo, n int) error {
+	insert := fmt.Sprintf("INSERT INTO %s (name, email) VALUES (%s, %s)",
+		repo.table(models.User{}), repo.dialect.Bind(1), repo.dialect.Bind(2))
+	batch := &Batch{}
+	for i := 0; i < n; i++ {
+		batch.Queue(insert, fmt.Sprintf("bench %d", i), fmt.Sprintf("bench%d@example.com", i))
+	}
+	_, err := repo.SendBatch(context.Background(), batch)
+	return err
+}
+
+func BenchmarkPostgresCreate(b *testing.B) {
+	repo := benchPostgresRepo(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Create(models.User{Name: fmt.Sprintf("bench %d", i)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPostgresBatchInsert(b *testing.B) {
+	repo := benchPostgresRepo(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := batchInsert(repo, *benchRows); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N**benchRows)/b.Elapsed().Seconds(), "rows/s")
+}
+
+func BenchmarkPostgresGetAll(b *testing.B) {
+	repo := benchPostgresRepo(b)
+	if err := batchInsert(repo, *benchRows); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		users, err := repo.GetAll()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(users) != *benchRows {
+			b.Fatalf("GetAll returned %d users, want %d", len(users), *benchRows)
+		}
+	}
+}
+
+// BenchmarkPostgresPaginate reads every user a page at a time per op
+func BenchmarkPostgresPaginate(b *testing.B) {
+	repo := benchPostgresRepo(b)
+	from := time.Now().Add(-time.Minute)
+	if err := batchInsert(repo, *benchRows); err != nil {
+		b.Fatal(err)
+	}
+	to := time.Now().Add(time.Minute)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		read := 0
+		for offset := 0; ; offset += *benchPageSize {
+			page, err := repo.ListCreatedBetween(from, to, ListOptions{Limit: *benchPageSize, Offset: offset})
+			if err != nil {
+				b.Fatal(err)
+			}
+			read += len(page)
+			if len(page) < *benchPageSize {
+				break
+			}
+		}
+		if read != *benchRows {
+			b.Fatalf("paginated %d users, want %d", read, *benchRows)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"project/apperr"
+	"project/models"
+)
+
+// ErrUnfilteredDelete is returned by DeleteWhere for an empty filter
+// without AllowFullTableDelete
+var ErrUnfilteredDelete = apperr.New(apperr.InvalidArgument, "refusing to delete every user without AllowFullTableDelete")
+
+// DeleteOptions controls DeleteWhere
+type DeleteOptions struct {
+	// BatchSize bounds the rows removed per statement, 500 by default
+	BatchSize int
+	// AllowFullTableDelete permits an empty filter
+	AllowFullTableDelete bool
+}
+
+// BulkDeleter is implemented by adapters that can delete users by criteria
+type BulkDeleter interface {
+	DeleteWhere(ctx context.Context, filter Filter, opts DeleteOptions) (int64, error)
+}
+
+// DeleteWhere permanently removes the users matching filter, soft-deleted
+// or not, in batches of opts.BatchSize so no statement holds locks on the
+// whole set, and returns the number removed. Unlike Delete it skips hooks
+// and history. Inside WithTransaction the batches join the transaction.
+func (s *SQLRepo) DeleteWhere(ctx context.Context, filter Filter, opts DeleteOptions) (int64, error) {
+	if len(filter) == 0 && !opts.AllowFullTableDelete {
+		return 0, ErrUnfilteredDelete
+	}
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = 500
+	}
+
+	where, args, err := filter.where(s.dialect.Bind, 0)
+	if err != nil {
+		return 0, err
+	}
+	// the batch size is an int, safe to inline
+	table := s.table(models.User{})
+	query := fmt.Sprintf("DELETE FROM %[1]s WHERE id IN (SELECT id FROM %[1]s WHERE %[2]s ORDER BY id LIMIT %[3]d)", table, where, batch)
+	if s.dialect.Name() == "mysql" {
+		// MySQL can't LIMIT a subquery of the table being deleted from
+		query = fmt.Sprintf("DELETE FROM %s WHERE %s ORDER BY id LIMIT %d", table, where, batch)
+	}
+
+	db := dbFrom(ctx, s.db)
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return total, fmt.Errorf("failed to delete users: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		total += n
+		if n < int64(batch) {
+			return total, nil
+		}
+	}
+}
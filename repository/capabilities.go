@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Capabilities describes what an adapter supports, so callers can adapt to
+// a limited backend instead of failing at runtime
+type Capabilities struct {
+	// SupportsUpsert reports atomic insert-or-update writes
+	SupportsUpsert bool
+	// SupportsFullTextSearch reports native full-text search, see
+	// FullTextSearcher
+	SupportsFullTextSearch bool
+	// SupportsTransactions reports multi-statement transactions, see
+	// TxRunner
+	SupportsTransactions bool
+	// MaxBatchSize caps the items of one batch write; 0 means no limit
+	MaxBatchSize int
+}
+
+// CapabilityReporter is implemented by adapters that describe their
+// capabilities
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf returns the capabilities of repo. Adapters that don't
+// report them are assumed to support whichever optional interfaces they
+// implement, and nothing else.
+func CapabilitiesOf(repo UserRepository) Capabilities {
+	if r, ok := repo.(CapabilityReporter); ok {
+		return r.Capabilities()
+	}
+	_, fts := repo.(FullTextSearcher)
+	_, tx := repo.(TxRunner)
+	return Capabilities{SupportsFullTextSearch: fts, SupportsTransactions: tx}
+}
+
+// Capabilities implements CapabilityReporter
+func (s *SQLRepo) Capabilities() Capabilities {
+	return Capabilities{SupportsUpsert: true, SupportsTransactions: true}
+}
+
+// Capabilities implements CapabilityReporter
+func (p *PostgresRepo) Capabilities() Capabilities {
+	c := p.SQLRepo.Capabilities()
+	c.SupportsFullTextSearch = true
+	return c
+}
+
+// Capabilities implements CapabilityReporter; events are appended in
+// transactions, but a stream can't be upserted
+func (r *EventSourcedRepo) Capabilities() Capabilities {
+	return Capabilities{SupportsTransactions: true}
+}
+
+// Capabilities implements CapabilityReporter. Only the UserRepository
+// methods are mirrored, and never in one transaction across both stores,
+// so batches are all that carry over.
+func (d *DualWriteRepository) Capabilities() Capabilities {
+	limit := CapabilitiesOf(d.old).MaxBatchSize
+	if n := CapabilitiesOf(d.new).MaxBatchSize; n != 0 && (limit == 0 || n < limit) {
+		limit = n
+	}
+	return Capabilities{MaxBatchSize: limit}
+}
+
+// Capabilities implements CapabilityReporter with those of the wrapped
+// adapter; the decorator's own stubs for the rest return ErrUnsupported
+func (d *decorated) Capabilities() Capabilities {
+	return CapabilitiesOf(d.inner)
+}
+
+// Feature is one row of a capability matrix: whether the adapter for a
+// dialect implements a feature, and whether the connected server has it
+type Feature struct {
+	Name string
+	// Adapter reports the package implements the feature on the dialect
+	Adapter bool
+	// Server reports the server passed the feature's probe; Err is the
+	// probe's failure
+	Server bool
+	Err    error
+	// Detail says how the feature is provided, e.g. "ON CONFLICT"
+	Detail string
+}
+
+// featureProbe is a statement that succeeds only where a feature works
+type featureProbe struct {
+	name    string
+	adapter bool
+	detail  string
+	query   string
+}
+
+// ProbeFeatures checks which features the adapter for d implements and
+// which the server behind db supports, running a harmless statement per
+// feature. It helps choose an adapter and explain an ErrUnsupported.
+func ProbeFeatures(ctx context.Context, db *sql.DB, d Dialect) []Feature {
+	var probes []featureProbe
+	if d.Name() == "postgres" {
+		caps := (&PostgresRepo{SQLRepo: &SQLRepo{}}).Capabilities()
+		probes = []featureProbe{
+			{"upsert", caps.SupportsUpsert, "ON CONFLICT", "SELECT 1 WHERE current_setting('server_version_num')::int >= 90500"},
+			{"full-text search", caps.SupportsFullTextSearch, "tsvector",
+				"SELECT to_tsvector('simple', 'adapter') @@ plainto_tsquery('simple', 'adapter')"},
+			{"listen/notify", false, "pg_notify, not used by the adapters", "SELECT pg_notify('adapter_capabilities', '')"},
+			{"arrays", true, "native arrays", "SELECT ARRAY[1]::BIGINT[]"},
+		}
+	} else {
+		caps := NewSQLRepo(nil, d).Capabilities()
+		probes = []featureProbe{
+			{"upsert", caps.SupportsUpsert, "ON DUPLICATE KEY UPDATE", "SELECT 1"},
+			{"full-text search", caps.SupportsFullTextSearch, "FULLTEXT indexes, not used by the adapter",
+				"SELECT @@innodb_ft_min_token_size"},
+			{"listen/notify", false, "not available", ""},
+			{"arrays", true, "stored as JSON", "SELECT JSON_ARRAY(1)"},
+		}
+	}
+
+	features := []Feature{probeTransactions(ctx, db, d)}
+	for _, p := range probes {
+		f := Feature{Name: p.name, Adapter: p.adapter, Detail: p.detail}
+		if p.query != "" {
+			var out sql.RawBytes
+			rows, err := db.QueryContext(ctx, p.query)
+			if err == nil {
+				// a probe that matches no row, e.g. a version check, fails
+				if !rows.Next() {
+					err = rows.Err()
+					if err == nil {
+						err = errors.New("server too old")
+					}
+				} else {
+					err = rows.Scan(&out)
+				}
+				rows.Close()
+			}
+			f.Server, f.Err = err == nil, err
+		}
+		features = append(features, f)
+	}
+	return features
+}
+
+// probeTransactions begins and rolls back a transaction; on MySQL the
+// default storage engine must be transactional too
+func probeTransactions(ctx context.Context, db *sql.DB, d Dialect) Feature {
+	f := Feature{Name: "transactions", Adapter: true, Detail: "BEGIN/COMMIT"}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		f.Err = err
+		return f
+	}
+	defer tx.Rollback()
+
+	if d.Name() == "mysql" {
+		var engine string
+		if err := tx.QueryRowContext(ctx, "SELECT @@default_storage_engine").Scan(&engine); err != nil {
+			f.Err = err
+			return f
+		}
+		if !strings.EqualFold(engine, "InnoDB") {
+			f.Err = fmt.Errorf("default storage engine %s isn't transactional", engine)
+			return f
+		}
+		f.Detail = "InnoDB"
+	}
+	f.Server = true
+	return f
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeText returns s in Unicode NFC, so a name typed with a combining
+// accent ("Jose\u0301") is stored, compared and indexed like its
+// precomposed form ("José")
+func normalizeText(s string) string {
+	return norm.NFC.String(s)
+}
+
+// collationPattern matches collation names, e.g. "de-x-icu", "C" or
+// "utf8mb4_0900_ai_ci"; they are spliced into DDL
+var collationPattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
+
+// WithCollation sets the collation Plan and AutoMigrate give the columns
+// tagged collate without a value of their own, e.g. `db:"name,collate"`,
+// so a deployment can sort names by its locale, e.g. "de-x-icu". Without
+// it those columns use the database default. Existing columns aren't
+// altered.
+func (m *Migrator) WithCollation(collation string) *Migrator {
+	m.collation = collation
+	return m
+}
+
+// columnCollation returns the COLLATE clause of col under the migrator
+// collation, quoted for d, or "" for the database default
+func columnCollation(d Dialect, col columnDef, collation string) (string, error) {
+	if !col.Collate {
+		return "", nil
+	}
+	if col.Collation != "" {
+		collation = col.Collation
+	}
+	if collation == "" {
+		return "", nil
+	}
+	if !collationPattern.MatchString(collation) {
+		return "", fmt.Errorf("invalid collation %q for column %s", collation, col.Name)
+	}
+	return " COLLATE " + d.Quote(collation), nil
+}
+
+// unaccentFunction strips diacritics in the search vector and queries.
+// unaccent itself is only STABLE, since its dictionary can change, so an
+// IMMUTABLE wrapper is needed for the generated column.
+const unaccentFunction = "immutable_unaccent"
+
+// unaccentStatements install unaccentFunction; they are idempotent
+var unaccentStatements = []string{
+	"CREATE EXTENSION IF NOT EXISTS unaccent;",
+	"CREATE OR REPLACE FUNCTION " + unaccentFunction + "(text) RETURNS text " +
+		"LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT " +
+		"AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$;",
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CounterRepository is implemented by adapters that keep named counters,
+// e.g. for tenant quotas. Counters live in quota_counters, one row per
+// tenant, counter and window start.
+type CounterRepository interface {
+	IncrementCounter(ctx context.Context, tenant, counter string, window time.Time, delta int) (int, error)
+	// ResetCounter sets a counter of window back to zero in one statement,
+	// so increments racing it aren't lost
+	ResetCounter(ctx context.Context, tenant, counter string, window time.Time) error
+}
+
+// IncrementCounter atomically adds delta to a counter and returns its new
+// value
+func (p *PostgresRepo) IncrementCounter(ctx context.Context, tenant, counter string, window time.Time, delta int) (int, error) {
+	var value int
+	err := dbFrom(ctx, p.db).QueryRowContext(ctx,
+		`INSERT INTO quota_counters (tenant, counter, window_start, value) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (tenant, counter, window_start) DO UPDATE SET value = quota_counters.value + EXCLUDED.value
+		 RETURNING value`,
+		tenant, counter, window.UTC(), delta,
+	).Scan(&value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment counter: %w", err)
+	}
+	return value, nil
+}
+
+// ResetCounter implements CounterRepository by deleting the counter's row
+func (p *PostgresRepo) ResetCounter(ctx context.Context, tenant, counter string, window time.Time) error {
+	_, err := dbFrom(ctx, p.db).ExecContext(ctx,
+		"DELETE FROM quota_counters WHERE tenant = $1 AND counter = $2 AND window_start = $3",
+		tenant, counter, window.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reset counter: %w", err)
+	}
+	return nil
+}
+
+// IncrementCounter atomically adds delta to a counter and returns its new
+// value. LAST_INSERT_ID(expr) hands the updated value back on the same
+// connection, so both statements run in one transaction.
+func (m *MySQLRepo) IncrementCounter(ctx context.Context, tenant, counter string, window time.Time, delta int) (int, error) {
+	var value int
+	err := NewMySQLTransactor(m.db).WithTransaction(ctx, func(ctx context.Context) error {
+		q := dbFrom(ctx, m.db)
+		if _, err := q.ExecContext(ctx,
+			`INSERT INTO quota_counters (tenant, counter, window_start, value) VALUES (?, ?, ?, LAST_INSERT_ID(?))
+			 ON DUPLICATE KEY UPDATE value = LAST_INSERT_ID(value + VALUES(value))`,
+			tenant, counter, window.UTC(), delta,
+		); err != nil {
+			return err
+		}
+		return q.QueryRowContext(ctx, "SELECT LAST_INSERT_ID()").Scan(&value)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment counter: %w", err)
+	}
+	return value, nil
+}
+
+// ResetCounter implements CounterRepository by deleting the counter's row
+func (m *MySQLRepo) ResetCounter(ctx context.Context, tenant, counter string, window time.Time) error {
+	_, err := dbFrom(ctx, m.db).ExecContext(ctx,
+		"DELETE FROM quota_counters WHERE tenant = ? AND counter = ? AND window_start = ?",
+		tenant, counter, window.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reset counter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// DataMigration is a versioned Go migration of data, e.g. a backfill of a
+// column a schema migration added. Data migrations are tracked apart from
+// schema migrations, in data_migrations.
+type DataMigration struct {
+	Version int64
+	Name    string
+	// Schema is the schema migration version the data migration needs;
+	// it waits until that version is applied
+	Schema int64
+	// Up runs in a transaction, both passed as tx and carried by ctx so
+	// repository methods called with ctx take part in it
+	Up func(ctx context.Context, tx *sql.Tx) error
+}
+
+const createDataMigrationsTable = `CREATE TABLE IF NOT EXISTS data_migrations (
+	version BIGINT PRIMARY KEY,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// sortDataMigrations returns migrations sorted by version, rejecting
+// duplicate versions
+func sortDataMigrations(migrations []DataMigration) ([]DataMigration, error) {
+	sorted := append([]DataMigration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("duplicate data migration version %d: %q and %q",
+				sorted[i].Version, sorted[i-1].Name, sorted[i].Name)
+		}
+	}
+	return sorted, nil
+}
+
+// PendingDataMigrations returns the data migrations not yet recorded as
+// applied, sorted by version
+func (m *Migrator) PendingDataMigrations(ctx context.Context, migrations []DataMigration) ([]DataMigration, error) {
+	sorted, err := sortDataMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedDataVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []DataMigration
+	for _, mig := range sorted {
+		if !applied[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateData applies the pending data migrations in version order, each in
+// its own transaction, while holding the migration lock. It stops at the
+// first migration whose schema version isn't applied yet and returns the
+// number applied.
+func (m *Migrator) MigrateData(ctx context.Context, migrations []DataMigration) (int, error) {
+	sorted, err := sortDataMigrations(migrations)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		if _, err := conn.ExecContext(ctx, createDataMigrationsTable); err != nil {
+			return fmt.Errorf("failed to create data migrations table: %w", err)
+		}
+
+		applied, err := appliedDataVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		schema, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range sorted {
+			if applied[mig.Version] {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("data migrations stopped before %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if mig.Schema != 0 && !schema[mig.Schema] {
+				return fmt.Errorf("data migration %d_%s needs schema migration %d, which is not applied", mig.Version, mig.Name, mig.Schema)
+			}
+			if err := applyDataMigration(ctx, conn, mig); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+func appliedDataVersions(ctx context.Context, q querier) (map[int64]bool, error) {
+	var exists int
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'data_migrations'",
+	).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check data migrations table: %w", err)
+	}
+
+	applied := make(map[int64]bool)
+	if exists == 0 {
+		return applied, nil
+	}
+
+	err = eachRow(ctx, q, "SELECT version FROM data_migrations", func(rows *sql.Rows) error {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return err
+		}
+		applied[v] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied data migrations: %w", err)
+	}
+	return applied, nil
+}
+
+func applyDataMigration(ctx context.Context, conn *sql.Conn, mig DataMigration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin data migration %d: %w", mig.Version, err)
+	}
+	defer tx.Rollback()
+
+	txCtx := context.WithValue(ctx, txKey{}, &txState{tx: tx})
+	if err := mig.Up(txCtx, tx); err != nil {
+		return fmt.Errorf("failed to apply data migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	record := fmt.Sprintf("INSERT INTO data_migrations (version) VALUES (%d)", mig.Version)
+	if _, err := tx.ExecContext(ctx, record); err != nil {
+		return fmt.Errorf("failed to record data migration %d: %w", mig.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit data migration %d: %w", mig.Version, err)
+	}
+	return nil
+}
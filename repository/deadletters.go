@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"project/apperr"
+	"project/clock"
+)
+
+// DeadLetter is an outbox message that exhausted its delivery attempts
+type DeadLetter struct {
+	ID       int64
+	OutboxID int64
+	Topic    string
+	// Payload is encoded as ContentType says
+	Payload     []byte
+	ContentType string
+	Attempts    int
+	LastError   string
+	FailedAt    time.Time
+	// ReplayedAt is set once the message was queued again
+	ReplayedAt *time.Time
+}
+
+// DeadLetterFilter selects dead letters to list
+type DeadLetterFilter struct {
+	// Topic limits the list to one topic when set
+	Topic string
+	// Replayed includes the letters already replayed
+	Replayed bool
+	Limit    int
+}
+
+// ErrAlreadyReplayed is returned for editing or replaying a dead letter
+// that was replayed before
+var ErrAlreadyReplayed = apperr.New(apperr.Conflict, "dead letter was already replayed")
+
+// DeadLetters reads the dead_letters table the outbox fills, edits the
+// letters and replays them into the outbox
+type DeadLetters struct {
+	db     *sql.DB
+	bind   func(int) string
+	outbox *Outbox
+}
+
+// NewDeadLetters creates the dead letter queue of outbox
+func NewDeadLetters(outbox *Outbox) *DeadLetters {
+	return &DeadLetters{db: outbox.db, bind: outbox.bind, outbox: outbox}
+}
+
+// deadLetterColumns are the columns scanDeadLetter reads
+const deadLetterColumns = "id, outbox_id, topic, content_type, payload, body, attempts, last_error, failed_at, replayed_at"
+
+// List returns the dead letters matching f, the oldest first
+func (d *DeadLetters) List(ctx context.Context, f DeadLetterFilter) ([]DeadLetter, error) {
+	query := "SELECT " + deadLetterColumns + " FROM dead_letters WHERE TRUE"
+	var args []any
+	if f.Topic != "" {
+		args = append(args, f.Topic)
+		query += " AND topic = " + d.bind(len(args))
+	}
+	if !f.Replayed {
+		query += " AND replayed_at IS NULL"
+	}
+	query += " ORDER BY failed_at, id"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+
+	rows, err := dbFrom(ctx, d.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []DeadLetter
+	for rows.Next() {
+		l, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return letters, nil
+}
+
+// Get returns one dead letter, or ErrNotFound
+func (d *DeadLetters) Get(ctx context.Context, id int64) (DeadLetter, error) {
+	return d.get(ctx, dbFrom(ctx, d.db), id, "")
+}
+
+// get reads one dead letter, appending suffix, e.g. FOR UPDATE
+func (d *DeadLetters) get(ctx context.Context, db querier, id int64, suffix string) (DeadLetter, error) {
+	row := db.QueryRowContext(ctx, "SELECT "+deadLetterColumns+" FROM dead_letters WHERE id = "+d.bind(1)+suffix, id)
+	l, err := scanDeadLetter(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DeadLetter{}, ErrNotFound
+	}
+	return l, err
+}
+
+// Edit replaces the payload of a dead letter not replayed yet, e.g. to fix
+// what its consumer rejected. JSON payloads must stay valid JSON.
+func (d *DeadLetters) Edit(ctx context.Context, id int64, payload []byte) error {
+	l, err := d.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if l.ReplayedAt != nil {
+		return ErrAlreadyReplayed
+	}
+
+	column, value := "body", any(payload)
+	if l.ContentType == JSONContentType {
+		if !json.Valid(payload) {
+			return apperr.New(apperr.InvalidArgument, "payload is not valid JSON")
+		}
+		column, value = "payload", string(payload)
+	}
+	_, err = dbFrom(ctx, d.db).ExecContext(ctx,
+		fmt.Sprintf("UPDATE dead_letters SET %s = %s WHERE id = %s AND replayed_at IS NULL", column, d.bind(1), d.bind(2)),
+		value, id)
+	if err != nil {
+		return fmt.Errorf("failed to edit dead letter: %w", err)
+	}
+	return nil
+}
+
+// Replay queues a dead letter in the outbox again, with fresh attempts,
+// and marks it replayed in the same transaction
+func (d *DeadLetters) Replay(ctx context.Context, id int64) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	l, err := d.get(ctx, tx, id, " FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	if l.ReplayedAt != nil {
+		return ErrAlreadyReplayed
+	}
+
+	txCtx := context.WithValue(ctx, txKey{}, &txState{tx: tx})
+	if err := d.outbox.EnqueueEncoded(txCtx, l.Topic, l.ContentType, l.Payload); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE dead_letters SET replayed_at = "+d.bind(1)+" WHERE id = "+d.bind(2),
+		clock.Or(d.outbox.Clock).Now().UTC(), id); err != nil {
+		return fmt.Errorf("failed to mark dead letter replayed: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Delete discards a dead letter
+func (d *DeadLetters) Delete(ctx context.Context, id int64) error {
+	res, err := dbFrom(ctx, d.db).ExecContext(ctx, "DELETE FROM dead_letters WHERE id = "+d.bind(1), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanDeadLetter scans the deadLetterColumns of one row
+func scanDeadLetter(row interface{ Scan(dest ...any) error }) (DeadLetter, error) {
+	var (
+		l             DeadLetter
+		payload, body []byte
+		replayed      sql.NullTime
+	)
+	if err := row.Scan(&l.ID, &l.OutboxID, &l.Topic, &l.ContentType, &payload, &body, &l.Attempts, &l.LastError,
+		&l.FailedAt, &replayed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return DeadLetter{}, err
+		}
+		return DeadLetter{}, fmt.Errorf("failed to scan dead letter: %w", err)
+	}
+	l.Payload = payload
+	if body != nil {
+		l.Payload = body
+	}
+	if replayed.Valid {
+		l.ReplayedAt = &replayed.Time
+	}
+	return l, nil
+}
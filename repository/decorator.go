@@ -0,0 +1,751 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"project/apperr"
+	"project/models"
+)
+
+// ErrUnsupported is returned by a decorated repository when the wrapped
+// adapter lacks the optional capability called
+var ErrUnsupported error = apperr.New(apperr.Unimplemented, "operation not supported by repository")
+
+// Middleware wraps every repository call; method is the interface method
+// name, e.g. "GetByID". It must call next exactly once to run the call.
+type Middleware func(ctx context.Context, method string, next func(ctx context.Context) error) error
+
+// Decorate wraps repo so every call runs through mws, the first outermost.
+// The result implements all optional repository interfaces, returning
+// ErrUnsupported for those repo does not.
+func Decorate(repo UserRepository, mws ...Middleware) UserRepository {
+	return &decorated{inner: repo, mws: mws}
+}
+
+// ContextualRepository is implemented by repositories that can bind a
+// context, e.g. carrying a request ID, to the calls they make
+type ContextualRepository interface {
+	WithContext(ctx context.Context) UserRepository
+}
+
+// decorated is the repository returned by Decorate
+type decorated struct {
+	inner UserRepository
+	mws   []Middleware
+	ctx   context.Context
+}
+
+// WithContext implements ContextualRepository; middleware receive ctx
+func (d *decorated) WithContext(ctx context.Context) UserRepository {
+	bound := *d
+	bound.ctx = ctx
+	return &bound
+}
+
+// call runs fn through the middleware chain with the bound context
+func (d *decorated) call(method string, fn func() error) error {
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return d.callContext(ctx, method, func(context.Context) error { return fn() })
+}
+
+// callContext runs fn through the middleware chain with ctx
+func (d *decorated) callContext(ctx context.Context, method string, fn func(ctx context.Context) error) error {
+	next := fn
+	for i := len(d.mws) - 1; i >= 0; i-- {
+		mw, inner := d.mws[i], next
+		next = func(ctx context.Context) error { return mw(ctx, method, inner) }
+	}
+	return next(ctx)
+}
+
+// unsupported reports a missing optional capability
+func unsupported(capability string) error {
+	return fmt.Errorf("%w: %s", ErrUnsupported, capability)
+}
+
+// Create implements UserRepository
+func (d *decorated) Create(user models.User) (created models.User, err error) {
+	err = d.call("Create", func() error {
+		created, err = d.inner.Create(user)
+		return err
+	})
+	return created, err
+}
+
+// CreateContext implements ContextCreator
+func (d *decorated) CreateContext(ctx context.Context, user models.User) (created models.User, err error) {
+	repo, ok := d.inner.(ContextCreator)
+	if !ok {
+		return models.User{}, unsupported("context-aware create")
+	}
+	err = d.callContext(ctx, "Create", func(ctx context.Context) error {
+		created, err = repo.CreateContext(ctx, user)
+		return err
+	})
+	return created, err
+}
+
+// WithTransaction implements TxRunner. The transaction itself isn't passed
+// through the middleware; the calls made inside fn are.
+func (d *decorated) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	repo, ok := d.inner.(TxRunner)
+	if !ok {
+		return unsupported("transactions")
+	}
+	return repo.WithTransaction(ctx, fn)
+}
+
+// GetAll implements UserRepository
+func (d *decorated) GetAll() (users []models.User, err error) {
+	err = d.call("GetAll", func() error {
+		users, err = d.inner.GetAll()
+		return err
+	})
+	return users, err
+}
+
+// GetByID implements UserRepository
+func (d *decorated) GetByID(id int) (user models.User, err error) {
+	err = d.call("GetByID", func() error {
+		user, err = d.inner.GetByID(id)
+		return err
+	})
+	return user, err
+}
+
+// GetByIDs implements BatchGetter
+func (d *decorated) GetByIDs(ids []int) (users []models.User, missing []int, err error) {
+	repo, ok := d.inner.(BatchGetter)
+	if !ok {
+		return nil, nil, unsupported("batch get")
+	}
+	err = d.call("GetByIDs", func() error {
+		users, missing, err = repo.GetByIDs(ids)
+		return err
+	})
+	return users, missing, err
+}
+
+// ListCreatedBetween implements UserRepository
+func (d *decorated) ListCreatedBetween(from, to time.Time, opts ListOptions) (users []models.User, err error) {
+	err = d.call("ListCreatedBetween", func() error {
+		users, err = d.inner.ListCreatedBetween(from, to, opts)
+		return err
+	})
+	return users, err
+}
+
+// Update implements UserRepository
+func (d *decorated) Update(user models.User) error {
+	return d.call("Update", func() error { return d.inner.Update(user) })
+}
+
+// Delete implements UserRepository
+func (d *decorated) Delete(id int) error {
+	return d.call("Delete", func() error { return d.inner.Delete(id) })
+}
+
+// UpdateResult implements ResultReporter
+func (d *decorated) UpdateResult(user models.User) (res WriteResult, err error) {
+	repo, ok := d.inner.(ResultReporter)
+	if !ok {
+		return WriteResult{}, unsupported("write results")
+	}
+	err = d.call("Update", func() error {
+		res, err = repo.UpdateResult(user)
+		return err
+	})
+	return res, err
+}
+
+// DeleteResult implements ResultReporter
+func (d *decorated) DeleteResult(id int) (res WriteResult, err error) {
+	repo, ok := d.inner.(ResultReporter)
+	if !ok {
+		return WriteResult{}, unsupported("write results")
+	}
+	err = d.call("Delete", func() error {
+		res, err = repo.DeleteResult(id)
+		return err
+	})
+	return res, err
+}
+
+// CreatePost implements PostRepository
+func (d *decorated) CreatePost(post models.Post) error {
+	repo, ok := d.inner.(PostRepository)
+	if !ok {
+		return unsupported("posts")
+	}
+	return d.call("CreatePost", func() error { return repo.CreatePost(post) })
+}
+
+// GetUserWithPosts implements PostRepository
+func (d *decorated) GetUserWithPosts(id int) (user models.User, err error) {
+	repo, ok := d.inner.(PostRepository)
+	if !ok {
+		return models.User{}, unsupported("posts")
+	}
+	err = d.call("GetUserWithPosts", func() error {
+		user, err = repo.GetUserWithPosts(id)
+		return err
+	})
+	return user, err
+}
+
+// GetAllWithPosts implements PostRepository
+func (d *decorated) GetAllWithPosts() (users []models.User, err error) {
+	repo, ok := d.inner.(PostRepository)
+	if !ok {
+		return nil, unsupported("posts")
+	}
+	err = d.call("GetAllWithPosts", func() error {
+		users, err = repo.GetAllWithPosts()
+		return err
+	})
+	return users, err
+}
+
+// CountByCreatedDate implements AggregateRepository
+func (d *decorated) CountByCreatedDate() (counts map[string]int, err error) {
+	repo, ok := d.inner.(AggregateRepository)
+	if !ok {
+		return nil, unsupported("aggregates")
+	}
+	err = d.call("CountByCreatedDate", func() error {
+		counts, err = repo.CountByCreatedDate()
+		return err
+	})
+	return counts, err
+}
+
+// GroupBy implements AggregateRepository
+func (d *decorated) GroupBy(field string) (counts map[string]int, err error) {
+	repo, ok := d.inner.(AggregateRepository)
+	if !ok {
+		return nil, unsupported("aggregates")
+	}
+	err = d.call("GroupBy", func() error {
+		counts, err = repo.GroupBy(field)
+		return err
+	})
+	return counts, err
+}
+
+// SearchUsersFullText implements FullTextSearcher
+func (d *decorated) SearchUsersFullText(query string, opts ListOptions) (users []RankedUser, err error) {
+	repo, ok := d.inner.(FullTextSearcher)
+	if !ok {
+		return nil, unsupported("full-text search")
+	}
+	err = d.call("SearchUsersFullText", func() error {
+		users, err = repo.SearchUsersFullText(query, opts)
+		return err
+	})
+	return users, err
+}
+
+// SetAttributes implements AttributeRepository
+func (d *decorated) SetAttributes(id int, attrs models.JSONMap) error {
+	repo, ok := d.inner.(AttributeRepository)
+	if !ok {
+		return unsupported("attributes")
+	}
+	return d.call("SetAttributes", func() error { return repo.SetAttributes(id, attrs) })
+}
+
+// FindByAttributes implements AttributeRepository
+func (d *decorated) FindByAttributes(contains models.JSONMap) (users []models.User, err error) {
+	repo, ok := d.inner.(AttributeRepository)
+	if !ok {
+		return nil, unsupported("attributes")
+	}
+	err = d.call("FindByAttributes", func() error {
+		users, err = repo.FindByAttributes(contains)
+		return err
+	})
+	return users, err
+}
+
+// FindByAttributePath implements AttributeRepository
+func (d *decorated) FindByAttributePath(path []string, value string) (users []models.User, err error) {
+	repo, ok := d.inner.(AttributeRepository)
+	if !ok {
+		return nil, unsupported("attributes")
+	}
+	err = d.call("FindByAttributePath", func() error {
+		users, err = repo.FindByAttributePath(path, value)
+		return err
+	})
+	return users, err
+}
+
+// GetUserAsOf implements HistoryRepository
+func (d *decorated) GetUserAsOf(id int, t time.Time) (user models.User, err error) {
+	repo, ok := d.inner.(HistoryRepository)
+	if !ok {
+		return models.User{}, unsupported("history")
+	}
+	err = d.call("GetUserAsOf", func() error {
+		user, err = repo.GetUserAsOf(id, t)
+		return err
+	})
+	return user, err
+}
+
+// SetChannelPreference implements PreferenceRepository
+func (d *decorated) SetChannelPreference(ctx context.Context, pref ChannelPreference) error {
+	repo, ok := d.inner.(PreferenceRepository)
+	if !ok {
+		return unsupported("notification preferences")
+	}
+	return d.callContext(ctx, "SetChannelPreference", func(ctx context.Context) error {
+		return repo.SetChannelPreference(ctx, pref)
+	})
+}
+
+// ChannelPreferences implements PreferenceRepository
+func (d *decorated) ChannelPreferences(ctx context.Context, userID int) (prefs []ChannelPreference, err error) {
+	repo, ok := d.inner.(PreferenceRepository)
+	if !ok {
+		return nil, unsupported("notification preferences")
+	}
+	err = d.callContext(ctx, "ChannelPreferences", func(ctx context.Context) error {
+		prefs, err = repo.ChannelPreferences(ctx, userID)
+		return err
+	})
+	return prefs, err
+}
+
+// GetUserWithSettings implements SettingsRepository
+func (d *decorated) GetUserWithSettings(id int) (user models.User, err error) {
+	repo, ok := d.inner.(SettingsRepository)
+	if !ok {
+		return models.User{}, unsupported("user settings")
+	}
+	err = d.call("GetUserWithSettings", func() error {
+		user, err = repo.GetUserWithSettings(id)
+		return err
+	})
+	return user, err
+}
+
+// UpdateUserWithSettings implements SettingsRepository
+func (d *decorated) UpdateUserWithSettings(user models.User) error {
+	repo, ok := d.inner.(SettingsRepository)
+	if !ok {
+		return unsupported("user settings")
+	}
+	return d.call("UpdateUserWithSettings", func() error { return repo.UpdateUserWithSettings(user) })
+}
+
+// CreateOrganization implements OrganizationRepository
+func (d *decorated) CreateOrganization(ctx context.Context, org models.Organization) (created models.Organization, err error) {
+	repo, ok := d.inner.(OrganizationRepository)
+	if !ok {
+		return models.Organization{}, unsupported("organizations")
+	}
+	err = d.callContext(ctx, "CreateOrganization", func(ctx context.Context) error {
+		created, err = repo.CreateOrganization(ctx, org)
+		return err
+	})
+	return created, err
+}
+
+// DeleteOrganization implements OrganizationRepository
+func (d *decorated) DeleteOrganization(ctx context.Context, id int) error {
+	repo, ok := d.inner.(OrganizationRepository)
+	if !ok {
+		return unsupported("organizations")
+	}
+	return d.callContext(ctx, "DeleteOrganization", func(ctx context.Context) error {
+		return repo.DeleteOrganization(ctx, id)
+	})
+}
+
+// GetOrganization implements OrganizationRepository
+func (d *decorated) GetOrganization(ctx context.Context, id int) (org models.Organization, err error) {
+	repo, ok := d.inner.(OrganizationRepository)
+	if !ok {
+		return models.Organization{}, unsupported("organizations")
+	}
+	err = d.callContext(ctx, "GetOrganization", func(ctx context.Context) error {
+		org, err = repo.GetOrganization(ctx, id)
+		return err
+	})
+	return org, err
+}
+
+// AddMember implements OrganizationRepository
+func (d *decorated) AddMember(ctx context.Context, m models.Membership) error {
+	repo, ok := d.inner.(OrganizationRepository)
+	if !ok {
+		return unsupported("organizations")
+	}
+	return d.callContext(ctx, "AddMember", func(ctx context.Context) error { return repo.AddMember(ctx, m) })
+}
+
+// RemoveMember implements OrganizationRepository
+func (d *decorated) RemoveMember(ctx context.Context, orgID, userID int) error {
+	repo, ok := d.inner.(OrganizationRepository)
+	if !ok {
+		return unsupported("organizations")
+	}
+	return d.callContext(ctx, "RemoveMember", func(ctx context.Context) error { return repo.RemoveMember(ctx, orgID, userID) })
+}
+
+// ListMembers implements OrganizationRepository
+func (d *decorated) ListMembers(ctx context.Context, orgID int) (members []models.Membership, err error) {
+	repo, ok := d.inner.(OrganizationRepository)
+	if !ok {
+		return nil, unsupported("organizations")
+	}
+	err = d.callContext(ctx, "ListMembers", func(ctx context.Context) error {
+		members, err = repo.ListMembers(ctx, orgID)
+		return err
+	})
+	return members, err
+}
+
+// ListMemberships implements OrganizationRepository
+func (d *decorated) ListMemberships(ctx context.Context, userID int) (memberships []models.Membership, err error) {
+	repo, ok := d.inner.(OrganizationRepository)
+	if !ok {
+		return nil, unsupported("organizations")
+	}
+	err = d.callContext(ctx, "ListMemberships", func(ctx context.Context) error {
+		memberships, err = repo.ListMemberships(ctx, userID)
+		return err
+	})
+	return memberships, err
+}
+
+// CreateAPIKey implements APIKeyRepository
+func (d *decorated) CreateAPIKey(ctx context.Context, key models.APIKey) (created models.APIKey, err error) {
+	repo, ok := d.inner.(APIKeyRepository)
+	if !ok {
+		return models.APIKey{}, unsupported("api keys")
+	}
+	err = d.callContext(ctx, "CreateAPIKey", func(ctx context.Context) error {
+		created, err = repo.CreateAPIKey(ctx, key)
+		return err
+	})
+	return created, err
+}
+
+// GetAPIKeyByPrefix implements APIKeyRepository
+func (d *decorated) GetAPIKeyByPrefix(ctx context.Context, prefix string) (key models.APIKey, err error) {
+	repo, ok := d.inner.(APIKeyRepository)
+	if !ok {
+		return models.APIKey{}, unsupported("api keys")
+	}
+	err = d.callContext(ctx, "GetAPIKeyByPrefix", func(ctx context.Context) error {
+		key, err = repo.GetAPIKeyByPrefix(ctx, prefix)
+		return err
+	})
+	return key, err
+}
+
+// ListAPIKeys implements APIKeyRepository
+func (d *decorated) ListAPIKeys(ctx context.Context, userID int) (keys []models.APIKey, err error) {
+	repo, ok := d.inner.(APIKeyRepository)
+	if !ok {
+		return nil, unsupported("api keys")
+	}
+	err = d.callContext(ctx, "ListAPIKeys", func(ctx context.Context) error {
+		keys, err = repo.ListAPIKeys(ctx, userID)
+		return err
+	})
+	return keys, err
+}
+
+// RevokeAPIKey implements APIKeyRepository
+func (d *decorated) RevokeAPIKey(ctx context.Context, userID, id int, at time.Time) error {
+	repo, ok := d.inner.(APIKeyRepository)
+	if !ok {
+		return unsupported("api keys")
+	}
+	return d.callContext(ctx, "RevokeAPIKey", func(ctx context.Context) error { return repo.RevokeAPIKey(ctx, userID, id, at) })
+}
+
+// TouchAPIKey implements APIKeyRepository
+func (d *decorated) TouchAPIKey(ctx context.Context, id int, at time.Time) error {
+	repo, ok := d.inner.(APIKeyRepository)
+	if !ok {
+		return unsupported("api keys")
+	}
+	return d.callContext(ctx, "TouchAPIKey", func(ctx context.Context) error { return repo.TouchAPIKey(ctx, id, at) })
+}
+
+// RecordLoginAttempt implements LoginRepository
+func (d *decorated) RecordLoginAttempt(ctx context.Context, attempt models.LoginAttempt) error {
+	repo, ok := d.inner.(LoginRepository)
+	if !ok {
+		return unsupported("login tracking")
+	}
+	return d.callContext(ctx, "RecordLoginAttempt", func(ctx context.Context) error { return repo.RecordLoginAttempt(ctx, attempt) })
+}
+
+// LoginAttempts implements LoginRepository
+func (d *decorated) LoginAttempts(ctx context.Context, userID int, since time.Time) (attempts []models.LoginAttempt, err error) {
+	repo, ok := d.inner.(LoginRepository)
+	if !ok {
+		return nil, unsupported("login tracking")
+	}
+	err = d.callContext(ctx, "LoginAttempts", func(ctx context.Context) error {
+		attempts, err = repo.LoginAttempts(ctx, userID, since)
+		return err
+	})
+	return attempts, err
+}
+
+// LockUser implements LoginRepository
+func (d *decorated) LockUser(ctx context.Context, userID int, until time.Time) error {
+	repo, ok := d.inner.(LoginRepository)
+	if !ok {
+		return unsupported("login tracking")
+	}
+	return d.callContext(ctx, "LockUser", func(ctx context.Context) error { return repo.LockUser(ctx, userID, until) })
+}
+
+// UnlockUser implements LoginRepository
+func (d *decorated) UnlockUser(ctx context.Context, userID int) error {
+	repo, ok := d.inner.(LoginRepository)
+	if !ok {
+		return unsupported("login tracking")
+	}
+	return d.callContext(ctx, "UnlockUser", func(ctx context.Context) error { return repo.UnlockUser(ctx, userID) })
+}
+
+// LockedUntil implements LoginRepository
+func (d *decorated) LockedUntil(ctx context.Context, userID int) (until time.Time, err error) {
+	repo, ok := d.inner.(LoginRepository)
+	if !ok {
+		return time.Time{}, unsupported("login tracking")
+	}
+	err = d.callContext(ctx, "LockedUntil", func(ctx context.Context) error {
+		until, err = repo.LockedUntil(ctx, userID)
+		return err
+	})
+	return until, err
+}
+
+// RecordAudit implements AuditRepository
+func (d *decorated) RecordAudit(ctx context.Context, entry models.AuditEntry) error {
+	repo, ok := d.inner.(AuditRepository)
+	if !ok {
+		return unsupported("audit log")
+	}
+	return d.callContext(ctx, "RecordAudit", func(ctx context.Context) error { return repo.RecordAudit(ctx, entry) })
+}
+
+// AuditEntries implements AuditRepository
+func (d *decorated) AuditEntries(ctx context.Context, subject string) (entries []models.AuditEntry, err error) {
+	repo, ok := d.inner.(AuditRepository)
+	if !ok {
+		return nil, unsupported("audit log")
+	}
+	err = d.callContext(ctx, "AuditEntries", func(ctx context.Context) error {
+		entries, err = repo.AuditEntries(ctx, subject)
+		return err
+	})
+	return entries, err
+}
+
+// EraseUser implements ErasureRepository
+func (d *decorated) EraseUser(ctx context.Context, id int) error {
+	repo, ok := d.inner.(ErasureRepository)
+	if !ok {
+		return unsupported("erasure")
+	}
+	return d.callContext(ctx, "EraseUser", func(ctx context.Context) error { return repo.EraseUser(ctx, id) })
+}
+
+// UserCountsByDay implements ReportingRepository
+func (d *decorated) UserCountsByDay(ctx context.Context, tenantID string, from, to time.Time) (counts []DailyCount, err error) {
+	repo, ok := d.inner.(ReportingRepository)
+	if !ok {
+		return nil, unsupported("reporting views")
+	}
+	err = d.callContext(ctx, "UserCountsByDay", func(ctx context.Context) error {
+		counts, err = repo.UserCountsByDay(ctx, tenantID, from, to)
+		return err
+	})
+	return counts, err
+}
+
+// OrganizationMemberCount implements ReportingRepository
+func (d *decorated) OrganizationMemberCount(ctx context.Context, tenantID string, orgID int) (n int64, err error) {
+	repo, ok := d.inner.(ReportingRepository)
+	if !ok {
+		return 0, unsupported("reporting views")
+	}
+	err = d.callContext(ctx, "OrganizationMemberCount", func(ctx context.Context) error {
+		n, err = repo.OrganizationMemberCount(ctx, tenantID, orgID)
+		return err
+	})
+	return n, err
+}
+
+// ListAfter implements KeysetRepository
+func (d *decorated) ListAfter(ctx context.Context, afterID, limit int) (users []models.User, err error) {
+	repo, ok := d.inner.(KeysetRepository)
+	if !ok {
+		return nil, unsupported("keyset paging")
+	}
+	err = d.callContext(ctx, "ListAfter", func(ctx context.Context) error {
+		users, err = repo.ListAfter(ctx, afterID, limit)
+		return err
+	})
+	return users, err
+}
+
+// ParallelScan implements ParallelScanner
+func (d *decorated) ParallelScan(ctx context.Context, workers int) (users []models.User, err error) {
+	repo, ok := d.inner.(ParallelScanner)
+	if !ok {
+		return nil, unsupported("parallel scans")
+	}
+	err = d.callContext(ctx, "ParallelScan", func(ctx context.Context) error {
+		users, err = repo.ParallelScan(ctx, workers)
+		return err
+	})
+	return users, err
+}
+
+// ParallelScanTo implements ParallelScanner
+func (d *decorated) ParallelScanTo(ctx context.Context, workers int, out chan<- []models.User) error {
+	repo, ok := d.inner.(ParallelScanner)
+	if !ok {
+		close(out)
+		return unsupported("parallel scans")
+	}
+	called := false
+	err := d.callContext(ctx, "ParallelScanTo", func(ctx context.Context) error {
+		called = true
+		return repo.ParallelScanTo(ctx, workers, out)
+	})
+	if !called {
+		// a middleware short-circuited the call; out must still be closed
+		close(out)
+	}
+	return err
+}
+
+// DeleteWhere implements BulkDeleter
+func (d *decorated) DeleteWhere(ctx context.Context, filter Filter, opts DeleteOptions) (n int64, err error) {
+	repo, ok := d.inner.(BulkDeleter)
+	if !ok {
+		return 0, unsupported("bulk delete")
+	}
+	err = d.callContext(ctx, "DeleteWhere", func(ctx context.Context) error {
+		n, err = repo.DeleteWhere(ctx, filter, opts)
+		return err
+	})
+	return n, err
+}
+
+// Find implements Finder
+func (d *decorated) Find(ctx context.Context, filter Filter, opts ListOptions) (users []models.User, err error) {
+	repo, ok := d.inner.(Finder)
+	if !ok {
+		return nil, unsupported("finding by criteria")
+	}
+	err = d.callContext(ctx, "Find", func(ctx context.Context) error {
+		users, err = repo.Find(ctx, filter, opts)
+		return err
+	})
+	return users, err
+}
+
+// Explain implements Explainer
+func (d *decorated) Explain(ctx context.Context, q Query, analyze bool) (e *Explanation, err error) {
+	repo, ok := d.inner.(Explainer)
+	if !ok {
+		return nil, unsupported("explain")
+	}
+	err = d.callContext(ctx, "Explain", func(ctx context.Context) error {
+		e, err = repo.Explain(ctx, q, analyze)
+		return err
+	})
+	return e, err
+}
+
+// TotalUsers implements TotalUsersRepository
+func (d *decorated) TotalUsers(ctx context.Context) (n int64, err error) {
+	repo, ok := d.inner.(TotalUsersRepository)
+	if !ok {
+		return 0, unsupported("user totals")
+	}
+	err = d.callContext(ctx, "TotalUsers", func(ctx context.Context) error {
+		n, err = repo.TotalUsers(ctx)
+		return err
+	})
+	return n, err
+}
+
+// SaveLastSeen implements LastSeenRepository
+func (d *decorated) SaveLastSeen(ctx context.Context, seen map[int]time.Time) error {
+	repo, ok := d.inner.(LastSeenRepository)
+	if !ok {
+		return unsupported("last seen")
+	}
+	return d.callContext(ctx, "SaveLastSeen", func(ctx context.Context) error {
+		return repo.SaveLastSeen(ctx, seen)
+	})
+}
+
+// LastSeen implements LastSeenRepository
+func (d *decorated) LastSeen(ctx context.Context, userID int) (t time.Time, err error) {
+	repo, ok := d.inner.(LastSeenRepository)
+	if !ok {
+		return time.Time{}, unsupported("last seen")
+	}
+	err = d.callContext(ctx, "LastSeen", func(ctx context.Context) error {
+		t, err = repo.LastSeen(ctx, userID)
+		return err
+	})
+	return t, err
+}
+
+// SetPasswordHash implements PasswordRepository
+func (d *decorated) SetPasswordHash(ctx context.Context, userID int, hash string) error {
+	repo, ok := d.inner.(PasswordRepository)
+	if !ok {
+		return unsupported("passwords")
+	}
+	return d.callContext(ctx, "SetPasswordHash", func(ctx context.Context) error {
+		return repo.SetPasswordHash(ctx, userID, hash)
+	})
+}
+
+// PasswordHash implements PasswordRepository
+func (d *decorated) PasswordHash(ctx context.Context, userID int) (hash string, err error) {
+	repo, ok := d.inner.(PasswordRepository)
+	if !ok {
+		return "", unsupported("passwords")
+	}
+	err = d.callContext(ctx, "PasswordHash", func(ctx context.Context) error {
+		hash, err = repo.PasswordHash(ctx, userID)
+		return err
+	})
+	return hash, err
+}
+
+// MergeUsers implements MergeRepository
+func (d *decorated) MergeUsers(ctx context.Context, survivor models.User, duplicateID int) (report MergeReport, err error) {
+	repo, ok := d.inner.(MergeRepository)
+	if !ok {
+		return MergeReport{}, unsupported("merges")
+	}
+	err = d.callContext(ctx, "MergeUsers", func(ctx context.Context) error {
+		report, err = repo.MergeUsers(ctx, survivor, duplicateID)
+		return err
+	})
+	return report, err
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Dialect is what differs between the SQL databases an adapter supports:
+// placeholders, identifier quoting, column types, upserts and reading
+// generated keys. Supporting a new SQL database means writing a Dialect.
+type Dialect interface {
+	// Name identifies the dialect, e.g. "postgres"
+	Name() string
+	// Bind returns the n-th (1-based) placeholder
+	Bind(n int) string
+	// Quote quotes an identifier
+	Quote(ident string) string
+	// ColumnType returns the column type storing values of t, or an error
+	// when the dialect can't store them
+	ColumnType(t reflect.Type) (string, error)
+	// PrimaryKey returns the definition of a lone primary key column of
+	// columnType, generated by the database when autoIncrement is set
+	PrimaryKey(columnType string, autoIncrement bool) string
+	// Array encodes a slice field for storage
+	Array(v any) driver.Valuer
+	// Upsert returns the clause, appended to an INSERT, that updates
+	// columns to the inserted values when a row with the same conflict
+	// key exists
+	Upsert(conflict []string, columns ...string) string
+	// InsertID runs an INSERT and returns the generated id of the new row
+	InsertID(ctx context.Context, db querier, query string, args ...any) (int64, error)
+}
+
+// Dialects of the built-in adapters
+var (
+	PostgresDialect Dialect = postgresDialect{}
+	MySQLDialect    Dialect = mysqlDialect{}
+)
+
+// postgresDialect implements Dialect for PostgreSQL
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string              { return "postgres" }
+func (postgresDialect) Bind(n int) string         { return postgresBind(n) }
+func (postgresDialect) Quote(ident string) string { return quoteIdent(ident, `"`) }
+func (postgresDialect) Array(v any) driver.Valuer { return postgresArray(v) }
+
+func (postgresDialect) ColumnType(t reflect.Type) (string, error) {
+	return goTypeToPostgres(t)
+}
+
+// PrimaryKey implements Dialect. Auto-increment keys are the serial type
+// of their integer type.
+func (postgresDialect) PrimaryKey(columnType string, autoIncrement bool) string {
+	if serial, ok := serialTypes[columnType]; ok && autoIncrement {
+		columnType = serial
+	}
+	return columnType + " PRIMARY KEY"
+}
+
+// serialTypes maps integer column types to their auto-increment forms
+var serialTypes = map[string]string{
+	"SMALLINT": "SMALLSERIAL",
+	"INTEGER":  "SERIAL",
+	"BIGINT":   "BIGSERIAL",
+}
+
+func (postgresDialect) Upsert(conflict []string, columns ...string) string {
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		set[i] = fmt.Sprintf("%s = EXCLUDED.%s", c, c)
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(conflict, ", "), strings.Join(set, ", "))
+}
+
+func (postgresDialect) InsertID(ctx context.Context, db querier, query string, args ...any) (int64, error) {
+	return postgresInsertID(ctx, db, query, args...)
+}
+
+// mysqlDialect implements Dialect for MySQL
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string              { return "mysql" }
+func (mysqlDialect) Bind(n int) string         { return mysqlBind(n) }
+func (mysqlDialect) Quote(ident string) string { return quoteIdent(ident, "`") }
+func (mysqlDialect) Array(v any) driver.Valuer { return jsonArray(v) }
+
+func (mysqlDialect) ColumnType(t reflect.Type) (string, error) {
+	return goTypeToMySQL(t)
+}
+
+func (mysqlDialect) PrimaryKey(columnType string, autoIncrement bool) string {
+	if autoIncrement {
+		return columnType + " AUTO_INCREMENT PRIMARY KEY"
+	}
+	return columnType + " PRIMARY KEY"
+}
+
+// Upsert implements Dialect. MySQL matches on any unique key, so conflict
+// only documents the intent.
+func (mysqlDialect) Upsert(_ []string, columns ...string) string {
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		set[i] = fmt.Sprintf("%s = VALUES(%s)", c, c)
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+func (mysqlDialect) InsertID(ctx context.Context, db querier, query string, args ...any) (int64, error) {
+	return mysqlInsertID(ctx, db, query, args...)
+}
+
+// quoteIdent quotes ident with q, doubling any q inside it
+func quoteIdent(ident, q string) string {
+	return q + strings.ReplaceAll(ident, q, q+q) + q
+}
+
+// goTypeToMySQL is goTypeToPostgres for MySQL. Strings map to VARCHAR so
+// they can be indexed; arrays and maps are stored as JSON.
+func goTypeToMySQL(t reflect.Type) (string, error) {
+	if t.Kind() == reflect.Pointer {
+		return goTypeToMySQL(t.Elem())
+	}
+	if t == timeType {
+		return "DATETIME(6)", nil
+	}
+	if t == jsonMapType || isArrayField(t) {
+		return "JSON", nil
+	}
+	if inner, ok := nullTypes[t]; ok {
+		return goTypeToMySQL(inner)
+	}
+	if t == bytesType {
+		return "LONGBLOB", nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "BIGINT", nil
+	case reflect.Int8, reflect.Int16:
+		return "SMALLINT", nil
+	case reflect.Float32, reflect.Float64:
+		return "DOUBLE", nil
+	case reflect.String:
+		return "VARCHAR(255)", nil
+	case reflect.Bool:
+		return "BOOLEAN", nil
+	default:
+		return "", fmt.Errorf("unsupported column type %s", t)
+	}
+}
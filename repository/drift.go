@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// DriftKind classifies a difference between the models and the live schema
+type DriftKind string
+
+const (
+	DriftMissingTable     DriftKind = "missing_table"
+	DriftMissingColumn    DriftKind = "missing_column"
+	DriftExtraColumn      DriftKind = "extra_column"
+	DriftTypeMismatch     DriftKind = "type_mismatch"
+	DriftExtraIndex       DriftKind = "extra_index"
+	DriftPendingMigration DriftKind = "pending_migration"
+	DriftUnknownMigration DriftKind = "unknown_migration"
+)
+
+// Drift is a single difference between the expected and the live schema
+type Drift struct {
+	Kind   DriftKind
+	Table  string
+	Column string
+	Detail string
+}
+
+// String formats the drift for reports and logs
+func (d Drift) String() string {
+	target := d.Table
+	if d.Column != "" {
+		target += "." + d.Column
+	}
+	if d.Detail == "" {
+		return fmt.Sprintf("%s: %s", d.Kind, target)
+	}
+	return fmt.Sprintf("%s: %s (%s)", d.Kind, target, d.Detail)
+}
+
+// catalogTypes maps the DDL types AutoMigrate emits to the data_type names
+// reported by information_schema
+var catalogTypes = map[string]string{
+	"BIGINT":      "bigint",
+	"BIGSERIAL":   "bigint",
+	"SERIAL":      "integer",
+	"TEXT":        "text",
+	"TIMESTAMPTZ": "timestamp with time zone",
+	"TSVECTOR":    "tsvector",
+	"JSONB":       "jsonb",
+}
+
+// catalogType returns the information_schema data_type for a DDL type
+func catalogType(sqlType string) string {
+	if strings.HasSuffix(sqlType, "[]") {
+		return "ARRAY"
+	}
+	if t, ok := catalogTypes[sqlType]; ok {
+		return t
+	}
+	return strings.ToLower(sqlType)
+}
+
+// DetectDrift compares the live schema against the model definitions and,
+// when fsys is non-nil, against the versioned migration history
+func (m *Migrator) DetectDrift(ctx context.Context, fsys fs.FS, models ...any) ([]Drift, error) {
+	schema, err := m.InspectSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []Drift
+	for _, model := range models {
+		def, err := parseModel(model, m.naming)
+		if err != nil {
+			return nil, err
+		}
+		drift = append(drift, diffTable(def, schema.Table(def.Table))...)
+	}
+
+	if fsys != nil {
+		history, err := m.diffMigrations(ctx, fsys)
+		if err != nil {
+			return nil, err
+		}
+		drift = append(drift, history...)
+	}
+
+	return drift, nil
+}
+
+// diffTable compares a model definition against its live table
+func diffTable(def *modelDef, table *Table) []Drift {
+	if table == nil {
+		return []Drift{{Kind: DriftMissingTable, Table: def.Table}}
+	}
+
+	var drift []Drift
+	expected := make(map[string]bool)
+	for _, col := range def.Columns {
+		expected[col.Name] = true
+
+		live := table.Column(col.Name)
+		if live == nil {
+			drift = append(drift, Drift{Kind: DriftMissingColumn, Table: def.Table, Column: col.Name})
+			continue
+		}
+
+		if want := catalogType(col.SQLType); live.DataType != want {
+			drift = append(drift, Drift{
+				Kind:   DriftTypeMismatch,
+				Table:  def.Table,
+				Column: col.Name,
+				Detail: fmt.Sprintf("model %s, database %s", want, live.DataType),
+			})
+		}
+		if col.Nullable && !live.Nullable {
+			drift = append(drift, Drift{
+				Kind:   DriftTypeMismatch,
+				Table:  def.Table,
+				Column: col.Name,
+				Detail: "model nullable, database NOT NULL",
+			})
+		}
+	}
+
+	if search := def.searchColumns(); len(search) > 0 {
+		expected[searchVectorColumn] = true
+		if table.Column(searchVectorColumn) == nil {
+			drift = append(drift, Drift{Kind: DriftMissingColumn, Table: def.Table, Column: searchVectorColumn})
+		}
+	}
+
+	for _, col := range table.Columns {
+		if !expected[col.Name] {
+			drift = append(drift, Drift{Kind: DriftExtraColumn, Table: def.Table, Column: col.Name})
+		}
+	}
+
+	// indexes backing a constraint (e.g. the primary key) are expected, as
+	// are the ones AutoMigrate creates for indexed and foreign key columns
+	backing := make(map[string]bool)
+	for _, c := range table.Constraints {
+		backing[c.Name] = true
+	}
+	for _, col := range def.indexedColumns() {
+		backing[def.indexName(col)] = true
+	}
+	if len(def.searchColumns()) > 0 {
+		backing[def.indexName(searchVectorColumn)] = true
+	}
+	for _, idx := range table.Indexes {
+		if !backing[idx.Name] {
+			drift = append(drift, Drift{
+				Kind:   DriftExtraIndex,
+				Table:  def.Table,
+				Detail: fmt.Sprintf("%s on (%s)", idx.Name, strings.Join(idx.Columns, ", ")),
+			})
+		}
+	}
+
+	return drift
+}
+
+// diffMigrations reports migrations not yet applied, and applied versions
+// that are missing from the migration source
+func (m *Migrator) diffMigrations(ctx context.Context, fsys fs.FS) ([]Drift, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []Drift
+	known := make(map[int64]bool)
+	for _, mig := range migrations {
+		known[mig.Version] = true
+		if !applied[mig.Version] {
+			drift = append(drift, Drift{
+				Kind:   DriftPendingMigration,
+				Table:  "schema_migrations",
+				Detail: fmt.Sprintf("%d_%s", mig.Version, mig.Name),
+			})
+		}
+	}
+
+	for v := range applied {
+		if !known[v] {
+			drift = append(drift, Drift{
+				Kind:   DriftUnknownMigration,
+				Table:  "schema_migrations",
+				Detail: fmt.Sprintf("version %d", v),
+			})
+		}
+	}
+
+	return drift, nil
+}
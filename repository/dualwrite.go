@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"expvar"
+	"fmt"
+	"slices"
+	"time"
+
+	"project/models"
+)
+
+// dualWriteMetrics exposes dual-write divergence counters on /debug/vars
+var dualWriteMetrics = expvar.NewMap("dual_write")
+
+// DualWritePolicy decides what a failed write to the secondary store means
+type DualWritePolicy int
+
+const (
+	// DualWriteBestEffort returns only the primary's result; secondary
+	// failures are counted and reported as divergences
+	DualWriteBestEffort DualWritePolicy = iota
+	// DualWriteStrict also returns secondary failures. The primary write
+	// has already happened, so the stores still diverge until retried.
+	DualWriteStrict
+)
+
+// Divergence is a secondary write failure or a read that differed between
+// the stores
+type Divergence struct {
+	Op  string
+	ID  int
+	Err error
+}
+
+// DualWriteRepository writes to an old and a new adapter for zero-downtime
+// migrations between stores. Writes go to the old store first and then the
+// new one; reads are served by the old store until WithReadsFromNew. IDs
+// must line up across the stores, e.g. by backfilling the new one with the
+// old one's keys before turning dual writes on.
+type DualWriteRepository struct {
+	old, new UserRepository
+	policy   DualWritePolicy
+	compare  bool
+	readNew  bool
+
+	// OnDivergence, when set, is called for every divergence
+	OnDivergence func(Divergence)
+}
+
+// NewDualWriteRepository creates a best-effort dual writer from old to new
+func NewDualWriteRepository(old, new UserRepository) *DualWriteRepository {
+	return &DualWriteRepository{old: old, new: new}
+}
+
+// WithPolicy sets how secondary write failures are surfaced
+func (d *DualWriteRepository) WithPolicy(p DualWritePolicy) *DualWriteRepository {
+	d.policy = p
+	return d
+}
+
+// WithReadComparison also reads the other store on every read and reports
+// differences, validating parity before cutting over. The primary's result
+// is always returned.
+func (d *DualWriteRepository) WithReadComparison() *DualWriteRepository {
+	d.compare = true
+	return d
+}
+
+// WithReadsFromNew serves reads from the new store, the cut-over step;
+// writes still go to both
+func (d *DualWriteRepository) WithReadsFromNew() *DualWriteRepository {
+	d.readNew = true
+	return d
+}
+
+// Create inserts the user into both stores, returning it as stored by the
+// store serving reads, or by the old store when a best-effort write to the
+// new one failed
+func (d *DualWriteRepository) Create(user models.User) (created models.User, err error) {
+	read, _ := d.stores()
+	err = d.write("create", 0, func(r UserRepository) error {
+		u, err := r.Create(user)
+		if err == nil && (r == d.old || r == read) {
+			created = u
+		}
+		return err
+	})
+	return created, err
+}
+
+// Update saves the user in both stores
+func (d *DualWriteRepository) Update(user models.User) error {
+	return d.write("update", user.ID, func(r UserRepository) error { return r.Update(user) })
+}
+
+// Delete removes the user from both stores
+func (d *DualWriteRepository) Delete(id int) error {
+	return d.write("delete", id, func(r UserRepository) error { return r.Delete(id) })
+}
+
+// GetAll retrieves all users from the read store
+func (d *DualWriteRepository) GetAll() ([]models.User, error) {
+	return dualRead(d, "get_all", 0, func(r UserRepository) ([]models.User, error) { return r.GetAll() }, sameUsers)
+}
+
+// GetByID retrieves a user from the read store
+func (d *DualWriteRepository) GetByID(id int) (models.User, error) {
+	return dualRead(d, "get_by_id", id, func(r UserRepository) (models.User, error) { return r.GetByID(id) }, sameUser)
+}
+
+// ListCreatedBetween lists users from the read store
+func (d *DualWriteRepository) ListCreatedBetween(from, to time.Time, opts ListOptions) ([]models.User, error) {
+	return dualRead(d, "list_created_between", 0, func(r UserRepository) ([]models.User, error) {
+		return r.ListCreatedBetween(from, to, opts)
+	}, sameUsers)
+}
+
+// write runs fn on the old store and then the new one
+func (d *DualWriteRepository) write(op string, id int, fn func(UserRepository) error) error {
+	dualWriteMetrics.Add(op+".writes_total", 1)
+	if err := fn(d.old); err != nil {
+		return err
+	}
+	if err := fn(d.new); err != nil {
+		dualWriteMetrics.Add(op+".secondary_errors_total", 1)
+		d.diverged(Divergence{Op: op, ID: id, Err: err})
+		if d.policy == DualWriteStrict {
+			return fmt.Errorf("failed to %s in new store: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// stores returns the store serving reads and the one compared against
+func (d *DualWriteRepository) stores() (UserRepository, UserRepository) {
+	if d.readNew {
+		return d.new, d.old
+	}
+	return d.old, d.new
+}
+
+func (d *DualWriteRepository) diverged(div Divergence) {
+	if d.OnDivergence != nil {
+		d.OnDivergence(div)
+	}
+}
+
+// dualRead reads from the primary store and, with read comparison on,
+// checks the other store returns the same
+func dualRead[T any](d *DualWriteRepository, op string, id int, fn func(UserRepository) (T, error), same func(a, b T) bool) (T, error) {
+	primary, other := d.stores()
+	got, err := fn(primary)
+	if !d.compare {
+		return got, err
+	}
+
+	dualWriteMetrics.Add(op+".compares_total", 1)
+	want, otherErr := fn(other)
+	switch {
+	case (err == nil) != (otherErr == nil):
+		dualWriteMetrics.Add(op+".mismatches_total", 1)
+		d.diverged(Divergence{Op: op, ID: id, Err: fmt.Errorf("stores disagree: %v vs %v", err, otherErr)})
+	case err == nil && !same(got, want):
+		dualWriteMetrics.Add(op+".mismatches_total", 1)
+		d.diverged(Divergence{Op: op, ID: id, Err: fmt.Errorf("stores returned different users")})
+	}
+	return got, err
+}
+
+// sameUser compares the fields every adapter stores the same way;
+// timestamps are skipped since stores differ in precision
+func sameUser(a, b models.User) bool {
+	return a.ID == b.ID && a.Name == b.Name && a.Email == b.Email &&
+		a.TenantID == b.TenantID && slices.Equal(a.Tags, b.Tags)
+}
+
+// sameUsers compares user lists regardless of order, since unordered reads
+// may come back differently from each store
+func sameUsers(a, b []models.User) bool {
+	byID := func(x, y models.User) int { return x.ID - y.ID }
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.SortFunc(a, byID)
+	slices.SortFunc(b, byID)
+	return slices.EqualFunc(a, b, sameUser)
+}
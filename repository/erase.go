@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"project/models"
+)
+
+// ErasureRepository is implemented by adapters that can permanently erase
+// a user, e.g. to honour a GDPR erasure request
+type ErasureRepository interface {
+	// EraseUser hard-deletes a user, soft-deleted or not, with every row
+	// that references them and any copies kept in history or archive
+	// tables. It returns ErrNotFound if the user doesn't exist.
+	EraseUser(ctx context.Context, id int) error
+}
+
+// EraseUser permanently deletes a user. Rows referencing the user are
+// removed by their ON DELETE CASCADE foreign keys.
+func (p *PostgresRepo) EraseUser(ctx context.Context, id int) error {
+	return p.transactor().WithTransaction(ctx, func(ctx context.Context) error {
+		q := dbFrom(ctx, p.db)
+
+		// copies outside users have no foreign key to cascade from
+		for _, table := range []string{p.naming.usersCopy("_history"), p.naming.usersCopy("_archive")} {
+			var exists bool
+			if err := q.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to look up %s: %w", table, err)
+			}
+			if !exists {
+				continue
+			}
+			if _, err := q.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = $1", id); err != nil {
+				return fmt.Errorf("failed to erase user from %s: %w", table, err)
+			}
+		}
+
+		return eraseUser(ctx, q, postgresBind, p.table(models.User{}), id)
+	})
+}
+
+// EraseUser permanently deletes a user. Rows referencing the user are
+// removed by their ON DELETE CASCADE foreign keys.
+func (m *MySQLRepo) EraseUser(ctx context.Context, id int) error {
+	return eraseUser(ctx, dbFrom(ctx, m.db), mysqlBind, m.table(models.User{}), id)
+}
+
+func eraseUser(ctx context.Context, db querier, bind func(int) string, table string, id int) error {
+	res, err := db.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = "+bind(1), id)
+	if err != nil {
+		return fmt.Errorf("failed to erase user: %w", err)
+	}
+	return requireRow(res)
+}
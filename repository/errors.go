@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+
+	"project/apperr"
+	"project/requestid"
+)
+
+// MySQL error numbers ClassifyError recognises
+const (
+	mysqlErrDupEntry     = 1062 // ER_DUP_ENTRY
+	mysqlErrNoReferenced = 1452 // ER_NO_REFERENCED_ROW_2
+)
+
+// ClassifyError attaches an apperr code to driver errors it recognises:
+// unique violations become Conflict, references to missing rows (foreign
+// key violations) InvalidArgument and connection failures Unavailable.
+// Errors that already carry a code, and unrecognised ones, are returned
+// unchanged.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var coder apperr.Coder
+	if errors.As(err, &coder) {
+		return err
+	}
+
+	if code, ok := driverCode(err); ok {
+		return apperr.Wrap(code, err)
+	}
+	return err
+}
+
+// driverCode maps a driver or network error onto an apperr code
+func driverCode(err error) (apperr.Code, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505":
+			return apperr.Conflict, true
+		case pqErr.Code == "23503":
+			return apperr.InvalidArgument, true
+		case pqErr.Code.Class() == "08", pqErr.Code.Class() == "57":
+			// connection exception, operator intervention (e.g. shutdown)
+			return apperr.Unavailable, true
+		}
+		return "", false
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case mysqlErrDupEntry:
+			return apperr.Conflict, true
+		case mysqlErrNoReferenced:
+			return apperr.InvalidArgument, true
+		}
+		return "", false
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr) {
+		return apperr.Unavailable, true
+	}
+	return "", false
+}
+
+// ClassifyErrors is a Middleware applying ClassifyError to every call
+func ClassifyErrors(ctx context.Context, method string, next func(ctx context.Context) error) error {
+	return ClassifyError(next(ctx))
+}
+
+// TagRequestID is a Middleware attaching the context's request ID to errors
+func TagRequestID(ctx context.Context, method string, next func(ctx context.Context) error) error {
+	return requestid.WithError(ctx, next(ctx))
+}
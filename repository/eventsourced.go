@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"project/models"
+)
+
+// userAggregate names user streams in the event store
+const userAggregate = "user"
+
+// Events of user streams
+const (
+	UserCreatedEvent      = "UserCreated"
+	UserRenamedEvent      = "UserRenamed"
+	UserEmailChangedEvent = "UserEmailChanged"
+	UserTagsChangedEvent  = "UserTagsChanged"
+	UserDeletedEvent      = "UserDeleted"
+)
+
+// userCreated is the payload of UserCreatedEvent
+type userCreated struct {
+	Name       string         `json:"name"`
+	Email      string         `json:"email,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	Attributes models.JSONMap `json:"attributes,omitempty"`
+	TenantID   string         `json:"tenant_id,omitempty"`
+}
+
+// userSnapshotSchema is the encoding version of UserState snapshots; bump
+// it when UserState or models.User change shape
+const userSnapshotSchema = 1
+
+// UserState is a user rebuilt from its stream
+type UserState struct {
+	User    models.User
+	Version int
+}
+
+// Apply implements Aggregate
+func (u *UserState) Apply(ev RecordedEvent) error {
+	var err error
+	switch ev.Type {
+	case UserCreatedEvent:
+		var data userCreated
+		err = json.Unmarshal(ev.Data, &data)
+		u.User = models.User{
+			Base:       models.Base{ID: ev.StreamID, CreatedAt: ev.RecordedAt},
+			Name:       data.Name,
+			Email:      data.Email,
+			Tags:       data.Tags,
+			Attributes: data.Attributes,
+			TenantID:   data.TenantID,
+		}
+	case UserRenamedEvent:
+		var data struct{ Name string }
+		err = json.Unmarshal(ev.Data, &data)
+		u.User.Name = data.Name
+	case UserEmailChangedEvent:
+		var data struct{ Email string }
+		err = json.Unmarshal(ev.Data, &data)
+		u.User.Email = data.Email
+	case UserTagsChangedEvent:
+		var data struct{ Tags []string }
+		err = json.Unmarshal(ev.Data, &data)
+		u.User.Tags = data.Tags
+	case UserDeletedEvent:
+		u.User.DeletedAt = sql.NullTime{Time: ev.RecordedAt, Valid: true}
+	default:
+		return fmt.Errorf("unknown user event %q", ev.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", ev.Type, err)
+	}
+
+	u.User.UpdatedAt = ev.RecordedAt
+	u.Version = ev.Version
+	return nil
+}
+
+// EventSourcedRepo implements UserRepository on an EventStore: every
+// change is appended to the user's stream and reads replay it. Lists
+// replay every user stream, so it suits modest user counts; serve larger
+// reads from a projection.
+type EventSourcedRepo struct {
+	store         *EventStore
+	snapshotEvery int
+}
+
+// NewEventSourcedRepo creates a repository persisting users in store
+func NewEventSourcedRepo(store *EventStore) *EventSourcedRepo {
+	return &EventSourcedRepo{store: store}
+}
+
+// WithSnapshots snapshots each user every n events, so loading a user
+// replays at most n events. Zero, the default, disables snapshots.
+func (r *EventSourcedRepo) WithSnapshots(n int) *EventSourcedRepo {
+	r.snapshotEvery = n
+	return r
+}
+
+// Create implements UserRepository
+func (r *EventSourcedRepo) Create(user models.User) (models.User, error) {
+	return r.CreateContext(context.Background(), user)
+}
+
+// CreateContext starts a stream for user, joining the transaction in ctx
+// if any, and returns the user replayed from it
+func (r *EventSourcedRepo) CreateContext(ctx context.Context, user models.User) (created models.User, err error) {
+	err = r.store.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		id, err := r.store.CreateStream(ctx, userAggregate)
+		if err != nil {
+			return err
+		}
+		_, err = r.store.Append(ctx, id, 0, EventData{Type: UserCreatedEvent, Data: userCreated{
+			Name:       user.Name,
+			Email:      user.Email,
+			Tags:       user.Tags,
+			Attributes: user.Attributes,
+			TenantID:   user.TenantID,
+		}})
+		if err != nil {
+			return err
+		}
+		state, err := r.LoadUser(ctx, id)
+		created = state.User
+		return err
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return created, nil
+}
+
+// WithTransaction implements TxRunner
+func (r *EventSourcedRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.store.tx.WithTransaction(ctx, fn)
+}
+
+// LoadUser replays the stream of a user, returning ErrNotFound if it has
+// none. Deleted users are returned with DeletedAt set.
+func (r *EventSourcedRepo) LoadUser(ctx context.Context, id int) (UserState, error) {
+	var (
+		state UserState
+		err   error
+	)
+	if r.snapshotEvery > 0 {
+		_, _, err = r.store.RehydrateFromSnapshot(ctx, id, userSnapshotSchema, &state)
+	} else {
+		_, err = r.store.Rehydrate(ctx, id, 0, &state)
+	}
+	if err != nil {
+		return UserState{}, err
+	}
+	if state.Version == 0 {
+		return UserState{}, ErrNotFound
+	}
+	return state, nil
+}
+
+// GetByID implements UserRepository
+func (r *EventSourcedRepo) GetByID(id int) (models.User, error) {
+	state, err := r.LoadUser(context.Background(), id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if state.User.DeletedAt.Valid {
+		return models.User{}, ErrNotFound
+	}
+	return state.User, nil
+}
+
+// GetAll implements UserRepository
+func (r *EventSourcedRepo) GetAll() ([]models.User, error) {
+	evs, err := r.store.LoadAggregate(context.Background(), userAggregate)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[int]*UserState)
+	var order []int
+	for _, ev := range evs {
+		state, ok := states[ev.StreamID]
+		if !ok {
+			state = &UserState{}
+			states[ev.StreamID] = state
+			order = append(order, ev.StreamID)
+		}
+		if err := state.Apply(ev); err != nil {
+			return nil, fmt.Errorf("failed to apply %s event %d: %w", ev.Type, ev.Version, err)
+		}
+	}
+
+	var users []models.User
+	for _, id := range order {
+		if u := states[id].User; !u.DeletedAt.Valid {
+			users = append(users, u)
+		}
+	}
+	// match the SQL adapters, which return rows in ID order
+	slices.SortFunc(users, func(a, b models.User) int { return cmp.Compare(a.ID, b.ID) })
+	return users, nil
+}
+
+// ListCreatedBetween implements UserRepository
+func (r *EventSourcedRepo) ListCreatedBetween(from, to time.Time, opts ListOptions) ([]models.User, error) {
+	all, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	for _, u := range all {
+		if !u.CreatedAt.Before(from) && u.CreatedAt.Before(to) {
+			users = append(users, u)
+		}
+	}
+	if err := sortUsers(users, opts.Sort, SortField{Column: "created_at"}); err != nil {
+		return nil, err
+	}
+
+	users = users[min(opts.Offset, len(users)):]
+	if opts.Limit > 0 {
+		users = users[:min(opts.Limit, len(users))]
+	}
+	return users, nil
+}
+
+// Update implements UserRepository, appending an event per changed field.
+// It fails with ErrVersionConflict if the user changed since it was read.
+func (r *EventSourcedRepo) Update(user models.User) error {
+	ctx := context.Background()
+	state, err := r.LoadUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if state.User.DeletedAt.Valid {
+		return ErrNotFound
+	}
+
+	var evs []EventData
+	if user.Name != state.User.Name {
+		evs = append(evs, EventData{Type: UserRenamedEvent, Data: map[string]string{"name": user.Name}})
+	}
+	if user.Email != state.User.Email {
+		evs = append(evs, EventData{Type: UserEmailChangedEvent, Data: map[string]string{"email": user.Email}})
+	}
+	if !slices.Equal(user.Tags, state.User.Tags) {
+		evs = append(evs, EventData{Type: UserTagsChangedEvent, Data: map[string][]string{"tags": user.Tags}})
+	}
+
+	return r.append(ctx, user.ID, state.Version, evs...)
+}
+
+// Delete implements UserRepository
+func (r *EventSourcedRepo) Delete(id int) error {
+	ctx := context.Background()
+	state, err := r.LoadUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if state.User.DeletedAt.Valid {
+		return ErrNotFound
+	}
+
+	return r.append(ctx, id, state.Version, EventData{Type: UserDeletedEvent, Data: struct{}{}})
+}
+
+// append appends evs to a user's stream, taking a snapshot when the stream
+// crosses a multiple of snapshotEvery
+func (r *EventSourcedRepo) append(ctx context.Context, id, expected int, evs ...EventData) error {
+	version, err := r.store.Append(ctx, id, expected, evs...)
+	if err != nil {
+		return err
+	}
+	if r.snapshotEvery == 0 || version/r.snapshotEvery == expected/r.snapshotEvery {
+		return nil
+	}
+
+	// the events are stored; a failed snapshot is retried at the next crossing
+	if err := r.snapshot(ctx, id); err != nil {
+		eventStoreMetrics.Add("snapshot_errors_total", 1)
+	}
+	return nil
+}
+
+// snapshot stores the current state of a user
+func (r *EventSourcedRepo) snapshot(ctx context.Context, id int) error {
+	state, err := r.LoadUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.store.SaveSnapshot(ctx, id, state.Version, userSnapshotSchema, state); err != nil {
+		return err
+	}
+	eventStoreMetrics.Add("snapshots_total", 1)
+	return nil
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"project/apperr"
+	"project/clock"
+)
+
+// ErrVersionConflict is returned by Append when the stream was written to
+// since it was read
+var ErrVersionConflict error = apperr.New(apperr.Conflict, "stream was modified concurrently")
+
+// EventData is an event to append to a stream; Data is marshalled as JSON
+type EventData struct {
+	Type string
+	Data any
+}
+
+// RecordedEvent is an event as stored in a stream
+type RecordedEvent struct {
+	StreamID   int
+	Version    int
+	Type       string
+	Data       json.RawMessage
+	RecordedAt time.Time
+}
+
+// EventStore keeps append-only event streams in the event_streams and
+// events tables. Each stream has a version, the number of events in it,
+// and appends must name the version they expect, so concurrent writers to
+// a stream can't both succeed.
+type EventStore struct {
+	db       *sql.DB
+	bind     func(int) string
+	insertID insertID
+	tx       *Transactor
+	// saveSnapshot upserts a snapshot in the dialect
+	saveSnapshot string
+
+	// Clock stamps recorded events; nil means the system clock
+	Clock clock.Clock
+}
+
+// NewPostgresEventStore creates an event store on a PostgreSQL db
+func NewPostgresEventStore(db *sql.DB) *EventStore {
+	return &EventStore{db: db, bind: postgresBind, insertID: postgresInsertID, tx: NewTransactor(db),
+		saveSnapshot: `INSERT INTO snapshots (stream_id, version, schema_version, state, taken_at) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (stream_id) DO UPDATE SET version = EXCLUDED.version, schema_version = EXCLUDED.schema_version,
+			state = EXCLUDED.state, taken_at = EXCLUDED.taken_at WHERE snapshots.version <= EXCLUDED.version`,
+	}
+}
+
+// NewMySQLEventStore creates an event store on a MySQL db
+func NewMySQLEventStore(db *sql.DB) *EventStore {
+	return &EventStore{db: db, bind: mysqlBind, insertID: mysqlInsertID, tx: NewMySQLTransactor(db),
+		saveSnapshot: `INSERT INTO snapshots (stream_id, version, schema_version, state, taken_at) VALUES (?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE schema_version = IF(VALUES(version) >= version, VALUES(schema_version), schema_version),
+			state = IF(VALUES(version) >= version, VALUES(state), state),
+			taken_at = IF(VALUES(version) >= version, VALUES(taken_at), taken_at),
+			version = GREATEST(version, VALUES(version))`,
+	}
+}
+
+// CreateStream starts an empty stream for an aggregate type, e.g. "user",
+// and returns its ID
+func (s *EventStore) CreateStream(ctx context.Context, aggregate string) (int, error) {
+	id, err := s.insertID(ctx, dbFrom(ctx, s.db),
+		fmt.Sprintf("INSERT INTO event_streams (aggregate) VALUES (%s)", s.bind(1)), aggregate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create stream: %w", err)
+	}
+	return int(id), nil
+}
+
+// Append adds events to a stream at expectedVersion and returns the new
+// version, or ErrVersionConflict if the stream is no longer at
+// expectedVersion. It joins the transaction in ctx if any.
+func (s *EventStore) Append(ctx context.Context, streamID, expectedVersion int, evs ...EventData) (int, error) {
+	if len(evs) == 0 {
+		return expectedVersion, nil
+	}
+
+	now := clock.Or(s.Clock).Now().UTC()
+	version := expectedVersion + len(evs)
+
+	err := s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		db := dbFrom(ctx, s.db)
+
+		res, err := db.ExecContext(ctx,
+			fmt.Sprintf("UPDATE event_streams SET version = %s WHERE id = %s AND version = %s", s.bind(1), s.bind(2), s.bind(3)),
+			version, streamID, expectedVersion,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to advance stream: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return ErrVersionConflict
+		}
+
+		query := fmt.Sprintf("INSERT INTO events (stream_id, version, type, data, recorded_at) VALUES (%s, %s, %s, %s, %s)",
+			s.bind(1), s.bind(2), s.bind(3), s.bind(4), s.bind(5))
+		for i, ev := range evs {
+			data, err := json.Marshal(ev.Data)
+			if err != nil {
+				return fmt.Errorf("failed to marshal %s event: %w", ev.Type, err)
+			}
+			if _, err := db.ExecContext(ctx, query, streamID, expectedVersion+i+1, ev.Type, string(data), now); err != nil {
+				return fmt.Errorf("failed to append %s event: %w", ev.Type, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Load returns the events of a stream after version afterVersion, in order
+func (s *EventStore) Load(ctx context.Context, streamID, afterVersion int) ([]RecordedEvent, error) {
+	rows, err := dbFrom(ctx, s.db).QueryContext(ctx,
+		fmt.Sprintf("SELECT stream_id, version, type, data, recorded_at FROM events WHERE stream_id = %s AND version > %s ORDER BY version",
+			s.bind(1), s.bind(2)),
+		streamID, afterVersion,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stream: %w", err)
+	}
+	return scanEvents(rows)
+}
+
+// LoadAggregate returns the events of every stream of an aggregate type,
+// ordered by stream then version
+func (s *EventStore) LoadAggregate(ctx context.Context, aggregate string) ([]RecordedEvent, error) {
+	rows, err := dbFrom(ctx, s.db).QueryContext(ctx,
+		fmt.Sprintf(`SELECT e.stream_id, e.version, e.type, e.data, e.recorded_at FROM events e
+			JOIN event_streams s ON s.id = e.stream_id WHERE s.aggregate = %s ORDER BY e.stream_id, e.version`, s.bind(1)),
+		aggregate,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load streams: %w", err)
+	}
+	return scanEvents(rows)
+}
+
+// StreamVersion returns the current version of a stream, or ErrNotFound
+func (s *EventStore) StreamVersion(ctx context.Context, streamID int) (int, error) {
+	var version int
+	err := dbFrom(ctx, s.db).QueryRowContext(ctx,
+		fmt.Sprintf("SELECT version FROM event_streams WHERE id = %s", s.bind(1)), streamID,
+	).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get stream version: %w", err)
+	}
+	return version, nil
+}
+
+func scanEvents(rows *sql.Rows) ([]RecordedEvent, error) {
+	defer rows.Close()
+
+	var evs []RecordedEvent
+	for rows.Next() {
+		var (
+			ev   RecordedEvent
+			data []byte
+		)
+		if err := rows.Scan(&ev.StreamID, &ev.Version, &ev.Type, &data, &ev.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		ev.Data = data
+		evs = append(evs, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate events: %w", err)
+	}
+	return evs, nil
+}
+
+// Aggregate is state rebuilt by applying the events of its stream in order
+type Aggregate interface {
+	Apply(ev RecordedEvent) error
+}
+
+// Rehydrate applies the events of a stream after version afterVersion to
+// agg and returns the version it reached
+func (s *EventStore) Rehydrate(ctx context.Context, streamID, afterVersion int, agg Aggregate) (int, error) {
+	evs, err := s.Load(ctx, streamID, afterVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	version := afterVersion
+	for _, ev := range evs {
+		if err := agg.Apply(ev); err != nil {
+			return 0, fmt.Errorf("failed to apply %s event %d: %w", ev.Type, ev.Version, err)
+		}
+		version = ev.Version
+	}
+	return version, nil
+}
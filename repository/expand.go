@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MigrationStep is one step of a zero-downtime schema change. Steps are
+// deployed one at a time, with application releases in between, and run by
+// Migrator.RunSteps.
+type MigrationStep struct {
+	Name string
+	Run  func(ctx context.Context, conn *sql.Conn) error
+}
+
+// BackfillOptions paces a backfill
+type BackfillOptions struct {
+	// Key is the unique integer column batches are walked by; "id" when
+	// empty
+	Key       string
+	BatchSize int
+	// RowsPerSecond bounds the update rate; 0 means unlimited
+	RowsPerSecond int
+}
+
+// AddColumn returns a step adding a nullable column, which PostgreSQL does
+// without rewriting the table
+func AddColumn(table, column, typ string) MigrationStep {
+	q := PostgresDialect.Quote
+	return MigrationStep{
+		Name: fmt.Sprintf("add %s.%s", table, column),
+		Run: func(ctx context.Context, conn *sql.Conn) error {
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", q(table), q(column), typ)); err != nil {
+				return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+			}
+			return nil
+		},
+	}
+}
+
+// BackfillColumn returns a step setting column to the SQL expression expr
+// on the rows where it is still NULL, in batches of opts.BatchSize each
+// committed on its own, so locks are short and replicas keep up. Rows the
+// application writes meanwhile are left as they are.
+func BackfillColumn(table, column, expr string, opts BackfillOptions) MigrationStep {
+	if opts.Key == "" {
+		opts.Key = "id"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	q := PostgresDialect.Quote
+	bound := fmt.Sprintf("SELECT MAX(%[2]s) FROM (SELECT %[2]s FROM %[1]s WHERE %[2]s > $1 ORDER BY %[2]s LIMIT $2) batch",
+		q(table), q(opts.Key))
+	update := fmt.Sprintf("UPDATE %[1]s SET %[2]s = %[3]s WHERE %[4]s > $1 AND %[4]s <= $2 AND %[2]s IS NULL",
+		q(table), q(column), expr, q(opts.Key))
+
+	return MigrationStep{
+		Name: fmt.Sprintf("backfill %s.%s", table, column),
+		Run: func(ctx context.Context, conn *sql.Conn) error {
+			// walking the key rather than re-selecting NULL rows ends even
+			// when expr is NULL for some of them
+			var last int64
+			for {
+				var upper sql.NullInt64
+				if err := conn.QueryRowContext(ctx, bound, last, opts.BatchSize).Scan(&upper); err != nil {
+					return fmt.Errorf("failed to select backfill batch: %w", err)
+				}
+				if !upper.Valid {
+					return nil
+				}
+
+				res, err := conn.ExecContext(ctx, update, last, upper.Int64)
+				if err != nil {
+					return fmt.Errorf("failed to backfill %s.%s: %w", table, column, err)
+				}
+				n, err := res.RowsAffected()
+				if err != nil {
+					return fmt.Errorf("failed to get rows affected: %w", err)
+				}
+				last = upper.Int64
+
+				if err := pace(ctx, int(n), opts.RowsPerSecond); err != nil {
+					return err
+				}
+			}
+		},
+	}
+}
+
+// SwapColumns returns a step renaming column to column_old and replacement
+// to column in one transaction. It refuses while replacement is missing
+// values column has, i.e. before the backfill finished.
+func SwapColumns(table, column, replacement string) MigrationStep {
+	q := PostgresDialect.Quote
+	return MigrationStep{
+		Name: fmt.Sprintf("swap %s.%s for %s", table, column, replacement),
+		Run: func(ctx context.Context, conn *sql.Conn) error {
+			tx, err := conn.BeginTx(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("failed to begin transaction: %w", err)
+			}
+			defer tx.Rollback()
+
+			// the lock keeps writers from adding unfilled rows until commit
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("LOCK TABLE %s IN SHARE ROW EXCLUSIVE MODE", q(table))); err != nil {
+				return fmt.Errorf("failed to lock %s: %w", table, err)
+			}
+			var missing int64
+			err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s IS NULL AND %s IS NOT NULL",
+				q(table), q(replacement), q(column))).Scan(&missing)
+			if err != nil {
+				return fmt.Errorf("failed to check backfill: %w", err)
+			}
+			if missing > 0 {
+				return fmt.Errorf("%d rows of %s have no %s yet, finish the backfill first", missing, table, replacement)
+			}
+
+			for _, rename := range [][2]string{{column, column + "_old"}, {replacement, column}} {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", q(table), q(rename[0]), q(rename[1]))); err != nil {
+					return fmt.Errorf("failed to rename %s.%s: %w", table, rename[0], err)
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit swap: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// DropColumn returns a step dropping a column no release reads any more
+func DropColumn(table, column string) MigrationStep {
+	q := PostgresDialect.Quote
+	return MigrationStep{
+		Name: fmt.Sprintf("drop %s.%s", table, column),
+		Run: func(ctx context.Context, conn *sql.Conn) error {
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", q(table), q(column))); err != nil {
+				return fmt.Errorf("failed to drop column %s.%s: %w", table, column, err)
+			}
+			return nil
+		},
+	}
+}
+
+// ColumnChange changes a column's type or contents without downtime, by
+// expand/contract:
+//
+//  1. Expand adds <column>_new; then release code writing both columns
+//  2. Backfill fills <column>_new for the existing rows
+//  3. Swap renames <column> to <column>_old and <column>_new to <column>;
+//     then release code reading only the new column
+//  4. Contract drops <column>_old
+type ColumnChange struct {
+	Table  string
+	Column string
+	// Type is the column's new SQL type
+	Type string
+	// Using computes the new value from the row, e.g. "lower(email)"; the
+	// old column, cast to Type, when empty
+	Using    string
+	Backfill BackfillOptions
+}
+
+// Steps returns the four steps of the change, in order
+func (c ColumnChange) Steps() []MigrationStep {
+	replacement := c.Column + "_new"
+	using := c.Using
+	if using == "" {
+		using = fmt.Sprintf("CAST(%s AS %s)", PostgresDialect.Quote(c.Column), c.Type)
+	}
+	return []MigrationStep{
+		AddColumn(c.Table, replacement, c.Type),
+		BackfillColumn(c.Table, replacement, using, c.Backfill),
+		SwapColumns(c.Table, c.Column, replacement),
+		DropColumn(c.Table, c.Column+"_old"),
+	}
+}
+
+// RunSteps runs steps in order while holding the migration lock, stopping
+// at the first failing one
+func (m *Migrator) RunSteps(ctx context.Context, steps ...MigrationStep) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		for _, step := range steps {
+			if err := step.Run(ctx, conn); err != nil {
+				return fmt.Errorf("migration step %q: %w", step.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// pace waits long enough after writing n rows to honour rowsPerSecond
+func pace(ctx context.Context, n, rowsPerSecond int) error {
+	if rowsPerSecond <= 0 || n == 0 {
+		return nil
+	}
+	t := time.NewTimer(time.Duration(n) * time.Second / time.Duration(rowsPerSecond))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"project/models"
+)
+
+// Explainer is implemented by adapters that can show how the database
+// runs a Query
+type Explainer interface {
+	Explain(ctx context.Context, q Query, analyze bool) (*Explanation, error)
+}
+
+// Plan is one node of a PostgreSQL execution plan, as reported by EXPLAIN
+// (FORMAT JSON). The Actual fields are only set by ANALYZE.
+type Plan struct {
+	NodeType     string  `json:"Node Type"`
+	RelationName string  `json:"Relation Name,omitempty"`
+	IndexName    string  `json:"Index Name,omitempty"`
+	IndexCond    string  `json:"Index Cond,omitempty"`
+	Filter       string  `json:"Filter,omitempty"`
+	StartupCost  float64 `json:"Startup Cost"`
+	TotalCost    float64 `json:"Total Cost"`
+	PlanRows     float64 `json:"Plan Rows"`
+	ActualRows   float64 `json:"Actual Rows,omitempty"`
+	// ActualTotalTime is in milliseconds
+	ActualTotalTime float64 `json:"Actual Total Time,omitempty"`
+	Plans           []Plan  `json:"Plans,omitempty"`
+}
+
+// Explanation is the execution plan of a query
+type Explanation struct {
+	Plan Plan `json:"Plan"`
+	// PlanningTime and ExecutionTime are in milliseconds; ExecutionTime is
+	// only set by ANALYZE
+	PlanningTime  float64 `json:"Planning Time,omitempty"`
+	ExecutionTime float64 `json:"Execution Time,omitempty"`
+}
+
+// Walk calls fn for p and every node below it, depth first
+func (p Plan) Walk(fn func(Plan)) {
+	fn(p)
+	for _, child := range p.Plans {
+		child.Walk(fn)
+	}
+}
+
+// Indexes returns the indexes the plan reads
+func (e *Explanation) Indexes() []string {
+	var names []string
+	e.Plan.Walk(func(p Plan) {
+		if p.IndexName != "" {
+			names = append(names, p.IndexName)
+		}
+	})
+	return names
+}
+
+// SeqScans returns the tables the plan reads in full
+func (e *Explanation) SeqScans() []string {
+	var tables []string
+	e.Plan.Walk(func(p Plan) {
+		if p.NodeType == "Seq Scan" {
+			tables = append(tables, p.RelationName)
+		}
+	})
+	return tables
+}
+
+// String renders the plan as an indented tree
+func (e *Explanation) String() string {
+	var b strings.Builder
+	var write func(p Plan, depth int)
+	write = func(p Plan, depth int) {
+		fmt.Fprintf(&b, "%s%s", strings.Repeat("  ", depth), p.NodeType)
+		if p.IndexName != "" {
+			fmt.Fprintf(&b, " using %s", p.IndexName)
+		}
+		if p.RelationName != "" {
+			fmt.Fprintf(&b, " on %s", p.RelationName)
+		}
+		fmt.Fprintf(&b, " (cost=%.2f..%.2f rows=%.0f)\n", p.StartupCost, p.TotalCost, p.PlanRows)
+		for _, cond := range []string{p.IndexCond, p.Filter} {
+			if cond != "" {
+				fmt.Fprintf(&b, "%s  %s\n", strings.Repeat("  ", depth), cond)
+			}
+		}
+		for _, child := range p.Plans {
+			write(child, depth+1)
+		}
+	}
+	write(e.Plan, 0)
+	return b.String()
+}
+
+// Explain returns the plan PostgreSQL picks for q as Find would run it,
+// hints included, so developers can check their filters use an index
+// before shipping them.
+// With analyze the query is executed and the plan carries actual row
+// counts and timings.
+func (p *PostgresRepo) Explain(ctx context.Context, q Query, analyze bool) (*Explanation, error) {
+	query, args, err := q.sql(p.dialect, p.table(models.User{}), p.maxRows)
+	if err != nil {
+		return nil, err
+	}
+	options := "FORMAT JSON"
+	if analyze {
+		options = "ANALYZE, " + options
+	}
+
+	var raw []byte
+	err = withHints(ctx, p.db, p.dialect, q.Options.Hints, func(db querier) error {
+		if err := db.QueryRowContext(ctx, "EXPLAIN ("+options+") "+query, args...).Scan(&raw); err != nil {
+			return fmt.Errorf("failed to explain query: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []Explanation
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return nil, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("failed to parse query plan: empty result")
+	}
+	return &plans[0], nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is the default error returned by a FaultInjector
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultConfig sets the probability, 0..1, of each kind of fault per call
+type FaultConfig struct {
+	// LatencyRate calls are delayed by up to Latency before running
+	LatencyRate float64
+	Latency     time.Duration
+	// ErrorRate calls fail with Error, ErrInjectedFault when nil, without
+	// running
+	ErrorRate float64
+	Error     error
+	// DropRate calls fail with driver.ErrBadConn, as if the connection was
+	// lost, without running
+	DropRate float64
+	// Methods limits faults to these repository methods; empty means all
+	Methods []string
+}
+
+// FaultInjector injects latency, errors and connection drops into
+// repository calls, for resilience testing of retry and circuit-breaker
+// layers in staging. Its config can be changed while in use.
+type FaultInjector struct {
+	mu  sync.Mutex
+	cfg FaultConfig
+	rng *rand.Rand
+}
+
+// NewFaultInjector creates an injector; seed makes the fault sequence
+// reproducible
+func NewFaultInjector(cfg FaultConfig, seed int64) *FaultInjector {
+	return &FaultInjector{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+// NewFaultInjectingRepository wraps repo so its calls suffer the faults of
+// injector
+func NewFaultInjectingRepository(repo UserRepository, injector *FaultInjector) UserRepository {
+	return Decorate(repo, injector.Middleware)
+}
+
+// SetConfig replaces the fault probabilities
+func (f *FaultInjector) SetConfig(cfg FaultConfig) {
+	f.mu.Lock()
+	f.cfg = cfg
+	f.mu.Unlock()
+}
+
+// Middleware implements Middleware
+func (f *FaultInjector) Middleware(ctx context.Context, method string, next func(ctx context.Context) error) error {
+	delay, err := f.roll(method)
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return next(ctx)
+}
+
+// roll decides the faults of one call
+func (f *FaultInjector) roll(method string) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cfg := f.cfg
+	if len(cfg.Methods) > 0 && !slices.Contains(cfg.Methods, method) {
+		return 0, nil
+	}
+
+	var delay time.Duration
+	if cfg.Latency > 0 && f.rng.Float64() < cfg.LatencyRate {
+		delay = time.Duration(f.rng.Int63n(int64(cfg.Latency) + 1))
+	}
+
+	switch {
+	case f.rng.Float64() < cfg.DropRate:
+		return delay, driver.ErrBadConn
+	case f.rng.Float64() < cfg.ErrorRate:
+		if cfg.Error != nil {
+			return delay, cfg.Error
+		}
+		return delay, ErrInjectedFault
+	}
+	return delay, nil
+}
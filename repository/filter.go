@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"project/apperr"
+	"project/models"
+)
+
+// Condition compares one user column with a value
+type Condition struct {
+	Column string
+	// Op is one of =, <>, <, <=, >, >=, "IS NULL" and "IS NOT NULL"; the
+	// last two take no Value
+	Op    string
+	Value any
+}
+
+// Filter selects the users matching all of its conditions
+type Filter []Condition
+
+// Finder is implemented by adapters that can select users by criteria
+type Finder interface {
+	Find(ctx context.Context, filter Filter, opts ListOptions) ([]models.User, error)
+}
+
+// Eq returns the condition column = value
+func Eq(column string, value any) Condition {
+	return Condition{Column: column, Op: "=", Value: value}
+}
+
+// Where returns the condition column op value
+func Where(column, op string, value any) Condition {
+	return Condition{Column: column, Op: op, Value: value}
+}
+
+// filterOps are the operators a Condition may use, and whether they bind
+// a value
+var filterOps = map[string]bool{
+	"=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+	"IS NULL": false, "IS NOT NULL": false,
+}
+
+// where returns the SQL condition of f and its arguments, binding from
+// placeholder first+1; column names are checked against the User model
+// since they can't be bound
+func (f Filter) where(bind func(int) string, first int) (string, []any, error) {
+	columns := columnFields(reflect.TypeOf(models.User{}))
+	terms := make([]string, 0, len(f))
+	var args []any
+	for _, c := range f {
+		if _, ok := columns[c.Column]; !ok {
+			return "", nil, apperr.New(apperr.InvalidArgument, fmt.Sprintf("cannot filter users by %q", c.Column))
+		}
+		op := strings.ToUpper(c.Op)
+		binds, ok := filterOps[op]
+		if !ok {
+			return "", nil, apperr.New(apperr.InvalidArgument, fmt.Sprintf("unsupported filter operator %q", c.Op))
+		}
+		if !binds {
+			terms = append(terms, c.Column+" "+op)
+			continue
+		}
+		args = append(args, c.Value)
+		terms = append(terms, fmt.Sprintf("%s %s %s", c.Column, op, bind(first+len(args))))
+	}
+	if len(terms) == 0 {
+		return "TRUE", nil, nil
+	}
+	return strings.Join(terms, " AND "), args, nil
+}
+
+// Query is a Find call as a value, e.g. to Explain it
+type Query struct {
+	Filter  Filter
+	Options ListOptions
+}
+
+// sql returns the statement Find runs for q
+func (q Query) sql(d Dialect, users string, maxRows int) (string, []any, error) {
+	where, args, err := q.Filter.where(d.Bind, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	order, err := orderBy(q.Options.Sort)
+	if err != nil {
+		return "", nil, err
+	}
+	table, err := hintedTable(d, users, q.Options.Hints)
+	if err != nil {
+		return "", nil, err
+	}
+	query := fmt.Sprintf(
+		"SELECT id, created_at, updated_at, name, email, tenant_id FROM %s WHERE deleted_at IS NULL AND %s %s",
+		table, where, order,
+	)
+
+	opts := q.Options
+	if opts.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %s OFFSET %s", d.Bind(len(args)+1), d.Bind(len(args)+2))
+		args = append(args, opts.Limit, opts.Offset)
+	} else if opts.Offset > 0 {
+		return "", nil, fmt.Errorf("offset requires a limit")
+	} else {
+		query = limitRows(query, maxRows)
+	}
+	return query, args, nil
+}
+
+// Find returns the live users matching filter, ordered by opts.Sort and
+// then ID. Without a Limit the result is capped, see SetMaxRows.
+func (s *SQLRepo) Find(ctx context.Context, filter Filter, opts ListOptions) ([]models.User, error) {
+	query, args, err := Query{Filter: filter, Options: opts}.sql(s.dialect, s.table(models.User{}), s.maxRows)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	err = withHints(ctx, s.db, s.dialect, opts.Hints, func(q querier) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to find users: %w", err)
+		}
+		defer rows.Close()
+
+		users, err = ScanAll[models.User](rows)
+		return err
+	})
+	if err != nil || opts.Limit > 0 {
+		return users, err
+	}
+	return checkRows(users, s.maxRows)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"project/apperr"
+	"project/models"
+)
+
+// FuzzFilterSQL checks that only User columns, the fixed operators and
+// placeholders reach the SQL Find runs, whatever a caller passes as the
+// column, operator, value and sort spec
+func FuzzFilterSQL(f *testing.F) {
+	for _, seed := range []struct{ column, op, value, sort string }{
+		{"name", "=", "alice", ""},
+		{"email", "<>", "a@example.com", "name,-created_at"},
+		{"created_at", ">=", "2024-01-01", "-id"},
+		{"deleted_at", "IS NULL", "", "email"},
+		{"tenant_id", "is not null", "", "tenant_id,updated_at"},
+		{"name", "LIKE", "%", ""},
+		{"name", "= 1 OR 1 =", "x", ""},
+		{"Name", "=", "x", "-"},
+		{"", "", "", ",,"},
+	} {
+		f.Add(seed.column, seed.op, seed.value, seed.sort)
+	}
+
+	columns := columnFields(reflect.TypeOf(models.User{}))
+	f.Fuzz(func(t *testing.T, column, op, value, spec string) {
+		filter := Filter{Where(column, op, value)}
+		where, args, err := filter.where(PostgresDialect.Bind, 0)
+		if err != nil {
+			if code := apperr.CodeOf(err); code != apperr.InvalidArgument {
+				t.Fatalf("where(%q, %q) failed with code %s, want %s: %v", column, op, code, apperr.InvalidArgument, err)
+			}
+			return
+		}
+
+		if _, ok := columns[column]; !ok {
+			t.Fatalf("where accepted column %q, which User doesn't have", column)
+		}
+		upper := strings.ToUpper(op)
+		binds, ok := filterOps[upper]
+		if !ok {
+			t.Fatalf("where accepted operator %q", op)
+		}
+		want, wantArgs := column+" "+upper, 0
+		if binds {
+			want, wantArgs = want+" $1", 1
+		}
+		if where != want || len(args) != wantArgs {
+			t.Fatalf("where(%q, %q) = %q with %d args, want %q with %d", column, op, where, len(args), want, wantArgs)
+		}
+
+		sort, err := ParseSort(spec)
+		if err != nil {
+			return
+		}
+		for _, s := range sort {
+			if !sortableUserColumns[s.Column] {
+				t.Fatalf("ParseSort(%q) accepted column %q", spec, s.Column)
+			}
+		}
+		query, _, err := Query{Filter: filter, Options: ListOptions{Sort: sort}}.sql(PostgresDialect, "users", 0)
+		if err != nil {
+			t.Fatalf("sql of a valid filter and sort failed: %v", err)
+		}
+		order, err := orderBy(sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantQuery := "SELECT id, created_at, updated_at, name, email, tenant_id FROM users WHERE deleted_at IS NULL AND " +
+			want + " " + order
+		if query != wantQuery {
+			t.Fatalf("sql = %q, want %q", query, wantQuery)
+		}
+	})
+}
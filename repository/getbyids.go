@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"project/models"
+)
+
+// getByIDsChunk bounds the IDs of one GetByIDs statement, well below the
+// bind parameter limits of both dialects
+const getByIDsChunk = 1000
+
+// BatchGetter is implemented by adapters that load many users at once, e.g.
+// to hydrate the user references of a page of records
+type BatchGetter interface {
+	GetByIDs(ids []int) (users []models.User, missing []int, err error)
+}
+
+// GetByIDs retrieves the users with ids, in the order of ids, in one query
+// per getByIDsChunk IDs: = ANY on Postgres, an IN list on MySQL. IDs with
+// no live user are returned in missing, in input order; a repeated ID is
+// returned at each of its positions.
+func (s *SQLRepo) GetByIDs(ids []int) ([]models.User, []int, error) {
+	def, err := parseModel(&models.User{}, NamingStrategy{})
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make([]string, len(def.Columns))
+	for i, col := range def.Columns {
+		columns[i] = col.Name
+	}
+
+	unique := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	found := make(map[int]models.User, len(unique))
+	for start := 0; start < len(unique); start += getByIDsChunk {
+		chunk := unique[start:min(start+getByIDsChunk, len(unique))]
+		users, err := s.getChunk(columns, chunk)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, u := range users {
+			found[u.ID] = u
+		}
+	}
+
+	users := make([]models.User, 0, len(ids))
+	var missing []int
+	for _, id := range ids {
+		if u, ok := found[id]; ok {
+			users = append(users, u)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return users, missing, nil
+}
+
+// getChunk loads the live users among ids in one statement
+func (s *SQLRepo) getChunk(columns []string, ids []int) ([]models.User, error) {
+	d := s.dialect
+	var (
+		match string
+		args  []any
+	)
+	if d.Name() == "postgres" {
+		// one array parameter keeps the statement text the same for every chunk
+		match, args = "id = ANY("+d.Bind(1)+")", []any{d.Array(ids)}
+	} else {
+		placeholders := make([]string, len(ids))
+		args = make([]any, len(ids))
+		for i, id := range ids {
+			placeholders[i], args[i] = d.Bind(i+1), id
+		}
+		match = "id IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	rows, err := s.reader(ListOptions{}).Query(fmt.Sprintf("SELECT %s FROM %s WHERE %s AND %s IS NULL",
+		strings.Join(columns, ", "), s.table(models.User{}), match, softDeleteColumn), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+	return ScanAll[models.User](rows)
+}
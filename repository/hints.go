@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"project/apperr"
+)
+
+// Hint is a dialect-specific planner hint for one call, set in
+// ListOptions.Hints, for the rare query the planner gets wrong. A hint
+// applies on its dialect only and is ignored on the others, so callers
+// can pass hints for every database they run on.
+type Hint struct {
+	dialect string
+	// setting and value are a Postgres run-time setting
+	setting, value string
+	// clause and indexes are a MySQL index hint
+	clause  string
+	indexes []string
+}
+
+// Setting returns a Postgres hint setting name to value for the call, as
+// SET LOCAL would, e.g. Setting("enable_seqscan", "off"). Only planner
+// settings are allowed: the enable_* switches and plannerSettings.
+func Setting(name, value string) Hint {
+	return Hint{dialect: "postgres", setting: name, value: value}
+}
+
+// UseIndex returns a MySQL hint restricting the call to indexes of the
+// table it reads, as USE INDEX
+func UseIndex(indexes ...string) Hint {
+	return Hint{dialect: "mysql", clause: "USE INDEX", indexes: indexes}
+}
+
+// ForceIndex is UseIndex making table scans a last resort, as FORCE INDEX
+func ForceIndex(indexes ...string) Hint {
+	return Hint{dialect: "mysql", clause: "FORCE INDEX", indexes: indexes}
+}
+
+// IgnoreIndex returns a MySQL hint keeping the call off indexes, as
+// IGNORE INDEX
+func IgnoreIndex(indexes ...string) Hint {
+	return Hint{dialect: "mysql", clause: "IGNORE INDEX", indexes: indexes}
+}
+
+// plannerSettings are the Postgres settings a hint may change besides the
+// enable_* switches: costs, memory, join planning, parallelism and
+// timeouts. Anything else, e.g. role or search_path, would change what the
+// call may see rather than how it is planned.
+var plannerSettings = map[string]bool{
+	"work_mem":                        true,
+	"random_page_cost":                true,
+	"seq_page_cost":                   true,
+	"cpu_tuple_cost":                  true,
+	"cpu_index_tuple_cost":            true,
+	"cpu_operator_cost":               true,
+	"effective_cache_size":            true,
+	"effective_io_concurrency":        true,
+	"join_collapse_limit":             true,
+	"from_collapse_limit":             true,
+	"geqo":                            true,
+	"geqo_threshold":                  true,
+	"cursor_tuple_fraction":           true,
+	"plan_cache_mode":                 true,
+	"jit":                             true,
+	"parallel_setup_cost":             true,
+	"parallel_tuple_cost":             true,
+	"max_parallel_workers_per_gather": true,
+	"statement_timeout":               true,
+	"lock_timeout":                    true,
+}
+
+// enablePattern matches the planner's enable_* switches, e.g. enable_seqscan
+var enablePattern = regexp.MustCompile(`^enable_[a-z_]+$`)
+
+// indexPattern matches MySQL index names, PRIMARY included
+var indexPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validate checks the names of h, since index names are spliced into SQL
+// and settings could otherwise change more than the plan
+func (h Hint) validate() error {
+	switch h.dialect {
+	case "postgres":
+		if !enablePattern.MatchString(h.setting) && !plannerSettings[h.setting] {
+			return apperr.New(apperr.InvalidArgument, fmt.Sprintf("setting %q is not a planner setting", h.setting))
+		}
+	case "mysql":
+		if len(h.indexes) == 0 {
+			return apperr.New(apperr.InvalidArgument, h.clause+" needs an index")
+		}
+		for _, index := range h.indexes {
+			if !indexPattern.MatchString(index) {
+				return apperr.New(apperr.InvalidArgument, fmt.Sprintf("invalid index name %q", index))
+			}
+		}
+	default:
+		return apperr.New(apperr.InvalidArgument, "empty hint")
+	}
+	return nil
+}
+
+// hintedTable returns table followed by the index hints in hints for d,
+// for the FROM clause of a query
+func hintedTable(d Dialect, table string, hints []Hint) (string, error) {
+	for _, h := range hints {
+		if err := h.validate(); err != nil {
+			return "", err
+		}
+		if h.dialect == d.Name() && h.clause != "" {
+			table += " " + h.clause + " (" + strings.Join(h.indexes, ", ") + ")"
+		}
+	}
+	return table, nil
+}
+
+// withHints runs fn with the settings in hints for d applied. They are
+// scoped to a transaction: the one in ctx, where they stay until it ends,
+// or one begun on db for fn. Without settings fn runs as dbFrom would.
+func withHints(ctx context.Context, db *sql.DB, d Dialect, hints []Hint, fn func(q querier) error) error {
+	var settings []Hint
+	for _, h := range hints {
+		if err := h.validate(); err != nil {
+			return err
+		}
+		if h.dialect == d.Name() && h.setting != "" {
+			settings = append(settings, h)
+		}
+	}
+	if len(settings) == 0 {
+		return fn(dbFrom(ctx, db))
+	}
+
+	tx, inTx := TxFromContext(ctx)
+	if !inTx {
+		var err error
+		if tx, err = db.BeginTx(ctx, nil); err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+	}
+	// set_config over SET LOCAL, since it takes bind parameters
+	for _, h := range settings {
+		if _, err := tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", h.setting, h.value); err != nil {
+			return fmt.Errorf("failed to apply hint %s: %w", h.setting, err)
+		}
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if !inTx {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}
+	return nil
+}
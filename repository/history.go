@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"project/models"
+)
+
+// EnableHistory creates users_history and starts recording the previous
+// version of a user on every update and delete. The history table copies the
+// users columns at creation time; fields added to User later must be added
+// to users_history too.
+func (p *PostgresRepo) EnableHistory() error {
+	history := p.naming.usersCopy("_history")
+	_, err := p.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		LIKE %s INCLUDING DEFAULTS,
+		valid_from TIMESTAMPTZ NOT NULL,
+		valid_to TIMESTAMPTZ NOT NULL
+	)`, history, p.table(models.User{})))
+	if err != nil {
+		return fmt.Errorf("failed to create history table: %w", err)
+	}
+
+	_, err = p.db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_id_valid_idx ON %s (id, valid_from, valid_to)", bareTable(history), history))
+	if err != nil {
+		return fmt.Errorf("failed to create history index: %w", err)
+	}
+
+	p.history = true
+	return nil
+}
+
+// mutate runs a write to user id in a transaction, first copying the current
+// row into users_history when history is enabled
+func (p *PostgresRepo) mutate(id int, fn func(tx *sql.Tx) error) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if p.history {
+		// the version being replaced was current from its last update until now
+		// mapped columns only, so columns added to users by hand don't
+		// break the copy
+		columns := strings.Join(Columns[models.User](), ", ")
+		_, err := tx.Exec(fmt.Sprintf(`INSERT INTO %[2]s (%[1]s, valid_from, valid_to)
+			SELECT %[1]s, updated_at, CURRENT_TIMESTAMP FROM %[3]s
+			WHERE id = $1 AND deleted_at IS NULL
+			FOR UPDATE`, columns, p.naming.usersCopy("_history"), p.table(models.User{})), id)
+		if err != nil {
+			return fmt.Errorf("failed to record user history: %w", err)
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetUserAsOf returns the version of a user that was current at t, or
+// ErrNotFound if the user didn't exist or was deleted at that time
+func (p *PostgresRepo) GetUserAsOf(id int, t time.Time) (models.User, error) {
+	if !p.history {
+		return models.User{}, fmt.Errorf("user history is not enabled")
+	}
+
+	def, err := parseModel(models.User{}, NamingStrategy{})
+	if err != nil {
+		return models.User{}, err
+	}
+	columns := make([]string, len(def.Columns))
+	for i, col := range def.Columns {
+		columns[i] = col.Name
+	}
+	cols := strings.Join(columns, ", ")
+
+	query := fmt.Sprintf(`SELECT %[1]s FROM %[2]s WHERE id = $1 AND updated_at <= $2
+		UNION ALL
+		SELECT %[1]s FROM %[3]s WHERE id = $1 AND valid_from <= $2 AND valid_to > $2
+		LIMIT 1`, cols, p.table(models.User{}), p.naming.usersCopy("_history"))
+
+	rows, err := p.db.Query(query, id, t)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to query user history: %w", err)
+	}
+	defer rows.Close()
+
+	users, err := ScanAll[models.User](rows)
+	if err != nil {
+		return models.User{}, err
+	}
+	if len(users) == 0 {
+		return models.User{}, ErrNotFound
+	}
+
+	u := users[0]
+	if u.DeletedAt.Valid && !u.DeletedAt.Time.After(t) {
+		return models.User{}, ErrNotFound
+	}
+	return u, nil
+}
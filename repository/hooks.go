@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+)
+
+// BeforeCreator is implemented by models that run logic before being inserted,
+// e.g. generating a slug. Returning an error aborts the create.
+type BeforeCreator interface {
+	BeforeCreate() error
+}
+
+// AfterCreator is implemented by models that run logic after being inserted
+type AfterCreator interface {
+	AfterCreate() error
+}
+
+// BeforeUpdater is implemented by models that run logic before being
+// updated. Returning an error aborts the update.
+type BeforeUpdater interface {
+	BeforeUpdate() error
+}
+
+// AfterDeleter is implemented by models that run logic after being deleted,
+// e.g. busting a cache
+type AfterDeleter interface {
+	AfterDelete() error
+}
+
+// HookEvent names a point in a model's lifecycle
+type HookEvent string
+
+const (
+	BeforeCreate HookEvent = "before_create"
+	AfterCreate  HookEvent = "after_create"
+	BeforeUpdate HookEvent = "before_update"
+	AfterDelete  HookEvent = "after_delete"
+)
+
+// HookFunc is a callback registered for a lifecycle event. model is a
+// pointer to the model being written.
+type HookFunc func(model any) error
+
+// Hooks holds the callbacks registered on a repository
+type Hooks struct {
+	mu        sync.RWMutex
+	callbacks map[HookEvent][]HookFunc
+}
+
+// On registers fn to run on event, after the model's own hook method
+func (h *Hooks) On(event HookEvent, fn HookFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.callbacks == nil {
+		h.callbacks = make(map[HookEvent][]HookFunc)
+	}
+	h.callbacks[event] = append(h.callbacks[event], fn)
+}
+
+// run invokes the model's hook method for event, then the registered
+// callbacks, stopping at the first error
+func (h *Hooks) run(event HookEvent, model any) error {
+	if err := runModelHook(event, model); err != nil {
+		return fmt.Errorf("%s hook failed: %w", event, err)
+	}
+
+	h.mu.RLock()
+	callbacks := h.callbacks[event]
+	h.mu.RUnlock()
+
+	for _, fn := range callbacks {
+		if err := fn(model); err != nil {
+			return fmt.Errorf("%s hook failed: %w", event, err)
+		}
+	}
+	return nil
+}
+
+func runModelHook(event HookEvent, model any) error {
+	switch event {
+	case BeforeCreate:
+		if m, ok := model.(BeforeCreator); ok {
+			return m.BeforeCreate()
+		}
+	case AfterCreate:
+		if m, ok := model.(AfterCreator); ok {
+			return m.AfterCreate()
+		}
+	case BeforeUpdate:
+		if m, ok := model.(BeforeUpdater); ok {
+			return m.BeforeUpdate()
+		}
+	case AfterDelete:
+		if m, ok := model.(AfterDeleter); ok {
+			return m.AfterDelete()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"project/clock"
+)
+
+// IDGenerator produces primary keys for models whose key is tagged manual,
+// i.e. assigned by the application instead of a database sequence. NewID
+// returns a value convertible to the key field's type.
+type IDGenerator interface {
+	NewID() (any, error)
+}
+
+// crockford is the ULID base32 alphabet
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator generates 26-character ULIDs: a 48-bit millisecond timestamp
+// followed by 80 random bits. IDs generated in the same millisecond are
+// monotonic, so they sort in creation order. Clock and Entropy default to
+// the system clock and crypto/rand.
+type ULIDGenerator struct {
+	Clock   clock.Clock
+	Entropy io.Reader
+
+	mu      sync.Mutex
+	lastMS  uint64
+	entropy [10]byte
+}
+
+// NewID implements IDGenerator, returning a string
+func (g *ULIDGenerator) NewID() (any, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(clock.Or(g.Clock).Now().UnixMilli())
+	if ms == g.lastMS {
+		// same millisecond: increment the entropy instead of re-rolling it
+		if !incrementBytes(g.entropy[:]) {
+			return nil, fmt.Errorf("ulid entropy exhausted for millisecond %d", ms)
+		}
+	} else {
+		entropy := g.Entropy
+		if entropy == nil {
+			entropy = rand.Reader
+		}
+		if _, err := io.ReadFull(entropy, g.entropy[:]); err != nil {
+			return nil, fmt.Errorf("failed to read entropy: %w", err)
+		}
+		g.lastMS = ms
+	}
+
+	var id [16]byte
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	copy(id[6:], g.entropy[:])
+
+	return encodeULID(id), nil
+}
+
+// incrementBytes adds one to a big-endian number, reporting false on overflow
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID encodes 128 bits as 26 Crockford base32 characters
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// snowflakeEpoch is the custom epoch of SnowflakeGenerator, 2024-01-01 UTC
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// SnowflakeGenerator generates 63-bit k-sortable integer IDs: 41 bits of
+// milliseconds since 2024-01-01, a 10-bit node ID and a 12-bit sequence.
+// Every instance must use a distinct node ID.
+type SnowflakeGenerator struct {
+	clock  clock.Clock
+	mu     sync.Mutex
+	node   int64
+	lastMS int64
+	seq    int64
+}
+
+// NewSnowflakeGenerator creates a generator for node, in [0, 1023]
+func NewSnowflakeGenerator(node int64) (*SnowflakeGenerator, error) {
+	if node < 0 || node > snowflakeMaxNode {
+		return nil, fmt.Errorf("snowflake node must be between 0 and %d, got %d", snowflakeMaxNode, node)
+	}
+	return &SnowflakeGenerator{node: node, clock: clock.System}, nil
+}
+
+// WithClock replaces the clock timestamps are read from
+func (g *SnowflakeGenerator) WithClock(c clock.Clock) *SnowflakeGenerator {
+	g.clock = c
+	return g
+}
+
+// NewID implements IDGenerator, returning an int64
+func (g *SnowflakeGenerator) NewID() (any, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.clock.Now().Sub(snowflakeEpoch).Milliseconds()
+	if ms < g.lastMS {
+		// clock moved backwards; keep issuing from the last timestamp
+		ms = g.lastMS
+	}
+
+	if ms == g.lastMS {
+		g.seq = (g.seq + 1) & snowflakeMaxSeq
+		if g.seq == 0 {
+			// sequence exhausted: borrow the next millisecond rather than
+			// wait for it, the backwards-clock check above catches up
+			ms++
+		}
+	} else {
+		g.seq = 0
+	}
+	g.lastMS = ms
+
+	return ms<<(snowflakeNodeBits+snowflakeSeqBits) | g.node<<snowflakeSeqBits | g.seq, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// isAutoIncrement reports whether col is a database-generated key, i.e. the
+// sole integer primary key of a model that doesn't tag it manual
+func (d *modelDef) isAutoIncrement(col columnDef) bool {
+	return col.Primary && !col.Manual && len(d.primaryKey()) == 1 && col.SQLType == "BIGINT"
+}
+
+// insertModel inserts the row for model, a pointer to a model struct, into
+// its table under naming. Database-generated keys and zero-valued columns
+// with a default are left to the database; zero manual keys are filled
+// from gen first.
+func insertModel(db *sql.DB, bind func(int) string, array func(any) driver.Valuer, naming NamingStrategy, gen IDGenerator, model any) error {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("model must be a pointer to a struct")
+	}
+	v = v.Elem()
+
+	def, err := parseModel(model, naming)
+	if err != nil {
+		return err
+	}
+
+	var columns, placeholders []string
+	var args []any
+	for _, col := range def.Columns {
+		field := v.FieldByIndex(col.Index)
+
+		switch {
+		case def.isAutoIncrement(col):
+			continue
+		case col.Primary && col.Manual && field.IsZero():
+			if gen == nil {
+				return fmt.Errorf("%s.%s is a manual key but no IDGenerator is set", def.Table, col.Name)
+			}
+			id, err := gen.NewID()
+			if err != nil {
+				return fmt.Errorf("failed to generate id: %w", err)
+			}
+			idv := reflect.ValueOf(id)
+			if !idv.CanConvert(field.Type()) {
+				return fmt.Errorf("generated id %T does not fit %s.%s (%s)", id, def.Table, col.Name, field.Type())
+			}
+			field.Set(idv.Convert(field.Type()))
+		case col.Default != "" && field.IsZero():
+			continue
+		}
+
+		if err := checkEnum(def.Table, col, field); err != nil {
+			return err
+		}
+
+		arg := field.Interface()
+		if isArrayField(col.Type) {
+			arg = array(arg)
+		}
+
+		columns = append(columns, col.Name)
+		args = append(args, arg)
+		placeholders = append(placeholders, bind(len(args)))
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		def.Table,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+	)
+	if _, err := db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to insert %s: %w", def.Table, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"project/apperr"
+	"project/models"
+)
+
+// ErrNotFound is returned when no row matches the requested key
+var ErrNotFound error = apperr.New(apperr.NotFound, "record not found")
+
+// postgresBind returns the n-th (1-based) PostgreSQL placeholder
+func postgresBind(n int) string { return "$" + strconv.Itoa(n) }
+
+// mysqlBind returns a MySQL placeholder
+func mysqlBind(int) string { return "?" }
+
+// keyValues extracts the primary key values for def from key. key may be a
+// scalar for single-column keys, or a struct (such as the model itself or a
+// dedicated key struct) whose fields map to the primary key columns.
+func keyValues(def *modelDef, key any) ([]any, error) {
+	pk := def.primaryKey()
+	if len(pk) == 0 {
+		return nil, fmt.Errorf("%s has no primary key", def.Table)
+	}
+
+	v := reflect.ValueOf(key)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct || v.Type() == timeType {
+		if len(pk) != 1 {
+			return nil, fmt.Errorf("%s has a composite primary key, pass a key struct", def.Table)
+		}
+		return []any{v.Interface()}, nil
+	}
+
+	fields := make(map[string]reflect.Value)
+	walkFields(v.Type(), nil, func(f reflect.StructField, index []int) {
+		if name, _, ok := columnTag(f); ok {
+			fields[name] = v.FieldByIndex(index)
+		}
+	})
+
+	values := make([]any, len(pk))
+	for i, col := range pk {
+		fv, ok := fields[col.Name]
+		if !ok {
+			return nil, fmt.Errorf("key %s is missing primary key column %q", v.Type(), col.Name)
+		}
+		values[i] = fv.Interface()
+	}
+	return values, nil
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// softDelete marks a live user as deleted. The timestamps keep their
+// microseconds, which MySQL's CURRENT_TIMESTAMP drops without a precision,
+// so updated_at never goes back before created_at.
+func softDelete(db execer, bind func(int) string, table string, id int) (int64, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET deleted_at = CURRENT_TIMESTAMP(6), updated_at = CURRENT_TIMESTAMP(6) WHERE id = %s AND deleted_at IS NULL",
+		table, bind(1),
+	)
+	res, err := db.Exec(query, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user: %w", err)
+	}
+	return requireRows(res)
+}
+
+// updateUser writes the mutable user fields of a live user, the name in NFC
+func updateUser(db execer, bind func(int) string, array func(any) driver.Valuer, table string, user models.User) (int64, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET name = %s, tags = %s, updated_at = CURRENT_TIMESTAMP(6) WHERE id = %s AND deleted_at IS NULL",
+		table, bind(1), bind(2), bind(3),
+	)
+	res, err := db.Exec(query, normalizeText(user.Name), array(user.Tags), user.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update user: %w", err)
+	}
+	return requireRows(res)
+}
+
+// requireRow returns ErrNotFound if res affected no rows
+func requireRow(res sql.Result) error {
+	_, err := requireRows(res)
+	return err
+}
+
+// requireRows returns the rows res affected, ErrNotFound if none
+func requireRows(res sql.Result) (int64, error) {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if n == 0 {
+		return 0, ErrNotFound
+	}
+	return n, nil
+}
+
+// getByKey loads the row of dest's table, named by naming, matching key
+// into dest, which must be a pointer to a model struct
+func getByKey(db *sql.DB, bind func(int) string, naming NamingStrategy, key any, dest any) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dest must be a pointer to a struct")
+	}
+
+	def, err := parseModel(dest, naming)
+	if err != nil {
+		return err
+	}
+
+	args, err := keyValues(def, key)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, len(def.Columns))
+	targets := make([]any, len(def.Columns))
+	for i, col := range def.Columns {
+		columns[i] = col.Name
+		targets[i] = scanTarget(dv.Elem().FieldByIndex(col.Index))
+	}
+
+	conds := make([]string, 0, len(args))
+	for i, col := range def.primaryKey() {
+		conds = append(conds, col.Name+" = "+bind(i+1))
+	}
+	if def.softDelete() {
+		conds = append(conds, softDeleteColumn+" IS NULL")
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s",
+		strings.Join(columns, ", "),
+		def.Table,
+		strings.Join(conds, " AND "),
+	)
+
+	if err := db.QueryRow(query, args...).Scan(targets...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get %s: %w", def.Table, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"project/models"
+)
+
+// KeysetRepository is implemented by adapters that page through users in
+// primary key order. Unlike OFFSET paging each page costs the same however
+// deep it is, and rows written meanwhile can't shift pages.
+type KeysetRepository interface {
+	// ListAfter returns up to limit users with an ID above afterID,
+	// ascending; pass 0 for the first page
+	ListAfter(ctx context.Context, afterID, limit int) ([]models.User, error)
+}
+
+// ListAfter implements KeysetRepository
+func (s *SQLRepo) ListAfter(ctx context.Context, afterID, limit int) ([]models.User, error) {
+	return listAfter(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.User{}), afterID, limit)
+}
+
+func listAfter(ctx context.Context, db querier, bind func(int) string, table string, afterID, limit int) ([]models.User, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("keyset page limit must be positive")
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, created_at, updated_at, name, email, tenant_id FROM %s
+		 WHERE deleted_at IS NULL AND id > %s ORDER BY id LIMIT %s`, table, bind(1), bind(2)),
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	return ScanAll[models.User](rows)
+}
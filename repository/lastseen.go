@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LastSeenRepository is implemented by adapters that persist when users
+// were last seen in user_last_seen
+type LastSeenRepository interface {
+	SaveLastSeen(ctx context.Context, seen map[int]time.Time) error
+	LastSeen(ctx context.Context, userID int) (time.Time, error)
+}
+
+// SaveLastSeen records when each user was last seen in one statement
+func (s *SQLRepo) SaveLastSeen(ctx context.Context, seen map[int]time.Time) error {
+	if len(seen) == 0 {
+		return nil
+	}
+
+	// a fixed row order keeps concurrent batches from deadlocking
+	ids := make([]int, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	values := make([]string, 0, len(ids))
+	args := make([]any, 0, 2*len(ids))
+	for i, id := range ids {
+		values = append(values, fmt.Sprintf("(%s, %s)", s.dialect.Bind(2*i+1), s.dialect.Bind(2*i+2)))
+		args = append(args, id, seen[id].UTC())
+	}
+	_, err := dbFrom(ctx, s.db).ExecContext(ctx,
+		"INSERT INTO user_last_seen (user_id, last_seen_at) VALUES "+strings.Join(values, ", ")+" "+
+			s.dialect.Upsert([]string{"user_id"}, "last_seen_at"),
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save last seen: %w", err)
+	}
+	return nil
+}
+
+// LastSeen returns when the user was last seen, or ErrNotFound if never
+func (s *SQLRepo) LastSeen(ctx context.Context, userID int) (time.Time, error) {
+	var t time.Time
+	err := dbFrom(ctx, s.db).QueryRowContext(ctx,
+		"SELECT last_seen_at FROM user_last_seen WHERE user_id = "+s.dialect.Bind(1), userID).Scan(&t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query last seen: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"project/models"
+)
+
+// LoginRepository is implemented by adapters that record login attempts
+// and account lockouts
+type LoginRepository interface {
+	RecordLoginAttempt(ctx context.Context, attempt models.LoginAttempt) error
+	// LoginAttempts returns a user's attempts since a time, newest first
+	LoginAttempts(ctx context.Context, userID int, since time.Time) ([]models.LoginAttempt, error)
+	// LockUser locks a user out until a time, extending any shorter lock
+	LockUser(ctx context.Context, userID int, until time.Time) error
+	UnlockUser(ctx context.Context, userID int) error
+	// LockedUntil returns the end of a user's lockout, or the zero time
+	LockedUntil(ctx context.Context, userID int) (time.Time, error)
+}
+
+// RecordLoginAttempt stores a login attempt
+func (s *SQLRepo) RecordLoginAttempt(ctx context.Context, attempt models.LoginAttempt) error {
+	return recordLoginAttempt(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.LoginAttempt{}), attempt)
+}
+
+// LoginAttempts retrieves a user's recent login attempts
+func (s *SQLRepo) LoginAttempts(ctx context.Context, userID int, since time.Time) ([]models.LoginAttempt, error) {
+	return loginAttempts(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.LoginAttempt{}), userID, since)
+}
+
+// LockUser locks a user out until a time
+func (p *PostgresRepo) LockUser(ctx context.Context, userID int, until time.Time) error {
+	_, err := dbFrom(ctx, p.db).ExecContext(ctx,
+		`INSERT INTO account_lockouts (user_id, locked_until) VALUES ($1, $2)
+		 ON CONFLICT (user_id) DO UPDATE SET locked_until = GREATEST(account_lockouts.locked_until, EXCLUDED.locked_until)`,
+		userID, until.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	return nil
+}
+
+// UnlockUser lifts a user's lockout
+func (s *SQLRepo) UnlockUser(ctx context.Context, userID int) error {
+	return unlockUser(ctx, dbFrom(ctx, s.db), s.dialect.Bind, userID)
+}
+
+// LockedUntil returns the end of a user's lockout
+func (s *SQLRepo) LockedUntil(ctx context.Context, userID int) (time.Time, error) {
+	return lockedUntil(ctx, dbFrom(ctx, s.db), s.dialect.Bind, userID)
+}
+
+// LockUser locks a user out until a time
+func (m *MySQLRepo) LockUser(ctx context.Context, userID int, until time.Time) error {
+	_, err := dbFrom(ctx, m.db).ExecContext(ctx,
+		`INSERT INTO account_lockouts (user_id, locked_until) VALUES (?, ?)
+		 ON DUPLICATE KEY UPDATE locked_until = GREATEST(locked_until, VALUES(locked_until))`,
+		userID, until.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	return nil
+}
+
+func recordLoginAttempt(ctx context.Context, db querier, bind func(int) string, table string, a models.LoginAttempt) error {
+	_, err := db.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (user_id, ip, succeeded, attempted_at) VALUES (%s, %s, %s, %s)",
+			table, bind(1), bind(2), bind(3), bind(4)),
+		a.UserID, a.IP, a.Succeeded, a.AttemptedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
+
+func loginAttempts(ctx context.Context, db querier, bind func(int) string, table string, userID int, since time.Time) ([]models.LoginAttempt, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, user_id, ip, succeeded, attempted_at FROM %s
+		 WHERE user_id = %s AND attempted_at >= %s ORDER BY attempted_at DESC, id DESC`, table, bind(1), bind(2)),
+		userID, since.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login attempts: %w", err)
+	}
+	defer rows.Close()
+
+	return ScanAll[models.LoginAttempt](rows)
+}
+
+func unlockUser(ctx context.Context, db querier, bind func(int) string, userID int) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM account_lockouts WHERE user_id = "+bind(1), userID); err != nil {
+		return fmt.Errorf("failed to unlock user: %w", err)
+	}
+	return nil
+}
+
+func lockedUntil(ctx context.Context, db querier, bind func(int) string, userID int) (time.Time, error) {
+	var until time.Time
+	err := db.QueryRowContext(ctx, "SELECT locked_until FROM account_lockouts WHERE user_id = "+bind(1), userID).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query lockout: %w", err)
+	}
+	return until, nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"fmt"
+
+	"project/apperr"
+	"project/models"
+)
+
+// ErrTooManyRows is returned by an unpaged read whose result exceeds the
+// repository's row limit, see SetMaxRows
+var ErrTooManyRows = apperr.New(apperr.ResourceExhausted, "result set too large, page or stream it instead")
+
+// SetMaxRows makes unpaged reads, GetAll, Find and ListCreatedBetween
+// without a Limit, fail with ErrTooManyRows instead of loading more than n
+// rows; 0 disables the guard. Paged reads, ListAfter and the parallel
+// scans aren't limited.
+func (s *SQLRepo) SetMaxRows(n int) {
+	s.maxRows = n
+}
+
+// limitRows caps an unpaged query one row past max, so an overflow is
+// detected without loading the whole table
+func limitRows(query string, max int) string {
+	if max <= 0 {
+		return query
+	}
+	return fmt.Sprintf("%s LIMIT %d", query, max+1)
+}
+
+// checkRows returns ErrTooManyRows when users overflowed max
+func checkRows(users []models.User, max int) ([]models.User, error) {
+	if max > 0 && len(users) > max {
+		return nil, fmt.Errorf("%w: more than %d rows", ErrTooManyRows, max)
+	}
+	return users, nil
+}
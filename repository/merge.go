@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"project/models"
+)
+
+// MergeRepository is implemented by adapters that can fold a duplicate
+// user into another
+type MergeRepository interface {
+	// MergeUsers saves the email, tags and attributes of survivor,
+	// re-points every row referencing duplicateID to survivor and
+	// soft-deletes the duplicate, all in one transaction, joining the one
+	// in ctx if any. It returns ErrNotFound if either user isn't live.
+	MergeUsers(ctx context.Context, survivor models.User, duplicateID int) (MergeReport, error)
+}
+
+// MergeReport counts, per table, the rows a merge re-pointed to the
+// survivor and the rows of the duplicate it dropped because the survivor
+// already had one for the same key, e.g. their settings
+type MergeReport struct {
+	Moved   map[string]int64 `json:"moved"`
+	Dropped map[string]int64 `json:"dropped"`
+}
+
+// mergeTable is a table referencing users.id through user_id
+type mergeTable struct {
+	name string
+	// model names the table through the repository's NamingStrategy when
+	// set, overriding name
+	model any
+	// unique is set when user_id, with key if any, identifies a row, so
+	// only rows the survivor lacks can move
+	unique bool
+	key    []string
+}
+
+// mergeTables are the tables whose rows follow a user into a merge
+var mergeTables = []mergeTable{
+	{model: models.Post{}},
+	{model: models.APIKey{}},
+	{name: "sessions"},
+	{model: models.LoginAttempt{}},
+	{model: models.Membership{}, unique: true, key: []string{"organization_id"}},
+	{name: "notification_preferences", unique: true, key: []string{"channel"}},
+	{model: models.UserSettings{}, unique: true},
+	{name: "user_passwords", unique: true},
+	{name: "user_last_seen", unique: true},
+	{name: "account_lockouts", unique: true},
+}
+
+// MergeUsers implements MergeRepository
+func (s *SQLRepo) MergeUsers(ctx context.Context, survivor models.User, duplicateID int) (MergeReport, error) {
+	if survivor.ID == duplicateID {
+		return MergeReport{}, fmt.Errorf("cannot merge user %d into itself", duplicateID)
+	}
+
+	report := MergeReport{Moved: map[string]int64{}, Dropped: map[string]int64{}}
+	d := s.dialect
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		tx, _ := TxFromContext(ctx)
+
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE %s SET email = %s, tags = %s, attributes = %s, updated_at = CURRENT_TIMESTAMP WHERE id = %s AND deleted_at IS NULL",
+			s.table(models.User{}), d.Bind(1), d.Bind(2), d.Bind(3), d.Bind(4)),
+			survivor.Email, d.Array(survivor.Tags), survivor.Attributes, survivor.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update user %d: %w", survivor.ID, err)
+		}
+		if err := requireRow(res); err != nil {
+			return err
+		}
+
+		for _, t := range mergeTables {
+			if t.model != nil {
+				t.name = s.table(t.model)
+			}
+			var exists int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = "+d.Bind(1), t.name).
+				Scan(&exists); err != nil {
+				return fmt.Errorf("failed to look up %s: %w", t.name, err)
+			}
+			if exists == 0 {
+				continue
+			}
+			moved, dropped, err := repointRows(ctx, tx, d, t, survivor.ID, duplicateID)
+			if err != nil {
+				return fmt.Errorf("failed to move %s of user %d: %w", t.name, duplicateID, err)
+			}
+			if moved > 0 {
+				report.Moved[t.name] = moved
+			}
+			if dropped > 0 {
+				report.Dropped[t.name] = dropped
+			}
+		}
+
+		if _, err := softDelete(tx, d.Bind, s.table(models.User{}), duplicateID); err != nil {
+			return err
+		}
+		return s.counter.add(tx, -1)
+	})
+	if err != nil {
+		return MergeReport{}, err
+	}
+	return report, nil
+}
+
+// repointRows moves the rows of t from user from to user to. For unique
+// tables rows the survivor already has a match for are deleted instead:
+// Postgres skips them with NOT EXISTS, MySQL, which can't read the table
+// it updates, with UPDATE IGNORE.
+func repointRows(ctx context.Context, q querier, d Dialect, t mergeTable, to, from int) (moved, dropped int64, err error) {
+	var res sql.Result
+	switch {
+	case !t.unique:
+		res, err = q.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET user_id = %s WHERE user_id = %s", t.name, d.Bind(1), d.Bind(2)), to, from)
+	case d.Name() == "mysql":
+		res, err = q.ExecContext(ctx, fmt.Sprintf("UPDATE IGNORE %s SET user_id = %s WHERE user_id = %s", t.name, d.Bind(1), d.Bind(2)), to, from)
+	default:
+		match := []string{"k.user_id = " + d.Bind(1)}
+		for _, col := range t.key {
+			match = append(match, fmt.Sprintf("k.%[1]s = %[2]s.%[1]s", col, t.name))
+		}
+		res, err = q.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE %[1]s SET user_id = %[2]s WHERE user_id = %[3]s AND NOT EXISTS (SELECT 1 FROM %[1]s k WHERE %[4]s)",
+			t.name, d.Bind(1), d.Bind(2), strings.Join(match, " AND ")), to, from)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if moved, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	if !t.unique {
+		return moved, 0, nil
+	}
+
+	res, err = q.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE user_id = %s", t.name, d.Bind(1)), from)
+	if err != nil {
+		return 0, 0, err
+	}
+	if dropped, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	return moved, dropped, nil
+}
@@ -1,57 +1,357 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"reflect"
 	"strings"
+
+	"project/locks"
 )
 
-func goTypeToPostgres(t reflect.Type) string {
+// goTypeToPostgres returns the PostgreSQL column type storing values of t,
+// or an error when there is none
+func goTypeToPostgres(t reflect.Type) (string, error) {
+	if t.Kind() == reflect.Pointer {
+		return goTypeToPostgres(t.Elem())
+	}
+	if t == timeType {
+		return "TIMESTAMPTZ", nil
+	}
+	if t == jsonMapType {
+		return "JSONB", nil
+	}
+	if inner, ok := nullTypes[t]; ok {
+		return goTypeToPostgres(inner)
+	}
+	if t == bytesType {
+		return "BYTEA", nil
+	}
+	if isArrayField(t) {
+		elem, err := goTypeToPostgres(t.Elem())
+		return elem + "[]", err
+	}
+
 	switch t.Kind() {
-	case reflect.Int, reflect.Int64:
-		return "BIGSERIAL"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "BIGINT", nil
+	case reflect.Int8, reflect.Int16:
+		return "SMALLINT", nil
+	case reflect.Float32, reflect.Float64:
+		return "DOUBLE PRECISION", nil
 	case reflect.String:
-		return "TEXT"
+		return "TEXT", nil
+	case reflect.Bool:
+		return "BOOLEAN", nil
 	default:
-		panic("unsupported type: " + t.String())
+		return "", fmt.Errorf("unsupported column type %s", t)
+	}
+}
+
+// Migrator generates and applies schema changes for models
+type Migrator struct {
+	db        *sql.DB
+	locker    MigrationLocker
+	naming    NamingStrategy
+	rls       bool
+	collation string
+	// driver names the database's driver, "postgres" when empty
+	driver string
+}
+
+// NewMigrator creates a new migrator for the given database. Migrations are
+// serialized across instances with a Postgres advisory lock by default.
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{
+		db:     db,
+		locker: PostgresAdvisoryLocker{Key: MigrationLockKey},
+	}
+}
+
+// WithDriver tells the migrator which driver db was opened with, e.g.
+// "mysql", so the DDL, the migration lock and InspectSchema match the
+// database. Call WithLocker or WithLock afterwards to lock otherwise.
+func (m *Migrator) WithDriver(driver string) *Migrator {
+	m.driver = driver
+	if driver == "mysql" {
+		m.locker = MySQLNamedLocker{Name: MigrationLockName, TimeoutSeconds: -1}
 	}
+	return m
 }
 
+// dialect returns the Dialect of the migrator's driver
+func (m *Migrator) dialect() Dialect {
+	if m.driver == "mysql" {
+		return MySQLDialect
+	}
+	return PostgresDialect
+}
+
+// WithLock serializes migrations with l instead, e.g. a locks.RedisLock
+// shared with instances on other databases
+func (m *Migrator) WithLock(l locks.Lock) *Migrator {
+	m.locker = distributedLocker{lock: l}
+	return m
+}
+
+// WithLocker replaces the lock used to serialize migrations, e.g. with a
+// MySQLNamedLocker that gives up after a timeout
+func (m *Migrator) WithLocker(locker MigrationLocker) *Migrator {
+	m.locker = locker
+	return m
+}
+
+// Plan returns the DDL statements AutoMigrate would execute for the given
+// models, in order, without running them. Tables referenced by foreign keys
+// are created before the tables referencing them.
+func (m *Migrator) Plan(models ...any) ([]string, error) {
+	models, err := sortByDependency(models, m.naming)
+	if err != nil {
+		return nil, err
+	}
+
+	// schemas of qualified tables are created first
+	var stmts []string
+	seen := make(map[string]bool)
+	for _, model := range models {
+		schema, _ := splitTable(m.naming.TableName(model))
+		if schema != "" && !seen[schema] {
+			seen[schema] = true
+			stmts = append(stmts, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", schema))
+		}
+	}
+	// the search vectors of new tables strip accents
+	for _, model := range models {
+		def, err := parseModel(model, m.naming)
+		if err != nil {
+			return nil, err
+		}
+		if len(def.searchColumns()) > 0 {
+			stmts = append(stmts, unaccentStatements...)
+			break
+		}
+	}
+	for _, model := range models {
+		stmt, err := createTableStatement(model, m.naming, m.dialect(), m.collation)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+
+		indexes, err := createIndexStatements(model, m.naming)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, indexes...)
+
+		if m.rls {
+			policies, err := rowLevelSecurityStatements(model, m.naming)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, policies...)
+		}
+	}
+	return stmts, nil
+}
+
+// sortByDependency orders models so every foreign key target comes before
+// the model referencing it, keeping the given order otherwise
+func sortByDependency(models []any, naming NamingStrategy) ([]any, error) {
+	defs := make(map[string]*modelDef)
+	byTable := make(map[string]any)
+	var tables []string
+	for _, model := range models {
+		def, err := parseModel(model, naming)
+		if err != nil {
+			return nil, err
+		}
+		defs[def.Table] = def
+		byTable[def.Table] = model
+		tables = append(tables, def.Table)
+	}
+
+	var sorted []any
+	state := make(map[string]int) // 1 visiting, 2 done
+	var visit func(table string) error
+	visit = func(table string) error {
+		switch state[table] {
+		case 1:
+			return fmt.Errorf("foreign key cycle involving %s", table)
+		case 2:
+			return nil
+		}
+		state[table] = 1
+		for _, col := range defs[table].Columns {
+			// references outside this batch or to itself are assumed to exist
+			if col.FK != nil && col.FK.RefTable != table && defs[col.FK.RefTable] != nil {
+				if err := visit(col.FK.RefTable); err != nil {
+					return err
+				}
+			}
+		}
+		state[table] = 2
+		sorted = append(sorted, byTable[table])
+		return nil
+	}
+
+	for _, table := range tables {
+		if err := visit(table); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// WithNaming sets the strategy used to derive table names from models
+func (m *Migrator) WithNaming(naming NamingStrategy) *Migrator {
+	m.naming = naming
+	return m
+}
+
+// AutoMigrate creates the tables for the given models if they don't exist
+func (m *Migrator) AutoMigrate(models ...any) error {
+	return m.AutoMigrateContext(context.Background(), models...)
+}
+
+// AutoMigrateContext is AutoMigrate stopping between statements when ctx
+// is done; the statements are idempotent, so a later run completes them
+func (m *Migrator) AutoMigrateContext(ctx context.Context, models ...any) error {
+	stmts, err := m.Plan(models...)
+	if err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		for _, stmt := range stmts {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("auto-migration stopped: %w", err)
+			}
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to execute migration: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// AutoMigrate creates the table for model if it doesn't exist
 func (p *PostgresRepo) AutoMigrate(model any) error {
-	t := reflect.TypeOf(model)
-	if t.Kind() != reflect.Struct {
-		return fmt.Errorf("model must be a struct")
+	return NewMigrator(p.db).AutoMigrate(model)
+}
+
+func createTableStatement(model any, naming NamingStrategy, d Dialect, collation string) (string, error) {
+	def, err := parseModel(model, naming)
+	if err != nil {
+		return "", err
 	}
 
-	table := strings.ToLower(t.Name()) + "s"
+	pk := def.primaryKey()
+
 	var columns []string
+	for _, col := range def.Columns {
+		sqlType, err := d.ColumnType(col.Type)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		collate, err := columnCollation(d, col, collation)
+		if err != nil {
+			return "", err
+		}
+		sqlType += collate
+		// a lone integer key is auto-increment unless tagged manual
+		if col.Primary && len(pk) == 1 {
+			sqlType = d.PrimaryKey(sqlType, def.isAutoIncrement(col))
+		}
 
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		tag := f.Tag.Get("db")
-		if tag == "" {
-			continue
+		column := col.Name + " " + sqlType
+		// nullability follows the field type alone: pointers and sql.Null*
+		// hold NULL, other fields can't, default or not
+		if !col.Nullable && !col.Primary {
+			column += " NOT NULL"
+		}
+		if col.Default != "" {
+			column += " DEFAULT " + col.Default
 		}
+		columns = append(columns, column)
+	}
 
-		parts := strings.Split(tag, ",")
-		col := parts[0]
+	if search := def.searchColumns(); len(search) > 0 {
+		columns = append(columns, searchVectorColumn+" "+searchVectorDefinition(search))
+	}
 
-		sqlType := goTypeToPostgres(f.Type)
-		def := col + " " + sqlType
+	if len(pk) > 1 {
+		names := make([]string, len(pk))
+		for i, col := range pk {
+			names[i] = col.Name
+		}
+		columns = append(columns, "PRIMARY KEY ("+strings.Join(names, ", ")+")")
+	}
 
-		if len(parts) > 1 && parts[1] == "primary" {
-			def += " PRIMARY KEY"
+	for _, col := range def.Columns {
+		if len(col.Enum) == 0 {
+			continue
 		}
+		columns = append(columns, fmt.Sprintf("CONSTRAINT %s CHECK (%s IN ('%s'))",
+			def.enumConstraintName(col.Name), col.Name, strings.Join(col.Enum, "', '")))
+	}
 
-		columns = append(columns, def)
+	for _, col := range def.Columns {
+		if col.FK == nil {
+			continue
+		}
+		fk := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+			def.foreignKeyName(col.Name), col.Name, col.FK.RefTable, col.FK.RefColumn)
+		if col.FK.OnDelete != "" {
+			fk += " ON DELETE " + col.FK.OnDelete
+		}
+		if col.FK.OnUpdate != "" {
+			fk += " ON UPDATE " + col.FK.OnUpdate
+		}
+		columns = append(columns, fk)
 	}
 
-	query := fmt.Sprintf(
+	return fmt.Sprintf(
 		"CREATE TABLE IF NOT EXISTS %s (%s);",
-		table,
+		def.Table,
 		strings.Join(columns, ", "),
-	)
+	), nil
+}
+
+func createIndexStatements(model any, naming NamingStrategy) ([]string, error) {
+	def, err := parseModel(model, naming)
+	if err != nil {
+		return nil, err
+	}
+
+	unique := make(map[string]bool)
+	for _, col := range def.Columns {
+		unique[col.Name] = col.Unique
+	}
 
-	_, err := p.db.Exec(query)
-	return err
+	var stmts []string
+	for _, col := range def.indexedColumns() {
+		kind := "INDEX"
+		if unique[col] {
+			kind = "UNIQUE INDEX"
+		}
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE %s IF NOT EXISTS %s ON %s (%s);",
+			kind,
+			def.indexName(col),
+			def.Table,
+			col,
+		))
+	}
+
+	if len(def.searchColumns()) > 0 {
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s);",
+			def.indexName(searchVectorColumn),
+			def.Table,
+			searchVectorColumn,
+		))
+	}
+	return stmts, nil
 }
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"project/locks"
+)
+
+// MigrationLockKey is the advisory lock key held while migrations run
+const MigrationLockKey int64 = 7231490641
+
+// MigrationLockName is the MySQL named lock held while migrations run
+const MigrationLockName = "adapter_migrations"
+
+// MigrationLocker serializes schema changes across concurrent instances.
+// Lock and Unlock are issued on the same connection the migration runs on,
+// since both Postgres advisory locks and MySQL named locks are session-scoped.
+type MigrationLocker interface {
+	Lock(ctx context.Context, conn *sql.Conn) error
+	Unlock(ctx context.Context, conn *sql.Conn) error
+}
+
+// PostgresAdvisoryLocker locks migrations with pg_advisory_lock
+type PostgresAdvisoryLocker struct {
+	Key int64
+}
+
+// Lock blocks until the advisory lock is acquired
+func (l PostgresAdvisoryLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", l.Key); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	return nil
+}
+
+// Unlock releases the advisory lock
+func (l PostgresAdvisoryLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.Key); err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+	return nil
+}
+
+// MySQLNamedLocker locks migrations with GET_LOCK
+type MySQLNamedLocker struct {
+	Name string
+	// TimeoutSeconds is how long GET_LOCK waits; negative waits forever
+	TimeoutSeconds int
+}
+
+// Lock waits up to TimeoutSeconds for the named lock
+func (l MySQLNamedLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	var got sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", l.Name, l.TimeoutSeconds).Scan(&got)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return fmt.Errorf("failed to acquire migration lock %q: timed out", l.Name)
+	}
+	return nil
+}
+
+// Unlock releases the named lock
+func (l MySQLNamedLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", l.Name); err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+	return nil
+}
+
+// distributedLocker adapts a locks.Lock, which holds its own connection,
+// to MigrationLocker
+type distributedLocker struct {
+	lock locks.Lock
+}
+
+// Lock blocks until the lock is acquired
+func (l distributedLocker) Lock(ctx context.Context, _ *sql.Conn) error {
+	if err := l.lock.Lock(ctx); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	return nil
+}
+
+// Unlock releases the lock
+func (l distributedLocker) Unlock(ctx context.Context, _ *sql.Conn) error {
+	if err := l.lock.Unlock(ctx); err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+	return nil
+}
+
+// withLock runs fn on a dedicated connection while holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.locker.Lock(ctx, conn); err != nil {
+		return err
+	}
+
+	fnErr := fn(conn)
+
+	// release with a fresh context so a cancelled ctx doesn't leak the lock
+	if err := m.locker.Unlock(context.Background(), conn); err != nil && fnErr == nil {
+		return err
+	}
+	return fnErr
+}
+
+// runLocked runs fn under l when it is free, or unconditionally when l is
+// nil, and reports whether fn ran
+func runLocked(ctx context.Context, l locks.Lock, fn func(ctx context.Context) error) (bool, error) {
+	if l == nil {
+		return true, fn(ctx)
+	}
+	return locks.Run(ctx, l, fn)
+}
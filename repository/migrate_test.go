@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateTableStatementDialects(t *testing.T) {
+	type widget struct {
+		ID      int       `db:"id,primary"`
+		Name    string    `db:"name,collate"`
+		Weight  float64   `db:"weight"`
+		Rank    int16     `db:"rank"`
+		Blob    []byte    `db:"blob"`
+		Tags    []string  `db:"tags"`
+		Created time.Time `db:"created"`
+		Deleted *time.Time
+	}
+	type manual struct {
+		Code string `db:"code,primary"`
+	}
+	naming := NamingStrategy{}
+
+	tests := []struct {
+		name    string
+		model   any
+		dialect Dialect
+		want    string
+	}{
+		{
+			name: "postgres", model: widget{}, dialect: PostgresDialect,
+			want: `CREATE TABLE IF NOT EXISTS widgets (id BIGSERIAL PRIMARY KEY, name TEXT COLLATE "und-x-icu" NOT NULL, ` +
+				`weight DOUBLE PRECISION NOT NULL, rank SMALLINT NOT NULL, blob BYTEA NOT NULL, tags TEXT[] NOT NULL, ` +
+				`created TIMESTAMPTZ NOT NULL, deleted TIMESTAMPTZ);`,
+		},
+		{
+			name: "mysql", model: widget{}, dialect: MySQLDialect,
+			want: "CREATE TABLE IF NOT EXISTS widgets (id BIGINT AUTO_INCREMENT PRIMARY KEY, name VARCHAR(255) COLLATE `und-x-icu` NOT NULL, " +
+				"weight DOUBLE NOT NULL, rank SMALLINT NOT NULL, blob LONGBLOB NOT NULL, tags JSON NOT NULL, " +
+				"created DATETIME(6) NOT NULL, deleted DATETIME(6));",
+		},
+		{
+			name: "postgres string key", model: manual{}, dialect: PostgresDialect,
+			want: "CREATE TABLE IF NOT EXISTS manuals (code TEXT PRIMARY KEY);",
+		},
+		{
+			name: "mysql string key", model: manual{}, dialect: MySQLDialect,
+			want: "CREATE TABLE IF NOT EXISTS manuals (code VARCHAR(255) PRIMARY KEY);",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createTableStatement(tt.model, naming, tt.dialect, "und-x-icu")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("createTableStatement =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigratorWithDriverLocker(t *testing.T) {
+	tests := []struct {
+		driver string
+		want   MigrationLocker
+	}{
+		{driver: "", want: PostgresAdvisoryLocker{Key: MigrationLockKey}},
+		{driver: "postgres", want: PostgresAdvisoryLocker{Key: MigrationLockKey}},
+		{driver: "mysql", want: MySQLNamedLocker{Name: MigrationLockName, TimeoutSeconds: -1}},
+	}
+
+	for _, tt := range tests {
+		if got := NewMigrator(nil).WithDriver(tt.driver).locker; got != tt.want {
+			t.Errorf("WithDriver(%q) locks with %#v, want %#v", tt.driver, got, tt.want)
+		}
+	}
+}
+
+func TestCreateIndexStatementsUnique(t *testing.T) {
+	type key struct {
+		ID     int    `db:"id,primary"`
+		UserID int    `db:"user_id,fk=users.id"`
+		Name   string `db:"name,index"`
+		Prefix string `db:"prefix,unique"`
+	}
+	got, err := createIndexStatements(key{}, NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CREATE INDEX IF NOT EXISTS keys_user_id_idx ON keys (user_id);",
+		"CREATE INDEX IF NOT EXISTS keys_name_idx ON keys (name);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS keys_prefix_idx ON keys (prefix);",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("createIndexStatements =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
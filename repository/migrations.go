@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a single versioned SQL migration
+type Migration struct {
+	Version int64
+	Name    string
+	SQL     string
+	// File is the name the migration was loaded from
+	File string
+}
+
+// LoadMigrations reads migrations named <version>_<name>.sql from the root of
+// fsys, sorted by version. Works with embed.FS, os.DirFS, or any fs.FS.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %q: missing version prefix", e.Name())
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %q and %q", version, other, e.Name())
+		}
+		seen[version] = e.Name()
+
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", e.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(body), File: e.Name()})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// PendingMigrations returns the migrations in fsys not yet recorded as applied
+func (m *Migrator) PendingMigrations(fsys fs.FS) ([]Migration, error) {
+	return m.PendingMigrationsContext(context.Background(), fsys)
+}
+
+// PendingMigrationsContext is PendingMigrations bounded by ctx
+func (m *Migrator) PendingMigrationsContext(ctx context.Context, fsys fs.FS) ([]Migration, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range migrations {
+		if !applied[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies all pending migrations from fsys, each in its own
+// transaction, while holding the migration lock
+func (m *Migrator) Migrate(fsys fs.FS) error {
+	return m.MigrateContext(context.Background(), fsys)
+}
+
+// MigrateContext is Migrate stopping when ctx is done: the migration
+// running then is rolled back and the following ones aren't started, so a
+// later run resumes with it
+func (m *Migrator) MigrateContext(ctx context.Context, fsys fs.FS) error {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+			return fmt.Errorf("failed to create migrations table: %w", err)
+		}
+
+		// re-read under the lock, another instance may have just migrated
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			if applied[mig.Version] {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("migrations stopped before %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if err := applyMigration(ctx, conn, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// querier is satisfied by *sql.DB, *sql.Conn and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func appliedVersions(ctx context.Context, q querier) (map[int64]bool, error) {
+	var exists int
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'schema_migrations'",
+	).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+
+	applied := make(map[int64]bool)
+	if exists == 0 {
+		return applied, nil
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %w", err)
+		}
+		applied[v] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return applied, nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", mig.Version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	// version is an integer parsed from the file name, safe to inline and
+	// keeps the statement portable across placeholder styles
+	record := fmt.Sprintf("INSERT INTO schema_migrations (version) VALUES (%d)", mig.Version)
+	if _, err := tx.ExecContext(ctx, record); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", mig.Version, err)
+	}
+	return nil
+}
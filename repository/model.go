@@ -0,0 +1,502 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"project/apperr"
+	"project/models"
+)
+
+// modelDef is the table definition derived from a model struct
+type modelDef struct {
+	Table   string
+	Columns []columnDef
+	naming  NamingStrategy
+}
+
+// indexName returns the name of the single-column index AutoMigrate creates
+// for a column
+func (d *modelDef) indexName(column string) string {
+	return d.naming.indexName(bareTable(d.Table), column)
+}
+
+// enumConstraintName returns the name of the CHECK constraint AutoMigrate
+// creates for an enum column
+func (d *modelDef) enumConstraintName(column string) string {
+	return d.naming.constraintName(bareTable(d.Table), "check", column)
+}
+
+// foreignKeyName returns the name of the FOREIGN KEY constraint AutoMigrate
+// creates for a column tagged fk
+func (d *modelDef) foreignKeyName(column string) string {
+	return d.naming.constraintName(bareTable(d.Table), "fkey", column)
+}
+
+// indexedColumns returns the columns AutoMigrate creates a single-column
+// index for: those tagged index or unique, and foreign keys no other index leads
+// with, which PostgreSQL doesn't index on its own but joins and cascading
+// deletes need
+func (d *modelDef) indexedColumns() []string {
+	var leading string
+	if pk := d.primaryKey(); len(pk) > 0 {
+		leading = pk[0].Name
+	}
+
+	var cols []string
+	for _, col := range d.Columns {
+		if col.Indexed || (col.FK != nil && col.Name != leading) {
+			cols = append(cols, col.Name)
+		}
+	}
+	return cols
+}
+
+// columnDef is a single mapped struct field
+type columnDef struct {
+	Name    string
+	Field   string
+	Index   []int
+	Type    reflect.Type
+	SQLType string
+	Primary bool
+	Manual  bool
+	Default string
+	Indexed bool
+	// Unique makes the column's index unique, from the unique tag option
+	Unique bool
+	Search bool
+	Tenant bool
+	// Nullable is set for pointer and sql.Null* fields, whose NULLs scan
+	// into nil or an invalid Null* value
+	Nullable bool
+	// Enum lists the allowed values of a string column, from the enum tag
+	// option, e.g. `db:"status,enum=active|suspended"`
+	Enum []string
+	FK   *foreignKey
+	// Collate is set by the collate tag option, giving the column the
+	// migrator's collation, or Collation when the option has a value, e.g.
+	// `db:"name,collate=de-x-icu"`
+	Collate   bool
+	Collation string
+}
+
+// foreignKey is a FOREIGN KEY declared with the fk tag option, e.g.
+// `db:"user_id,fk=users.id,ondelete=cascade"`
+type foreignKey struct {
+	RefTable  string
+	RefColumn string
+	OnDelete  string
+	OnUpdate  string
+}
+
+// referentialActions maps ondelete/onupdate tag values to SQL actions
+var referentialActions = map[string]string{
+	"cascade":    "CASCADE",
+	"restrict":   "RESTRICT",
+	"setnull":    "SET NULL",
+	"setdefault": "SET DEFAULT",
+	"noaction":   "NO ACTION",
+}
+
+// primaryKey returns the columns tagged primary, in declaration order
+func (d *modelDef) primaryKey() []columnDef {
+	var pk []columnDef
+	for _, col := range d.Columns {
+		if col.Primary {
+			pk = append(pk, col)
+		}
+	}
+	return pk
+}
+
+// searchColumns returns the columns tagged search, which feed the generated
+// full-text search vector
+func (d *modelDef) searchColumns() []columnDef {
+	var cols []columnDef
+	for _, col := range d.Columns {
+		if col.Search {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// softDeleteColumn marks rows as deleted without removing them. Models with
+// this column are hidden from reads once it is set.
+const softDeleteColumn = "deleted_at"
+
+// softDelete reports whether the model is soft-deleted via deleted_at
+func (d *modelDef) softDelete() bool {
+	for _, col := range d.Columns {
+		if col.Name == softDeleteColumn {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantColumn returns the column tagged tenant, or "" if none
+func (d *modelDef) tenantColumn() string {
+	for _, col := range d.Columns {
+		if col.Tenant {
+			return col.Name
+		}
+	}
+	return ""
+}
+
+// knownTagOptions are the options accepted after the column name in a db tag
+var knownTagOptions = map[string]bool{
+	"primary":  true,
+	"manual":   true,
+	"default":  true,
+	"index":    true,
+	"unique":   true,
+	"search":   true,
+	"tenant":   true,
+	"enum":     true,
+	"fk":       true,
+	"ondelete": true,
+	"onupdate": true,
+	"collate":  true,
+}
+
+// parseModel reads the fields of a model struct into a table definition.
+// Exported fields map to their snake_case name unless a db tag overrides it;
+// `db:"-"` skips the field. Fields of embedded structs are flattened into the
+// parent table.
+func parseModel(model any, naming NamingStrategy) (*modelDef, error) {
+	t := indirectType(model)
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model must be a struct")
+	}
+	if err := validateModelType(t); err != nil {
+		return nil, err
+	}
+
+	def := &modelDef{Table: naming.TableName(model), naming: naming}
+	// table names are spliced into DDL and queries unquoted
+	if !validTable(def.Table) {
+		return nil, fmt.Errorf("table name %q must be lower snake_case, optionally schema-qualified", def.Table)
+	}
+
+	walkFields(t, nil, func(f reflect.StructField, index []int) {
+		name, opts, ok := columnTag(f)
+		if !ok {
+			return
+		}
+
+		// validateModelType rejected the types with no column type
+		sqlType, _ := goTypeToPostgres(f.Type)
+		col := columnDef{
+			Name:    name,
+			Field:   f.Name,
+			Index:   index,
+			Type:    f.Type,
+			SQLType: sqlType,
+
+			Nullable: isNullable(f.Type),
+		}
+
+		var fk foreignKey
+		for _, opt := range opts {
+			key, value, _ := strings.Cut(opt, "=")
+			switch key {
+			case "primary":
+				col.Primary = true
+			case "manual":
+				col.Manual = true
+			case "default":
+				col.Default = value
+			case "index":
+				col.Indexed = true
+			case "unique":
+				col.Indexed, col.Unique = true, true
+			case "search":
+				col.Search = true
+			case "tenant":
+				col.Tenant = true
+			case "enum":
+				col.Enum = strings.Split(value, "|")
+			case "collate":
+				col.Collate, col.Collation = true, value
+			case "fk":
+				fk.RefTable, fk.RefColumn = splitTable(value)
+			case "ondelete":
+				fk.OnDelete = referentialActions[value]
+			case "onupdate":
+				fk.OnUpdate = referentialActions[value]
+			}
+		}
+		if fk.RefTable != "" {
+			col.FK = &fk
+		}
+
+		def.Columns = append(def.Columns, col)
+	})
+
+	return def, nil
+}
+
+// walkFields calls fn for every field of t, descending into embedded
+// structs so their promoted fields are visited in declaration order
+func walkFields(t reflect.Type, parent []int, fn func(f reflect.StructField, index []int)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(append([]int(nil), parent...), i)
+
+		if isEmbeddedModel(f) {
+			walkFields(f.Type, index, fn)
+			continue
+		}
+		fn(f, index)
+	}
+}
+
+// isEmbeddedModel reports whether f is an embedded struct to be flattened
+func isEmbeddedModel(f reflect.StructField) bool {
+	if !f.Anonymous || f.Type.Kind() != reflect.Struct || f.Type == timeType {
+		return false
+	}
+	tag, _ := f.Tag.Lookup("db")
+	return tag != "-"
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	jsonMapType = reflect.TypeOf(models.JSONMap{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+)
+
+// nullTypes maps the database/sql Null* wrappers to the type they hold;
+// their columns are always nullable
+var nullTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(sql.NullString{}): reflect.TypeOf(""),
+	reflect.TypeOf(sql.NullInt64{}):  reflect.TypeOf(int64(0)),
+	reflect.TypeOf(sql.NullInt32{}):  reflect.TypeOf(int32(0)),
+	reflect.TypeOf(sql.NullBool{}):   reflect.TypeOf(false),
+	reflect.TypeOf(sql.NullTime{}):   timeType,
+}
+
+// isNullable reports whether fields of type t hold NULL: pointers, where
+// nil is NULL, and the sql.Null* wrappers
+func isNullable(t reflect.Type) bool {
+	_, null := nullTypes[t]
+	return null || t.Kind() == reflect.Pointer
+}
+
+// columnTag returns the column name and options for a struct field, and
+// false if the field isn't mapped to a column
+func columnTag(f reflect.StructField) (string, []string, bool) {
+	if !f.IsExported() {
+		return "", nil, false
+	}
+
+	tag, _ := f.Tag.Lookup("db")
+	if tag == "-" {
+		return "", nil, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		name = toSnakeCase(f.Name)
+	}
+
+	var opts []string
+	for _, p := range parts[1:] {
+		if p = strings.TrimSpace(p); p != "" {
+			opts = append(opts, p)
+		}
+	}
+	return name, opts, true
+}
+
+// ValidateModels checks the struct tags and field types of each model,
+// reporting every malformed tag, unknown db tag option, field type with no
+// column type and duplicate column at once. Call it at startup so mistakes
+// fail fast instead of silently dropping columns or failing migrations.
+func ValidateModels(models ...any) error {
+	var errs []error
+	for _, model := range models {
+		t := indirectType(model)
+		if t.Kind() != reflect.Struct {
+			errs = append(errs, fmt.Errorf("%s: model must be a struct", t))
+			continue
+		}
+		if err := validateModelType(t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateModelType(t reflect.Type) error {
+	var errs []error
+	columns := make(map[string]string)
+
+	walkFields(t, nil, func(f reflect.StructField, _ []int) {
+		if err := validateStructTag(string(f.Tag)); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: malformed struct tag `%s`: %w", t.Name(), f.Name, f.Tag, err))
+			return
+		}
+
+		if f.Anonymous && f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct {
+			errs = append(errs, fmt.Errorf("%s.%s: embedded struct pointers are not supported, embed by value", t.Name(), f.Name))
+			return
+		}
+
+		name, opts, ok := columnTag(f)
+		if !ok {
+			return
+		}
+		// column names are spliced into DDL and queries unquoted
+		if !identifierPattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("%s.%s: column name %q must be lower snake_case", t.Name(), f.Name, name))
+		}
+		if _, err := goTypeToPostgres(f.Type); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err))
+		}
+
+		hasFK := false
+		for _, opt := range opts {
+			key, value, _ := strings.Cut(opt, "=")
+			if !knownTagOptions[key] {
+				errs = append(errs, fmt.Errorf("%s.%s: unknown db tag option %q", t.Name(), f.Name, opt))
+				continue
+			}
+			switch key {
+			case "fk":
+				hasFK = true
+				table, column := splitTable(value)
+				if !validTable(table) || !identifierPattern.MatchString(column) {
+					errs = append(errs, fmt.Errorf("%s.%s: fk must be table.column or schema.table.column, got %q", t.Name(), f.Name, value))
+				}
+			case "enum":
+				if err := validateEnum(f.Type, value); err != nil {
+					errs = append(errs, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err))
+				}
+			case "collate":
+				if ft := f.Type; ft.Kind() != reflect.String && (ft.Kind() != reflect.Pointer || ft.Elem().Kind() != reflect.String) {
+					errs = append(errs, fmt.Errorf("%s.%s: collate requires a string field, got %s", t.Name(), f.Name, f.Type))
+				} else if value != "" && !collationPattern.MatchString(value) {
+					errs = append(errs, fmt.Errorf("%s.%s: invalid collation %q", t.Name(), f.Name, value))
+				}
+			case "ondelete", "onupdate":
+				if _, ok := referentialActions[value]; !ok {
+					errs = append(errs, fmt.Errorf("%s.%s: unknown %s action %q", t.Name(), f.Name, key, value))
+				}
+			}
+		}
+		for _, opt := range opts {
+			if key, _, _ := strings.Cut(opt, "="); (key == "ondelete" || key == "onupdate") && !hasFK {
+				errs = append(errs, fmt.Errorf("%s.%s: %s requires fk", t.Name(), f.Name, key))
+			}
+		}
+
+		if other, dup := columns[name]; dup {
+			errs = append(errs, fmt.Errorf("%s.%s: column %q already mapped by %s", t.Name(), f.Name, name, other))
+		}
+		columns[name] = f.Name
+	})
+
+	return errors.Join(errs...)
+}
+
+// enumValuePattern matches the values allowed in an enum tag option, which
+// are spliced into CHECK constraints
+var enumValuePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// validateEnum checks an enum tag option on a field of type t
+func validateEnum(t reflect.Type, value string) error {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.String {
+		return fmt.Errorf("enum requires a string field, got %s", t)
+	}
+	for _, v := range strings.Split(value, "|") {
+		if !enumValuePattern.MatchString(v) {
+			return fmt.Errorf("invalid enum value %q", v)
+		}
+	}
+	return nil
+}
+
+// checkEnum returns an InvalidArgument error if field holds a value col's
+// enum doesn't allow; nil pointers are NULL and always allowed
+func checkEnum(table string, col columnDef, field reflect.Value) error {
+	if len(col.Enum) == 0 {
+		return nil
+	}
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	if v := field.String(); !slices.Contains(col.Enum, v) {
+		return apperr.New(apperr.InvalidArgument, fmt.Sprintf("%s.%s must be one of %s, got %q",
+			table, col.Name, strings.Join(col.Enum, ", "), v))
+	}
+	return nil
+}
+
+// validateStructTag checks tag follows the conventional key:"value" syntax,
+// mirroring the go vet structtag check
+func validateStructTag(tag string) error {
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		if tag == "" {
+			break
+		}
+
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 {
+			return errors.New("bad syntax for struct tag key")
+		}
+		if i+1 >= len(tag) || tag[i] != ':' {
+			return errors.New("bad syntax for struct tag pair")
+		}
+		if tag[i+1] != '"' {
+			return errors.New("bad syntax for struct tag value")
+		}
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return errors.New("bad syntax for struct tag value")
+		}
+		if _, err := strconv.Unquote(tag[:i+1]); err != nil {
+			return errors.New("bad syntax for struct tag value")
+		}
+		tag = tag[i+1:]
+	}
+	return nil
+}
+
+// indirectType returns the struct type of a model passed by value or pointer
+func indirectType(model any) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
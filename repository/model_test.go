@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+// FuzzColumnTag checks that a db tag ValidateModels accepts only puts
+// identifiers where AutoMigrate and the queries splice names unquoted, and
+// that no tag panics the parser
+func FuzzColumnTag(f *testing.F) {
+	for _, seed := range []string{
+		`db:"name"`,
+		`db:"-"`,
+		`db:""`,
+		`db:",primary"`,
+		`db:"id,primary,manual"`,
+		`db:"email,default='',index"`,
+		`db:"prefix,unique"`,
+		`db:"tags,default='{}'"`,
+		`db:"name,search,collate"`,
+		`db:"name,collate=de-x-icu"`,
+		`db:"name,collate=de\"; DROP"`,
+		`db:"status,enum=active|disabled"`,
+		`db:"user_id,fk=users.id,ondelete=cascade"`,
+		`db:"user_id,fk=auth.users.id,onupdate=restrict"`,
+		`db:"user_id,fk=users"`,
+		`db:"user_id,ondelete=cascade"`,
+		`db:"Name"`,
+		`db:"name,unknown"`,
+		`json:"name" db:"name" validate:"required"`,
+		`db:"name`,
+		`db`,
+		"db:\"na\\\"me\"",
+	} {
+		f.Add(seed)
+	}
+
+	naming := NamingStrategy{SingularTable: true, TablePrefix: "fuzz"}
+	f.Fuzz(func(t *testing.T, tag string) {
+		typ := reflect.StructOf([]reflect.StructField{{Name: "Field", Type: reflect.TypeOf(""), Tag: reflect.StructTag(tag)}})
+		model := reflect.New(typ).Elem().Interface()
+
+		if err := ValidateModels(model); err != nil {
+			return
+		}
+		def, err := parseModel(model, naming)
+		if err != nil {
+			t.Fatalf("parseModel rejected tag %q that ValidateModels accepted: %v", tag, err)
+		}
+		for _, col := range def.Columns {
+			if !identifierPattern.MatchString(col.Name) {
+				t.Fatalf("tag %q gave column %q", tag, col.Name)
+			}
+			if col.Collation != "" && !collationPattern.MatchString(col.Collation) {
+				t.Fatalf("tag %q gave collation %q", tag, col.Collation)
+			}
+			if fk := col.FK; fk != nil && (!validTable(fk.RefTable) || !identifierPattern.MatchString(fk.RefColumn)) {
+				t.Fatalf("tag %q gave foreign key %s.%s", tag, fk.RefTable, fk.RefColumn)
+			}
+		}
+		if _, err := createTableStatement(model, naming, PostgresDialect, ""); err != nil {
+			t.Fatalf("createTableStatement of tag %q: %v", tag, err)
+		}
+		if _, err := createIndexStatements(model, naming); err != nil {
+			t.Fatalf("createIndexStatements of tag %q: %v", tag, err)
+		}
+	})
+}
+
+func TestValidateModelsFieldTypes(t *testing.T) {
+	type nested struct{ A int }
+	tests := []struct {
+		name    string
+		model   any
+		wantErr bool
+		// want is the column type of Field when the model is valid
+		want string
+	}{
+		{name: "int", model: struct{ Field int }{}, want: "BIGINT"},
+		{name: "int16", model: struct{ Field int16 }{}, want: "SMALLINT"},
+		{name: "int8 pointer", model: struct{ Field *int8 }{}, want: "SMALLINT"},
+		{name: "float32", model: struct{ Field float32 }{}, want: "DOUBLE PRECISION"},
+		{name: "float64", model: struct{ Field float64 }{}, want: "DOUBLE PRECISION"},
+		{name: "bytes", model: struct{ Field []byte }{}, want: "BYTEA"},
+		{name: "string array", model: struct{ Field []string }{}, want: "TEXT[]"},
+		{name: "uint", model: struct{ Field uint }{}, wantErr: true},
+		{name: "uint64", model: struct{ Field uint64 }{}, wantErr: true},
+		{name: "nested struct", model: struct{ Field nested }{}, wantErr: true},
+		{name: "float array", model: struct{ Field []float64 }{}, wantErr: true},
+		{name: "map", model: struct{ Field map[string]int }{}, wantErr: true},
+		{name: "unsupported but skipped", model: struct {
+			Field string
+			Skip  nested `db:"-"`
+		}{}, want: "TEXT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateModels(tt.model)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ValidateModels accepted a field with no column type")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateModels: %v", err)
+			}
+			def, err := parseModel(tt.model, NamingStrategy{TablePrefix: "t"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := def.Columns[0].SQLType; got != tt.want {
+				t.Errorf("column type = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"project/models"
+)
+
+// Tabler is implemented by models that choose their own table name
+type Tabler interface {
+	TableName() string
+}
+
+// NamingStrategy derives table names from model type names, and the names
+// of the indexes and constraints AutoMigrate creates. The zero value maps a
+// type name to its pluralized snake_case form, e.g. UserSetting to
+// user_settings and Person to people, and names indexes and constraints the
+// way PostgreSQL does, e.g. users_email_idx and posts_user_id_fkey.
+type NamingStrategy struct {
+	// Schema qualifies derived table names, e.g. "auth" maps User to
+	// auth.users; Tabler models qualify their own names the same way
+	Schema string
+	// TablePrefix is prepended to derived table names, e.g. "app_"
+	TablePrefix string
+	// SingularTable disables pluralization
+	SingularTable bool
+	// Pluralize overrides the built-in English pluralizer
+	Pluralize func(word string) string
+	// IndexName overrides the names of generated indexes
+	IndexName func(table string, columns ...string) string
+	// ConstraintName overrides the names of generated constraints; kind is
+	// "fkey" or "check"
+	ConstraintName func(table, kind string, columns ...string) string
+}
+
+// TableName returns the table for a model, honoring Tabler implementations
+// on either receiver
+func (n NamingStrategy) TableName(model any) string {
+	if t, ok := asTabler(model); ok {
+		return t.TableName()
+	}
+	typeName := indirectType(model).Name()
+
+	name := toSnakeCase(typeName)
+	if !n.SingularTable {
+		// pluralize only the last word: user_setting -> user_settings
+		head, last := "", name
+		if i := strings.LastIndex(name, "_"); i >= 0 {
+			head, last = name[:i+1], name[i+1:]
+		}
+		pluralize := n.Pluralize
+		if pluralize == nil {
+			pluralize = Pluralize
+		}
+		name = head + pluralize(last)
+	}
+	if n.Schema != "" {
+		return n.Schema + "." + n.TablePrefix + name
+	}
+	return n.TablePrefix + name
+}
+
+// SetNaming names the model tables the hand-written queries use by naming,
+// which should be the strategy AutoMigrate created them with
+func (s *SQLRepo) SetNaming(naming NamingStrategy) {
+	s.naming = naming
+}
+
+// Naming returns the strategy the model tables are named by
+func (s *SQLRepo) Naming() NamingStrategy {
+	return s.naming
+}
+
+// table returns the possibly schema-qualified table of model
+func (s *SQLRepo) table(model any) string {
+	return s.naming.TableName(model)
+}
+
+// usersCopy returns the table keeping copies of users rows, e.g.
+// users_history for suffix "_history", in the schema of the users table
+func (n NamingStrategy) usersCopy(suffix string) string {
+	return n.TableName(models.User{}) + suffix
+}
+
+var tablerType = reflect.TypeOf((*Tabler)(nil)).Elem()
+
+// asTabler returns model as a Tabler, also when it is a value whose
+// TableName is declared on the pointer receiver
+func asTabler(model any) (Tabler, bool) {
+	if t, ok := model.(Tabler); ok {
+		return t, true
+	}
+	v := reflect.ValueOf(model)
+	if !v.IsValid() || v.Kind() == reflect.Pointer || !reflect.PointerTo(v.Type()).Implements(tablerType) {
+		return nil, false
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface().(Tabler), true
+}
+
+// splitTable splits a possibly schema-qualified table name, e.g.
+// auth.users, into its schema, empty when unqualified, and bare name
+func splitTable(table string) (schema, name string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// validTable reports whether table is a table name, optionally
+// schema-qualified, safe to splice into SQL
+func validTable(table string) bool {
+	schema, name := splitTable(table)
+	if schema != "" && !identifierPattern.MatchString(schema) {
+		return false
+	}
+	return identifierPattern.MatchString(name)
+}
+
+// bareTable returns table without its schema, as index and constraint
+// names take it since they live in their table's schema
+func bareTable(table string) string {
+	_, name := splitTable(table)
+	return name
+}
+
+// indexName returns the name of a generated index on columns of table
+func (n NamingStrategy) indexName(table string, columns ...string) string {
+	if n.IndexName != nil {
+		return n.IndexName(table, columns...)
+	}
+	return table + "_" + strings.Join(columns, "_") + "_idx"
+}
+
+// constraintName returns the name of a generated constraint on columns of
+// table
+func (n NamingStrategy) constraintName(table, kind string, columns ...string) string {
+	if n.ConstraintName != nil {
+		return n.ConstraintName(table, kind, columns...)
+	}
+	return table + "_" + strings.Join(columns, "_") + "_" + kind
+}
+
+var irregularPlurals = map[string]string{
+	"person": "people",
+	"child":  "children",
+	"man":    "men",
+	"woman":  "women",
+	"mouse":  "mice",
+	"goose":  "geese",
+	"tooth":  "teeth",
+	"foot":   "feet",
+	"datum":  "data",
+}
+
+var uncountable = map[string]bool{
+	"data":      true,
+	"metadata":  true,
+	"info":      true,
+	"news":      true,
+	"series":    true,
+	"species":   true,
+	"settings":  true,
+	"equipment": true,
+}
+
+// Pluralize returns the English plural of a lowercase word
+func Pluralize(word string) string {
+	if word == "" || uncountable[word] {
+		return word
+	}
+	if p, ok := irregularPlurals[word]; ok {
+		return p
+	}
+
+	switch {
+	case strings.HasSuffix(word, "s"), strings.HasSuffix(word, "x"),
+		strings.HasSuffix(word, "z"), strings.HasSuffix(word, "ch"),
+		strings.HasSuffix(word, "sh"):
+		return word + "es"
+	case strings.HasSuffix(word, "y") && len(word) > 1 && !strings.ContainsRune("aeiou", rune(word[len(word)-2])):
+		return word[:len(word)-1] + "ies"
+	default:
+		return word + "s"
+	}
+}
+
+// toSnakeCase converts a Go identifier to snake_case, keeping acronyms
+// together: UserID -> user_id, APIKey -> api_key
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
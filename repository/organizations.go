@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"project/models"
+)
+
+// OrganizationRepository is implemented by adapters that store
+// organizations and their memberships. Its methods join the transaction
+// carried by ctx, so an organization and its first members can be created
+// atomically with WithTransaction.
+type OrganizationRepository interface {
+	CreateOrganization(ctx context.Context, org models.Organization) (models.Organization, error)
+	GetOrganization(ctx context.Context, id int) (models.Organization, error)
+	// DeleteOrganization deletes an organization and its memberships
+	DeleteOrganization(ctx context.Context, id int) error
+	AddMember(ctx context.Context, m models.Membership) error
+	RemoveMember(ctx context.Context, orgID, userID int) error
+	// ListMembers returns the memberships of an organization with User
+	// populated, ordered by user name; soft-deleted users are left out
+	ListMembers(ctx context.Context, orgID int) ([]models.Membership, error)
+	// ListMemberships returns the memberships of a user
+	ListMemberships(ctx context.Context, userID int) ([]models.Membership, error)
+}
+
+// insertID runs an INSERT and returns the generated id of the new row
+type insertID func(ctx context.Context, db querier, query string, args ...any) (int64, error)
+
+// postgresInsertID reads the generated id with RETURNING
+func postgresInsertID(ctx context.Context, db querier, query string, args ...any) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
+// mysqlInsertID reads the generated id with LAST_INSERT_ID()
+func mysqlInsertID(ctx context.Context, db querier, query string, args ...any) (int64, error) {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// CreateOrganization inserts an organization and returns it as stored
+func (s *SQLRepo) CreateOrganization(ctx context.Context, org models.Organization) (models.Organization, error) {
+	return createOrganization(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.Organization{}), s.dialect.InsertID, org)
+}
+
+// GetOrganization retrieves an organization, returning ErrNotFound if none exists
+func (s *SQLRepo) GetOrganization(ctx context.Context, id int) (models.Organization, error) {
+	return getOrganization(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.Organization{}), id)
+}
+
+// DeleteOrganization deletes an organization; memberships cascade
+func (s *SQLRepo) DeleteOrganization(ctx context.Context, id int) error {
+	return deleteOrganization(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.Organization{}), id)
+}
+
+// AddMember adds a user to an organization
+func (s *SQLRepo) AddMember(ctx context.Context, m models.Membership) error {
+	return addMember(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.Membership{}), m)
+}
+
+// RemoveMember removes a user from an organization
+func (s *SQLRepo) RemoveMember(ctx context.Context, orgID, userID int) error {
+	return removeMember(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.Membership{}), orgID, userID)
+}
+
+// ListMembers retrieves the members of an organization
+func (s *SQLRepo) ListMembers(ctx context.Context, orgID int) ([]models.Membership, error) {
+	return listMembers(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.Membership{}), s.table(models.User{}), orgID)
+}
+
+// ListMemberships retrieves the organizations a user belongs to
+func (s *SQLRepo) ListMemberships(ctx context.Context, userID int) ([]models.Membership, error) {
+	return listMemberships(ctx, dbFrom(ctx, s.db), s.dialect.Bind, s.table(models.Membership{}), userID)
+}
+
+func createOrganization(ctx context.Context, db querier, bind func(int) string, table string, insert insertID, org models.Organization) (models.Organization, error) {
+	id, err := insert(ctx, db,
+		fmt.Sprintf("INSERT INTO %s (name, tenant_id) VALUES (%s, %s)", table, bind(1), bind(2)),
+		org.Name, org.TenantID,
+	)
+	if err != nil {
+		return models.Organization{}, fmt.Errorf("failed to insert organization: %w", err)
+	}
+	return getOrganization(ctx, db, bind, table, int(id))
+}
+
+func getOrganization(ctx context.Context, db querier, bind func(int) string, table string, id int) (models.Organization, error) {
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf("SELECT id, created_at, updated_at, name, tenant_id FROM %s WHERE id = %s", table, bind(1)),
+		id,
+	)
+	if err != nil {
+		return models.Organization{}, fmt.Errorf("failed to get organization: %w", err)
+	}
+	defer rows.Close()
+
+	orgs, err := ScanAll[models.Organization](rows)
+	if err != nil {
+		return models.Organization{}, err
+	}
+	if len(orgs) == 0 {
+		return models.Organization{}, ErrNotFound
+	}
+	return orgs[0], nil
+}
+
+func addMember(ctx context.Context, db querier, bind func(int) string, table string, m models.Membership) error {
+	role := m.Role
+	if role == "" {
+		role = models.RoleMember
+	}
+
+	_, err := db.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (organization_id, user_id, role) VALUES (%s, %s, %s)", table, bind(1), bind(2), bind(3)),
+		m.OrganizationID, m.UserID, role,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return nil
+}
+
+func deleteOrganization(ctx context.Context, db querier, bind func(int) string, table string, id int) error {
+	res, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = %s", table, bind(1)), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	return requireRow(res)
+}
+
+func removeMember(ctx context.Context, db querier, bind func(int) string, table string, orgID, userID int) error {
+	res, err := db.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE organization_id = %s AND user_id = %s", table, bind(1), bind(2)),
+		orgID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	return requireRow(res)
+}
+
+func listMembers(ctx context.Context, db querier, bind func(int) string, memberships, users string, orgID int) ([]models.Membership, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT m.organization_id, m.user_id, m.role, m.created_at, u.name, u.email
+		 FROM %s m JOIN %s u ON u.id = m.user_id
+		 WHERE m.organization_id = %s AND u.deleted_at IS NULL
+		 ORDER BY u.name, u.id`, memberships, users, bind(1)),
+		orgID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []models.Membership
+	for rows.Next() {
+		var (
+			m models.Membership
+			u models.User
+		)
+		if err := rows.Scan(&m.OrganizationID, &m.UserID, &m.Role, &m.CreatedAt, &u.Name, &u.Email); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		u.ID = m.UserID
+		m.User = &u
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	return members, nil
+}
+
+func listMemberships(ctx context.Context, db querier, bind func(int) string, table string, userID int) ([]models.Membership, error) {
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf("SELECT organization_id, user_id, role, created_at FROM %s WHERE user_id = %s ORDER BY organization_id", table, bind(1)),
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memberships: %w", err)
+	}
+	defer rows.Close()
+
+	return ScanAll[models.Membership](rows)
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"project/labels"
+	"project/metrics"
+)
+
+// instrumentationName identifies this package to OpenTelemetry
+const instrumentationName = "project/repository"
+
+// NewOTelMiddleware returns a Middleware that traces every repository call
+// and records its duration and errors as OpenTelemetry metrics:
+// db.repository.duration (s) and db.repository.errors, both with a
+// db.repository.method attribute and the labels of the context, see Label.
+// Nil providers use the global ones.
+func NewOTelMiddleware(tp trace.TracerProvider, mp metric.MeterProvider) (Middleware, error) {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+
+	tracer := tp.Tracer(instrumentationName)
+	meter := mp.Meter(instrumentationName)
+
+	duration, err := meter.Float64Histogram("db.repository.duration",
+		metric.WithDescription("Duration of repository calls"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create duration histogram: %w", err)
+	}
+
+	failures, err := meter.Int64Counter("db.repository.errors",
+		metric.WithDescription("Repository calls that returned an error"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create error counter: %w", err)
+	}
+
+	return func(ctx context.Context, method string, next func(ctx context.Context) error) error {
+		tags := labels.FromContext(ctx).Attributes()
+		attrs := metric.WithAttributes(append(tags, attribute.String("db.repository.method", method))...)
+
+		ctx, span := tracer.Start(ctx, "repository."+method, trace.WithSpanKind(trace.SpanKindClient))
+		defer span.End()
+		span.SetAttributes(SessionFromContext(ctx).Attributes()...)
+		span.SetAttributes(tags...)
+
+		start := time.Now()
+		err := next(ctx)
+		duration.Record(ctx, time.Since(start).Seconds(), attrs)
+
+		// A missing row is an answer, not a failure
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			failures.Add(ctx, 1, attrs)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}, nil
+}
+
+// Label returns a Middleware tagging the calls it wraps with l, which
+// NewOTelMiddleware adds to metrics and spans and labels.NewLogHandler to
+// log records. It goes before them in Decorate.
+func Label(l labels.Labels) Middleware {
+	return func(ctx context.Context, method string, next func(ctx context.Context) error) error {
+		return next(labels.NewContext(ctx, l))
+	}
+}
+
+// NewMetricsMiddleware returns a Middleware recording the duration of every
+// repository call in duration and its errors in failures, both by method.
+// It goes after NewOTelMiddleware in Decorate, so its observations carry
+// the call's span as exemplar; see metrics.Bundle.
+func NewMetricsMiddleware(duration *metrics.HistogramVec, failures *metrics.CounterVec) Middleware {
+	return func(ctx context.Context, method string, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+		duration.Observe(ctx, time.Since(start).Seconds(), method)
+		// A missing row is an answer, not a failure
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			failures.Add(ctx, 1, method)
+		}
+		return err
+	}
+}
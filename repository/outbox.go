@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"project/clock"
+)
+
+// Outbox message statuses
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed"
+)
+
+// JSONContentType is the content type of payloads queued with Enqueue
+const JSONContentType = "application/json"
+
+// OutboxMessage is one queued message
+type OutboxMessage struct {
+	ID    int64
+	Topic string
+	// Payload is encoded as ContentType says, JSON unless it was queued
+	// with EnqueueEncoded
+	Payload     []byte
+	ContentType string
+	Attempts    int
+}
+
+// Outbox stores messages in the outbox table alongside the writes that
+// produce them, so a message is queued if and only if its transaction
+// commits. Workers then deliver messages at least once.
+type Outbox struct {
+	db   *sql.DB
+	bind func(int) string
+
+	// MaxAttempts is how often delivery is tried before a message is
+	// marked failed and copied to dead_letters
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubling per attempt
+	Backoff time.Duration
+	// Clock schedules retries; nil means the system clock
+	Clock clock.Clock
+
+	// topics limits Process to these topics when set
+	topics []string
+}
+
+// NewPostgresOutbox creates an outbox on a PostgreSQL db
+func NewPostgresOutbox(db *sql.DB) *Outbox {
+	return &Outbox{db: db, bind: postgresBind, MaxAttempts: 8, Backoff: 30 * time.Second}
+}
+
+// NewMySQLOutbox creates an outbox on a MySQL db
+func NewMySQLOutbox(db *sql.DB) *Outbox {
+	return &Outbox{db: db, bind: mysqlBind, MaxAttempts: 8, Backoff: 30 * time.Second}
+}
+
+// ForTopics returns an outbox whose Process only claims messages of
+// topics, so each consumer handles the topics it knows
+func (o *Outbox) ForTopics(topics ...string) *Outbox {
+	scoped := *o
+	scoped.topics = topics
+	return &scoped
+}
+
+// Enqueue queues payload, marshalled as JSON, under topic. Called with a
+// context from WithTransaction it joins that transaction.
+func (o *Outbox) Enqueue(ctx context.Context, topic string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+	return o.EnqueueEncoded(ctx, topic, JSONContentType, data)
+}
+
+// EnqueueEncoded queues a payload already encoded as contentType, e.g. by
+// a serializer. JSON payloads are stored as JSON, others as bytes.
+func (o *Outbox) EnqueueEncoded(ctx context.Context, topic, contentType string, data []byte) error {
+	var payload, body any
+	if contentType == JSONContentType {
+		payload = string(data)
+	} else {
+		body = data
+	}
+
+	query := fmt.Sprintf("INSERT INTO outbox (topic, payload, body, content_type, next_attempt_at) VALUES (%s, %s, %s, %s, %s)",
+		o.bind(1), o.bind(2), o.bind(3), o.bind(4), o.bind(5))
+	if _, err := dbFrom(ctx, o.db).ExecContext(ctx, query, topic, payload, body, contentType, clock.Or(o.Clock).Now().UTC()); err != nil {
+		return fmt.Errorf("failed to enqueue outbox message: %w", err)
+	}
+	return nil
+}
+
+// Process claims up to limit due messages and passes each to deliver. A
+// message deliver accepts is marked delivered; on error its attempt is
+// recorded and it is retried with backoff, or marked failed and
+// dead-lettered after MaxAttempts. Claimed rows stay locked until Process
+// returns, so concurrent workers skip them. It returns how many messages
+// were handled.
+func (o *Outbox) Process(ctx context.Context, limit int, deliver func(ctx context.Context, msg OutboxMessage) error) (int, error) {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := clock.Or(o.Clock).Now().UTC()
+	args := []any{OutboxPending, now}
+	var topics string
+	if len(o.topics) > 0 {
+		binds := make([]string, len(o.topics))
+		for i, t := range o.topics {
+			binds[i] = o.bind(len(args) + 1)
+			args = append(args, t)
+		}
+		topics = " AND topic IN (" + strings.Join(binds, ", ") + ")"
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, topic, payload, body, content_type, attempts FROM outbox
+		 WHERE status = %s AND next_attempt_at <= %s%s
+		 ORDER BY next_attempt_at LIMIT %d
+		 FOR UPDATE SKIP LOCKED`, o.bind(1), o.bind(2), topics, limit),
+		args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	var msgs []OutboxMessage
+	for rows.Next() {
+		var (
+			msg           OutboxMessage
+			payload, body []byte
+		)
+		if err := rows.Scan(&msg.ID, &msg.Topic, &payload, &body, &msg.ContentType, &msg.Attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		msg.Payload = payload
+		if body != nil {
+			msg.Payload = body
+		}
+		msgs = append(msgs, msg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	for _, msg := range msgs {
+		if err := o.settle(ctx, tx, msg, deliver(ctx, msg), now); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(msgs), nil
+}
+
+// settle records the outcome of delivering msg
+func (o *Outbox) settle(ctx context.Context, tx *sql.Tx, msg OutboxMessage, deliverErr error, now time.Time) error {
+	b := o.bind
+	var err error
+	switch {
+	case deliverErr == nil:
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("UPDATE outbox SET status = %s, attempts = attempts + 1, delivered_at = %s, last_error = NULL WHERE id = %s", b(1), b(2), b(3)),
+			OutboxDelivered, now, msg.ID)
+	case msg.Attempts+1 >= o.MaxAttempts:
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("UPDATE outbox SET status = %s, attempts = attempts + 1, last_error = %s WHERE id = %s", b(1), b(2), b(3)),
+			OutboxFailed, deliverErr.Error(), msg.ID)
+		if err == nil {
+			// dead-lettered in the same transaction, so no message is lost
+			// between giving up and recording it
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(
+				`INSERT INTO dead_letters (outbox_id, topic, content_type, payload, body, attempts, last_error, failed_at)
+				 SELECT id, topic, content_type, payload, body, attempts, last_error, %s FROM outbox WHERE id = %s`, b(1), b(2)),
+				now, msg.ID)
+		}
+	default:
+		next := now.Add(o.Backoff << msg.Attempts)
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("UPDATE outbox SET attempts = attempts + 1, last_error = %s, next_attempt_at = %s WHERE id = %s", b(1), b(2), b(3)),
+			deliverErr.Error(), next, msg.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update outbox message %d: %w", msg.ID, err)
+	}
+	return nil
+}
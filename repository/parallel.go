@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"project/models"
+)
+
+// chunksPerWorker splits the ID range finer than the worker count, so a
+// worker that drew a dense chunk doesn't hold up the rest
+const chunksPerWorker = 4
+
+// ParallelScanner is implemented by adapters that read the whole users
+// table with concurrent range scans. The ID range is split into chunks
+// fetched by workers on separate pooled connections, so scans never join
+// a transaction and run at most as wide as the pool allows.
+type ParallelScanner interface {
+	// ParallelScan returns every user, ordered by ID
+	ParallelScan(ctx context.Context, workers int) ([]models.User, error)
+	// ParallelScanTo sends each chunk of users on out as it is fetched, in
+	// no particular order, and closes out when the scan ends
+	ParallelScanTo(ctx context.Context, workers int, out chan<- []models.User) error
+}
+
+// ParallelScan implements ParallelScanner
+func (s *SQLRepo) ParallelScan(ctx context.Context, workers int) ([]models.User, error) {
+	return collectParallelScan(ctx, s.db, s.dialect.Bind, s.table(models.User{}), workers)
+}
+
+// ParallelScanTo implements ParallelScanner
+func (s *SQLRepo) ParallelScanTo(ctx context.Context, workers int, out chan<- []models.User) error {
+	defer close(out)
+	return parallelScan(ctx, s.db, s.dialect.Bind, s.table(models.User{}), workers, func(_ int, users []models.User) error {
+		return send(ctx, out, users)
+	})
+}
+
+func send(ctx context.Context, out chan<- []models.User, users []models.User) error {
+	select {
+	case out <- users:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// collectParallelScan merges the chunks of a parallel scan in ID order
+func collectParallelScan(ctx context.Context, db *sql.DB, bind func(int) string, table string, workers int) ([]models.User, error) {
+	var (
+		mu     sync.Mutex
+		chunks = make(map[int][]models.User)
+	)
+	err := parallelScan(ctx, db, bind, table, workers, func(chunk int, users []models.User) error {
+		mu.Lock()
+		defer mu.Unlock()
+		chunks[chunk] = users
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	for i := 0; i < len(chunks); i++ {
+		users = append(users, chunks[i]...)
+	}
+	return users, nil
+}
+
+// parallelScan splits the ID range of live users into chunks and has
+// workers fetch them concurrently, passing each to emit with its index.
+// The first error cancels the remaining chunks.
+func parallelScan(ctx context.Context, db *sql.DB, bind func(int) string, table string, workers int, emit func(chunk int, users []models.User) error) error {
+	if workers <= 0 {
+		return fmt.Errorf("parallel scan needs at least one worker")
+	}
+
+	var lo, hi sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MIN(id), MAX(id) FROM "+table+" WHERE deleted_at IS NULL").Scan(&lo, &hi); err != nil {
+		return fmt.Errorf("failed to read id range: %w", err)
+	}
+	if !lo.Valid {
+		return nil
+	}
+
+	// every chunk is emitted, empty ones too, so collectors see each index
+	n := int64(workers * chunksPerWorker)
+	size := max((hi.Int64-lo.Int64+1+n-1)/n, 1)
+	type chunk struct {
+		index  int
+		lo, hi int64
+	}
+	var ranges []chunk
+	for start := lo.Int64; start <= hi.Int64; start += size {
+		ranges = append(ranges, chunk{index: len(ranges), lo: start, hi: start + size})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		`SELECT id, created_at, updated_at, name, email, tenant_id FROM %s
+		 WHERE deleted_at IS NULL AND id >= %s AND id < %s ORDER BY id`, table, bind(1), bind(2))
+
+	work := make(chan chunk)
+	var (
+		wg      sync.WaitGroup
+		errOnce sync.Once
+		scanErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			scanErr = err
+			cancel()
+		})
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range work {
+				rows, err := db.QueryContext(ctx, query, c.lo, c.hi)
+				if err != nil {
+					fail(fmt.Errorf("failed to scan ids %d-%d: %w", c.lo, c.hi-1, err))
+					continue
+				}
+				users, err := ScanAll[models.User](rows)
+				rows.Close()
+				if err == nil {
+					err = emit(c.index, users)
+				}
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	for _, c := range ranges {
+		select {
+		case work <- c:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if scanErr != nil {
+		return scanErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// PasswordRepository is implemented by adapters that store user password
+// hashes in user_passwords
+type PasswordRepository interface {
+	// SetPasswordHash stores a user's password hash, replacing any
+	// previous one
+	SetPasswordHash(ctx context.Context, userID int, hash string) error
+	// PasswordHash returns a user's password hash, or ErrNotFound if the
+	// user has no password
+	PasswordHash(ctx context.Context, userID int) (string, error)
+}
+
+// SetPasswordHash stores a user's password hash
+func (s *SQLRepo) SetPasswordHash(ctx context.Context, userID int, hash string) error {
+	_, err := dbFrom(ctx, s.db).ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO user_passwords (user_id, password_hash) VALUES (%s, %s) ",
+			s.dialect.Bind(1), s.dialect.Bind(2))+
+			s.dialect.Upsert([]string{"user_id"}, "password_hash")+", changed_at = CURRENT_TIMESTAMP",
+		userID, hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	return nil
+}
+
+// PasswordHash returns a user's password hash
+func (s *SQLRepo) PasswordHash(ctx context.Context, userID int) (string, error) {
+	var hash string
+	err := dbFrom(ctx, s.db).QueryRowContext(ctx,
+		"SELECT password_hash FROM user_passwords WHERE user_id = "+s.dialect.Bind(1), userID).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query password: %w", err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// ChannelPreference is whether, and where, a user receives notifications
+// on one channel, e.g. "sms" to a phone number
+type ChannelPreference struct {
+	UserID  int
+	Channel string
+	// Address is the channel's destination: an email address, phone
+	// number or device token
+	Address string
+	Enabled bool
+}
+
+// PreferenceRepository is implemented by adapters that store per-user
+// notification channel preferences in notification_preferences
+type PreferenceRepository interface {
+	SetChannelPreference(ctx context.Context, pref ChannelPreference) error
+	ChannelPreferences(ctx context.Context, userID int) ([]ChannelPreference, error)
+}
+
+// SetChannelPreference creates or replaces a user's preference for a channel
+func (s *SQLRepo) SetChannelPreference(ctx context.Context, pref ChannelPreference) error {
+	return setChannelPreference(ctx, dbFrom(ctx, s.db), s.dialect, pref)
+}
+
+// ChannelPreferences returns a user's channel preferences
+func (s *SQLRepo) ChannelPreferences(ctx context.Context, userID int) ([]ChannelPreference, error) {
+	return channelPreferences(ctx, dbFrom(ctx, s.db), s.dialect.Bind, userID)
+}
+
+func setChannelPreference(ctx context.Context, db querier, d Dialect, pref ChannelPreference) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO notification_preferences (user_id, channel, address, enabled) VALUES (%s, %s, %s, %s) %s, updated_at = CURRENT_TIMESTAMP",
+		d.Bind(1), d.Bind(2), d.Bind(3), d.Bind(4), d.Upsert([]string{"user_id", "channel"}, "address", "enabled")),
+		pref.UserID, pref.Channel, pref.Address, pref.Enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set channel preference: %w", err)
+	}
+	return nil
+}
+
+func channelPreferences(ctx context.Context, db querier, bind func(int) string, userID int) ([]ChannelPreference, error) {
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf("SELECT user_id, channel, address, enabled FROM notification_preferences WHERE user_id = %s ORDER BY channel", bind(1)),
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query channel preferences: %w", err)
+	}
+	defer rows.Close()
+
+	var prefs []ChannelPreference
+	for rows.Next() {
+		var pref ChannelPreference
+		if err := rows.Scan(&pref.UserID, &pref.Channel, &pref.Address, &pref.Enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan channel preference: %w", err)
+		}
+		prefs = append(prefs, pref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query channel preferences: %w", err)
+	}
+	return prefs, nil
+}
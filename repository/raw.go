@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Raw runs a hand-written query with :name parameters bound from params
+func (s *SQLRepo) Raw(ctx context.Context, query string, params map[string]any) (*sql.Rows, error) {
+	return rawQuery(ctx, s.db, s.dialect, query, params)
+}
+
+func rawQuery(ctx context.Context, db *sql.DB, d Dialect, query string, params map[string]any) (*sql.Rows, error) {
+	expanded, args, err := expandNamed(query, params, d)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := dbFrom(ctx, db).QueryContext(ctx, expanded, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run raw query: %w", err)
+	}
+	return rows, nil
+}
+
+// expandNamed rewrites :name parameters into the dialect's placeholders and
+// returns the matching argument list. Values are always bound, never
+// interpolated; slice values expand to a comma-separated placeholder list for
+// use in IN (...). Text inside quotes and comments, and Postgres :: casts,
+// are left untouched, as are Postgres $tag$ dollar-quoted bodies. Backslash
+// escapes a quote in MySQL strings and Postgres E'...' strings.
+func expandNamed(query string, params map[string]any, d Dialect) (string, []any, error) {
+	var b strings.Builder
+	var args []any
+	postgres, mysql := d.Name() == "postgres", d.Name() == "mysql"
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			backslash := (mysql && c != '`') || (postgres && c == '\'' && isEscapeString(query, i))
+			end := skipQuoted(query, i, c, backslash)
+			b.WriteString(query[i:end])
+			i = end - 1
+
+		case postgres && c == '$':
+			tag := dollarTag(query, i)
+			if tag == "" {
+				b.WriteByte(c)
+				continue
+			}
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + 2*len(tag)
+			}
+			b.WriteString(query[i:end])
+			i = end - 1
+
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 2
+			} else {
+				end += 2
+			}
+			b.WriteString(query[i : i+2+end])
+			i += 1 + end
+
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			b.WriteString("::")
+			i++
+
+		case c == ':' && i+1 < len(query) && isIdentStart(query[i+1]):
+			j := i + 1
+			for j < len(query) && isIdentPart(query[j]) {
+				j++
+			}
+			name := query[i+1 : j]
+
+			value, ok := params[name]
+			if !ok {
+				return "", nil, fmt.Errorf("missing value for named parameter :%s", name)
+			}
+
+			values := expandSlice(value)
+			if len(values) == 0 {
+				return "", nil, fmt.Errorf("named parameter :%s is an empty list", name)
+			}
+			for k, v := range values {
+				if k > 0 {
+					b.WriteString(", ")
+				}
+				args = append(args, v)
+				b.WriteString(d.Bind(len(args)))
+			}
+			i = j - 1
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), args, nil
+}
+
+// skipQuoted returns the index just past the quoted section starting at i,
+// treating a doubled quote, and with backslash set a backslash, as escaping
+// the next character
+func skipQuoted(s string, i int, quote byte, backslash bool) int {
+	for j := i + 1; j < len(s); j++ {
+		if backslash && s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == quote {
+			if j+1 < len(s) && s[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// isEscapeString reports whether the quote at i opens a Postgres E'...' string
+func isEscapeString(s string, i int) bool {
+	return i > 0 && (s[i-1] == 'E' || s[i-1] == 'e') && (i == 1 || !isIdentPart(s[i-2]) && s[i-2] != '$')
+}
+
+// dollarTag returns the $tag$ opening a Postgres dollar-quoted string at
+// i, e.g. "$$" or "$fn$", or "" when the $ at i doesn't open one: $1 is a
+// placeholder, and $ inside an identifier is part of it
+func dollarTag(s string, i int) string {
+	if i > 0 && (isIdentPart(s[i-1]) || s[i-1] == '$') {
+		return ""
+	}
+	j := i + 1
+	if j < len(s) && isIdentStart(s[j]) {
+		for j < len(s) && isIdentPart(s[j]) {
+			j++
+		}
+	}
+	if j >= len(s) || s[j] != '$' {
+		return ""
+	}
+	return s[i : j+1]
+}
+
+// expandSlice returns the elements of a slice value, or the value itself.
+// []byte is treated as a single value.
+func expandSlice(value any) []any {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return []any{value}
+	}
+
+	out := make([]any, v.Len())
+	for i := range out {
+		out[i] = v.Index(i).Interface()
+	}
+	return out
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandNamed(t *testing.T) {
+	params := map[string]any{"x": 1, "y": 2, "ids": []int{3, 4}, "raw": []byte("b")}
+
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		query    string
+		want     string
+		wantArgs []any
+	}{
+		{
+			name: "named parameters", dialect: PostgresDialect,
+			query: "SELECT * FROM users WHERE id = :x AND tenant_id = :y",
+			want:  "SELECT * FROM users WHERE id = $1 AND tenant_id = $2", wantArgs: []any{1, 2},
+		},
+		{
+			name: "mysql placeholders", dialect: MySQLDialect,
+			query: "SELECT * FROM users WHERE id = :x", want: "SELECT * FROM users WHERE id = ?", wantArgs: []any{1},
+		},
+		{
+			name: "slices expand, bytes don't", dialect: PostgresDialect,
+			query: "WHERE id IN (:ids) AND data = :raw",
+			want:  "WHERE id IN ($1, $2) AND data = $3", wantArgs: []any{3, 4, []byte("b")},
+		},
+		{
+			name: "quoted text and identifiers", dialect: PostgresDialect,
+			query: `SELECT ':x', "a:x", 'it''s :x' WHERE a = :y`,
+			want:  `SELECT ':x', "a:x", 'it''s :x' WHERE a = $1`, wantArgs: []any{2},
+		},
+		{
+			name: "comments", dialect: PostgresDialect,
+			query: "SELECT 1 -- :x\n/* :x */ WHERE a = :y",
+			want:  "SELECT 1 -- :x\n/* :x */ WHERE a = $1", wantArgs: []any{2},
+		},
+		{
+			name: "casts", dialect: PostgresDialect,
+			query: "SELECT :x::int", want: "SELECT $1::int", wantArgs: []any{1},
+		},
+		{
+			name: "dollar-quoted body", dialect: PostgresDialect,
+			query: "DO $$ BEGIN PERFORM ':x'; END $$; SELECT :y",
+			want:  "DO $$ BEGIN PERFORM ':x'; END $$; SELECT $1", wantArgs: []any{2},
+		},
+		{
+			name: "tagged dollar quote holding $$", dialect: PostgresDialect,
+			query: "SELECT $fn$ a $$ :x $$ b $fn$, :y",
+			want:  "SELECT $fn$ a $$ :x $$ b $fn$, $1", wantArgs: []any{2},
+		},
+		{
+			name: "unterminated dollar quote runs to the end", dialect: PostgresDialect,
+			query: "SELECT $q$ :x", want: "SELECT $q$ :x",
+		},
+		{
+			name: "positional placeholder is no dollar quote", dialect: PostgresDialect,
+			query: "SELECT $1, :y, $2", want: "SELECT $1, $1, $2", wantArgs: []any{2},
+		},
+		{
+			name: "dollar inside an identifier", dialect: PostgresDialect,
+			query: "SELECT a$b$ FROM t WHERE c = :y", want: "SELECT a$b$ FROM t WHERE c = $1", wantArgs: []any{2},
+		},
+		{
+			name: "mysql has no dollar quotes", dialect: MySQLDialect,
+			query: "SELECT $$ :x $$", want: "SELECT $$ ? $$", wantArgs: []any{1},
+		},
+		{
+			name: "mysql backslash-escaped quote", dialect: MySQLDialect,
+			query: `SELECT 'it\'s :x' WHERE a = :y`, want: `SELECT 'it\'s :x' WHERE a = ?`, wantArgs: []any{2},
+		},
+		{
+			name: "mysql backslash-escaped double quote", dialect: MySQLDialect,
+			query: `SELECT "say \":x\"", :y`, want: `SELECT "say \":x\"", ?`, wantArgs: []any{2},
+		},
+		{
+			name: "mysql escaped backslash ends the string", dialect: MySQLDialect,
+			query: `SELECT 'a\\', :y`, want: `SELECT 'a\\', ?`, wantArgs: []any{2},
+		},
+		{
+			name: "mysql backticks don't escape", dialect: MySQLDialect,
+			query: "SELECT `a\\`, :y", want: "SELECT `a\\`, ?", wantArgs: []any{2},
+		},
+		{
+			name: "postgres backslash is literal", dialect: PostgresDialect,
+			query: `SELECT 'a\', :y`, want: `SELECT 'a\', $1`, wantArgs: []any{2},
+		},
+		{
+			name: "postgres escape string", dialect: PostgresDialect,
+			query: `SELECT E'it\'s :x', e'\\', :y`, want: `SELECT E'it\'s :x', e'\\', $1`, wantArgs: []any{2},
+		},
+		{
+			name: "identifier ending in e is no escape string", dialect: PostgresDialect,
+			query: `SELECT name'a\', :y`, want: `SELECT name'a\', $1`, wantArgs: []any{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := expandNamed(tt.query, params, tt.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("expandNamed(%q) = %q, %v, want %q, %v", tt.query, got, args, tt.want, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestExpandNamedErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "missing parameter", query: "SELECT :missing"},
+		{name: "empty list", query: "WHERE id IN (:empty)"},
+		{name: "missing after a dollar quote", query: "SELECT $$ :x $$, :missing"},
+		{name: "missing after an escape string", query: `SELECT E'\'', :missing`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := expandNamed(tt.query, map[string]any{"x": 1, "empty": []int{}}, PostgresDialect); err == nil {
+				t.Errorf("expandNamed(%q) succeeded", tt.query)
+			}
+		})
+	}
+}
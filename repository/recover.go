@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"project/apperr"
+)
+
+// PanicError is returned for a repository call that panicked, e.g. on a
+// model field type the dialect can't map
+type PanicError struct {
+	Method string
+	Value  any
+	// Stack is the goroutine's stack at the panic
+	Stack []byte
+}
+
+// Error implements error
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Method, e.Value)
+}
+
+// Unwrap returns the panic value when it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// ErrorCode implements apperr.Coder; a panic is always a bug
+func (e *PanicError) ErrorCode() apperr.Code {
+	return apperr.Internal
+}
+
+// Recover is a Middleware turning a panic in a call into a *PanicError, so
+// one bad model fails its requests instead of the process. Panics in
+// goroutines the adapter starts itself, e.g. ParallelScan workers, are not
+// caught.
+var Recover = RecoverWith(nil)
+
+// RecoverWith is Recover that also passes each panic to report, e.g. to log
+// it with the request ID the context carries
+func RecoverWith(report func(ctx context.Context, p *PanicError)) Middleware {
+	return func(ctx context.Context, method string, next func(ctx context.Context) error) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				p := &PanicError{Method: method, Value: v, Stack: debug.Stack()}
+				if report != nil {
+					report(ctx, p)
+				}
+				err = p
+			}
+		}()
+		return next(ctx)
+	}
+}
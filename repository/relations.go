@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"project/models"
+)
+
+// createPost inserts a post owned by post.UserID
+func createPost(db *sql.DB, bind func(int) string, table string, post models.Post) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (user_id, title, body) VALUES (%s, %s, %s)",
+		table, bind(1), bind(2), bind(3),
+	)
+	if _, err := db.Exec(query, post.UserID, post.Title, post.Body); err != nil {
+		return fmt.Errorf("failed to insert post: %w", err)
+	}
+	return nil
+}
+
+// preloadPosts fills the Posts of every user with a single batched IN query
+// instead of one query per user
+func preloadPosts(db *sql.DB, bind func(int) string, table string, users []models.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	byUser := make(map[int]int, len(users))
+	placeholders := make([]string, len(users))
+	args := make([]any, len(users))
+	for i, u := range users {
+		byUser[u.ID] = i
+		placeholders[i] = bind(i + 1)
+		args[i] = u.ID
+		users[i].Posts = []models.Post{}
+	}
+
+	query := fmt.Sprintf(
+		"SELECT id, created_at, updated_at, user_id, title, body FROM %s WHERE user_id IN (%s) ORDER BY id",
+		table, strings.Join(placeholders, ", "),
+	)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query posts: %w", err)
+	}
+	defer rows.Close()
+
+	posts, err := ScanAll[models.Post](rows)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		if i, ok := byUser[p.UserID]; ok {
+			users[i].Posts = append(users[i].Posts, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultLagCheckInterval is how long a measured replication lag is trusted
+// before the replica is asked again
+const defaultLagCheckInterval = time.Second
+
+// ReplicaOptions bound how stale the reads served by a replica may be
+type ReplicaOptions struct {
+	// MaxLag is the replication lag above which reads fall back to the
+	// primary; 0 reads from the replica however far behind it is
+	MaxLag time.Duration
+	// CheckInterval is how often the lag is measured, default one second;
+	// reads in between trust the last measurement
+	CheckInterval time.Duration
+}
+
+// SetReplica makes GetAll, GetByID, GetByKey, GetByIDs and
+// ListCreatedBetween read from replica while its replication lag is within
+// opts.MaxLag, and from the primary otherwise. The lag is read from
+// pg_last_xact_replay_timestamp on Postgres and SHOW SLAVE STATUS on MySQL;
+// a replica whose lag can't be measured is treated as too far behind.
+func (s *SQLRepo) SetReplica(replica *sql.DB, opts ReplicaOptions) {
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultLagCheckInterval
+	}
+	s.replica = &replicaRouter{db: replica, dialect: s.dialect, opts: opts, err: errLagUnmeasured}
+}
+
+// ReplicationLag measures how far the replica set with SetReplica is
+// behind the primary; it is 0 without a replica
+func (s *SQLRepo) ReplicationLag(ctx context.Context) (time.Duration, error) {
+	if s.replica == nil {
+		return 0, nil
+	}
+	return replicationLag(ctx, s.replica.db, s.dialect)
+}
+
+// reader returns the handle a read with opts runs on
+func (s *SQLRepo) reader(opts ListOptions) *sql.DB {
+	if s.replica == nil || opts.MaxReplicaLag < 0 {
+		return s.db
+	}
+	maxLag := s.replica.opts.MaxLag
+	if opts.MaxReplicaLag > 0 {
+		maxLag = opts.MaxReplicaLag
+	}
+	if s.replica.within(maxLag) {
+		return s.replica.db
+	}
+	return s.db
+}
+
+// replicaRouter caches the measured lag of a replica
+type replicaRouter struct {
+	db      *sql.DB
+	dialect Dialect
+	opts    ReplicaOptions
+
+	// mu guards the cached measurement only, never held across the query
+	mu        sync.Mutex
+	checked   time.Time
+	measuring bool
+	lag       time.Duration
+	err       error
+}
+
+// errLagUnmeasured is the cached lag error until the first measurement
+var errLagUnmeasured = errors.New("replication lag not measured yet")
+
+// within reports whether the replica is at most maxLag behind. When the
+// last measurement is older than the check interval the caller measures
+// the lag; reads meanwhile use the cached one rather than wait.
+func (r *replicaRouter) within(maxLag time.Duration) bool {
+	if maxLag <= 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	measure := !r.measuring && time.Since(r.checked) >= r.opts.CheckInterval
+	if measure {
+		r.measuring = true
+	}
+	lag, err := r.lag, r.err
+	r.mu.Unlock()
+
+	if measure {
+		ctx, cancel := context.WithTimeout(context.Background(), r.opts.CheckInterval)
+		lag, err = replicationLag(ctx, r.db, r.dialect)
+		cancel()
+
+		r.mu.Lock()
+		r.lag, r.err, r.checked, r.measuring = lag, err, time.Now(), false
+		r.mu.Unlock()
+	}
+	return err == nil && lag <= maxLag
+}
+
+// errReplicationStopped is returned for a MySQL replica whose SQL thread
+// isn't running, so its lag is unknown
+var errReplicationStopped = errors.New("replication is not running")
+
+// replicationLag measures how far db is behind its primary; a database
+// that isn't a replica has no lag
+func replicationLag(ctx context.Context, db *sql.DB, d Dialect) (time.Duration, error) {
+	if d.Name() == "postgres" {
+		// a replica that has replayed everything it received is caught up,
+		// however long ago the last transaction on the primary was
+		var seconds float64
+		err := db.QueryRowContext(ctx, `SELECT CASE
+			WHEN NOT pg_is_in_recovery() THEN 0
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END`).Scan(&seconds)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read replication lag: %w", err)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	rows, err := db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read replication lag: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read replication lag: %w", err)
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	values := make([]sql.RawBytes, len(columns))
+	targets := make([]any, len(columns))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return 0, fmt.Errorf("failed to read replication lag: %w", err)
+	}
+	for i, col := range columns {
+		if col != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errReplicationStopped
+		}
+		seconds, err := strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse replication lag %q: %w", values[i], err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("SHOW SLAVE STATUS has no Seconds_Behind_Master")
+}
@@ -1,9 +1,92 @@
 package repository
 
-import "project/models"
+import (
+	"context"
+	"time"
+
+	"project/models"
+)
+
+// ListOptions controls paging of list queries. A zero Limit means no limit.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	// Sort orders the results, see ParseSort; id is always the final
+	// tiebreaker so pages are stable
+	Sort []SortField
+	// Hints steer the planner for this call, see Hint
+	Hints []Hint
+	// MaxReplicaLag bounds the replication lag of the replica serving this
+	// call, overriding ReplicaOptions.MaxLag; negative reads from the
+	// primary. See SQLRepo.SetReplica.
+	MaxReplicaLag time.Duration
+}
 
 // UserRepository defines the contract for user data access
 type UserRepository interface {
-	Create(user models.User) error
+	// Create returns the user as persisted, with its generated ID and
+	// timestamps
+	Create(user models.User) (models.User, error)
 	GetAll() ([]models.User, error)
+	GetByID(id int) (models.User, error)
+	ListCreatedBetween(from, to time.Time, opts ListOptions) ([]models.User, error)
+	Update(user models.User) error
+	Delete(id int) error
+}
+
+// ContextCreator is implemented by adapters whose Create can join the
+// transaction carried by a context
+type ContextCreator interface {
+	CreateContext(ctx context.Context, user models.User) (models.User, error)
+}
+
+// WriteResult is the metadata of an update or delete
+type WriteResult struct {
+	// RowsAffected is the number of rows the write changed
+	RowsAffected int64
+}
+
+// ResultReporter is implemented by adapters that report what their updates
+// and deletes changed
+type ResultReporter interface {
+	UpdateResult(user models.User) (WriteResult, error)
+	DeleteResult(id int) (WriteResult, error)
+}
+
+// TxRunner is implemented by adapters that run functions in a transaction
+// carried by the context passed to them
+type TxRunner interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// PostRepository defines the contract for posts and preloading them onto users
+type PostRepository interface {
+	CreatePost(post models.Post) error
+	GetUserWithPosts(id int) (models.User, error)
+	GetAllWithPosts() ([]models.User, error)
+}
+
+// AggregateRepository is implemented by adapters that can compute user
+// aggregates in the database
+type AggregateRepository interface {
+	CountByCreatedDate() (map[string]int, error)
+	GroupBy(field string) (map[string]int, error)
+}
+
+// FullTextSearcher is implemented by adapters with native full-text search
+type FullTextSearcher interface {
+	SearchUsersFullText(query string, opts ListOptions) ([]RankedUser, error)
+}
+
+// AttributeRepository is implemented by adapters that can store and query
+// the free-form User.Attributes JSON
+type AttributeRepository interface {
+	SetAttributes(id int, attrs models.JSONMap) error
+	FindByAttributes(contains models.JSONMap) ([]models.User, error)
+	FindByAttributePath(path []string, value string) ([]models.User, error)
+}
+
+// HistoryRepository is implemented by adapters that keep prior user versions
+type HistoryRepository interface {
+	GetUserAsOf(id int, t time.Time) (models.User, error)
 }
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"project/clock"
+	"project/locks"
+)
+
+// retentionMetrics exposes retention engine counters on /debug/vars
+var retentionMetrics = expvar.NewMap("retention")
+
+// RetentionRule deletes the rows of Table whose TimeColumn is older than
+// MaxAge and that match Where, e.g. users created more than 30 days ago
+// that never set an email address:
+//
+//	{"name": "unverified_users", "table": "users", "time_column": "created_at",
+//	 "max_age": "720h", "where": "email = ''"}
+type RetentionRule struct {
+	Name       string
+	Table      string
+	TimeColumn string
+	MaxAge     time.Duration
+	// Where is an optional trusted SQL condition narrowing the rule
+	Where string
+	// Key is the column batches are selected by, "id" by default
+	Key string
+}
+
+// retentionRuleJSON is the config file form of a RetentionRule
+type retentionRuleJSON struct {
+	Name       string `json:"name"`
+	Table      string `json:"table"`
+	TimeColumn string `json:"time_column"`
+	MaxAge     string `json:"max_age"`
+	Where      string `json:"where"`
+	Key        string `json:"key"`
+}
+
+// UnmarshalJSON decodes a rule, with max_age as a Go duration string
+func (r *RetentionRule) UnmarshalJSON(data []byte) error {
+	var raw retentionRuleJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	age, err := time.ParseDuration(raw.MaxAge)
+	if err != nil {
+		return fmt.Errorf("retention rule %q: invalid max_age: %w", raw.Name, err)
+	}
+	*r = RetentionRule{Name: raw.Name, Table: raw.Table, TimeColumn: raw.TimeColumn, MaxAge: age, Where: raw.Where, Key: raw.Key}
+	return nil
+}
+
+// identifierPattern matches the table and column names rules may use
+var identifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// validate checks the identifiers of r, which are interpolated into SQL
+func (r RetentionRule) validate() error {
+	if r.Name == "" {
+		return errors.New("retention rule has no name")
+	}
+	for _, id := range []string{r.Table, r.TimeColumn, r.key()} {
+		if !identifierPattern.MatchString(id) {
+			return fmt.Errorf("retention rule %q: invalid identifier %q", r.Name, id)
+		}
+	}
+	if r.MaxAge <= 0 {
+		return fmt.Errorf("retention rule %q: max_age must be positive", r.Name)
+	}
+	return nil
+}
+
+func (r RetentionRule) key() string {
+	if r.Key == "" {
+		return "id"
+	}
+	return r.Key
+}
+
+// predicate returns the rule's condition, binding the cutoff as $1
+func (r RetentionRule) predicate() string {
+	p := r.TimeColumn + " < $1"
+	if r.Where != "" {
+		p += " AND (" + r.Where + ")"
+	}
+	return p
+}
+
+// DefaultRetentionRules expire the operational data that accumulates
+// without bound. Rules on an expiry column use a MaxAge of a nanosecond,
+// deleting rows as soon as they expire.
+func DefaultRetentionRules() []RetentionRule {
+	return []RetentionRule{
+		{Name: "expired_sessions", Table: "sessions", TimeColumn: "expires_at", MaxAge: time.Nanosecond},
+		{Name: "expired_lockouts", Table: "account_lockouts", TimeColumn: "locked_until", MaxAge: time.Nanosecond, Key: "user_id"},
+		{Name: "old_login_attempts", Table: "login_attempts", TimeColumn: "attempted_at", MaxAge: 90 * 24 * time.Hour},
+		{Name: "delivered_outbox", Table: "outbox", TimeColumn: "delivered_at", MaxAge: 30 * 24 * time.Hour, Where: "status = 'delivered'"},
+	}
+}
+
+// LoadRetentionRules reads a JSON array of rules from path
+func LoadRetentionRules(path string) ([]RetentionRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retention rules: %w", err)
+	}
+	var rules []RetentionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse retention rules: %w", err)
+	}
+	return rules, nil
+}
+
+// RetentionReport summarizes one rule of a retention run
+type RetentionReport struct {
+	Rule   string
+	DryRun bool
+	// Matched is the number of rows due for deletion when the run started
+	Matched  int
+	Deleted  int
+	Batches  int
+	Duration time.Duration
+}
+
+// RetentionEngine deletes rows matching its rules in batches, each in its
+// own transaction, pacing batches to bound the load on the database. Its
+// statements are written for PostgreSQL.
+type RetentionEngine struct {
+	db    *sql.DB
+	rules []RetentionRule
+
+	BatchSize int
+	// RowsPerSecond bounds the deletion rate; 0 means unlimited
+	RowsPerSecond int
+	// DryRun reports what would be deleted without deleting it
+	DryRun bool
+	// Clock computes cutoffs and times runs; nil means the system clock
+	Clock clock.Clock
+	// Lock, when set, makes Schedule skip a run while another instance
+	// holds it
+	Lock locks.Lock
+}
+
+// NewRetentionEngine creates an engine applying rules to db
+func NewRetentionEngine(db *sql.DB, rules ...RetentionRule) *RetentionEngine {
+	return &RetentionEngine{db: db, rules: rules, BatchSize: 500}
+}
+
+// Run applies every rule once, stopping at the first failing rule
+func (e *RetentionEngine) Run(ctx context.Context) ([]RetentionReport, error) {
+	reports := make([]RetentionReport, 0, len(e.rules))
+	for _, rule := range e.rules {
+		report, err := e.apply(ctx, rule)
+		reports = append(reports, report)
+		if err != nil {
+			retentionMetrics.Add("errors_total", 1)
+			return reports, fmt.Errorf("retention rule %q: %w", rule.Name, err)
+		}
+	}
+	return reports, nil
+}
+
+// Schedule runs the engine every interval until ctx is cancelled, logging
+// each run with logf
+func (e *RetentionEngine) Schedule(ctx context.Context, interval time.Duration, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ran, err := runLocked(ctx, e.Lock, func(ctx context.Context) error {
+				reports, err := e.Run(ctx)
+				for _, r := range reports {
+					logf("Retention %s: matched %d, deleted %d in %d batches (%s)", r.Rule, r.Matched, r.Deleted, r.Batches, r.Duration)
+				}
+				return err
+			})
+			if err != nil {
+				logf("Retention run failed: %v", err)
+			} else if !ran {
+				retentionMetrics.Add("skipped_total", 1)
+			}
+		}
+	}
+}
+
+func (e *RetentionEngine) apply(ctx context.Context, rule RetentionRule) (RetentionReport, error) {
+	report := RetentionReport{Rule: rule.Name, DryRun: e.DryRun}
+	if err := rule.validate(); err != nil {
+		return report, err
+	}
+
+	now := clock.Or(e.Clock).Now()
+	start := now
+	cutoff := now.Add(-rule.MaxAge).UTC()
+	defer func() { report.Duration = clock.Or(e.Clock).Now().Sub(start) }()
+
+	err := e.db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", rule.Table, rule.predicate()), cutoff,
+	).Scan(&report.Matched)
+	if err != nil {
+		return report, fmt.Errorf("failed to count rows: %w", err)
+	}
+	retentionMetrics.Add("matched_total", int64(report.Matched))
+	if e.DryRun || report.Matched == 0 {
+		return report, nil
+	}
+
+	query := fmt.Sprintf(
+		"DELETE FROM %[1]s WHERE %[2]s IN (SELECT %[2]s FROM %[1]s WHERE %[3]s ORDER BY %[2]s LIMIT $2 FOR UPDATE SKIP LOCKED)",
+		rule.Table, rule.key(), rule.predicate(),
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		res, err := e.db.ExecContext(ctx, query, cutoff, e.BatchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to delete rows: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return report, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if n == 0 {
+			return report, nil
+		}
+
+		report.Batches++
+		report.Deleted += int(n)
+		retentionMetrics.Add("batches_total", 1)
+		retentionMetrics.Add("deleted_total", n)
+
+		if err := e.pace(ctx, int(n)); err != nil {
+			return report, err
+		}
+	}
+}
+
+// pace waits long enough after deleting n rows to honour RowsPerSecond
+func (e *RetentionEngine) pace(ctx context.Context, n int) error {
+	return pace(ctx, n, e.RowsPerSecond)
+}
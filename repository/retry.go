@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers that are expected under contention and safe to
+// retry by re-running the whole transaction
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// RetryPolicy bounds how often a transaction is re-run after a retryable
+// error. Delays grow exponentially from BaseDelay up to MaxDelay, with full
+// jitter so contending instances don't retry in lockstep.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Retryable reports whether err warrants another attempt
+	Retryable func(err error) bool
+}
+
+// DefaultMySQLRetryPolicy retries deadlocks and lock wait timeouts
+var DefaultMySQLRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    200 * time.Millisecond,
+	Retryable:   IsMySQLContention,
+}
+
+// IsMySQLContention reports whether err is a MySQL deadlock (1213) or lock
+// wait timeout (1205)
+func IsMySQLContention(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	return myErr.Number == mysqlErrDeadlock || myErr.Number == mysqlErrLockWaitTimeout
+}
+
+// backoff returns the jittered delay before retry attempt n (1-based)
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// run calls fn until it succeeds, returns a non-retryable error, or the
+// attempts are used up, returning the last error
+func (p RetryPolicy) run(ctx context.Context, fn func() error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 || p.Retryable == nil {
+		attempts = 1
+	}
+
+	var err error
+	for n := 1; ; n++ {
+		err = fn()
+		if err == nil || n >= attempts || !p.Retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.backoff(n)):
+		}
+	}
+}
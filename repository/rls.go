@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Session settings read by the row-level security policies
+const (
+	tenantSetting = "app.current_tenant"
+	userSetting   = "app.current_user"
+)
+
+// WithRowLevelSecurity makes Plan and AutoMigrate enable Postgres row-level
+// security on every model with a column tagged tenant, with a policy
+// limiting rows to the tenant in app.current_tenant. The policy is forced,
+// so it binds the table owner too: every query on those tables must then
+// run in a transaction from a Transactor using WithRowLevelSecurity.
+func (m *Migrator) WithRowLevelSecurity() *Migrator {
+	m.rls = true
+	return m
+}
+
+// rowLevelSecurityStatements returns the DDL isolating model's rows by
+// tenant, or nothing if it has no tenant column
+func rowLevelSecurityStatements(model any, naming NamingStrategy) ([]string, error) {
+	def, err := parseModel(model, naming)
+	if err != nil {
+		return nil, err
+	}
+
+	column := def.tenantColumn()
+	if column == "" {
+		return nil, nil
+	}
+
+	policy := bareTable(def.Table) + "_tenant_isolation"
+	// missing_ok: an unset setting reads as NULL, matching no rows
+	match := fmt.Sprintf("%s = current_setting('%s', true)", column, tenantSetting)
+
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY;", def.Table),
+		fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY;", def.Table),
+		fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s;", policy, def.Table),
+		fmt.Sprintf("CREATE POLICY %s ON %s USING (%s) WITH CHECK (%s);", policy, def.Table, match, match),
+	}, nil
+}
+
+// WithRowLevelSecurity makes every transaction set app.current_tenant and
+// app.current_user from the tenant and actor in its context, scoped to the
+// transaction as with SET LOCAL. Postgres only.
+func (t *Transactor) WithRowLevelSecurity() *Transactor {
+	t.rls = true
+	return t
+}
+
+// setSessionIdentity copies the context's tenant and actor into the
+// transaction's settings. set_config is used over SET LOCAL because it
+// takes bind parameters.
+func setSessionIdentity(ctx context.Context, tx *sql.Tx) error {
+	s := SessionFromContext(ctx)
+	_, err := tx.ExecContext(ctx,
+		"SELECT set_config($1, $2, true), set_config($3, $4, true)",
+		tenantSetting, s.TenantID,
+		userSetting, s.Actor,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set session identity: %w", err)
+	}
+	return nil
+}
+
+// EnableRowLevelSecurity makes the repository's transactions carry the
+// tenant and actor of their context, see Transactor.WithRowLevelSecurity
+func (p *PostgresRepo) EnableRowLevelSecurity() {
+	p.rls = true
+}
+
+// transactor returns the Transactor for the repository's transactions
+func (p *PostgresRepo) transactor() *Transactor {
+	t := NewTransactor(p.db)
+	if p.rls {
+		t.WithRowLevelSecurity()
+	}
+	return t
+}
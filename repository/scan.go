@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldMaps caches column name -> field index paths per struct type
+var fieldMaps sync.Map
+
+// columnFields returns the field index path of every mapped column of t,
+// following the same tag rules as AutoMigrate
+func columnFields(t reflect.Type) map[string][]int {
+	if m, ok := fieldMaps.Load(t); ok {
+		return m.(map[string][]int)
+	}
+
+	fields := make(map[string][]int)
+	walkFields(t, nil, func(f reflect.StructField, index []int) {
+		if name, _, ok := columnTag(f); ok {
+			fields[name] = index
+		}
+	})
+
+	fieldMaps.Store(t, fields)
+	return fields
+}
+
+// ColumnMismatchError reports result columns with no struct field and
+// struct fields the result didn't include, e.g. after manual DDL
+type ColumnMismatchError struct {
+	Type string
+	// Extra are result columns no field maps to
+	Extra []string
+	// Missing are the fields whose column the result lacks
+	Missing []string
+}
+
+func (e *ColumnMismatchError) Error() string {
+	var parts []string
+	if len(e.Extra) > 0 {
+		parts = append(parts, fmt.Sprintf("columns %s have no field", strings.Join(e.Extra, ", ")))
+	}
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("fields %s have no column", strings.Join(e.Missing, ", ")))
+	}
+	return fmt.Sprintf("result does not match %s: %s", e.Type, strings.Join(parts, "; "))
+}
+
+// Columns returns the mapped columns of T in declaration order, for
+// selecting exactly what ScanAll reads instead of SELECT *
+func Columns[T any]() []string {
+	var zero T
+	var columns []string
+	walkFields(reflect.TypeOf(zero), nil, func(f reflect.StructField, _ []int) {
+		if name, _, ok := columnTag(f); ok {
+			columns = append(columns, name)
+		}
+	})
+	return columns
+}
+
+// ScanAll reads every remaining row into a T, matching result columns to
+// struct fields by db tag (or snake_case field name). Columns with no field
+// are skipped, so a table gaining columns doesn't break reads. It does not
+// close rows.
+func ScanAll[T any](rows *sql.Rows) ([]T, error) {
+	return scanAll[T](rows, false)
+}
+
+// ScanAllStrict is ScanAll failing with a *ColumnMismatchError unless the
+// result columns and the fields of T match exactly
+func ScanAllStrict[T any](rows *sql.Rows) ([]T, error) {
+	return scanAll[T](rows, true)
+}
+
+func scanAll[T any](rows *sql.Rows, strict bool) ([]T, error) {
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ScanAll: %s is not a struct", t)
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	fields := columnFields(t)
+	indexes := make([][]int, len(columns))
+	seen := make(map[string]bool, len(columns))
+	mismatch := &ColumnMismatchError{Type: t.String()}
+	for i, col := range columns {
+		seen[col] = true
+		if index, ok := fields[col]; ok {
+			indexes[i] = index
+		} else {
+			mismatch.Extra = append(mismatch.Extra, col)
+		}
+	}
+	if strict {
+		walkFields(t, nil, func(f reflect.StructField, _ []int) {
+			if name, _, ok := columnTag(f); ok && !seen[name] {
+				mismatch.Missing = append(mismatch.Missing, f.Name)
+			}
+		})
+		if len(mismatch.Extra) > 0 || len(mismatch.Missing) > 0 {
+			return nil, mismatch
+		}
+	}
+
+	var out []T
+	targets := make([]any, len(columns))
+	for rows.Next() {
+		var item T
+		v := reflect.ValueOf(&item).Elem()
+		for i, index := range indexes {
+			if index == nil {
+				targets[i] = new(any)
+				continue
+			}
+			targets[i] = scanTarget(v.FieldByIndex(index))
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", t.Name(), err)
+		}
+		out = append(out, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return out, nil
+}
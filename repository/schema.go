@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"project/apperr"
+)
+
+// ErrUnsupportedDialect is returned for an operation the database's dialect
+// has no implementation of
+var ErrUnsupportedDialect error = apperr.New(apperr.Unimplemented, "operation not supported by the database dialect")
+
+// Schema describes the tables of the connected database
+type Schema struct {
+	Tables []Table
+}
+
+// Table describes a single table
+type Table struct {
+	Name        string
+	Columns     []Column
+	Indexes     []Index
+	Constraints []Constraint
+}
+
+// Column describes a table column as reported by the database
+type Column struct {
+	Name     string
+	DataType string
+	Nullable bool
+	Default  *string
+}
+
+// Index describes a table index
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// Constraint describes a PRIMARY KEY, UNIQUE, FOREIGN KEY or CHECK constraint
+type Constraint struct {
+	Name       string
+	Type       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// Table returns the table with the given name, or nil if it doesn't exist
+func (s *Schema) Table(name string) *Table {
+	for i := range s.Tables {
+		if s.Tables[i].Name == name {
+			return &s.Tables[i]
+		}
+	}
+	return nil
+}
+
+// Column returns the column with the given name, or nil if it doesn't exist
+func (t *Table) Column(name string) *Column {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// introspectionQueries holds the dialect-specific catalog queries. Each must
+// return rows in the column order scanned by inspectSchema.
+type introspectionQueries struct {
+	tables      string // table_name
+	columns     string // table_name, column_name, data_type, is_nullable, column_default
+	indexes     string // table_name, index_name, is_unique, column_name
+	constraints string // table_name, constraint_name, constraint_type, column_name, ref_table, ref_column
+}
+
+// postgresIntrospection reads every user schema. Tables of the current
+// schema keep their bare names, so they match unqualified models; tables
+// of other schemas are named schema.table, as qualified models are.
+var postgresIntrospection = introspectionQueries{
+	tables: `SELECT ` + pgQualified("table_schema", "table_name") + ` FROM information_schema.tables
+		WHERE ` + pgUserSchema("table_schema") + ` AND table_type = 'BASE TABLE'
+		ORDER BY 1`,
+	columns: `SELECT ` + pgQualified("table_schema", "table_name") + `, column_name, data_type, is_nullable = 'YES', column_default
+		FROM information_schema.columns
+		WHERE ` + pgUserSchema("table_schema") + `
+		ORDER BY 1, ordinal_position`,
+	indexes: `SELECT ` + pgQualified("n.nspname", "t.relname") + `, i.relname, ix.indisunique, a.attname
+		FROM pg_index ix
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE ` + pgUserSchema("n.nspname") + `
+		ORDER BY 1, i.relname, k.ord`,
+	constraints: `SELECT ` + pgQualified("tc.table_schema", "tc.table_name") + `, tc.constraint_name, tc.constraint_type,
+			COALESCE(kcu.column_name, ''),
+			COALESCE(` + pgQualified("ref.table_schema", "ref.table_name") + `, ''),
+			COALESCE(ref.column_name, '')
+		FROM information_schema.table_constraints tc
+		LEFT JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_schema = tc.constraint_schema
+			AND kcu.constraint_name = tc.constraint_name
+			AND kcu.table_name = tc.table_name
+		LEFT JOIN information_schema.referential_constraints rc
+			ON rc.constraint_schema = tc.constraint_schema
+			AND rc.constraint_name = tc.constraint_name
+		LEFT JOIN information_schema.key_column_usage ref
+			ON ref.constraint_schema = rc.unique_constraint_schema
+			AND ref.constraint_name = rc.unique_constraint_name
+			AND ref.ordinal_position = kcu.position_in_unique_constraint
+		WHERE ` + pgUserSchema("tc.table_schema") + `
+			AND tc.constraint_name NOT LIKE '%_not_null'
+		ORDER BY 1, tc.constraint_name, kcu.ordinal_position`,
+}
+
+// pgQualified returns the SQL naming a table bare in the current schema
+// and schema-qualified elsewhere
+func pgQualified(schema, table string) string {
+	return "CASE WHEN " + schema + " = current_schema() THEN " + table + " ELSE " + schema + " || '.' || " + table + " END"
+}
+
+// pgUserSchema returns the SQL condition excluding the system schemas
+func pgUserSchema(schema string) string {
+	return schema + " NOT IN ('pg_catalog', 'information_schema') AND " + schema + " NOT LIKE 'pg\\_%'"
+}
+
+var mysqlIntrospection = introspectionQueries{
+	tables: `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'
+		ORDER BY table_name`,
+	columns: `SELECT table_name, column_name, data_type, is_nullable = 'YES', column_default
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE()
+		ORDER BY table_name, ordinal_position`,
+	indexes: `SELECT table_name, index_name, non_unique = 0, column_name
+		FROM information_schema.statistics
+		WHERE table_schema = DATABASE()
+		ORDER BY table_name, index_name, seq_in_index`,
+	constraints: `SELECT tc.table_name, tc.constraint_name, tc.constraint_type,
+			COALESCE(kcu.column_name, ''), COALESCE(kcu.referenced_table_name, ''),
+			COALESCE(kcu.referenced_column_name, '')
+		FROM information_schema.table_constraints tc
+		LEFT JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_schema = tc.constraint_schema
+			AND kcu.constraint_name = tc.constraint_name
+			AND kcu.table_name = tc.table_name
+		WHERE tc.table_schema = DATABASE()
+		ORDER BY tc.table_name, tc.constraint_name, kcu.ordinal_position`,
+}
+
+// InspectSchema returns the tables, columns, indexes and constraints of the
+// connected PostgreSQL database's schemas, see postgresIntrospection
+func (p *PostgresRepo) InspectSchema(ctx context.Context) (*Schema, error) {
+	return inspectSchema(ctx, p.db, postgresIntrospection)
+}
+
+// InspectSchema returns the tables, columns, indexes and constraints of the
+// connected MySQL database
+func (m *MySQLRepo) InspectSchema(ctx context.Context) (*Schema, error) {
+	return inspectSchema(ctx, m.db, mysqlIntrospection)
+}
+
+// InspectSchema returns the schema of the database the migrator targets,
+// read from the catalog of its driver, see WithDriver. Drivers other than
+// postgres and mysql get ErrUnsupportedDialect.
+func (m *Migrator) InspectSchema(ctx context.Context) (*Schema, error) {
+	switch m.driver {
+	case "", "postgres":
+		return inspectSchema(ctx, m.db, postgresIntrospection)
+	case "mysql":
+		return inspectSchema(ctx, m.db, mysqlIntrospection)
+	default:
+		return nil, fmt.Errorf("%w: schema inspection on %s", ErrUnsupportedDialect, m.driver)
+	}
+}
+
+func inspectSchema(ctx context.Context, db querier, q introspectionQueries) (*Schema, error) {
+	schema := &Schema{}
+	byName := make(map[string]int)
+
+	err := eachRow(ctx, db, q.tables, func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		byName[name] = len(schema.Tables)
+		schema.Tables = append(schema.Tables, Table{Name: name})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect tables: %w", err)
+	}
+
+	err = eachRow(ctx, db, q.columns, func(rows *sql.Rows) error {
+		var table string
+		var col Column
+		var def sql.NullString
+		if err := rows.Scan(&table, &col.Name, &col.DataType, &col.Nullable, &def); err != nil {
+			return err
+		}
+		if def.Valid {
+			col.Default = &def.String
+		}
+		if i, ok := byName[table]; ok {
+			schema.Tables[i].Columns = append(schema.Tables[i].Columns, col)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect columns: %w", err)
+	}
+
+	err = eachRow(ctx, db, q.indexes, func(rows *sql.Rows) error {
+		var table, name, column string
+		var unique bool
+		if err := rows.Scan(&table, &name, &unique, &column); err != nil {
+			return err
+		}
+		i, ok := byName[table]
+		if !ok {
+			return nil
+		}
+		t := &schema.Tables[i]
+		if n := len(t.Indexes); n > 0 && t.Indexes[n-1].Name == name {
+			t.Indexes[n-1].Columns = append(t.Indexes[n-1].Columns, column)
+		} else {
+			t.Indexes = append(t.Indexes, Index{Name: name, Unique: unique, Columns: []string{column}})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect indexes: %w", err)
+	}
+
+	err = eachRow(ctx, db, q.constraints, func(rows *sql.Rows) error {
+		var table, name, typ, column, refTable, refColumn string
+		if err := rows.Scan(&table, &name, &typ, &column, &refTable, &refColumn); err != nil {
+			return err
+		}
+		i, ok := byName[table]
+		if !ok {
+			return nil
+		}
+		t := &schema.Tables[i]
+		n := len(t.Constraints)
+		if n == 0 || t.Constraints[n-1].Name != name {
+			t.Constraints = append(t.Constraints, Constraint{Name: name, Type: typ, RefTable: refTable})
+			n++
+		}
+		c := &t.Constraints[n-1]
+		if column != "" {
+			c.Columns = append(c.Columns, column)
+		}
+		if refColumn != "" {
+			c.RefColumns = append(c.RefColumns, refColumn)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect constraints: %w", err)
+	}
+
+	return schema, nil
+}
+
+// eachRow runs query and calls fn for every returned row
+func eachRow(ctx context.Context, db querier, query string, fn func(rows *sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"project/models"
+)
+
+// searchVectorColumn is the generated tsvector column AutoMigrate adds to
+// tables with columns tagged search
+const searchVectorColumn = "search_vector"
+
+// searchConfig is the text search configuration. 'simple' doesn't stem, so
+// it works for names in any language.
+const searchConfig = "simple"
+
+// searchVectorDefinition returns the DDL of the search vector column. As a
+// STORED generated column it is kept current by Postgres on every write.
+// The 'simple' configuration lowercases and unaccentFunction strips
+// diacritics, so "jose" finds "José".
+func searchVectorDefinition(cols []columnDef) string {
+	parts := make([]string, len(cols))
+	for i, col := range cols {
+		parts[i] = fmt.Sprintf("coalesce(%s, '')", col.Name)
+	}
+	return fmt.Sprintf(
+		"TSVECTOR GENERATED ALWAYS AS (to_tsvector('%s', %s(%s))) STORED",
+		searchConfig,
+		unaccentFunction,
+		strings.Join(parts, " || ' ' || "),
+	)
+}
+
+// RankedUser is a full-text search hit with its relevance rank
+type RankedUser struct {
+	models.User
+	Rank float64 `db:"rank"`
+}
+
+// SearchUsersFullText returns users matching query, written in web search
+// syntax ("quoted phrases", -exclusions, or), ordered by ts_rank. Matching
+// ignores case and diacritics.
+func (p *PostgresRepo) SearchUsersFullText(query string, opts ListOptions) ([]RankedUser, error) {
+	q := fmt.Sprintf(`SELECT id, created_at, updated_at, name,
+			ts_rank(search_vector, websearch_to_tsquery('%[1]s', %[2]s($1))) AS rank
+		FROM %[3]s
+		WHERE deleted_at IS NULL AND search_vector @@ websearch_to_tsquery('%[1]s', %[2]s($1))
+		ORDER BY rank DESC, id`, searchConfig, unaccentFunction, p.table(models.User{}))
+	args := []any{normalizeText(query)}
+
+	if opts.Limit > 0 {
+		q += " LIMIT $2 OFFSET $3"
+		args = append(args, opts.Limit, opts.Offset)
+	}
+
+	ctx := context.Background()
+	var ranked []RankedUser
+	err := withHints(ctx, p.db, p.dialect, opts.Hints, func(db querier) error {
+		rows, err := db.QueryContext(ctx, q, args...)
+		if err != nil {
+			return fmt.Errorf("failed to search users: %w", err)
+		}
+		defer rows.Close()
+
+		ranked, err = ScanAll[RankedUser](rows)
+		return err
+	})
+	return ranked, err
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"project/requestid"
+	"project/tenant"
+)
+
+// Session is the cross-cutting state of a unit of work carried in its
+// context: the ambient transaction, tenant, acting user and request ID.
+// Adapters and middleware read it from the context instead of taking each
+// as a parameter.
+type Session struct {
+	// Tx is the transaction calls join, set by WithTransaction
+	Tx        *sql.Tx
+	TenantID  string
+	Actor     string
+	RequestID string
+}
+
+// SessionFromContext returns the session carried by ctx; unset fields are
+// zero
+func SessionFromContext(ctx context.Context) Session {
+	tx, _ := TxFromContext(ctx)
+	return Session{
+		Tx:        tx,
+		TenantID:  tenant.FromContext(ctx),
+		Actor:     tenant.ActorFromContext(ctx),
+		RequestID: requestid.FromContext(ctx),
+	}
+}
+
+// NewSessionContext returns a copy of ctx carrying the tenant, actor and
+// request ID of s; empty fields keep those of ctx. The transaction can't be
+// set this way, use WithTransaction.
+func NewSessionContext(ctx context.Context, s Session) context.Context {
+	if s.TenantID != "" {
+		ctx = tenant.NewContext(ctx, s.TenantID)
+	}
+	if s.Actor != "" {
+		ctx = tenant.WithActor(ctx, s.Actor)
+	}
+	if s.RequestID != "" {
+		ctx = requestid.NewContext(ctx, s.RequestID)
+	}
+	return ctx
+}
+
+// Attributes returns the set fields of s as trace attributes
+func (s Session) Attributes() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if s.RequestID != "" {
+		attrs = append(attrs, attribute.String("request.id", s.RequestID))
+	}
+	if s.TenantID != "" {
+		attrs = append(attrs, attribute.String("tenant.id", s.TenantID))
+	}
+	if s.Actor != "" {
+		attrs = append(attrs, attribute.String("enduser.id", s.Actor))
+	}
+	if s.Tx != nil {
+		attrs = append(attrs, attribute.Bool("db.transaction", true))
+	}
+	return attrs
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"project/models"
+)
+
+// SettingsRepository is implemented by adapters that store each user's
+// settings in user_settings, one-to-one with users
+type SettingsRepository interface {
+	// GetUserWithSettings returns a user with Settings populated, the
+	// defaults if none were saved
+	GetUserWithSettings(id int) (models.User, error)
+	// UpdateUserWithSettings saves user and its Settings in one
+	// transaction, so neither is written if the other fails
+	UpdateUserWithSettings(user models.User) error
+}
+
+// settingsUpsert returns the dialect's clause replacing an existing row
+func settingsUpsert(d Dialect) string {
+	return d.Upsert([]string{"user_id"}, "locale", "timezone", "theme", "email_opt_in") +
+		", updated_at = CURRENT_TIMESTAMP"
+}
+
+// GetUserWithSettings retrieves a user together with their settings
+func (s *SQLRepo) GetUserWithSettings(id int) (models.User, error) {
+	u, err := s.GetByID(id)
+	if err != nil {
+		return models.User{}, err
+	}
+	return preloadSettings(s.db, s.dialect.Bind, s.table(models.UserSettings{}), u)
+}
+
+// UpdateUserWithSettings saves the user and their settings atomically
+func (p *PostgresRepo) UpdateUserWithSettings(user models.User) error {
+	if user.Settings == nil {
+		return fmt.Errorf("user %d has no settings to save", user.ID)
+	}
+	if err := p.hooks.run(BeforeUpdate, &user); err != nil {
+		return err
+	}
+
+	return p.mutate(user.ID, func(tx *sql.Tx) error {
+		if _, err := updateUser(tx, postgresBind, func(v any) driver.Valuer { return postgresArray(v) }, p.table(models.User{}), user); err != nil {
+			return err
+		}
+		return upsertSettings(tx, postgresBind, settingsUpsert(PostgresDialect), p.table(models.UserSettings{}), user.ID, *user.Settings)
+	})
+}
+
+// UpdateUserWithSettings saves the user and their settings atomically
+func (m *MySQLRepo) UpdateUserWithSettings(user models.User) error {
+	if user.Settings == nil {
+		return fmt.Errorf("user %d has no settings to save", user.ID)
+	}
+	if err := m.hooks.run(BeforeUpdate, &user); err != nil {
+		return err
+	}
+
+	return NewMySQLTransactor(m.db).WithTransaction(context.Background(), func(ctx context.Context) error {
+		tx, _ := TxFromContext(ctx)
+		if _, err := updateUser(tx, mysqlBind, jsonArray, m.table(models.User{}), user); err != nil {
+			return err
+		}
+		return upsertSettings(tx, mysqlBind, settingsUpsert(MySQLDialect), m.table(models.UserSettings{}), user.ID, *user.Settings)
+	})
+}
+
+// preloadSettings fills user.Settings, with the defaults when the user
+// never saved any
+func preloadSettings(db *sql.DB, bind func(int) string, table string, user models.User) (models.User, error) {
+	rows, err := db.Query(fmt.Sprintf(
+		"SELECT user_id, locale, timezone, theme, email_opt_in, updated_at FROM %s WHERE user_id = %s", table, bind(1)),
+		user.ID,
+	)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to query user settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings, err := ScanAll[models.UserSettings](rows)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	s := models.DefaultUserSettings(user.ID)
+	if len(settings) > 0 {
+		s = settings[0]
+	}
+	user.Settings = &s
+	return user, nil
+}
+
+// upsertSettings creates or replaces the settings row of userID; upsert is
+// the dialect's conflict clause
+func upsertSettings(db execer, bind func(int) string, upsert, table string, userID int, s models.UserSettings) error {
+	if s.UserID != 0 && s.UserID != userID {
+		return errors.New("settings belong to a different user")
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (user_id, locale, timezone, theme, email_opt_in) VALUES (%s, %s, %s, %s, %s) %s",
+		table, bind(1), bind(2), bind(3), bind(4), bind(5), upsert,
+	)
+	if _, err := db.Exec(query, userID, s.Locale, s.Timezone, s.Theme, s.EmailOptIn); err != nil {
+		return fmt.Errorf("failed to save user settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"time"
+
+	"project/clock"
+)
+
+// eventStoreMetrics exposes snapshotting on /debug/vars
+var eventStoreMetrics = expvar.NewMap("event_store")
+
+// Snapshot is the state of an aggregate at a version of its stream, so
+// rehydrating it only replays the events after that version
+type Snapshot struct {
+	StreamID int
+	Version  int
+	// Schema is the version of the state's encoding. Snapshots of another
+	// schema are ignored and replaced, so changing an aggregate's shape
+	// only needs a bump of its schema.
+	Schema  int
+	State   json.RawMessage
+	TakenAt time.Time
+}
+
+// SaveSnapshot stores the state of a stream at version, replacing an older
+// snapshot. A snapshot older than the stored one is dropped, so racing
+// writers can't move it backwards.
+func (s *EventStore) SaveSnapshot(ctx context.Context, streamID, version, schema int, state any) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	if _, err := dbFrom(ctx, s.db).ExecContext(ctx, s.saveSnapshot,
+		streamID, version, schema, string(data), clock.Or(s.Clock).Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot returns the latest snapshot of a stream, or ErrNotFound
+func (s *EventStore) LoadSnapshot(ctx context.Context, streamID int) (Snapshot, error) {
+	var (
+		snap Snapshot
+		data []byte
+	)
+	err := dbFrom(ctx, s.db).QueryRowContext(ctx,
+		fmt.Sprintf("SELECT stream_id, version, schema_version, state, taken_at FROM snapshots WHERE stream_id = %s", s.bind(1)),
+		streamID,
+	).Scan(&snap.StreamID, &snap.Version, &snap.Schema, &data, &snap.TakenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, ErrNotFound
+	}
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	snap.State = data
+	return snap, nil
+}
+
+// RehydrateFromSnapshot restores agg from the stream's snapshot when it
+// has the given schema, then applies the events after it. It returns the
+// version reached and whether a snapshot was used.
+func (s *EventStore) RehydrateFromSnapshot(ctx context.Context, streamID, schema int, agg Aggregate) (int, bool, error) {
+	snap, err := s.LoadSnapshot(ctx, streamID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return 0, false, err
+	}
+
+	after := 0
+	if err == nil && snap.Schema == schema {
+		if err := json.Unmarshal(snap.State, agg); err != nil {
+			return 0, false, fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+		after = snap.Version
+	}
+
+	version, err := s.Rehydrate(ctx, streamID, after, agg)
+	if err != nil {
+		return 0, false, err
+	}
+	return version, after > 0, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"project/models"
+)
+
+// SortField orders results by one column
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// sortableUserColumns are the user columns results may be sorted by;
+// column names can't be bound as parameters, so nothing else reaches SQL
+var sortableUserColumns = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"name":       true,
+	"email":      true,
+	"tenant_id":  true,
+}
+
+// ParseSort parses a comma-separated sort spec such as "name,-created_at",
+// where a leading "-" sorts descending
+func ParseSort(spec string) ([]SortField, error) {
+	var fields []SortField
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		f := SortField{Column: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !sortableUserColumns[f.Column] {
+			return nil, fmt.Errorf("cannot sort users by %q", f.Column)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// withTiebreak returns sort, or fallback when empty, ending in id so rows
+// with equal sort keys keep the same order across pages
+func withTiebreak(sort []SortField, fallback ...SortField) ([]SortField, error) {
+	if len(sort) == 0 {
+		sort = fallback
+	}
+	out := make([]SortField, 0, len(sort)+1)
+	for _, f := range sort {
+		if !sortableUserColumns[f.Column] {
+			return nil, fmt.Errorf("cannot sort users by %q", f.Column)
+		}
+		out = append(out, f)
+		if f.Column == "id" {
+			return out, nil
+		}
+	}
+	return append(out, SortField{Column: "id"}), nil
+}
+
+// orderBy returns the ORDER BY clause for sort, see withTiebreak
+func orderBy(sort []SortField, fallback ...SortField) (string, error) {
+	fields, err := withTiebreak(sort, fallback...)
+	if err != nil {
+		return "", err
+	}
+	terms := make([]string, len(fields))
+	for i, f := range fields {
+		terms[i] = f.Column
+		if f.Desc {
+			terms[i] += " DESC"
+		}
+	}
+	return "ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+// sortUsers sorts users in memory the way orderBy sorts them in SQL, for
+// adapters that can't push ordering down
+func sortUsers(users []models.User, sort []SortField, fallback ...SortField) error {
+	fields, err := withTiebreak(sort, fallback...)
+	if err != nil {
+		return err
+	}
+	index := columnFields(reflect.TypeOf(models.User{}))
+	slices.SortStableFunc(users, func(a, b models.User) int {
+		av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+		for _, f := range fields {
+			c := compareValues(av.FieldByIndex(index[f.Column]), bv.FieldByIndex(index[f.Column]))
+			if f.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return nil
+}
+
+// compareValues orders two values of a sortable column
+func compareValues(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	}
+	if t, ok := a.Interface().(time.Time); ok {
+		return t.Compare(b.Interface().(time.Time))
+	}
+	return 0
+}
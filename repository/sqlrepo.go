@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"project/models"
+)
+
+// SQLRepo implements UserRepository on any SQL database through its
+// Dialect, so every adapter shares the same queries and behaves the same.
+// PostgresRepo and MySQLRepo embed it and add what only their database can
+// do.
+type SQLRepo struct {
+	db      *sql.DB
+	dialect Dialect
+	hooks   Hooks
+	ids     IDGenerator
+	maxRows int
+	counter *UserCounter
+	replica *replicaRouter
+	naming  NamingStrategy
+
+	// mutator runs an update or delete of user id; adapters override it to
+	// wrap writes, e.g. to record history
+	mutator func(id int, fn func(db execer) error) error
+}
+
+// NewSQLRepo creates a repository for db speaking dialect
+func NewSQLRepo(db *sql.DB, dialect Dialect) *SQLRepo {
+	s := &SQLRepo{db: db, dialect: dialect}
+	s.mutator = func(_ int, fn func(db execer) error) error {
+		if s.counter == nil {
+			return fn(s.db)
+		}
+		// the counter update must commit with the write
+		return s.inTx(context.Background(), func(ctx context.Context) error {
+			tx, _ := TxFromContext(ctx)
+			return fn(tx)
+		})
+	}
+	return s
+}
+
+// Dialect returns the dialect of the repository
+func (s *SQLRepo) Dialect() Dialect {
+	return s.dialect
+}
+
+// Hooks returns the lifecycle callbacks of this repository
+func (s *SQLRepo) Hooks() *Hooks {
+	return &s.hooks
+}
+
+// Create inserts a new user and returns it as stored
+func (s *SQLRepo) Create(user models.User) (models.User, error) {
+	return s.CreateContext(context.Background(), user)
+}
+
+// CreateContext inserts a new user, joining the transaction in ctx if any,
+// and returns it with its generated ID and timestamps
+func (s *SQLRepo) CreateContext(ctx context.Context, user models.User) (models.User, error) {
+	if err := s.hooks.run(BeforeCreate, &user); err != nil {
+		return models.User{}, err
+	}
+
+	d := s.dialect
+	err := s.counted(ctx, 1, func(ctx context.Context) error {
+		return insertUser(ctx, dbFrom(ctx, s.db), d, s.table(models.User{}), &user)
+	})
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to insert user: %w", err)
+	}
+
+	if err := s.hooks.run(AfterCreate, &user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// insertUser inserts user, its name in NFC, and sets its ID and timestamps
+// from the new row: with RETURNING on Postgres, from LastInsertId and a
+// read back on MySQL
+func insertUser(ctx context.Context, db querier, d Dialect, table string, user *models.User) error {
+	user.Name = normalizeText(user.Name)
+	query := fmt.Sprintf(
+		"INSERT INTO %s (name, email, tags, tenant_id) VALUES (%s, %s, %s, %s)",
+		table, d.Bind(1), d.Bind(2), d.Bind(3), d.Bind(4))
+	args := []any{user.Name, user.Email, d.Array(user.Tags), user.TenantID}
+
+	if d.Name() == "postgres" {
+		return db.QueryRowContext(ctx, query+" RETURNING id, created_at, updated_at", args...).
+			Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	}
+	id, err := d.InsertID(ctx, db, query, args...)
+	if err != nil {
+		return err
+	}
+	user.ID = int(id)
+	return db.QueryRowContext(ctx, fmt.Sprintf("SELECT created_at, updated_at FROM %s WHERE id = %s", table, d.Bind(1)), id).
+		Scan(&user.CreatedAt, &user.UpdatedAt)
+}
+
+// GetAll retrieves all users, in ID order
+func (s *SQLRepo) GetAll() ([]models.User, error) {
+	rows, err := s.reader(ListOptions{}).Query(limitRows("SELECT id, name FROM "+s.table(models.User{})+" WHERE deleted_at IS NULL ORDER BY id", s.maxRows))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users, err := ScanAll[models.User](rows)
+	if err != nil {
+		return nil, err
+	}
+	return checkRows(users, s.maxRows)
+}
+
+// GetByID retrieves a single user by ID, returning ErrNotFound if none exists
+func (s *SQLRepo) GetByID(id int) (models.User, error) {
+	var u models.User
+	if err := s.GetByKey(id, &u); err != nil {
+		return models.User{}, err
+	}
+	return u, nil
+}
+
+// GetByKey loads the row matching key into dest, a pointer to a model. For
+// models with a composite primary key, key is a struct holding each key column.
+func (s *SQLRepo) GetByKey(key any, dest any) error {
+	return getByKey(s.reader(ListOptions{}), s.dialect.Bind, s.naming, key, dest)
+}
+
+// CreatePost inserts a new post
+func (s *SQLRepo) CreatePost(post models.Post) error {
+	return createPost(s.db, s.dialect.Bind, s.table(models.Post{}), post)
+}
+
+// GetUserWithPosts retrieves a user together with all of their posts
+func (s *SQLRepo) GetUserWithPosts(id int) (models.User, error) {
+	u, err := s.GetByID(id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	users := []models.User{u}
+	if err := preloadPosts(s.db, s.dialect.Bind, s.table(models.Post{}), users); err != nil {
+		return models.User{}, err
+	}
+	return users[0], nil
+}
+
+// GetAllWithPosts retrieves all users with their posts preloaded in one query
+func (s *SQLRepo) GetAllWithPosts() ([]models.User, error) {
+	users, err := s.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := preloadPosts(s.db, s.dialect.Bind, s.table(models.Post{}), users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ListCreatedBetween retrieves users created in [from, to), oldest first
+func (s *SQLRepo) ListCreatedBetween(from, to time.Time, opts ListOptions) ([]models.User, error) {
+	return listCreatedBetween(s.reader(opts), s.dialect, s.table(models.User{}), from, to, opts, s.maxRows)
+}
+
+// Update saves the name and tags of an existing user
+func (s *SQLRepo) Update(user models.User) error {
+	_, err := s.UpdateResult(user)
+	return err
+}
+
+// UpdateResult is Update reporting the rows it changed
+func (s *SQLRepo) UpdateResult(user models.User) (WriteResult, error) {
+	if err := s.hooks.run(BeforeUpdate, &user); err != nil {
+		return WriteResult{}, err
+	}
+
+	var res WriteResult
+	err := s.mutator(user.ID, func(db execer) (err error) {
+		res.RowsAffected, err = updateUser(db, s.dialect.Bind, s.dialect.Array, s.table(models.User{}), user)
+		return err
+	})
+	if err != nil {
+		return WriteResult{}, err
+	}
+	return res, nil
+}
+
+// Delete soft-deletes a user; the row is kept until archived
+func (s *SQLRepo) Delete(id int) error {
+	_, err := s.DeleteResult(id)
+	return err
+}
+
+// DeleteResult is Delete reporting the rows it changed
+func (s *SQLRepo) DeleteResult(id int) (WriteResult, error) {
+	var res WriteResult
+	err := s.mutator(id, func(db execer) (err error) {
+		if res.RowsAffected, err = softDelete(db, s.dialect.Bind, s.table(models.User{}), id); err != nil {
+			return err
+		}
+		return s.counter.add(db, -1)
+	})
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	// only the ID is known without an extra read
+	if err := s.hooks.run(AfterDelete, &models.User{Base: models.Base{ID: id}}); err != nil {
+		return WriteResult{}, err
+	}
+	return res, nil
+}
+
+// SetIDGenerator sets the generator used for keys tagged manual
+func (s *SQLRepo) SetIDGenerator(gen IDGenerator) {
+	s.ids = gen
+}
+
+// Insert inserts any model, passed as a pointer. Keys tagged manual are
+// generated with the repository's IDGenerator and written back to the model.
+func (s *SQLRepo) Insert(model any) error {
+	if err := s.hooks.run(BeforeCreate, model); err != nil {
+		return err
+	}
+	if err := insertModel(s.db, s.dialect.Bind, s.dialect.Array, s.naming, s.ids, model); err != nil {
+		return err
+	}
+	return s.hooks.run(AfterCreate, model)
+}
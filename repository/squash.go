@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// baselineName names the migration a squash produces
+const baselineName = "baseline"
+
+// SquashResult describes a squash of versioned migrations
+type SquashResult struct {
+	// Baseline is the file that replaced the squashed migrations; Removed
+	// are the files it replaced
+	Baseline string
+	Removed  []string
+	// Pruned is the number of schema_migrations rows removed
+	Pruned int64
+}
+
+// SquashMigrations collapses the migrations up to and including version
+// through into one baseline migration with that version, so databases that
+// applied them are already at the baseline
+func SquashMigrations(migrations []Migration, through int64) (Migration, error) {
+	var squashed []Migration
+	for _, mig := range migrations {
+		if mig.Version <= through {
+			squashed = append(squashed, mig)
+		}
+	}
+	if len(squashed) == 0 || squashed[len(squashed)-1].Version != through {
+		return Migration{}, fmt.Errorf("no migration with version %d to squash", through)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Baseline squashed from migrations %d through %d.\n", squashed[0].Version, through)
+	b.WriteString("-- Databases that applied them record only this version in schema_migrations.\n")
+	for _, mig := range squashed {
+		fmt.Fprintf(&b, "\n-- %d_%s\n%s\n", mig.Version, mig.Name, strings.TrimSpace(mig.SQL))
+	}
+	return Migration{Version: through, Name: baselineName, SQL: b.String()}, nil
+}
+
+// Squash collapses the applied migrations in dir up to and including
+// version through, or all applied ones when through is 0, into a baseline
+// file and removes the rows of the squashed versions below it from
+// schema_migrations. Only squash migrations every environment has applied:
+// a database still behind would run the whole baseline.
+//
+// Once dir holds the baseline, Squash against another database only prunes
+// its schema_migrations, so it can be run once per environment.
+func (m *Migrator) Squash(ctx context.Context, dir string, through int64) (*SquashResult, error) {
+	migrations, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var result *SquashResult
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if through == 0 {
+			for _, mig := range migrations {
+				if !applied[mig.Version] {
+					break
+				}
+				through = mig.Version
+			}
+			if through == 0 {
+				return fmt.Errorf("no applied migrations to squash")
+			}
+		}
+
+		var squashed []Migration
+		for _, mig := range migrations {
+			if mig.Version > through {
+				break
+			}
+			if !applied[mig.Version] {
+				return fmt.Errorf("migration %d_%s is not applied, only applied migrations can be squashed", mig.Version, mig.Name)
+			}
+			squashed = append(squashed, mig)
+		}
+
+		result = &SquashResult{}
+		if len(squashed) == 1 && squashed[0].Name == baselineName {
+			// dir is already squashed, only this database's history is left
+			result.Baseline = filepath.Join(dir, squashed[0].File)
+		} else {
+			if result.Baseline, result.Removed, err = writeBaseline(dir, migrations, squashed, through); err != nil {
+				return err
+			}
+		}
+
+		// the files go first: a table pruned while the squashed files
+		// remain would have Migrate apply them again
+		res, err := conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM schema_migrations WHERE version < %d", through))
+		if err != nil {
+			return fmt.Errorf("failed to prune migrations table: %w", err)
+		}
+		result.Pruned, _ = res.RowsAffected()
+		return nil
+	})
+	return result, err
+}
+
+// writeBaseline writes the baseline replacing squashed to dir and removes
+// the squashed files, returning the baseline path and the removed files
+func writeBaseline(dir string, migrations, squashed []Migration, through int64) (string, []string, error) {
+	baseline, err := SquashMigrations(migrations, through)
+	if err != nil {
+		return "", nil, err
+	}
+	name := fmt.Sprintf("%04d_%s.sql", baseline.Version, baseline.Name)
+	path := filepath.Join(dir, name)
+
+	// write next to the target and rename, so a failed write leaves the
+	// squashed files untouched
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(baseline.SQL), 0o644); err != nil {
+		return "", nil, fmt.Errorf("failed to write baseline: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", nil, fmt.Errorf("failed to write baseline: %w", err)
+	}
+
+	var removed []string
+	for _, mig := range squashed {
+		if mig.File == name {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, mig.File)); err != nil {
+			return path, removed, fmt.Errorf("failed to remove squashed migration: %w", err)
+		}
+		removed = append(removed, mig.File)
+	}
+	return path, removed, nil
+}
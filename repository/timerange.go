@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"project/models"
+)
+
+// listCreatedBetween backs ListCreatedBetween for the SQL adapters; the
+// created_at index keeps the range scan cheap on large tables. Without a
+// Limit the result is capped at maxRows, see SetMaxRows.
+func listCreatedBetween(db *sql.DB, d Dialect, table string, from, to time.Time, opts ListOptions, maxRows int) ([]models.User, error) {
+	bind := d.Bind
+	order, err := orderBy(opts.Sort, SortField{Column: "created_at"})
+	if err != nil {
+		return nil, err
+	}
+	hinted, err := hintedTable(d, table, opts.Hints)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(
+		"SELECT id, created_at, updated_at, name FROM %s WHERE deleted_at IS NULL AND created_at >= %s AND created_at < %s %s",
+		hinted, bind(1), bind(2), order,
+	)
+	args := []any{from, to}
+
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit, opts.Offset)
+		query += fmt.Sprintf(" LIMIT %s OFFSET %s", bind(3), bind(4))
+	} else if opts.Offset > 0 {
+		return nil, fmt.Errorf("offset requires a limit")
+	} else {
+		query = limitRows(query, maxRows)
+	}
+
+	ctx := context.Background()
+	var users []models.User
+	err = withHints(ctx, db, d, opts.Hints, func(q querier) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query users: %w", err)
+		}
+		defer rows.Close()
+
+		users, err = ScanAll[models.User](rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Limit > 0 {
+		return users, nil
+	}
+	return checkRows(users, maxRows)
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// txKey is the context key of the ambient transaction
+type txKey struct{}
+
+// txState is the transaction stored in a context by WithTransaction
+type txState struct {
+	tx    *sql.Tx
+	opts  sql.TxOptions
+	depth int
+}
+
+// TxFromContext returns the transaction started by WithTransaction, if any
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	st, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	return st.tx, true
+}
+
+// dbFrom returns the ambient transaction of ctx, or db outside one
+func dbFrom(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// Transactor runs functions inside database transactions
+type Transactor struct {
+	db     *sql.DB
+	levels map[sql.IsolationLevel]sql.IsolationLevel
+	retry  RetryPolicy
+	rls    bool
+}
+
+// postgresLevels maps requested isolation levels to the ones Postgres runs.
+// Postgres treats READ UNCOMMITTED as READ COMMITTED, so ask for that.
+var postgresLevels = map[sql.IsolationLevel]sql.IsolationLevel{
+	sql.LevelDefault:         sql.LevelDefault,
+	sql.LevelReadUncommitted: sql.LevelReadCommitted,
+	sql.LevelReadCommitted:   sql.LevelReadCommitted,
+	sql.LevelRepeatableRead:  sql.LevelRepeatableRead,
+	sql.LevelSerializable:    sql.LevelSerializable,
+}
+
+// mysqlLevels maps requested isolation levels to the ones InnoDB runs
+var mysqlLevels = map[sql.IsolationLevel]sql.IsolationLevel{
+	sql.LevelDefault:         sql.LevelDefault,
+	sql.LevelReadUncommitted: sql.LevelReadUncommitted,
+	sql.LevelReadCommitted:   sql.LevelReadCommitted,
+	sql.LevelRepeatableRead:  sql.LevelRepeatableRead,
+	sql.LevelSerializable:    sql.LevelSerializable,
+}
+
+// NewTransactor creates a transactor for a PostgreSQL db
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db: db, levels: postgresLevels}
+}
+
+// NewMySQLTransactor creates a transactor for a MySQL db. Transactions hit
+// by a deadlock or lock wait timeout are re-run per DefaultMySQLRetryPolicy.
+func NewMySQLTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db: db, levels: mysqlLevels, retry: DefaultMySQLRetryPolicy}
+}
+
+// WithRetry sets the policy for re-running transactions that fail with a
+// retryable error. fn must be safe to run more than once.
+func (t *Transactor) WithRetry(policy RetryPolicy) *Transactor {
+	t.retry = policy
+	return t
+}
+
+// WithTransaction runs fn in a transaction carried by the context passed to
+// it. A nested WithTransaction on that context creates a SAVEPOINT instead of
+// a new transaction: if the inner fn fails only its work is rolled back and
+// the error is returned to the outer fn, which decides whether to carry on.
+// The transaction commits when the outermost fn returns nil.
+func (t *Transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return t.WithTransactionOptions(ctx, sql.TxOptions{}, fn)
+}
+
+// WithTransactionOptions is WithTransaction with an isolation level and
+// access mode. Isolation levels the database can't provide are rejected
+// rather than silently downgraded. A nested call runs in the outer
+// transaction, so it may only repeat the outer options or leave them zero.
+func (t *Transactor) WithTransactionOptions(ctx context.Context, opts sql.TxOptions, fn func(ctx context.Context) error) error {
+	level, ok := t.levels[opts.Isolation]
+	if !ok {
+		return fmt.Errorf("isolation level %s is not supported by this database", opts.Isolation)
+	}
+	opts.Isolation = level
+
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		if opts != (sql.TxOptions{}) && opts != st.opts {
+			return fmt.Errorf("nested transaction cannot change options from %+v to %+v", st.opts, opts)
+		}
+		// retries happen at the outermost level, which re-runs the inner
+		// closures along with everything else
+		return withSavepoint(ctx, st, fn)
+	}
+
+	return t.retry.run(ctx, func() error {
+		return t.runTx(ctx, opts, fn)
+	})
+}
+
+// runTx runs fn once in a new transaction
+func (t *Transactor) runTx(ctx context.Context, opts sql.TxOptions, fn func(ctx context.Context) error) error {
+	tx, err := t.db.BeginTx(ctx, &opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if t.rls {
+		if err := setSessionIdentity(ctx, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, &txState{tx: tx, opts: opts})); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func withSavepoint(ctx context.Context, parent *txState, fn func(ctx context.Context) error) error {
+	inner := &txState{tx: parent.tx, opts: parent.opts, depth: parent.depth + 1}
+	name := fmt.Sprintf("sp_%d", inner.depth)
+
+	if _, err := parent.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			parent.tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, inner)); err != nil {
+		if _, rbErr := parent.tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if _, err := parent.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// WithTransaction runs fn in a transaction, or a savepoint when nested.
+// Context-aware repository methods called with the ctx passed to fn join it.
+func (p *PostgresRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.transactor().WithTransaction(ctx, fn)
+}
+
+// WithTransaction runs fn in a transaction, or a savepoint when nested.
+// Context-aware repository methods called with the ctx passed to fn join it.
+func (m *MySQLRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return NewMySQLTransactor(m.db).WithTransaction(ctx, fn)
+}
+
+// WithTransactionOptions runs fn in a transaction with the given isolation
+// level and access mode
+func (p *PostgresRepo) WithTransactionOptions(ctx context.Context, opts sql.TxOptions, fn func(ctx context.Context) error) error {
+	return p.transactor().WithTransactionOptions(ctx, opts, fn)
+}
+
+// WithTransactionOptions runs fn in a transaction with the given isolation
+// level and access mode
+func (m *MySQLRepo) WithTransactionOptions(ctx context.Context, opts sql.TxOptions, fn func(ctx context.Context) error) error {
+	return NewMySQLTransactor(m.db).WithTransactionOptions(ctx, opts, fn)
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"project/models"
+)
+
+// UserCountShards is the number of rows of user_count_shards
+const UserCountShards = 16
+
+// TotalUsersRepository is implemented by adapters that count live users
+type TotalUsersRepository interface {
+	TotalUsers(ctx context.Context) (int64, error)
+}
+
+// UserCounter maintains the number of live users in user_count_shards, so
+// totals don't need a COUNT(*) of the users table. Each write adds to a
+// random shard, so concurrent writers seldom wait on the same row, and the
+// total is the sum of the shards.
+type UserCounter struct {
+	db      *sql.DB
+	dialect Dialect
+
+	// Naming names the users table counted on reconciliation, as
+	// AutoMigrate did
+	Naming NamingStrategy
+}
+
+// NewUserCounter creates a counter over the shards in db
+func NewUserCounter(db *sql.DB, dialect Dialect) *UserCounter {
+	return &UserCounter{db: db, dialect: dialect}
+}
+
+// add adds delta to a random shard through db, normally the transaction of
+// the write being counted
+func (c *UserCounter) add(db execer, delta int) error {
+	if c == nil {
+		return nil
+	}
+	_, err := db.Exec(fmt.Sprintf("UPDATE user_count_shards SET value = value + %s WHERE shard = %s",
+		c.dialect.Bind(1), c.dialect.Bind(2)), delta, rand.Intn(UserCountShards))
+	if err != nil {
+		return fmt.Errorf("failed to update user count: %w", err)
+	}
+	return nil
+}
+
+// Total returns the number of live users
+func (c *UserCounter) Total(ctx context.Context) (int64, error) {
+	var n int64
+	if err := dbFrom(ctx, c.db).QueryRowContext(ctx, "SELECT COALESCE(SUM(value), 0) FROM user_count_shards").Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to read user count: %w", err)
+	}
+	return n, nil
+}
+
+// Reconcile resets the shards to a COUNT(*) of the live users, correcting
+// writes the counter doesn't see, e.g. batch imports, bulk deletes and
+// restores. It returns how far the counter was off.
+func (c *UserCounter) Reconcile(ctx context.Context) (int64, error) {
+	if err := c.ensureShards(ctx); err != nil {
+		return 0, err
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// locking every shard waits out the counted writes in flight and holds
+	// back new ones, so the count below matches the shards
+	var counted int64
+	rows, err := tx.QueryContext(ctx, "SELECT value FROM user_count_shards FOR UPDATE")
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock user count: %w", err)
+	}
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan user count: %w", err)
+		}
+		counted += v
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	var actual int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+c.Naming.TableName(models.User{})+" WHERE deleted_at IS NULL").Scan(&actual); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	if actual != counted {
+		if _, err := tx.ExecContext(ctx, "UPDATE user_count_shards SET value = CASE WHEN shard = 0 THEN "+c.dialect.Bind(1)+" ELSE 0 END", actual); err != nil {
+			return 0, fmt.Errorf("failed to reset user count: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit user count: %w", err)
+	}
+	return actual - counted, nil
+}
+
+// ensureShards creates missing shard rows, which would drop their updates
+func (c *UserCounter) ensureShards(ctx context.Context) error {
+	for shard := 0; shard < UserCountShards; shard++ {
+		_, err := c.db.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO user_count_shards (shard, value) SELECT %[1]s, 0 WHERE NOT EXISTS (SELECT 1 FROM user_count_shards WHERE shard = %[1]s)",
+			c.dialect.Bind(1)), shard)
+		if err != nil {
+			return fmt.Errorf("failed to create user count shard: %w", err)
+		}
+	}
+	return nil
+}
+
+// Schedule reconciles the counter every interval until ctx is cancelled,
+// logging corrections with logf
+func (c *UserCounter) Schedule(ctx context.Context, interval time.Duration, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			drift, err := c.Reconcile(ctx)
+			switch {
+			case err != nil:
+				logf("User count reconciliation failed: %v", err)
+			case drift != 0:
+				logf("User count corrected by %+d", drift)
+			}
+		}
+	}
+}
+
+// SetUserCounter makes Create and Delete maintain c in the transaction of
+// the write, and TotalUsers read it instead of counting rows
+func (s *SQLRepo) SetUserCounter(c *UserCounter) {
+	s.counter = c
+}
+
+// TotalUsers implements TotalUsersRepository
+func (s *SQLRepo) TotalUsers(ctx context.Context) (int64, error) {
+	if s.counter != nil {
+		return s.counter.Total(ctx)
+	}
+	var n int64
+	if err := dbFrom(ctx, s.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.table(models.User{})+" WHERE deleted_at IS NULL").Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return n, nil
+}
+
+// counted runs write and adds delta to the user counter in one
+// transaction
+func (s *SQLRepo) counted(ctx context.Context, delta int, write func(ctx context.Context) error) error {
+	if s.counter == nil {
+		return write(ctx)
+	}
+	return s.inTx(ctx, func(ctx context.Context) error {
+		if err := write(ctx); err != nil {
+			return err
+		}
+		tx, _ := TxFromContext(ctx)
+		return s.counter.add(tx, delta)
+	})
+}
+
+// inTx runs fn in the ambient transaction of ctx, or in a new one carried
+// by the context passed to fn
+func (s *SQLRepo) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, &txState{tx: tx})); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	timePtrType  = reflect.TypeOf((*time.Time)(nil))
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// isTimeField reports whether t is a time.Time, *time.Time or sql.NullTime,
+// whose scanned values are converted to UTC
+func isTimeField(t reflect.Type) bool {
+	return t == timeType || t == timePtrType || t == nullTimeType
+}
+
+// utcScanner scans a timestamp into a time field in UTC, so a row reads
+// the same whichever server, driver or session time zone produced it
+type utcScanner struct {
+	dest reflect.Value
+}
+
+// Scan implements sql.Scanner
+func (u utcScanner) Scan(src any) error {
+	var nt sql.NullTime
+	if err := nt.Scan(src); err != nil {
+		return err
+	}
+	t := nt.Time.UTC()
+
+	switch u.dest.Type() {
+	case timeType:
+		if !nt.Valid {
+			return fmt.Errorf("cannot scan NULL into %s", u.dest.Type())
+		}
+		u.dest.Set(reflect.ValueOf(t))
+	case timePtrType:
+		if !nt.Valid {
+			u.dest.Set(reflect.Zero(timePtrType))
+			return nil
+		}
+		u.dest.Set(reflect.ValueOf(&t))
+	default:
+		u.dest.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: nt.Valid}))
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"project/clock"
+	"project/locks"
+)
+
+// viewMetrics exposes materialized view refreshes on /debug/vars
+var viewMetrics = expvar.NewMap("materialized_views")
+
+// Materialized views created by the migrations
+const (
+	UserCountsByDayView         = "user_counts_by_day"
+	OrganizationMemberCountView = "organization_member_counts"
+)
+
+// MaterializedView is a view refreshed on a schedule. Reads of it may be
+// up to Interval stale.
+type MaterializedView struct {
+	Name     string
+	Interval time.Duration
+}
+
+// DefaultMaterializedViews returns the reporting views of the migrations
+func DefaultMaterializedViews() []MaterializedView {
+	return []MaterializedView{
+		{Name: UserCountsByDayView, Interval: 5 * time.Minute},
+		{Name: OrganizationMemberCountView, Interval: 5 * time.Minute},
+	}
+}
+
+// ViewRefresher refreshes PostgreSQL materialized views. Refreshes run
+// CONCURRENTLY, so readers are never blocked; each view needs a unique
+// index for that.
+type ViewRefresher struct {
+	db    *sql.DB
+	views []MaterializedView
+	// Clock times refreshes; nil means the system clock
+	Clock clock.Clock
+	// LockFor, when set, returns the lock of a view; Run skips refreshing
+	// a view while another instance holds its lock
+	LockFor func(view string) locks.Lock
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// NewViewRefresher creates a refresher for views
+func NewViewRefresher(db *sql.DB, views ...MaterializedView) *ViewRefresher {
+	return &ViewRefresher{db: db, views: views, last: make(map[string]time.Time)}
+}
+
+// Refresh refreshes one view now
+func (r *ViewRefresher) Refresh(ctx context.Context, name string) error {
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("invalid view name %q", name)
+	}
+
+	start := clock.Or(r.Clock).Now()
+	if _, err := r.db.ExecContext(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY "+name); err != nil {
+		viewMetrics.Add(name+".errors_total", 1)
+		return fmt.Errorf("failed to refresh %s: %w", name, err)
+	}
+	end := clock.Or(r.Clock).Now()
+
+	r.mu.Lock()
+	r.last[name] = end
+	r.mu.Unlock()
+
+	viewMetrics.Add(name+".refreshes_total", 1)
+	viewMetrics.Add(name+".refresh_ms_total", end.Sub(start).Milliseconds())
+	return nil
+}
+
+// LastRefresh returns when a view was last refreshed by r, or the zero
+// time
+func (r *ViewRefresher) LastRefresh(name string) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last[name]
+}
+
+// Run refreshes each view on its interval until ctx is cancelled, logging
+// failures with logf. Every view is refreshed once at start.
+func (r *ViewRefresher) Run(ctx context.Context, logf func(format string, args ...any)) {
+	var wg sync.WaitGroup
+	for _, v := range r.views {
+		wg.Add(1)
+		go func(v MaterializedView) {
+			defer wg.Done()
+			ticker := time.NewTicker(v.Interval)
+			defer ticker.Stop()
+
+			var lock locks.Lock
+			if r.LockFor != nil {
+				lock = r.LockFor(v.Name)
+			}
+
+			for {
+				_, err := runLocked(ctx, lock, func(ctx context.Context) error {
+					return r.Refresh(ctx, v.Name)
+				})
+				if err != nil && ctx.Err() == nil {
+					logf("Failed to refresh materialized view: %v", err)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(v)
+	}
+	wg.Wait()
+}
+
+// DailyCount is one row of a per-day reporting view
+type DailyCount struct {
+	Day   time.Time
+	Count int64
+}
+
+// ReportingRepository is implemented by adapters that serve reporting
+// queries from materialized views instead of the hot tables. Views are
+// not covered by row-level security, so each method filters by tenant.
+type ReportingRepository interface {
+	UserCountsByDay(ctx context.Context, tenantID string, from, to time.Time) ([]DailyCount, error)
+	OrganizationMemberCount(ctx context.Context, tenantID string, orgID int) (int64, error)
+}
+
+// UserCountsByDay returns the users created per day in [from, to), as of
+// the last refresh of user_counts_by_day
+func (p *PostgresRepo) UserCountsByDay(ctx context.Context, tenantID string, from, to time.Time) ([]DailyCount, error) {
+	rows, err := dbFrom(ctx, p.db).QueryContext(ctx,
+		"SELECT day, users FROM "+UserCountsByDayView+" WHERE tenant_id = $1 AND day >= $2 AND day < $3 ORDER BY day",
+		tenantID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user counts: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []DailyCount
+	for rows.Next() {
+		var c DailyCount
+		if err := rows.Scan(&c.Day, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan user count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate user counts: %w", err)
+	}
+	return counts, nil
+}
+
+// OrganizationMemberCount returns the members of an organization as of the
+// last refresh of organization_member_counts
+func (p *PostgresRepo) OrganizationMemberCount(ctx context.Context, tenantID string, orgID int) (int64, error) {
+	var n int64
+	err := dbFrom(ctx, p.db).QueryRowContext(ctx,
+		"SELECT members FROM "+OrganizationMemberCountView+" WHERE tenant_id = $1 AND organization_id = $2",
+		tenantID, orgID,
+	).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query member count: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,47 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fromMetadata reuses a valid incoming x-request-id or generates one,
+// echoing it in the response header
+func fromMetadata(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(strings.ToLower(Header)); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	if !valid(id) {
+		id = New()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(Header), id))
+	return NewContext(ctx, id)
+}
+
+// UnaryServerInterceptor is Middleware for unary gRPC calls, reading and
+// echoing the x-request-id metadata
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(fromMetadata(ctx), req)
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls
+func StreamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: fromMetadata(ss.Context())})
+}
+
+// serverStream replaces the context of a server stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,132 @@
+// Package requestid generates, accepts and propagates request IDs through
+// context, logs, traces and errors.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Header is the HTTP header carrying the request ID
+const Header = "X-Request-ID"
+
+// maxLength bounds accepted client-supplied IDs
+const maxLength = 128
+
+// ctxKey stores the request ID in a context
+type ctxKey struct{}
+
+// New returns a random request ID
+func New() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("requestid: failed to read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request ID in ctx, or "" if none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// valid reports whether a client-supplied ID is safe to reuse: non-empty,
+// bounded and printable ASCII, so it can't forge log lines or headers
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware reuses a valid incoming X-Request-ID or generates one, stores
+// it in the request context and echoes it on the response
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// Error attaches a request ID to an error
+type Error struct {
+	ID  string
+	Err error
+}
+
+// WithError wraps err with the request ID in ctx. It returns err unchanged
+// when it is nil, already tagged or ctx has no ID.
+func WithError(ctx context.Context, err error) error {
+	id := FromContext(ctx)
+	if err == nil || id == "" || IDOf(err) != "" {
+		return err
+	}
+	return &Error{ID: id, Err: err}
+}
+
+// IDOf returns the request ID attached to err, or ""
+func IDOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.ID
+	}
+	return ""
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// logHandler adds the context's request ID to every record
+type logHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps h so records logged with a context carrying a
+// request ID get a request_id attribute
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return logHandler{h}
+}
+
+// Handle implements slog.Handler
+func (h logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,171 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"project/clock"
+	"project/ids"
+)
+
+// Coordinator runs registered sagas, persisting each one's progress in a
+// Store
+type Coordinator struct {
+	store Store
+
+	mu   sync.RWMutex
+	defs map[string]Definition
+
+	// Clock stamps records; nil means the system clock
+	Clock clock.Clock
+	// IDs names saga runs; nil means random IDs
+	IDs ids.Source
+}
+
+// NewCoordinator creates a coordinator persisting to store
+func NewCoordinator(store Store) *Coordinator {
+	return &Coordinator{store: store, defs: make(map[string]Definition)}
+}
+
+// Register adds a saga definition, replacing one of the same name
+func (c *Coordinator) Register(def Definition) *Coordinator {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.defs[def.Name] = def
+	return c
+}
+
+func (c *Coordinator) definition(name string) (Definition, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	def, ok := c.defs[name]
+	if !ok {
+		return Definition{}, fmt.Errorf("unknown saga %q", name)
+	}
+	return def, nil
+}
+
+// Start runs a new saga with initial data and returns its record. If a
+// step fails the saga is compensated and the step's error returned; the
+// record tells whether compensation completed.
+func (c *Coordinator) Start(ctx context.Context, name string, data Data) (Record, error) {
+	def, err := c.definition(name)
+	if err != nil {
+		return Record{}, err
+	}
+
+	id, err := ids.Or(c.IDs).NewID()
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to generate saga id: %w", err)
+	}
+	if data == nil {
+		data = Data{}
+	}
+
+	now := clock.Or(c.Clock).Now()
+	rec := Record{ID: id, Name: name, Status: Running, Data: data, CreatedAt: now, UpdatedAt: now}
+	if err := c.store.Save(ctx, rec); err != nil {
+		return Record{}, err
+	}
+	return c.run(ctx, def, rec)
+}
+
+// Resume continues a saga from its persisted state: a running saga
+// carries on with its next step, a compensating or stuck one with its
+// compensation. Finished sagas are returned unchanged.
+func (c *Coordinator) Resume(ctx context.Context, id string) (Record, error) {
+	rec, err := c.store.Get(ctx, id)
+	if err != nil {
+		return Record{}, err
+	}
+	if rec.Status == Completed || rec.Status == Compensated {
+		return rec, nil
+	}
+
+	def, err := c.definition(rec.Name)
+	if err != nil {
+		return Record{}, err
+	}
+	if rec.Status == Stuck {
+		rec.Status = Compensating
+	}
+	return c.run(ctx, def, rec)
+}
+
+// ResumePending resumes every saga left running or compensating, e.g. by
+// a crash, and reports all failures. Call it once at startup.
+func (c *Coordinator) ResumePending(ctx context.Context) error {
+	recs, err := c.store.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, rec := range recs {
+		if _, err := c.Resume(ctx, rec.ID); err != nil {
+			errs = append(errs, fmt.Errorf("saga %s: %w", rec.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run drives rec to a final status
+func (c *Coordinator) run(ctx context.Context, def Definition, rec Record) (Record, error) {
+	var failure error
+	for rec.Status == Running && rec.Step < len(def.Steps) {
+		step := def.Steps[rec.Step]
+		if err := step.Do(ctx, rec.Data); err != nil {
+			// the failed step is compensated too, undoing any partial work
+			failure = fmt.Errorf("step %s failed: %w", step.Name, err)
+			rec.Status = Compensating
+			rec.Error = failure.Error()
+		} else {
+			rec.Step++
+		}
+		if err := c.save(ctx, &rec); err != nil {
+			return rec, err
+		}
+	}
+	if rec.Status == Running {
+		rec.Status = Completed
+		return rec, c.save(ctx, &rec)
+	}
+
+	for rec.Step >= 0 {
+		step := def.Steps[rec.Step]
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, rec.Data); err != nil {
+				rec.Status = Stuck
+				if serr := c.save(ctx, &rec); serr != nil {
+					return rec, serr
+				}
+				return rec, errors.Join(failure, fmt.Errorf("failed to compensate step %s: %w", step.Name, err))
+			}
+		}
+		rec.Step--
+		if err := c.save(ctx, &rec); err != nil {
+			return rec, err
+		}
+	}
+
+	rec.Status = Compensated
+	if err := c.save(ctx, &rec); err != nil {
+		return rec, err
+	}
+	if failure == nil {
+		// resumed compensation of an earlier run's failure
+		failure = errors.New(rec.Error)
+	}
+	return rec, failure
+}
+
+func (c *Coordinator) save(ctx context.Context, rec *Record) error {
+	rec.UpdatedAt = clock.Or(c.Clock).Now()
+	if err := c.store.Save(ctx, *rec); err != nil {
+		return fmt.Errorf("failed to persist saga %s: %w", rec.ID, err)
+	}
+	return nil
+}
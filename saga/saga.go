@@ -0,0 +1,115 @@
+// Package saga coordinates workflows that span several repositories or
+// services without a shared transaction. Each step declares how to undo
+// it; when a step fails the completed steps are compensated in reverse.
+// Progress is persisted after every step, so a saga interrupted by a
+// crash is resumed where it stopped.
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrNotFound is returned by a Store for an unknown saga
+var ErrNotFound = errors.New("saga not found")
+
+// Saga statuses
+const (
+	// Running sagas are executing their steps
+	Running = "running"
+	// Compensating sagas are undoing their completed steps after a failure
+	Compensating = "compensating"
+	// Completed sagas ran every step
+	Completed = "completed"
+	// Compensated sagas failed and were fully undone
+	Compensated = "compensated"
+	// Stuck sagas failed to compensate and need an operator; Resume
+	// retries the compensation
+	Stuck = "stuck"
+)
+
+// Data is the state steps share, e.g. the ID of a created organization for
+// the steps after it. It is persisted as JSON, so values read back after a
+// resume are JSON types; use the accessors to read them.
+type Data map[string]any
+
+// Int returns key as an int, 0 if unset
+func (d Data) Int(key string) int {
+	switch v := d[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := strconv.Atoi(v.String())
+		return n
+	}
+	return 0
+}
+
+// String returns key as a string, "" if unset
+func (d Data) String(key string) string {
+	s, _ := d[key].(string)
+	return s
+}
+
+// Ints returns key as a slice of ints
+func (d Data) Ints(key string) []int {
+	switch v := d[key].(type) {
+	case []int:
+		return v
+	case []any:
+		ints := make([]int, len(v))
+		for i := range v {
+			ints[i] = Data{"": v[i]}.Int("")
+		}
+		return ints
+	}
+	return nil
+}
+
+// Step is one action of a saga. Steps may run more than once when a saga
+// is resumed after a crash, so Do and Compensate must be idempotent. A
+// failed step is compensated along with the ones before it, so Compensate
+// must also tolerate work that was only partly done, or not at all.
+type Step struct {
+	Name string
+	Do   func(ctx context.Context, data Data) error
+	// Compensate undoes Do; nil for steps with nothing to undo, e.g.
+	// sending an email
+	Compensate func(ctx context.Context, data Data) error
+}
+
+// Definition is a named sequence of steps
+type Definition struct {
+	Name  string
+	Steps []Step
+}
+
+// Record is the persisted state of one saga run
+type Record struct {
+	ID     string
+	Name   string
+	Status string
+	// Step is the next step to run while Running, or the next step to
+	// compensate while Compensating
+	Step int
+	Data Data
+	// Error is the step failure that triggered compensation
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Store persists saga records
+type Store interface {
+	Save(ctx context.Context, rec Record) error
+	Get(ctx context.Context, id string) (Record, error)
+	// Pending returns the sagas still running or compensating
+	Pending(ctx context.Context) ([]Record, error)
+}
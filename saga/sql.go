@@ -0,0 +1,112 @@
+package saga
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SQLStore keeps saga records in the sagas table
+type SQLStore struct {
+	db      *sql.DB
+	queries sqlQueries
+}
+
+// sqlQueries are the dialect's statements
+type sqlQueries struct {
+	get, save, pending string
+}
+
+// NewPostgresStore creates a SQLStore for PostgreSQL
+func NewPostgresStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, queries: sqlQueries{
+		get: "SELECT id, name, status, step, data, error, created_at, updated_at FROM sagas WHERE id = $1",
+		save: `INSERT INTO sagas (id, name, status, step, data, error, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, step = EXCLUDED.step, data = EXCLUDED.data,
+			error = EXCLUDED.error, updated_at = EXCLUDED.updated_at`,
+		pending: "SELECT id, name, status, step, data, error, created_at, updated_at FROM sagas WHERE status IN ($1, $2) ORDER BY created_at",
+	}}
+}
+
+// NewMySQLStore creates a SQLStore for MySQL
+func NewMySQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, queries: sqlQueries{
+		get: "SELECT id, name, status, step, data, error, created_at, updated_at FROM sagas WHERE id = ?",
+		save: `INSERT INTO sagas (id, name, status, step, data, error, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE status = VALUES(status), step = VALUES(step), data = VALUES(data),
+			error = VALUES(error), updated_at = VALUES(updated_at)`,
+		pending: "SELECT id, name, status, step, data, error, created_at, updated_at FROM sagas WHERE status IN (?, ?) ORDER BY created_at",
+	}}
+}
+
+// Save implements Store
+func (s *SQLStore) Save(ctx context.Context, rec Record) error {
+	data, err := json.Marshal(rec.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga data: %w", err)
+	}
+	if rec.Data == nil {
+		data = []byte("{}")
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.queries.save, rec.ID, rec.Name, rec.Status, rec.Step, string(data), rec.Error,
+		rec.CreatedAt.UTC(), rec.UpdatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save saga: %w", err)
+	}
+	return nil
+}
+
+// Get implements Store
+func (s *SQLStore) Get(ctx context.Context, id string) (Record, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries.get, id)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to get saga: %w", err)
+	}
+	recs, err := scanRecords(rows)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(recs) == 0 {
+		return Record{}, ErrNotFound
+	}
+	return recs[0], nil
+}
+
+// Pending implements Store
+func (s *SQLStore) Pending(ctx context.Context) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries.pending, Running, Compensating)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending sagas: %w", err)
+	}
+	return scanRecords(rows)
+}
+
+func scanRecords(rows *sql.Rows) ([]Record, error) {
+	defer rows.Close()
+
+	var recs []Record
+	for rows.Next() {
+		var (
+			rec  Record
+			data []byte
+		)
+		if err := rows.Scan(&rec.ID, &rec.Name, &rec.Status, &rec.Step, &data, &rec.Error, &rec.CreatedAt, &rec.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saga: %w", err)
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&rec.Data); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to decode saga data: %w", err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sagas: %w", err)
+	}
+	return recs, nil
+}
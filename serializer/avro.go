@@ -0,0 +1,177 @@
+package serializer
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"project/events"
+)
+
+// AvroSchema is the Avro schema of events. Data values are JSON, so the
+// schema doesn't change with the keys an event type carries.
+const AvroSchema = `{"type":"record","name":"Event","namespace":"adapter.events","fields":[` +
+	`{"name":"type","type":"string"},` +
+	`{"name":"subject","type":"long"},` +
+	`{"name":"tenant_id","type":"string","default":""},` +
+	`{"name":"data","type":{"type":"map","values":"string"},"default":{}},` +
+	`{"name":"at","type":{"type":"long","logicalType":"timestamp-micros"}}]}`
+
+// Avro encodes events in Avro binary encoding with AvroSchema
+type Avro struct {
+	// Registry, when set, registers AvroSchema under each topic's subject
+	// and frames payloads with its ID
+	Registry Registry
+}
+
+// ContentType implements Serializer
+func (a *Avro) ContentType() string {
+	if a.Registry != nil {
+		return "application/vnd.schemaregistry.v1+avro"
+	}
+	return "avro/binary"
+}
+
+// Serialize implements Serializer
+func (a *Avro) Serialize(ctx context.Context, topic string, ev events.Event) ([]byte, error) {
+	var b []byte
+	b = appendAvroString(b, ev.Type)
+	b = binary.AppendVarint(b, int64(ev.Subject))
+	b = appendAvroString(b, ev.TenantID)
+
+	keys := make([]string, 0, len(ev.Data))
+	for k := range ev.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		b = binary.AppendVarint(b, int64(len(keys)))
+		for _, k := range keys {
+			v, err := json.Marshal(ev.Data[k])
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize event data %s: %w", k, err)
+			}
+			b = appendAvroString(b, k)
+			b = appendAvroString(b, string(v))
+		}
+	}
+	b = binary.AppendVarint(b, 0)
+	b = binary.AppendVarint(b, ev.At.UnixMicro())
+
+	if a.Registry == nil {
+		return b, nil
+	}
+	id, err := a.Registry.Register(ctx, Subject(topic), Schema{Type: SchemaAvro, Definition: AvroSchema})
+	if err != nil {
+		return nil, err
+	}
+	return frame(id, b), nil
+}
+
+// Deserialize implements Serializer. Framed payloads must have been
+// written with AvroSchema; other writer schemas need a schema-resolving
+// Avro library.
+func (a *Avro) Deserialize(ctx context.Context, _ string, data []byte) (events.Event, error) {
+	if a.Registry != nil {
+		id, rest, err := unframe(data)
+		if err != nil {
+			return events.Event{}, err
+		}
+		schema, err := a.Registry.Lookup(ctx, id)
+		if err != nil {
+			return events.Event{}, err
+		}
+		if schema.Type != SchemaAvro || !sameJSON(schema.Definition, AvroSchema) {
+			return events.Event{}, fmt.Errorf("schema %d is not the event schema this version reads", id)
+		}
+		data = rest
+	}
+
+	r := avroReader{b: data}
+	ev := events.Event{Type: r.string(), Subject: int(r.long()), TenantID: r.string()}
+	for {
+		n := r.long()
+		if n == 0 || r.err != nil {
+			break
+		}
+		if n < 0 {
+			// a negative count is followed by the block's size in bytes
+			n = -n
+			r.long()
+		}
+		if ev.Data == nil {
+			ev.Data = make(map[string]any)
+		}
+		for i := int64(0); i < n && r.err == nil; i++ {
+			k, raw := r.string(), r.string()
+			var v any
+			if err := json.Unmarshal([]byte(raw), &v); err != nil && r.err == nil {
+				r.err = fmt.Errorf("data %s: %w", k, err)
+			}
+			ev.Data[k] = v
+		}
+	}
+	ev.At = time.UnixMicro(r.long()).UTC()
+	if r.err != nil {
+		return events.Event{}, fmt.Errorf("failed to deserialize event: %w", r.err)
+	}
+	return ev, nil
+}
+
+// appendAvroString appends s as an Avro string: its length, then its bytes
+func appendAvroString(b []byte, s string) []byte {
+	b = binary.AppendVarint(b, int64(len(s)))
+	return append(b, s...)
+}
+
+// errShortAvro is returned for payloads that end mid-value
+var errShortAvro = errors.New("unexpected end of payload")
+
+// avroReader decodes Avro binary values, keeping the first error
+type avroReader struct {
+	b   []byte
+	err error
+}
+
+// long reads a zigzag varint
+func (r *avroReader) long() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errShortAvro
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// string reads a length-prefixed string
+func (r *avroReader) string() string {
+	n := r.long()
+	if r.err != nil {
+		return ""
+	}
+	if n < 0 || int64(len(r.b)) < n {
+		r.err = errShortAvro
+		return ""
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+// sameJSON reports whether a and b hold the same JSON value
+func sameJSON(a, b string) bool {
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
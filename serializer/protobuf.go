@@ -0,0 +1,99 @@
+package serializer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"project/events"
+	"project/proto/eventspb"
+)
+
+// Protobuf encodes events as eventspb.Event messages
+type Protobuf struct {
+	// Registry, when set, registers events.proto under each topic's subject
+	// and frames payloads with its ID
+	Registry Registry
+}
+
+// ContentType implements Serializer
+func (p *Protobuf) ContentType() string {
+	if p.Registry != nil {
+		return "application/vnd.schemaregistry.v1+protobuf"
+	}
+	return "application/x-protobuf"
+}
+
+// Serialize implements Serializer
+func (p *Protobuf) Serialize(ctx context.Context, topic string, ev events.Event) ([]byte, error) {
+	msg := &eventspb.Event{
+		Type:     ev.Type,
+		Subject:  int64(ev.Subject),
+		TenantId: ev.TenantID,
+		At:       timestamppb.New(ev.At),
+	}
+	if ev.Data != nil {
+		// through JSON, so values structpb can't hold, e.g. times, take
+		// their JSON form
+		raw, err := json.Marshal(ev.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize event data: %w", err)
+		}
+		msg.Data = &structpb.Struct{}
+		if err := msg.Data.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("failed to serialize event data: %w", err)
+		}
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize event: %w", err)
+	}
+	if p.Registry == nil {
+		return payload, nil
+	}
+
+	id, err := p.Registry.Register(ctx, Subject(topic), Schema{Type: SchemaProtobuf, Definition: eventspb.Schema})
+	if err != nil {
+		return nil, err
+	}
+	// the message index list: Event is the first message of the schema
+	return frame(id, append([]byte{0}, payload...)), nil
+}
+
+// Deserialize implements Serializer
+func (p *Protobuf) Deserialize(ctx context.Context, _ string, data []byte) (events.Event, error) {
+	if p.Registry != nil {
+		id, rest, err := unframe(data)
+		if err != nil {
+			return events.Event{}, err
+		}
+		schema, err := p.Registry.Lookup(ctx, id)
+		if err != nil {
+			return events.Event{}, err
+		}
+		if schema.Type != SchemaProtobuf {
+			return events.Event{}, fmt.Errorf("schema %d is %s, not %s", id, schema.Type, SchemaProtobuf)
+		}
+		if len(rest) == 0 || rest[0] != 0 {
+			return events.Event{}, fmt.Errorf("payload is not an Event message")
+		}
+		data = rest[1:]
+	}
+
+	var msg eventspb.Event
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return events.Event{}, fmt.Errorf("failed to deserialize event: %w", err)
+	}
+	ev := events.Event{Type: msg.Type, Subject: int(msg.Subject), TenantID: msg.TenantId}
+	if msg.At != nil {
+		ev.At = msg.At.AsTime()
+	}
+	if msg.Data != nil {
+		ev.Data = msg.Data.AsMap()
+	}
+	return ev, nil
+}
@@ -0,0 +1,207 @@
+package serializer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"project/degrade"
+)
+
+// Schema types understood by schema registries
+const (
+	SchemaAvro     = "AVRO"
+	SchemaProtobuf = "PROTOBUF"
+)
+
+// Schema is a schema as stored by a registry
+type Schema struct {
+	Type       string
+	Definition string
+}
+
+// Registry assigns IDs to schemas by subject and looks them up, e.g. a
+// Confluent-compatible schema registry. The registry enforces the
+// subject's compatibility rules on Register, so incompatible schema
+// changes fail before any payload uses them.
+type Registry interface {
+	Register(ctx context.Context, subject string, schema Schema) (int, error)
+	Lookup(ctx context.Context, id int) (Schema, error)
+}
+
+// Subject returns the registry subject of topic's payloads, following the
+// topic name strategy
+func Subject(topic string) string {
+	return topic + "-value"
+}
+
+// wireMagic leads framed payloads, followed by the big-endian schema ID
+const wireMagic = 0
+
+// frame prefixes payload with the wire header for schema id
+func frame(id int, payload []byte) []byte {
+	b := make([]byte, 5, 5+len(payload))
+	b[0] = wireMagic
+	binary.BigEndian.PutUint32(b[1:], uint32(id))
+	return append(b, payload...)
+}
+
+// unframe splits a framed payload into its schema ID and the rest
+func unframe(data []byte) (int, []byte, error) {
+	if len(data) < 5 || data[0] != wireMagic {
+		return 0, nil, fmt.Errorf("payload is not framed with a schema ID")
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
+
+// RegistryClient is a Registry over the Confluent schema registry REST
+// API. IDs and schemas are cached, since they never change once assigned.
+type RegistryClient struct {
+	baseURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	ids     map[string]int
+	schemas map[int]Schema
+}
+
+// NewRegistryClient creates a client of the registry at baseURL, e.g.
+// "http://schema-registry:8081"
+func NewRegistryClient(baseURL string) *RegistryClient {
+	return &RegistryClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  http.DefaultClient,
+		ids:     make(map[string]int),
+		schemas: make(map[int]Schema),
+	}
+}
+
+// registrySchema is a schema in the registry's requests and responses
+type registrySchema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+	ID         int    `json:"id,omitempty"`
+}
+
+// Register implements Registry
+func (c *RegistryClient) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	key := subject + "\x00" + schema.Type + "\x00" + schema.Definition
+	c.mu.Lock()
+	id, ok := c.ids[key]
+	c.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var resp registrySchema
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions",
+		registrySchema{Schema: schema.Definition, SchemaType: schema.Type}, &resp); err != nil {
+		return 0, fmt.Errorf("failed to register schema for %s: %w", subject, err)
+	}
+
+	c.mu.Lock()
+	c.ids[key] = resp.ID
+	c.schemas[resp.ID] = schema
+	c.mu.Unlock()
+	return resp.ID, nil
+}
+
+// Lookup implements Registry
+func (c *RegistryClient) Lookup(ctx context.Context, id int) (Schema, error) {
+	c.mu.Lock()
+	schema, ok := c.schemas[id]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	var resp registrySchema
+	if err := c.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return Schema{}, fmt.Errorf("failed to look up schema %d: %w", id, err)
+	}
+	// the registry omits the type of Avro schemas
+	schema = Schema{Type: resp.SchemaType, Definition: resp.Schema}
+	if schema.Type == "" {
+		schema.Type = SchemaAvro
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// do sends a registry request with body encoded as JSON and decodes the
+// response into out
+func (c *RegistryClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("schema registry returned %s: %s", resp.Status, e.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// errRegistryBypassed fails calls to a registry its monitor is bypassing
+var errRegistryBypassed = errors.New("schema registry bypassed after failing")
+
+// MonitoredRegistry is a Registry called as the dependency
+// "schema_registry" of a degrade.Monitor, so its outages show in the
+// health endpoint. Events can't be encoded without their schema, so under
+// the bypass policy calls fail fast instead of waiting on the registry.
+type MonitoredRegistry struct {
+	Registry
+	Monitor *degrade.Monitor
+}
+
+// Register implements Registry
+func (r MonitoredRegistry) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	var id int
+	err := r.Monitor.Call(ctx, "schema_registry",
+		func(ctx context.Context) (err error) {
+			id, err = r.Registry.Register(ctx, subject, schema)
+			return err
+		},
+		func(context.Context) error { return errRegistryBypassed })
+	return id, err
+}
+
+// Lookup implements Registry
+func (r MonitoredRegistry) Lookup(ctx context.Context, id int) (Schema, error) {
+	var schema Schema
+	err := r.Monitor.Call(ctx, "schema_registry",
+		func(ctx context.Context) (err error) {
+			schema, err = r.Registry.Lookup(ctx, id)
+			return err
+		},
+		func(context.Context) error { return errRegistryBypassed })
+	return schema, err
+}
@@ -0,0 +1,86 @@
+// Package serializer encodes domain events for downstream consumers as
+// JSON, Protobuf or Avro, optionally framed with schema registry IDs so
+// consumers can decode and evolve typed payloads.
+package serializer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"project/events"
+	"project/repository"
+)
+
+// Serializer encodes and decodes events published under a topic
+type Serializer interface {
+	// ContentType names the encoding, e.g. for the outbox
+	ContentType() string
+	Serialize(ctx context.Context, topic string, ev events.Event) ([]byte, error)
+	Deserialize(ctx context.Context, topic string, data []byte) (events.Event, error)
+}
+
+// New returns the serializer for format, "json", "protobuf" or "avro".
+// Protobuf and Avro payloads are framed with schema IDs from registry when
+// it isn't nil.
+func New(format string, registry Registry) (Serializer, error) {
+	switch format {
+	case "json":
+		return JSON{}, nil
+	case "protobuf":
+		return &Protobuf{Registry: registry}, nil
+	case "avro":
+		return &Avro{Registry: registry}, nil
+	default:
+		return nil, fmt.Errorf("unknown serializer format %q", format)
+	}
+}
+
+// JSON encodes events as JSON objects
+type JSON struct{}
+
+// jsonEvent is the JSON form of an event
+type jsonEvent struct {
+	Type     string         `json:"type"`
+	Subject  int            `json:"subject"`
+	TenantID string         `json:"tenant_id,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+	At       time.Time      `json:"at"`
+}
+
+// ContentType implements Serializer
+func (JSON) ContentType() string {
+	return repository.JSONContentType
+}
+
+// Serialize implements Serializer
+func (JSON) Serialize(_ context.Context, _ string, ev events.Event) ([]byte, error) {
+	data, err := json.Marshal(jsonEvent{Type: ev.Type, Subject: ev.Subject, TenantID: ev.TenantID, Data: ev.Data, At: ev.At.UTC()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize event: %w", err)
+	}
+	return data, nil
+}
+
+// Deserialize implements Serializer
+func (JSON) Deserialize(_ context.Context, _ string, data []byte) (events.Event, error) {
+	var ev jsonEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return events.Event{}, fmt.Errorf("failed to deserialize event: %w", err)
+	}
+	return events.Event{Type: ev.Type, Subject: ev.Subject, TenantID: ev.TenantID, Data: ev.Data, At: ev.At}, nil
+}
+
+// OutboxRelay returns a handler queueing every event it receives in outbox
+// under its type, encoded by s. Subscribe it with events.Bus.Subscribe for
+// "" to relay all events; consumers claim them with outbox.ForTopics.
+func OutboxRelay(outbox *repository.Outbox, s Serializer) events.Handler {
+	return func(ctx context.Context, ev events.Event) error {
+		data, err := s.Serialize(ctx, ev.Type, ev)
+		if err != nil {
+			return err
+		}
+		return outbox.EnqueueEncoded(ctx, ev.Type, s.ContentType(), data)
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"project/apperr"
+	"project/auth"
+	"project/clock"
+	"project/ids"
+	"project/models"
+	"project/repository"
+)
+
+// apiKeyMarker starts every API key so leaked keys are easy to recognise
+// and scan for
+const apiKeyMarker = "ak_"
+
+// ErrInvalidAPIKey is returned for unknown, malformed, revoked and expired
+// keys alike, so callers can't tell which
+var ErrInvalidAPIKey = apperr.New(apperr.Unauthenticated, "invalid api key")
+
+// apiKeys returns the repository's APIKeyRepository
+func (s *UserService) apiKeys() (repository.APIKeyRepository, error) {
+	repo, ok := s.repo.(repository.APIKeyRepository)
+	if !ok {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support api keys")
+	}
+	return repo, nil
+}
+
+// MintAPIKey issues a key for a user with scopes, expiring after ttl or
+// never when ttl is 0. It returns the full key, which is not stored and
+// can't be shown again, and the stored record.
+func (s *UserService) MintAPIKey(userID int, name string, scopes []string, ttl time.Duration) (string, models.APIKey, error) {
+	if err := s.admit(); err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	repo, err := s.apiKeys()
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	if _, err := s.repo.GetByID(userID); err != nil {
+		return "", models.APIKey{}, fmt.Errorf("failed to mint api key: %w", err)
+	}
+
+	src := ids.Or(s.ids)
+	prefix, err := src.NewID()
+	if err != nil {
+		return "", models.APIKey{}, fmt.Errorf("failed to mint api key: %w", err)
+	}
+	secret, err := src.NewID()
+	if err != nil {
+		return "", models.APIKey{}, fmt.Errorf("failed to mint api key: %w", err)
+	}
+
+	key := models.APIKey{
+		UserID:     userID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: hashSecret(secret),
+		Scopes:     scopes,
+	}
+	if ttl > 0 {
+		key.ExpiresAt = sql.NullTime{Time: clock.Or(s.clock).Now().Add(ttl).UTC(), Valid: true}
+	}
+	if err := validateModel(key); err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	created, err := repo.CreateAPIKey(s.context(), key)
+	if err != nil {
+		return "", models.APIKey{}, fmt.Errorf("failed to mint api key: %w", err)
+	}
+	created.SecretHash = ""
+	return apiKeyMarker + prefix + "." + secret, created, nil
+}
+
+// ListAPIKeys returns a user's keys, newest first, without their hashes
+func (s *UserService) ListAPIKeys(userID int) ([]models.APIKey, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
+	repo, err := s.apiKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := repo.ListAPIKeys(s.context(), userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	for i := range keys {
+		keys[i].SecretHash = ""
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of a user's keys; it stops authenticating at once
+func (s *UserService) RevokeAPIKey(userID, keyID int) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, err := s.apiKeys()
+	if err != nil {
+		return err
+	}
+
+	if err := repo.RevokeAPIKey(s.context(), userID, keyID, clock.Or(s.clock).Now().UTC()); err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the active key matching token, or
+// ErrInvalidAPIKey
+func (s *UserService) AuthenticateAPIKey(token string) (models.APIKey, error) {
+	if err := s.admit(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	repo, err := s.apiKeys()
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(token, apiKeyMarker), ".")
+	if !ok || !strings.HasPrefix(token, apiKeyMarker) || prefix == "" || secret == "" {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := repo.GetAPIKeyByPrefix(s.context(), prefix)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to authenticate api key: %w", err)
+	}
+
+	now := clock.Or(s.clock).Now()
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.SecretHash)) != 1 || !key.Active(now) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	// last use is informational; failing to record it doesn't fail the request
+	_ = repo.TouchAPIKey(s.context(), key.ID, now.UTC())
+
+	key.SecretHash = ""
+	return key, nil
+}
+
+// APIKeyAuthenticator returns an auth.Authenticator backed by
+// AuthenticateAPIKey, for auth.APIKeyMiddleware
+func (s *UserService) APIKeyAuthenticator() auth.Authenticator {
+	return auth.AuthenticatorFunc(func(ctx context.Context, token string) (auth.Principal, error) {
+		key, err := s.WithContext(ctx).AuthenticateAPIKey(token)
+		if err != nil {
+			return auth.Principal{}, err
+		}
+		return auth.Principal{UserID: key.UserID, KeyID: key.ID, Scopes: key.Scopes}, nil
+	})
+}
+
+// hashSecret hashes a key secret for storage. Secrets are random, so a
+// fast unsalted hash is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
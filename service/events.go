@@ -0,0 +1,37 @@
+package service
+
+import (
+	"fmt"
+
+	"project/clock"
+	"project/events"
+	"project/tenant"
+)
+
+// WithEvents publishes a domain event on bus after each committed write,
+// e.g. to keep projections up to date
+func (s *UserService) WithEvents(bus *events.Bus) *UserService {
+	s.bus = bus
+	return s
+}
+
+// publish sends an event about subject, if a bus is set. The write it is
+// about is already committed; an error means a subscriber lost the event.
+func (s *UserService) publish(eventType string, subject int, data map[string]any) error {
+	if s.bus == nil {
+		return nil
+	}
+
+	ctx := s.context()
+	err := s.bus.Publish(ctx, events.Event{
+		Type:     eventType,
+		Subject:  subject,
+		TenantID: tenant.FromContext(ctx),
+		Data:     data,
+		At:       clock.Or(s.clock).Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", eventType, err)
+	}
+	return nil
+}
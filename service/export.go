@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"project/apperr"
+	"project/models"
+	"project/repository"
+)
+
+// exportPageSize is how many users ExportUsers reads per query
+const exportPageSize = 500
+
+// ErrInvalidCursor is returned for an export cursor that wasn't issued by
+// ExportUsers
+var ErrInvalidCursor = apperr.New(apperr.InvalidArgument, "invalid export cursor")
+
+// ExportUsers passes every user after cursor, "" for the first, to emit in
+// ID order along with the cursor that resumes after it. It walks the table
+// in keyset order so the export can run for a long time against a live
+// table; it stops at the first error from emit or the bound context.
+func (s *UserService) ExportUsers(cursor string, emit func(user models.User, next string) error) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, ok := s.repo.(repository.KeysetRepository)
+	if !ok {
+		return apperr.New(apperr.Unimplemented, "repository does not support keyset paging")
+	}
+
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return err
+	}
+
+	ctx := s.context()
+	for {
+		users, err := repo.ListAfter(ctx, after, exportPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to export users: %w", err)
+		}
+		for _, u := range users {
+			after = u.ID
+			if err := emit(u, encodeCursor(after)); err != nil {
+				return err
+			}
+		}
+		if len(users) < exportPageSize {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// encodeCursor returns the opaque cursor resuming after id
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(string(b))
+	if err != nil || id < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"project/apperr"
+	"project/clock"
+	"project/events"
+	"project/models"
+	"project/repository"
+	"project/tenant"
+)
+
+// Audit actions recorded by the service
+const (
+	AuditExport = "gdpr.export"
+	AuditErase  = "gdpr.erase"
+)
+
+// UserExport is the machine-readable bundle of everything stored about a
+// user. Sections the repository doesn't support are left empty.
+type UserExport struct {
+	ExportedAt         time.Time                      `json:"exported_at"`
+	User               models.User                    `json:"user"`
+	Settings           *models.UserSettings           `json:"settings,omitempty"`
+	Posts              []models.Post                  `json:"posts"`
+	Memberships        []models.Membership            `json:"memberships"`
+	APIKeys            []models.APIKey                `json:"api_keys"`
+	LoginAttempts      []models.LoginAttempt          `json:"login_attempts"`
+	ChannelPreferences []repository.ChannelPreference `json:"channel_preferences"`
+	AuditTrail         []models.AuditEntry            `json:"audit_trail"`
+}
+
+// KeyShredder destroys the encryption key of a user's data, e.g. a
+// per-user key in a KMS. Erasing the key makes encrypted copies the
+// database can't reach, such as backups, unreadable.
+type KeyShredder interface {
+	ShredUserKey(ctx context.Context, userID int) error
+}
+
+// WithKeyShredder crypto-shreds users' keys with shredder when they are
+// erased
+func (s *UserService) WithKeyShredder(shredder KeyShredder) *UserService {
+	s.shredder = shredder
+	return s
+}
+
+// auditSubject names a user in the audit trail without personal data
+func auditSubject(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+// audit records action on subject when the repository keeps an audit trail
+func (s *UserService) audit(ctx context.Context, action, subject string, details models.JSONMap) error {
+	repo, ok := s.repo.(repository.AuditRepository)
+	if !ok {
+		return nil
+	}
+	return repo.RecordAudit(ctx, models.AuditEntry{
+		Actor:     tenant.ActorFromContext(ctx),
+		Action:    action,
+		Subject:   subject,
+		Details:   details,
+		CreatedAt: clock.Or(s.clock).Now(),
+	})
+}
+
+// ExportUserData returns every record tied to a user, and records the
+// export in the audit trail
+func (s *UserService) ExportUserData(id int) (UserExport, error) {
+	if err := s.admit(); err != nil {
+		return UserExport{}, err
+	}
+	ctx := s.context()
+
+	export := UserExport{ExportedAt: clock.Or(s.clock).Now().UTC()}
+
+	err := repository.ErrUnsupported
+	if repo, ok := s.repo.(repository.SettingsRepository); ok {
+		export.User, err = repo.GetUserWithSettings(id)
+		export.Settings = export.User.Settings
+	}
+	if errors.Is(err, repository.ErrUnsupported) {
+		export.User, err = s.repo.GetByID(id)
+	}
+	if err != nil {
+		return UserExport{}, fmt.Errorf("failed to export user data: %w", err)
+	}
+	export.User.Settings = nil
+
+	// each section is collected independently; the first failure aborts
+	sections := []func() error{
+		func() (err error) {
+			if repo, ok := s.repo.(repository.PostRepository); ok {
+				var u models.User
+				u, err = repo.GetUserWithPosts(id)
+				export.Posts = u.Posts
+			}
+			return err
+		},
+		func() (err error) {
+			if repo, ok := s.repo.(repository.OrganizationRepository); ok {
+				export.Memberships, err = repo.ListMemberships(ctx, id)
+			}
+			return err
+		},
+		func() (err error) {
+			if repo, ok := s.repo.(repository.APIKeyRepository); ok {
+				export.APIKeys, err = repo.ListAPIKeys(ctx, id)
+				for i := range export.APIKeys {
+					export.APIKeys[i].SecretHash = ""
+				}
+			}
+			return err
+		},
+		func() (err error) {
+			if repo, ok := s.repo.(repository.LoginRepository); ok {
+				export.LoginAttempts, err = repo.LoginAttempts(ctx, id, time.Time{})
+			}
+			return err
+		},
+		func() (err error) {
+			if repo, ok := s.repo.(repository.PreferenceRepository); ok {
+				export.ChannelPreferences, err = repo.ChannelPreferences(ctx, id)
+			}
+			return err
+		},
+		func() (err error) {
+			if repo, ok := s.repo.(repository.AuditRepository); ok {
+				export.AuditTrail, err = repo.AuditEntries(ctx, auditSubject(id))
+			}
+			return err
+		},
+	}
+	for _, section := range sections {
+		if err := section(); err != nil && !errors.Is(err, repository.ErrUnsupported) {
+			return UserExport{}, fmt.Errorf("failed to export user data: %w", err)
+		}
+	}
+
+	if err := s.audit(ctx, AuditExport, auditSubject(id), nil); err != nil {
+		return UserExport{}, fmt.Errorf("failed to audit user data export: %w", err)
+	}
+	return export, nil
+}
+
+// EraseUser permanently deletes a user and everything tied to them, with
+// an audit entry recorded in the same transaction. With a KeyShredder the
+// user's encryption key is destroyed afterwards; if that fails the call
+// can be retried.
+func (s *UserService) EraseUser(id int) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, ok := s.repo.(repository.ErasureRepository)
+	if !ok {
+		return apperr.New(apperr.Unimplemented, "repository does not support erasure")
+	}
+
+	// live users still hold a quota slot; soft-deleted ones released it
+	_, err := s.repo.GetByID(id)
+	live := err == nil
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	erase := func(ctx context.Context) error {
+		if err := repo.EraseUser(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, AuditErase, auditSubject(id), nil)
+	}
+
+	err = s.transact(erase)
+	if errors.Is(err, repository.ErrNotFound) && s.shredder != nil {
+		// erased by an earlier call whose key shredding failed
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to erase user: %w", err)
+	}
+	if live {
+		s.releaseUser()
+	}
+	published := s.publish(events.UserErased, id, nil)
+
+	if s.shredder != nil {
+		if err := s.shredder.ShredUserKey(s.context(), id); err != nil {
+			return fmt.Errorf("failed to shred user key: %w", err)
+		}
+	}
+	return published
+}
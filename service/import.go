@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"project/apperr"
+	"project/models"
+	"project/repository"
+	"project/tenant"
+)
+
+// ImportResult counts what ImportUsers did with a batch
+type ImportResult struct {
+	Created int
+	// Duplicates were already created by an interrupted run of the import
+	Duplicates int
+	// Invalid failed their validate tags and were skipped
+	Invalid int
+}
+
+// ImportUsers registers users, by name and email, one at a time. Invalid
+// users are skipped rather than failing the batch. With a non-zero
+// dedupeSince, users with the same name and email created since then are
+// taken to be from an interrupted run of the same import and skipped, so a
+// batch can be replayed safely.
+func (s *UserService) ImportUsers(users []models.User, dedupeSince time.Time) (ImportResult, error) {
+	var result ImportResult
+	if err := s.admit(); err != nil {
+		return result, err
+	}
+
+	for _, u := range users {
+		if !dedupeSince.IsZero() {
+			dup, err := s.importedSince(u, dedupeSince)
+			if err != nil {
+				return result, err
+			}
+			if dup {
+				result.Duplicates++
+				continue
+			}
+		}
+
+		err := s.RegisterUserWithEmail(u.Name, u.Email)
+		var invalid ValidationErrors
+		switch {
+		case errors.As(err, &invalid):
+			result.Invalid++
+		case err != nil:
+			return result, fmt.Errorf("failed to import user: %w", err)
+		default:
+			result.Created++
+		}
+	}
+	return result, nil
+}
+
+// importedSince reports whether a user with u's name and email was created
+// in the bound tenant at or after since
+func (s *UserService) importedSince(u models.User, since time.Time) (bool, error) {
+	finder, ok := s.repo.(repository.Finder)
+	if !ok {
+		return false, apperr.New(apperr.Unimplemented, "repository does not support finding users")
+	}
+
+	ctx := s.context()
+	found, err := finder.Find(ctx, repository.Filter{
+		repository.Eq("tenant_id", tenant.FromContext(ctx)),
+		repository.Eq("name", u.Name),
+		repository.Eq("email", u.Email),
+		repository.Where("created_at", ">=", since),
+	}, repository.ListOptions{Limit: 1})
+	if err != nil {
+		return false, fmt.Errorf("failed to check for imported user: %w", err)
+	}
+	return len(found) > 0, nil
+}
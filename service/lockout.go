@@ -0,0 +1,181 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"project/apperr"
+	"project/clock"
+	"project/models"
+	"project/repository"
+	"project/tenant"
+)
+
+// LockoutPolicy locks an account after MaxFailures failed logins within
+// Window, for Cooldown. Failures are counted in fixed windows, so a burst
+// straddling a window boundary may take up to twice MaxFailures.
+type LockoutPolicy struct {
+	MaxFailures int           `json:"max_failures"`
+	Window      time.Duration `json:"window"`
+	Cooldown    time.Duration `json:"cooldown"`
+}
+
+// DefaultLockoutPolicy locks for 15 minutes after 5 failures in 15 minutes
+var DefaultLockoutPolicy = LockoutPolicy{MaxFailures: 5, Window: 15 * time.Minute, Cooldown: 15 * time.Minute}
+
+// AccountLockedError is returned while an account is locked out
+type AccountLockedError struct {
+	UserID int
+	Until  time.Time
+}
+
+// Error implements error
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("user %d is locked out until %s", e.UserID, e.Until.UTC().Format(time.RFC3339))
+}
+
+// ErrorCode implements apperr.Coder
+func (e *AccountLockedError) ErrorCode() apperr.Code {
+	return apperr.PermissionDenied
+}
+
+// lockout applies a LockoutPolicy for a UserService
+type lockout struct {
+	policy   LockoutPolicy
+	counters repository.CounterRepository
+}
+
+// WithLockout enforces policy on logins, counting failures in counters
+func (s *UserService) WithLockout(policy LockoutPolicy, counters repository.CounterRepository) *UserService {
+	s.lockout = &lockout{policy: policy, counters: counters}
+	return s
+}
+
+// logins returns the repository's LoginRepository
+func (s *UserService) logins() (repository.LoginRepository, error) {
+	repo, ok := s.repo.(repository.LoginRepository)
+	if !ok {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support login tracking")
+	}
+	return repo, nil
+}
+
+// failureCounter names the counter of a user's failed logins
+func failureCounter(userID int) string {
+	return "login_failures:" + strconv.Itoa(userID)
+}
+
+// CheckLogin returns AccountLockedError if the user may not log in now.
+// Call it before verifying credentials, so locked accounts can't be probed.
+func (s *UserService) CheckLogin(userID int) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, err := s.logins()
+	if err != nil {
+		return err
+	}
+
+	until, err := repo.LockedUntil(s.context(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to check lockout: %w", err)
+	}
+	if clock.Or(s.clock).Now().Before(until) {
+		return &AccountLockedError{UserID: userID, Until: until}
+	}
+	return nil
+}
+
+// RecordLoginFailure records a failed login from ip. With a lockout policy
+// it returns AccountLockedError when this failure locks the account.
+func (s *UserService) RecordLoginFailure(userID int, ip string) error {
+	if err := s.recordLogin(userID, ip, false); err != nil {
+		return err
+	}
+	if s.lockout == nil || s.lockout.policy.MaxFailures == 0 {
+		return nil
+	}
+
+	ctx := s.context()
+	policy := s.lockout.policy
+	now := clock.Or(s.clock).Now().UTC()
+
+	n, err := s.lockout.counters.IncrementCounter(ctx, tenant.FromContext(ctx), failureCounter(userID), now.Truncate(policy.Window), 1)
+	if err != nil {
+		return fmt.Errorf("failed to count login failure: %w", err)
+	}
+	if n < policy.MaxFailures {
+		return nil
+	}
+
+	repo, err := s.logins()
+	if err != nil {
+		return err
+	}
+	until := now.Add(policy.Cooldown)
+	if err := repo.LockUser(ctx, userID, until); err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	return &AccountLockedError{UserID: userID, Until: until}
+}
+
+// RecordLoginSuccess records a successful login from ip and resets the
+// user's failure count
+func (s *UserService) RecordLoginSuccess(userID int, ip string) error {
+	if err := s.recordLogin(userID, ip, true); err != nil {
+		return err
+	}
+	s.MarkSeen(userID)
+	if s.lockout == nil || s.lockout.policy.MaxFailures == 0 {
+		return nil
+	}
+
+	ctx := s.context()
+	window := clock.Or(s.clock).Now().UTC().Truncate(s.lockout.policy.Window)
+	if err := s.lockout.counters.ResetCounter(ctx, tenant.FromContext(ctx), failureCounter(userID), window); err != nil {
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+	return nil
+}
+
+// UnlockUser lifts a lockout before its cooldown ends, e.g. after the user
+// proved their identity another way
+func (s *UserService) UnlockUser(userID int) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, err := s.logins()
+	if err != nil {
+		return err
+	}
+	if err := repo.UnlockUser(s.context(), userID); err != nil {
+		return fmt.Errorf("failed to unlock user: %w", err)
+	}
+	return nil
+}
+
+// recordLogin stores one login attempt
+func (s *UserService) recordLogin(userID int, ip string, succeeded bool) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, err := s.logins()
+	if err != nil {
+		return err
+	}
+
+	err = repo.RecordLoginAttempt(s.context(), models.LoginAttempt{
+		UserID:      userID,
+		IP:          ip,
+		Succeeded:   succeeded,
+		AttemptedAt: clock.Or(s.clock).Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
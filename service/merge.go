@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"project/apperr"
+	"project/events"
+	"project/models"
+	"project/repository"
+)
+
+// AuditMerge is the audit action recorded when a user is merged into another
+const AuditMerge = "user.merge"
+
+// MergeResult is what a merge changed, or with a dry run would change
+type MergeResult struct {
+	// Survivor is the kept user with the duplicate's data folded in
+	Survivor    models.User `json:"survivor"`
+	DuplicateID int         `json:"duplicate_id"`
+	DryRun      bool        `json:"dry_run"`
+	repository.MergeReport
+}
+
+// errMergeDryRun rolls back the transaction of a dry run
+var errMergeDryRun = errors.New("merge dry run")
+
+// MergeUsers folds the user duplicateID into survivorID: the survivor
+// keeps its name, takes the duplicate's email if it has none, gains the
+// duplicate's tags and the attributes it lacks, and inherits every record
+// of the duplicate, which is then deleted. The merge and its audit entry
+// are written in one transaction. With dryRun the same transaction runs
+// and is rolled back, so the result reports exactly what would change.
+func (s *UserService) MergeUsers(survivorID, duplicateID int, dryRun bool) (MergeResult, error) {
+	if err := s.admit(); err != nil {
+		return MergeResult{}, err
+	}
+	if survivorID == duplicateID {
+		return MergeResult{}, apperr.New(apperr.InvalidArgument, "a user can't be merged into itself")
+	}
+
+	repo, ok := s.repo.(repository.MergeRepository)
+	_, txOK := s.repo.(repository.TxRunner)
+	if !ok || !txOK || !s.Capabilities().SupportsTransactions {
+		return MergeResult{}, apperr.New(apperr.Unimplemented, "repository does not support transactional merges")
+	}
+
+	survivor, err := s.repo.GetByID(survivorID)
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("failed to get user %d: %w", survivorID, err)
+	}
+	duplicate, err := s.repo.GetByID(duplicateID)
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("failed to get user %d: %w", duplicateID, err)
+	}
+	if survivor.TenantID != duplicate.TenantID {
+		return MergeResult{}, apperr.New(apperr.InvalidArgument, "users of different tenants can't be merged")
+	}
+
+	merged := mergeUser(survivor, duplicate)
+	result := MergeResult{Survivor: merged, DuplicateID: duplicateID, DryRun: dryRun}
+	err = s.transact(func(ctx context.Context) error {
+		report, err := repo.MergeUsers(ctx, merged, duplicateID)
+		if err != nil {
+			return err
+		}
+		result.MergeReport = report
+
+		err = s.audit(ctx, AuditMerge, auditSubject(survivorID), models.JSONMap{
+			"duplicate": auditSubject(duplicateID),
+			"moved":     report.Moved,
+			"dropped":   report.Dropped,
+		})
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return errMergeDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errMergeDryRun) {
+		return MergeResult{}, fmt.Errorf("failed to merge users: %w", err)
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	s.releaseUser()
+	return result, errors.Join(
+		s.publish(events.UserUpdated, survivorID, map[string]any{"merged": duplicateID}),
+		s.publish(events.UserDeleted, duplicateID, map[string]any{"merged_into": survivorID}))
+}
+
+// mergeUser returns survivor with the data of duplicate folded in; the
+// survivor wins where both have a value
+func mergeUser(survivor, duplicate models.User) models.User {
+	merged := survivor
+	if merged.Email == "" {
+		merged.Email = duplicate.Email
+	}
+
+	merged.Tags = slices.Clone(survivor.Tags)
+	for _, tag := range duplicate.Tags {
+		if !slices.Contains(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+
+	merged.Attributes = make(models.JSONMap, len(survivor.Attributes)+len(duplicate.Attributes))
+	for k, v := range duplicate.Attributes {
+		merged.Attributes[k] = v
+	}
+	for k, v := range survivor.Attributes {
+		merged.Attributes[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+
+	"project/apperr"
+	"project/models"
+	"project/notifications"
+	"project/repository"
+	"project/saga"
+)
+
+// OnboardOrgSaga names the saga returned by OnboardOrg
+const OnboardOrgSaga = "onboard_org"
+
+// OnboardOrg returns the saga provisioning an organization: it creates
+// the organization with its owner, adds the members and, with an outbox
+// set, notifies the owner. If adding a member fails the organization is
+// deleted again. Start it with the data keys name, owner_id and
+// member_ids.
+func (s *UserService) OnboardOrg() saga.Definition {
+	return saga.Definition{
+		Name: OnboardOrgSaga,
+		Steps: []saga.Step{
+			{
+				Name: "create_org",
+				Do: func(ctx context.Context, data saga.Data) error {
+					if data.Int("org_id") != 0 {
+						return nil
+					}
+					org, err := s.WithContext(ctx).CreateOrg(data.String("name"), data.Int("owner_id"))
+					if err != nil {
+						return err
+					}
+					data["org_id"] = org.ID
+					return nil
+				},
+				Compensate: func(ctx context.Context, data saga.Data) error {
+					repo, err := s.organizations()
+					if err != nil {
+						return err
+					}
+					return ignoreCode(repo.DeleteOrganization(ctx, data.Int("org_id")), apperr.NotFound)
+				},
+			},
+			{
+				Name: "add_members",
+				Do: func(ctx context.Context, data saga.Data) error {
+					for _, id := range data.Ints("member_ids") {
+						err := s.WithContext(ctx).AddMember(data.Int("org_id"), id, models.RoleMember)
+						if err := ignoreCode(err, apperr.Conflict); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+				Compensate: func(ctx context.Context, data saga.Data) error {
+					for _, id := range data.Ints("member_ids") {
+						err := s.WithContext(ctx).RemoveMember(data.Int("org_id"), id)
+						if err := ignoreCode(err, apperr.NotFound); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+			{
+				Name: "notify_owner",
+				Do: func(ctx context.Context, data saga.Data) error {
+					if s.outbox == nil {
+						return nil
+					}
+					return s.outbox.Enqueue(ctx, notifications.NotifyTopic, notifications.Notification{
+						UserID:   data.Int("owner_id"),
+						Template: notifications.OrganizationReadyTemplate,
+						Data:     map[string]any{"Organization": data.String("name")},
+					})
+				},
+			},
+		},
+	}
+}
+
+// ignoreCode returns nil if err carries code, so retried steps treat work
+// already done as success
+func ignoreCode(err error, code apperr.Code) error {
+	if err == nil || apperr.CodeOf(repository.ClassifyError(err)) == code {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"project/apperr"
+	"project/events"
+	"project/models"
+	"project/repository"
+	"project/tenant"
+)
+
+// organizations returns the repository's OrganizationRepository
+func (s *UserService) organizations() (repository.OrganizationRepository, error) {
+	repo, ok := s.repo.(repository.OrganizationRepository)
+	if !ok {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support organizations")
+	}
+	return repo, nil
+}
+
+// CreateOrg creates an organization in the bound context's tenant with
+// ownerID as its owner. Both are written in one transaction when the
+// repository supports them.
+func (s *UserService) CreateOrg(name string, ownerID int) (models.Organization, error) {
+	if err := s.admit(); err != nil {
+		return models.Organization{}, err
+	}
+
+	repo, err := s.organizations()
+	if err != nil {
+		return models.Organization{}, err
+	}
+
+	org := models.Organization{Name: name, TenantID: tenant.FromContext(s.context())}
+	if err := validateModel(org); err != nil {
+		return models.Organization{}, err
+	}
+
+	create := func(ctx context.Context) error {
+		created, err := repo.CreateOrganization(ctx, org)
+		if err != nil {
+			return err
+		}
+		org = created
+		return repo.AddMember(ctx, models.Membership{OrganizationID: org.ID, UserID: ownerID, Role: models.RoleOwner})
+	}
+
+	err = s.transact(create)
+	if err != nil {
+		return models.Organization{}, fmt.Errorf("failed to create organization: %w", err)
+	}
+	return org, s.publish(events.MemberAdded, ownerID, map[string]any{"organization_id": org.ID, "role": models.RoleOwner})
+}
+
+// AddMember adds a user to an organization with role, "member" if empty,
+// returning ValidationErrors for an unknown role
+func (s *UserService) AddMember(orgID, userID int, role string) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, err := s.organizations()
+	if err != nil {
+		return err
+	}
+
+	m := models.Membership{OrganizationID: orgID, UserID: userID, Role: role}
+	if err := validateModel(m); err != nil {
+		return err
+	}
+
+	if err := repo.AddMember(s.context(), m); err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return s.publish(events.MemberAdded, userID, map[string]any{"organization_id": orgID, "role": m.Role})
+}
+
+// RemoveMember removes a user from an organization
+func (s *UserService) RemoveMember(orgID, userID int) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, err := s.organizations()
+	if err != nil {
+		return err
+	}
+
+	if err := repo.RemoveMember(s.context(), orgID, userID); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	return s.publish(events.MemberRemoved, userID, map[string]any{"organization_id": orgID})
+}
+
+// ListMembers returns the members of an organization with their users
+func (s *UserService) ListMembers(orgID int) ([]models.Membership, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
+	repo, err := s.organizations()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := repo.GetOrganization(s.context(), orgID); err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	members, err := repo.ListMembers(s.context(), orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	return members, nil
+}
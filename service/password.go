@@ -0,0 +1,328 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"project/apperr"
+	"project/degrade"
+	"project/events"
+	"project/models"
+	"project/repository"
+	"project/tenant"
+)
+
+// PasswordPolicy decides whether a password may be set. CheckPassword
+// returns ValidationErrors for a password it rejects, and another error
+// when it couldn't decide.
+type PasswordPolicy interface {
+	CheckPassword(ctx context.Context, password string) error
+}
+
+// PasswordPolicies is a PasswordPolicy requiring every policy in it to
+// accept the password; the rejections of all of them are reported together
+type PasswordPolicies []PasswordPolicy
+
+// CheckPassword implements PasswordPolicy
+func (ps PasswordPolicies) CheckPassword(ctx context.Context, password string) error {
+	var invalid ValidationErrors
+	for _, p := range ps {
+		err := p.CheckPassword(ctx, password)
+		var v ValidationErrors
+		switch {
+		case err == nil:
+		case errors.As(err, &v):
+			invalid = append(invalid, v...)
+		default:
+			return err
+		}
+	}
+	if len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// StrengthPolicy rejects short passwords and those missing a required
+// character class. Set it per deployment, e.g. from a JSON config.
+type StrengthPolicy struct {
+	MinLength     int  `json:"min_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+}
+
+// DefaultStrengthPolicy follows NIST SP 800-63B: length matters, character
+// classes aren't required
+var DefaultStrengthPolicy = StrengthPolicy{MinLength: 12}
+
+// CheckPassword implements PasswordPolicy
+func (p StrengthPolicy) CheckPassword(_ context.Context, password string) error {
+	var invalid ValidationErrors
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		invalid = append(invalid, FieldError{Field: "password", Rule: "min",
+			Message: fmt.Sprintf("must be at least %d characters", p.MinLength)})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	for _, class := range []struct {
+		required, present bool
+		rule, name        string
+	}{
+		{p.RequireUpper, upper, "upper", "an upper case letter"},
+		{p.RequireLower, lower, "lower", "a lower case letter"},
+		{p.RequireDigit, digit, "digit", "a digit"},
+		{p.RequireSymbol, symbol, "symbol", "a symbol"},
+	} {
+		if class.required && !class.present {
+			invalid = append(invalid, FieldError{Field: "password", Rule: class.rule, Message: "must contain " + class.name})
+		}
+	}
+
+	if len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// HIBPRangeURL is the Have I Been Pwned range API BreachCheck queries
+const HIBPRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// BreachCheck rejects passwords found in known breaches, asking the Have I
+// Been Pwned range API with k-anonymity: only the first five hex digits of
+// the password's SHA-1 leave the process, and the match is made locally.
+type BreachCheck struct {
+	// URL is the range endpoint, the prefix appended; HIBPRangeURL when
+	// empty
+	URL string
+	// Client sends the requests; http.DefaultClient when nil
+	Client *http.Client
+	// MinCount is how many breaches a password must appear in to be
+	// rejected; 1 when 0
+	MinCount int
+	// FailOpen accepts passwords when the API can't be reached instead of
+	// failing the call
+	FailOpen bool
+	// Monitor, when set, calls the API as its dependency "breach_check";
+	// under the bypass policy passwords are accepted while the API is down
+	Monitor *degrade.Monitor
+}
+
+// CheckPassword implements PasswordPolicy
+func (b BreachCheck) CheckPassword(ctx context.Context, password string) error {
+	var count int
+	err := b.call(ctx, func(ctx context.Context) (err error) {
+		count, err = b.breaches(ctx, password)
+		return err
+	})
+	if err != nil {
+		if b.FailOpen {
+			return nil
+		}
+		return apperr.Wrap(apperr.Unavailable, fmt.Errorf("failed to check password breaches: %w", err))
+	}
+	if count >= max(b.MinCount, 1) {
+		return ValidationErrors{{Field: "password", Rule: "breached",
+			Message: "has appeared in a data breach, choose another"}}
+	}
+	return nil
+}
+
+// call runs fn through the monitor, if one is set
+func (b BreachCheck) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	if b.Monitor == nil {
+		return fn(ctx)
+	}
+	return b.Monitor.Call(ctx, "breach_check", fn, nil)
+}
+
+// breaches returns how often password appears in the breach corpus
+func (b BreachCheck) breaches(ctx context.Context, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	url := b.URL
+	if url == "" {
+		url = HIBPRangeURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	// padding hides the prefix's true number of matches from observers
+	req.Header.Set("Add-Padding", "true")
+
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("range API returned %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		hash, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(hash, suffix) {
+			continue
+		}
+		// padding entries have a count of 0
+		return strconv.Atoi(count)
+	}
+	return 0, scanner.Err()
+}
+
+// WithPasswordPolicy enforces policy when a password is set, at
+// registration or on change. Without a policy DefaultStrengthPolicy
+// applies.
+func (s *UserService) WithPasswordPolicy(policy PasswordPolicy) *UserService {
+	s.passwords = policy
+	return s
+}
+
+// passwordRepo returns the repository's PasswordRepository
+func (s *UserService) passwordRepo() (repository.PasswordRepository, error) {
+	repo, ok := s.repo.(repository.PasswordRepository)
+	if !ok {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support passwords")
+	}
+	return repo, nil
+}
+
+// hashPassword checks password against the policy and returns its bcrypt
+// hash
+func (s *UserService) hashPassword(password string) (string, error) {
+	var policy PasswordPolicy = DefaultStrengthPolicy
+	if s.passwords != nil {
+		policy = s.passwords
+	}
+	if err := policy.CheckPassword(s.context(), password); err != nil {
+		return "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		// bcrypt only hashes the first 72 bytes
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return "", ValidationErrors{{Field: "password", Rule: "max", Message: "must be at most 72 bytes"}}
+		}
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// RegisterUserWithPassword is RegisterUserWithEmail setting the user's
+// password, returning ValidationErrors, before anything is created, if the
+// password policy rejects it
+func (s *UserService) RegisterUserWithPassword(name, email, password string) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+	repo, err := s.passwordRepo()
+	if err != nil {
+		return err
+	}
+	hash, err := s.hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user := models.User{Name: name, Email: email, TenantID: tenant.FromContext(s.context())}
+	if err := validateModel(user); err != nil {
+		return err
+	}
+	release, err := s.reserveUser()
+	if err != nil {
+		return err
+	}
+	created, err := s.create(user)
+	if err != nil {
+		release()
+		return fmt.Errorf("failed to register user: %w", err)
+	}
+	published := s.publish(events.UserRegistered, created.ID, map[string]any{"name": user.Name, "email": user.Email})
+
+	if err := repo.SetPasswordHash(s.context(), created.ID, hash); err != nil {
+		return fmt.Errorf("failed to set password of user %d: %w", created.ID, err)
+	}
+	return published
+}
+
+// ChangePassword replaces a user's password, returning ValidationErrors if
+// the password policy rejects the new one
+func (s *UserService) ChangePassword(userID int, password string) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+	repo, err := s.passwordRepo()
+	if err != nil {
+		return err
+	}
+	hash, err := s.hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.SetPasswordHash(s.context(), userID, hash); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+	return nil
+}
+
+// ErrWrongPassword is returned by VerifyPassword for a password that
+// doesn't match, or a user without one
+var ErrWrongPassword error = apperr.New(apperr.Unauthenticated, "wrong password")
+
+// VerifyPassword returns ErrWrongPassword unless password is the user's.
+// Call CheckLogin first and record the outcome, so guessing is locked out.
+func (s *UserService) VerifyPassword(userID int, password string) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+	repo, err := s.passwordRepo()
+	if err != nil {
+		return err
+	}
+
+	hash, err := repo.PasswordHash(s.context(), userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrWrongPassword
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify password: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"project/apperr"
+	"project/models"
+	"project/presence"
+	"project/repository"
+)
+
+// WithPresence records sightings of users in tracker, e.g. on login, and
+// answers IsOnline and ListOnlineUsers from it
+func (s *UserService) WithPresence(tracker *presence.Tracker) *UserService {
+	s.presence = tracker
+	return s
+}
+
+// MarkSeen records that the user is active now, e.g. on a request or a
+// WebSocket heartbeat. It doesn't block on I/O.
+func (s *UserService) MarkSeen(userID int) {
+	if s.presence != nil {
+		s.presence.Seen(userID)
+	}
+}
+
+// IsOnline reports whether the user was seen recently
+func (s *UserService) IsOnline(userID int) (bool, error) {
+	if err := s.admit(); err != nil {
+		return false, err
+	}
+	if s.presence == nil {
+		return false, apperr.New(apperr.Unimplemented, "presence tracking is not configured")
+	}
+
+	online, err := s.presence.IsOnline(s.context(), userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check presence: %w", err)
+	}
+	return online, nil
+}
+
+// ListOnlineUsers returns up to limit users seen recently, the most recent
+// first; users deleted since are skipped
+func (s *UserService) ListOnlineUsers(limit int) ([]models.User, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	if s.presence == nil {
+		return nil, apperr.New(apperr.Unimplemented, "presence tracking is not configured")
+	}
+
+	ids, err := s.presence.Online(s.context(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list online users: %w", err)
+	}
+	users := make([]models.User, 0, len(ids))
+	for _, id := range ids {
+		user, err := s.repo.GetByID(id)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get online user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"project/apperr"
+	"project/clock"
+	"project/repository"
+	"project/tenant"
+)
+
+// Quota counter names
+const (
+	quotaUsers    = "users"
+	quotaRequests = "requests"
+)
+
+// lifetimeWindow is the window of counters that never reset
+var lifetimeWindow = time.Unix(0, 0).UTC()
+
+// Quota limits one tenant; zero disables a limit
+type Quota struct {
+	MaxUsers          int `json:"max_users"`
+	RequestsPerMinute int `json:"requests_per_minute"`
+}
+
+// QuotaLimits holds the default quota and per-tenant overrides
+type QuotaLimits struct {
+	Default Quota            `json:"default"`
+	Tenants map[string]Quota `json:"tenants"`
+}
+
+// For returns the quota of tenant
+func (l QuotaLimits) For(tenant string) Quota {
+	if q, ok := l.Tenants[tenant]; ok {
+		return q
+	}
+	return l.Default
+}
+
+// QuotaExceededError is returned when a tenant is over a quota
+type QuotaExceededError struct {
+	Tenant string
+	Quota  string
+	Limit  int
+}
+
+// Error implements error
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("tenant %q exceeded %s quota of %d", e.Tenant, e.Quota, e.Limit)
+}
+
+// ErrorCode implements apperr.Coder
+func (e *QuotaExceededError) ErrorCode() apperr.Code {
+	return apperr.ResourceExhausted
+}
+
+// MemoryCounters is an in-process repository.CounterRepository for single
+// instance deployments
+type MemoryCounters struct {
+	mu     sync.Mutex
+	values map[string]int
+}
+
+// NewMemoryCounters creates empty in-memory counters
+func NewMemoryCounters() *MemoryCounters {
+	return &MemoryCounters{values: make(map[string]int)}
+}
+
+// IncrementCounter implements repository.CounterRepository. Counters of
+// past windows are dropped as new windows start.
+func (m *MemoryCounters) IncrementCounter(ctx context.Context, tenant, counter string, window time.Time, delta int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := fmt.Sprintf("%s\x00%s\x00%d", tenant, counter, window.UnixNano())
+	if _, ok := m.values[key]; !ok && !window.Equal(lifetimeWindow) {
+		prefix := fmt.Sprintf("%s\x00%s\x00", tenant, counter)
+		for k := range m.values {
+			if len(k) > len(prefix) && k[:len(prefix)] == prefix {
+				delete(m.values, k)
+			}
+		}
+	}
+	m.values[key] += delta
+	return m.values[key], nil
+}
+
+// ResetCounter implements repository.CounterRepository
+func (m *MemoryCounters) ResetCounter(ctx context.Context, tenant, counter string, window time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.values, fmt.Sprintf("%s\x00%s\x00%d", tenant, counter, window.UnixNano()))
+	return nil
+}
+
+// WithQuotas enforces limits per tenant of the bound context, counting
+// usage in counters. User counts cover users registered and deleted through
+// the service since counting began.
+func (s *UserService) WithQuotas(limits QuotaLimits, counters repository.CounterRepository) *UserService {
+	s.quotas = &quotaEnforcer{limits: limits, counters: counters}
+	return s
+}
+
+// quotaEnforcer checks quotas for a UserService
+type quotaEnforcer struct {
+	limits   QuotaLimits
+	counters repository.CounterRepository
+}
+
+// context returns the bound context, or Background
+func (s *UserService) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// admit counts a request against the tenant's per-minute limit
+func (s *UserService) admit() error {
+	if s.quotas == nil {
+		return nil
+	}
+	ctx := s.context()
+	t := tenant.FromContext(ctx)
+
+	limit := s.quotas.limits.For(t).RequestsPerMinute
+	if limit == 0 {
+		return nil
+	}
+
+	window := clock.Or(s.clock).Now().UTC().Truncate(time.Minute)
+	n, err := s.quotas.counters.IncrementCounter(ctx, t, quotaRequests, window, 1)
+	if err != nil {
+		return fmt.Errorf("failed to check request quota: %w", err)
+	}
+	if n > limit {
+		return &QuotaExceededError{Tenant: t, Quota: quotaRequests, Limit: limit}
+	}
+	return nil
+}
+
+// reserveUser claims one user slot, returning a release func to undo it
+// if the user isn't created
+func (s *UserService) reserveUser() (release func(), err error) {
+	noop := func() {}
+	if s.quotas == nil {
+		return noop, nil
+	}
+	ctx := s.context()
+	t := tenant.FromContext(ctx)
+
+	limit := s.quotas.limits.For(t).MaxUsers
+	if limit == 0 {
+		return noop, nil
+	}
+
+	release = func() {
+		s.quotas.counters.IncrementCounter(ctx, t, quotaUsers, lifetimeWindow, -1)
+	}
+
+	n, err := s.quotas.counters.IncrementCounter(ctx, t, quotaUsers, lifetimeWindow, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check user quota: %w", err)
+	}
+	if n > limit {
+		release()
+		return nil, &QuotaExceededError{Tenant: t, Quota: quotaUsers, Limit: limit}
+	}
+	return release, nil
+}
+
+// releaseUser frees the user slot of a deleted user
+func (s *UserService) releaseUser() {
+	if s.quotas == nil || s.quotas.limits.For(tenant.FromContext(s.context())).MaxUsers == 0 {
+		return
+	}
+	ctx := s.context()
+	s.quotas.counters.IncrementCounter(ctx, tenant.FromContext(ctx), quotaUsers, lifetimeWindow, -1)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"project/apperr"
+	"project/repository"
+	"project/tenant"
+)
+
+// UserCountsByDay returns the users of the current tenant created per day
+// in [from, to). Counts come from a materialized view and lag behind
+// registrations by up to its refresh interval.
+func (s *UserService) UserCountsByDay(from, to time.Time) ([]repository.DailyCount, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	if !from.Before(to) {
+		return nil, apperr.New(apperr.InvalidArgument, "from must be before to")
+	}
+
+	reports, ok := s.repo.(repository.ReportingRepository)
+	if !ok {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support reporting views")
+	}
+
+	ctx := s.context()
+	counts, err := reports.UserCountsByDay(ctx, tenant.FromContext(ctx), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	return counts, nil
+}
+
+// OrganizationMemberCount returns the members of an organization of the
+// current tenant, as of the last refresh of the reporting views
+func (s *UserService) OrganizationMemberCount(orgID int) (int64, error) {
+	if err := s.admit(); err != nil {
+		return 0, err
+	}
+
+	reports, ok := s.repo.(repository.ReportingRepository)
+	if !ok {
+		return 0, apperr.New(apperr.Unimplemented, "repository does not support reporting views")
+	}
+
+	ctx := s.context()
+	n, err := reports.OrganizationMemberCount(ctx, tenant.FromContext(ctx), orgID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count members: %w", err)
+	}
+	return n, nil
+}
+
+// TotalUsers returns the number of live users across all tenants, from a
+// maintained counter when the repository keeps one
+func (s *UserService) TotalUsers() (int64, error) {
+	if err := s.admit(); err != nil {
+		return 0, err
+	}
+
+	totals, ok := s.repo.(repository.TotalUsersRepository)
+	if !ok {
+		return 0, apperr.New(apperr.Unimplemented, "repository does not support user totals")
+	}
+
+	n, err := totals.TotalUsers(s.context())
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return n, nil
+}
@@ -1,14 +1,37 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
+
+	"project/apperr"
+	"project/clock"
+	"project/events"
+	"project/flags"
+	"project/ids"
 	"project/models"
+	"project/notifications"
+	"project/presence"
 	"project/repository"
+	"project/tenant"
 )
 
 // UserService handles business logic for user operations
 type UserService struct {
-	repo repository.UserRepository
+	repo      repository.UserRepository
+	flags     *flags.Set
+	quotas    *quotaEnforcer
+	lockout   *lockout
+	passwords PasswordPolicy
+	clock     clock.Clock
+	ids       ids.Source
+	outbox    *repository.Outbox
+	shredder  KeyShredder
+	bus       *events.Bus
+	presence  *presence.Tracker
+	ctx       context.Context
 }
 
 // NewUserService creates a new user service
@@ -16,25 +39,439 @@ func NewUserService(repo repository.UserRepository) *UserService {
 	return &UserService{repo: repo}
 }
 
-// RegisterUser creates a new user
+// WithContext returns a service whose repository calls carry ctx, e.g. its
+// request ID, when the repository is a ContextualRepository
+func (s *UserService) WithContext(ctx context.Context) *UserService {
+	bound := *s
+	bound.ctx = ctx
+	if repo, ok := s.repo.(repository.ContextualRepository); ok {
+		bound.repo = repo.WithContext(ctx)
+	}
+	return &bound
+}
+
+// WithClock replaces the clock the service reads the time from
+func (s *UserService) WithClock(c clock.Clock) *UserService {
+	s.clock = c
+	return s
+}
+
+// WithIDSource replaces the source of generated identifiers and tokens
+func (s *UserService) WithIDSource(src ids.Source) *UserService {
+	s.ids = src
+	return s
+}
+
+// WithFlags sets the feature flags gating optional behaviour. Without
+// flags every gated feature is on.
+func (s *UserService) WithFlags(f *flags.Set) *UserService {
+	s.flags = f
+	return s
+}
+
+// enabled reports whether a gated feature is on for the bound context's
+// tenant
+func (s *UserService) enabled(flag string) bool {
+	if s.flags == nil {
+		return true
+	}
+	return s.flags.Enabled(s.context(), flag)
+}
+
+// Capabilities reports what the repository supports, so callers can hide
+// features a limited backend lacks
+func (s *UserService) Capabilities() repository.Capabilities {
+	return repository.CapabilitiesOf(s.repo)
+}
+
+// transact runs fn in a transaction when the repository supports them, and
+// directly otherwise
+func (s *UserService) transact(fn func(ctx context.Context) error) error {
+	if runner, ok := s.repo.(repository.TxRunner); ok && s.Capabilities().SupportsTransactions {
+		return runner.WithTransaction(s.context(), fn)
+	}
+	return fn(s.context())
+}
+
+// WithOutbox queues a welcome email in outbox for users registered with an
+// email address, in the same transaction as the user
+func (s *UserService) WithOutbox(outbox *repository.Outbox) *UserService {
+	s.outbox = outbox
+	return s
+}
+
+// RegisterUser creates a new user, returning ValidationErrors if the user
+// fails its validate tags and QuotaExceededError if the tenant is full
 func (s *UserService) RegisterUser(name string) error {
-	if name == "" {
-		return fmt.Errorf("user name cannot be empty")
+	return s.RegisterUserWithEmail(name, "")
+}
+
+// RegisterUserWithEmail creates a new user with an email address. With an
+// outbox set the welcome email is queued atomically with the user.
+func (s *UserService) RegisterUserWithEmail(name, email string) error {
+	_, err := s.CreateUser(name, email)
+	return err
+}
+
+// CreateUser is RegisterUserWithEmail returning the user as stored. The
+// user is returned with a publishing error too, since it was created.
+func (s *UserService) CreateUser(name, email string) (models.User, error) {
+	if err := s.admit(); err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{Name: name, Email: email, TenantID: tenant.FromContext(s.context())}
+	if err := validateModel(user); err != nil {
+		return models.User{}, err
+	}
+
+	release, err := s.reserveUser()
+	if err != nil {
+		return models.User{}, err
+	}
+
+	created, err := s.create(user)
+	if err != nil {
+		release()
+		return models.User{}, fmt.Errorf("failed to register user: %w", err)
+	}
+
+	return created, s.publish(events.UserRegistered, created.ID, map[string]any{"name": user.Name, "email": user.Email})
+}
+
+// create inserts user and returns it as stored, queueing its welcome email
+// when there is one to send
+func (s *UserService) create(user models.User) (models.User, error) {
+	if s.outbox == nil || user.Email == "" {
+		return s.repo.Create(user)
+	}
+
+	creator, ok := s.repo.(repository.ContextCreator)
+	runner, txOK := s.repo.(repository.TxRunner)
+	if !ok || !txOK || !s.Capabilities().SupportsTransactions {
+		return models.User{}, apperr.New(apperr.Unimplemented, "repository does not support transactional outbox writes")
+	}
+
+	var created models.User
+	err := runner.WithTransaction(s.context(), func(ctx context.Context) error {
+		var err error
+		if created, err = creator.CreateContext(ctx, user); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(ctx, notifications.WelcomeTopic, notifications.Welcome{
+			UserName: user.Name,
+			Email:    user.Email,
+		})
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return created, nil
+}
+
+// SetChannelPreference enables or disables notifications to a user on
+// channel, e.g. "sms", delivered to address
+func (s *UserService) SetChannelPreference(userID int, channel, address string, enabled bool) error {
+	if err := s.admit(); err != nil {
+		return err
 	}
 
-	user := models.User{Name: name}
-	if err := s.repo.Create(user); err != nil {
-		return fmt.Errorf("failed to register user: %w", err)
+	prefs, ok := s.repo.(repository.PreferenceRepository)
+	if !ok {
+		return apperr.New(apperr.Unimplemented, "repository does not support notification preferences")
 	}
 
+	err := prefs.SetChannelPreference(s.context(), repository.ChannelPreference{
+		UserID:  userID,
+		Channel: channel,
+		Address: address,
+		Enabled: enabled,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set channel preference: %w", err)
+	}
 	return nil
 }
 
 // ListUsers retrieves all registered users
 func (s *UserService) ListUsers() ([]models.User, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
 	users, err := s.repo.GetAll()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
 	return users, nil
 }
+
+// ListUsersParallel retrieves all registered users with workers
+// concurrent range scans, for tables too large to read quickly in one
+// query. Without parallel scan support it falls back to ListUsers.
+func (s *UserService) ListUsersParallel(workers int) ([]models.User, error) {
+	repo, ok := s.repo.(repository.ParallelScanner)
+	if !ok {
+		return s.ListUsers()
+	}
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
+	users, err := repo.ParallelScan(s.context(), workers)
+	if errors.Is(err, repository.ErrUnsupported) {
+		users, err = s.repo.GetAll()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// GetUser retrieves a single user by ID
+func (s *UserService) GetUser(id int) (models.User, error) {
+	if err := s.admit(); err != nil {
+		return models.User{}, err
+	}
+
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// GetUsers retrieves the users with ids in the order given, along with the
+// IDs that matched no user
+func (s *UserService) GetUsers(ids []int) ([]models.User, []int, error) {
+	if err := s.admit(); err != nil {
+		return nil, nil, err
+	}
+
+	batch, ok := s.repo.(repository.BatchGetter)
+	if !ok {
+		return nil, nil, apperr.New(apperr.Unimplemented, "repository does not support batch get")
+	}
+
+	users, missing, err := batch.GetByIDs(ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	return users, missing, nil
+}
+
+// CountUsersByDay returns the number of users registered per day
+func (s *UserService) CountUsersByDay() (map[string]int, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
+	agg, ok := s.repo.(repository.AggregateRepository)
+	if !ok {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support aggregates")
+	}
+
+	counts, err := agg.CountByCreatedDate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	return counts, nil
+}
+
+// CountUsersBy returns the number of users per distinct value of field
+func (s *UserService) CountUsersBy(field string) (map[string]int, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
+	agg, ok := s.repo.(repository.AggregateRepository)
+	if !ok {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support aggregates")
+	}
+
+	counts, err := agg.GroupBy(field)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	return counts, nil
+}
+
+// ListUsersCreatedBetween retrieves users registered in [from, to)
+func (s *UserService) ListUsersCreatedBetween(from, to time.Time, opts repository.ListOptions) ([]models.User, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+
+	users, err := s.repo.ListCreatedBetween(from, to, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// SearchUsers returns users matching a full-text query, best matches first
+func (s *UserService) SearchUsers(query string, opts repository.ListOptions) ([]repository.RankedUser, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
+	if query == "" {
+		return nil, apperr.New(apperr.InvalidArgument, "search query cannot be empty")
+	}
+
+	if !s.enabled(flags.FullTextSearch) {
+		return nil, apperr.New(apperr.Unavailable, "full-text search is disabled")
+	}
+
+	searcher, ok := s.repo.(repository.FullTextSearcher)
+	if !ok || !s.Capabilities().SupportsFullTextSearch {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support full-text search")
+	}
+
+	users, err := searcher.SearchUsersFullText(query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	return users, nil
+}
+
+// SetUserAttributes replaces the custom metadata attached to a user
+func (s *UserService) SetUserAttributes(id int, attrs models.JSONMap) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, ok := s.repo.(repository.AttributeRepository)
+	if !ok {
+		return apperr.New(apperr.Unimplemented, "repository does not support attributes")
+	}
+
+	if err := repo.SetAttributes(id, attrs); err != nil {
+		return fmt.Errorf("failed to set user attributes: %w", err)
+	}
+	return nil
+}
+
+// FindUsersByAttributes returns users whose attributes contain all of attrs
+func (s *UserService) FindUsersByAttributes(attrs models.JSONMap) ([]models.User, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+
+	repo, ok := s.repo.(repository.AttributeRepository)
+	if !ok {
+		return nil, apperr.New(apperr.Unimplemented, "repository does not support attributes")
+	}
+
+	users, err := repo.FindByAttributes(attrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find users: %w", err)
+	}
+	return users, nil
+}
+
+// UpdateUser renames an existing user, returning ValidationErrors if the
+// result fails its validate tags
+func (s *UserService) UpdateUser(id int, name string) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	user.Name = name
+	if err := validateModel(user); err != nil {
+		return err
+	}
+
+	if err := s.repo.Update(user); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return s.publish(events.UserUpdated, id, map[string]any{"name": name})
+}
+
+// GetUserSettings returns a user's settings, the defaults if none were saved
+func (s *UserService) GetUserSettings(id int) (models.UserSettings, error) {
+	if err := s.admit(); err != nil {
+		return models.UserSettings{}, err
+	}
+
+	repo, ok := s.repo.(repository.SettingsRepository)
+	if !ok {
+		return models.UserSettings{}, apperr.New(apperr.Unimplemented, "repository does not support user settings")
+	}
+
+	user, err := repo.GetUserWithSettings(id)
+	if err != nil {
+		return models.UserSettings{}, fmt.Errorf("failed to get user settings: %w", err)
+	}
+	return *user.Settings, nil
+}
+
+// UpdateUserSettings replaces a user's settings, touching the user row in
+// the same transaction, and returns ValidationErrors if they fail their
+// validate tags
+func (s *UserService) UpdateUserSettings(id int, settings models.UserSettings) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	repo, ok := s.repo.(repository.SettingsRepository)
+	if !ok {
+		return apperr.New(apperr.Unimplemented, "repository does not support user settings")
+	}
+
+	user, err := repo.GetUserWithSettings(id)
+	if err != nil {
+		return fmt.Errorf("failed to update user settings: %w", err)
+	}
+
+	settings.UserID = id
+	user.Settings = &settings
+	if err := validateModel(user); err != nil {
+		return err
+	}
+
+	if err := repo.UpdateUserWithSettings(user); err != nil {
+		return fmt.Errorf("failed to update user settings: %w", err)
+	}
+	return s.publish(events.UserUpdated, id, nil)
+}
+
+// DeleteUser soft-deletes a user
+func (s *UserService) DeleteUser(id int) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	s.releaseUser()
+	return s.publish(events.UserDeleted, id, nil)
+}
+
+// GetUserAsOf returns the user as it was at t, if the repository keeps history
+func (s *UserService) GetUserAsOf(id int, t time.Time) (models.User, error) {
+	if err := s.admit(); err != nil {
+		return models.User{}, err
+	}
+
+	repo, ok := s.repo.(repository.HistoryRepository)
+	if !ok {
+		return models.User{}, apperr.New(apperr.Unimplemented, "repository does not keep user history")
+	}
+
+	user, err := repo.GetUserAsOf(id, t)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to get user history: %w", err)
+	}
+	return user, nil
+}
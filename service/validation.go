@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"project/apperr"
+)
+
+// FieldError describes why a single field failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is returned when a model fails its validate tags. It lists
+// every failing field so transports can report them all at once.
+type ValidationErrors []FieldError
+
+// Error implements error
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, fe := range v {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// ErrorCode implements apperr.Coder
+func (v ValidationErrors) ErrorCode() apperr.Code {
+	return apperr.InvalidArgument
+}
+
+var validate = newValidator()
+
+// newValidator reports fields by their column name, the name clients and
+// the database already know them by
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// validateModel checks model against its validate tags, returning
+// ValidationErrors on failure
+func validateModel(model any) error {
+	err := validate.Struct(model)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return fmt.Errorf("failed to validate: %w", err)
+	}
+
+	out := make(ValidationErrors, len(fieldErrs))
+	for i, fe := range fieldErrs {
+		out[i] = FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: validationMessage(fe),
+		}
+	}
+	return out
+}
+
+func validationMessage(fe validator.FieldError) string {
+	unit := "characters"
+	if k := fe.Kind(); k == reflect.Slice || k == reflect.Map || k == reflect.Array {
+		unit = "items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		return fmt.Sprintf("must be at most %s %s", fe.Param(), unit)
+	case "min":
+		return fmt.Sprintf("must be at least %s %s", fe.Param(), unit)
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "email":
+		return "must be a valid email address"
+	default:
+		return fmt.Sprintf("failed %s validation", fe.Tag())
+	}
+}
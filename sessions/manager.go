@@ -0,0 +1,202 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"project/apperr"
+	"project/clock"
+	"project/ids"
+	"project/tenant"
+)
+
+// Manager issues session cookies and resolves them to sessions
+type Manager struct {
+	store Store
+
+	// CookieName is the session cookie, "session" by default
+	CookieName string
+	// TTL is how long a session lasts without activity; sessions used in
+	// the second half of their TTL are extended
+	TTL time.Duration
+	// Insecure allows the cookie over plain HTTP, for local development
+	Insecure bool
+
+	// Clock and IDs default to the system clock and crypto/rand
+	Clock clock.Clock
+	IDs   ids.Source
+}
+
+// NewManager creates a manager keeping sessions in store for 24 hours
+func NewManager(store Store) *Manager {
+	return &Manager{store: store, CookieName: "session", TTL: 24 * time.Hour}
+}
+
+// storeID is the key a session is stored under: a hash of its cookie value
+func storeID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Start creates a session for userID and sets its cookie on w, e.g. after
+// a successful login
+func (m *Manager) Start(ctx context.Context, w http.ResponseWriter, userID int) (Session, error) {
+	token, err := ids.Or(m.IDs).NewID()
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	now := clock.Or(m.Clock).Now()
+	sess := Session{
+		ID:        storeID(token),
+		UserID:    userID,
+		Data:      map[string]string{},
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.TTL),
+	}
+	if err := m.store.Save(ctx, sess); err != nil {
+		return Session{}, err
+	}
+
+	m.setCookie(w, token, sess.ExpiresAt)
+	return sess, nil
+}
+
+// Destroy deletes the session of r, if any, and clears its cookie, e.g. on
+// logout
+func (m *Manager) Destroy(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	m.setCookie(w, "", time.Time{})
+
+	c, err := r.Cookie(m.CookieName)
+	if err != nil {
+		return nil
+	}
+	return m.store.Delete(ctx, storeID(c.Value))
+}
+
+// Save persists changes to the data of a session loaded by Middleware
+func (m *Manager) Save(ctx context.Context, sess Session) error {
+	return m.store.Save(ctx, sess)
+}
+
+// Middleware loads the session named by the request's cookie into the
+// request context. Requests without a valid session pass through without
+// one; wrap handlers needing a session in Require.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(m.CookieName)
+		if err != nil || c.Value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		sess, err := m.load(ctx, c.Value)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			m.setCookie(w, "", time.Time{})
+			next.ServeHTTP(w, r)
+			return
+		case err != nil:
+			status := apperr.HTTPStatus(apperr.Unavailable)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		// sliding expiry; the cookie is reissued with the new lifetime
+		now := clock.Or(m.Clock).Now()
+		if sess.ExpiresAt.Sub(now) < m.TTL/2 {
+			sess.ExpiresAt = now.Add(m.TTL)
+			if err := m.store.Save(ctx, sess); err == nil {
+				m.setCookie(w, c.Value, sess.ExpiresAt)
+			}
+		}
+
+		ctx = tenant.WithActor(NewContext(ctx, sess), strconv.Itoa(sess.UserID))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// load returns the live session for a cookie value
+func (m *Manager) load(ctx context.Context, token string) (Session, error) {
+	sess, err := m.store.Get(ctx, storeID(token))
+	if err != nil {
+		return Session{}, err
+	}
+	if sess.Expired(clock.Or(m.Clock).Now()) {
+		return Session{}, ErrNotFound
+	}
+	return sess, nil
+}
+
+// Require rejects requests that Middleware found no session for
+func Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := FromContext(r.Context()); !ok {
+			status := apperr.HTTPStatus(apperr.Unauthenticated)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setCookie sets the session cookie to token until expires; an empty token
+// deletes the cookie
+func (m *Manager) setCookie(w http.ResponseWriter, token string, expires time.Time) {
+	c := &http.Cookie{
+		Name:     m.CookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   !m.Insecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if token == "" {
+		c.MaxAge = -1
+	} else {
+		c.Expires = expires
+	}
+	http.SetCookie(w, c)
+}
+
+// Cleanup deletes expired sessions every interval until ctx is cancelled
+func (m *Manager) Cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := m.store.DeleteExpired(ctx, clock.Or(m.Clock).Now())
+			if err != nil {
+				log.Printf("Failed to delete expired sessions: %v", err)
+			} else if n > 0 {
+				log.Printf("Deleted %d expired sessions", n)
+			}
+		}
+	}
+}
+
+// ctxKey stores the session in a context
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying sess
+func NewContext(ctx context.Context, sess Session) context.Context {
+	return context.WithValue(ctx, ctxKey{}, sess)
+}
+
+// FromContext returns the session in ctx, if any
+func FromContext(ctx context.Context) (Session, bool) {
+	sess, ok := ctx.Value(ctxKey{}).(Session)
+	return sess, ok
+}
@@ -0,0 +1,81 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"project/clock"
+)
+
+// RedisClient is the subset of a Redis client RedisStore needs, so no
+// Redis driver is pulled into this module; adapt e.g. go-redis's Get,
+// Set and Del to it. Get returns found=false for a missing key.
+type RedisClient interface {
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+}
+
+// RedisStore keeps sessions in Redis under KeyPrefix, relying on key
+// expiry instead of a cleanup job
+type RedisStore struct {
+	client RedisClient
+	// KeyPrefix namespaces session keys, "session:" by default
+	KeyPrefix string
+	// Clock computes key TTLs; nil means the system clock
+	Clock clock.Clock
+}
+
+// NewRedisStore creates a RedisStore
+func NewRedisStore(client RedisClient) *RedisStore {
+	return &RedisStore{client: client, KeyPrefix: "session:"}
+}
+
+// Get implements Store
+func (s *RedisStore) Get(ctx context.Context, id string) (Session, error) {
+	data, found, err := s.client.Get(ctx, s.KeyPrefix+id)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	if !found {
+		return Session{}, ErrNotFound
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return Session{}, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return sess, nil
+}
+
+// Save implements Store. The key expires with the session.
+func (s *RedisStore) Save(ctx context.Context, sess Session) error {
+	ttl := sess.ExpiresAt.Sub(clock.Or(s.Clock).Now())
+	if ttl <= 0 {
+		return s.Delete(ctx, sess.ID)
+	}
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := s.client.Set(ctx, s.KeyPrefix+sess.ID, data, ttl); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	if err := s.client.Del(ctx, s.KeyPrefix+id); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired implements Store; Redis expires keys itself
+func (s *RedisStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
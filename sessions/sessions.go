@@ -0,0 +1,42 @@
+// Package sessions keeps server-side sessions for cookie-authenticated
+// clients in a pluggable Store.
+package sessions
+
+import (
+	"context"
+	"time"
+
+	"project/apperr"
+)
+
+// Session is the server-side state of a logged-in client
+type Session struct {
+	// ID is the store key. Managers store a hash of the cookie value, so a
+	// leaked store doesn't leak usable session cookies.
+	ID        string
+	UserID    int
+	Data      map[string]string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Expired reports whether s has expired at now
+func (s Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// ErrNotFound is returned for unknown and expired sessions
+var ErrNotFound = apperr.New(apperr.NotFound, "session not found")
+
+// Store persists sessions
+type Store interface {
+	// Get returns the session with id, or ErrNotFound
+	Get(ctx context.Context, id string) (Session, error)
+	// Save creates or replaces a session
+	Save(ctx context.Context, s Session) error
+	// Delete removes a session; deleting a missing one is not an error
+	Delete(ctx context.Context, id string) error
+	// DeleteExpired removes sessions expired before now and reports how
+	// many were removed
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
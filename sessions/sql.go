@@ -0,0 +1,100 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLStore keeps sessions in the sessions table
+type SQLStore struct {
+	db      *sql.DB
+	queries sqlQueries
+}
+
+// sqlQueries are the dialect's statements
+type sqlQueries struct {
+	get, save, delete, deleteExpired string
+}
+
+// NewPostgresStore creates a SQLStore for PostgreSQL
+func NewPostgresStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, queries: sqlQueries{
+		get: "SELECT id, user_id, data, created_at, expires_at FROM sessions WHERE id = $1",
+		save: `INSERT INTO sessions (id, user_id, data, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at`,
+		delete:        "DELETE FROM sessions WHERE id = $1",
+		deleteExpired: "DELETE FROM sessions WHERE expires_at <= $1",
+	}}
+}
+
+// NewMySQLStore creates a SQLStore for MySQL
+func NewMySQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, queries: sqlQueries{
+		get: "SELECT id, user_id, data, created_at, expires_at FROM sessions WHERE id = ?",
+		save: `INSERT INTO sessions (id, user_id, data, created_at, expires_at) VALUES (?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE data = VALUES(data), expires_at = VALUES(expires_at)`,
+		delete:        "DELETE FROM sessions WHERE id = ?",
+		deleteExpired: "DELETE FROM sessions WHERE expires_at <= ?",
+	}}
+}
+
+// Get implements Store
+func (s *SQLStore) Get(ctx context.Context, id string) (Session, error) {
+	var (
+		sess Session
+		data []byte
+	)
+	err := s.db.QueryRowContext(ctx, s.queries.get, id).Scan(&sess.ID, &sess.UserID, &data, &sess.CreatedAt, &sess.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrNotFound
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &sess.Data); err != nil {
+		return Session{}, fmt.Errorf("failed to decode session data: %w", err)
+	}
+	return sess, nil
+}
+
+// Save implements Store
+func (s *SQLStore) Save(ctx context.Context, sess Session) error {
+	data, err := json.Marshal(sess.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode session data: %w", err)
+	}
+	if sess.Data == nil {
+		data = []byte("{}")
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.queries.save, sess.ID, sess.UserID, string(data), sess.CreatedAt.UTC(), sess.ExpiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, s.queries.delete, id); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired implements Store
+func (s *SQLStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, s.queries.deleteExpired, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(n), nil
+}
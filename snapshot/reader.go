@@ -0,0 +1,157 @@
+package snapshot
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Reader reads a snapshot, checking every chunk against its CRC
+type Reader struct {
+	r        io.ReaderAt
+	size     int64
+	dec      *zstd.Decoder
+	manifest Manifest
+}
+
+// Open reads the header, trailer and manifest of the size-byte snapshot
+// in r, returning a CorruptError if they fail their checks
+func Open(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < int64(headerSize+trailerSize) {
+		return nil, &CorruptError{Reason: "too short"}
+	}
+
+	head := make([]byte, headerSize)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	if string(head[:len(headerMagic)]) != headerMagic {
+		return nil, &CorruptError{Reason: "not a snapshot"}
+	}
+	if v := binary.BigEndian.Uint32(head[len(headerMagic):]); v == 0 || v > Version {
+		return nil, fmt.Errorf("unsupported snapshot version %d, at most %d is supported", v, Version)
+	}
+
+	tail := make([]byte, trailerSize)
+	if _, err := r.ReadAt(tail, size-int64(trailerSize)); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot trailer: %w", err)
+	}
+	if string(tail[16:]) != trailerMagic {
+		return nil, &CorruptError{Reason: "missing trailer, the snapshot may be truncated"}
+	}
+	offset := int64(binary.BigEndian.Uint64(tail))
+	length := int64(binary.BigEndian.Uint32(tail[8:]))
+	crc := binary.BigEndian.Uint32(tail[12:])
+	if offset < int64(headerSize) || offset+length > size-int64(trailerSize) {
+		return nil, &CorruptError{Reason: "manifest out of bounds"}
+	}
+
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	sr := &Reader{r: r, size: size, dec: dec}
+
+	raw, err := sr.read(offset, length)
+	if err != nil {
+		dec.Close()
+		return nil, &CorruptError{Reason: "manifest: " + err.Error()}
+	}
+	if checksum(raw) != crc {
+		dec.Close()
+		return nil, &CorruptError{Reason: "manifest checksum mismatch"}
+	}
+	if err := json.Unmarshal(raw, &sr.manifest); err != nil {
+		dec.Close()
+		return nil, &CorruptError{Reason: "manifest: " + err.Error()}
+	}
+	return sr, nil
+}
+
+// Manifest returns the snapshot's manifest
+func (r *Reader) Manifest() Manifest {
+	return r.manifest
+}
+
+// Close releases the decoder; it doesn't close the underlying reader
+func (r *Reader) Close() {
+	r.dec.Close()
+}
+
+// Table returns a reader of the named table's data. Each chunk is checked
+// as it is read, and a bad one fails the read with a CorruptError.
+func (r *Reader) Table(name string) (io.Reader, error) {
+	t := r.manifest.Table(name)
+	if t == nil {
+		return nil, fmt.Errorf("table %s is not in the snapshot", name)
+	}
+	return &tableReader{sr: r, table: t}, nil
+}
+
+// Verify reads every chunk of every table, returning the first
+// CorruptError found
+func (r *Reader) Verify() error {
+	for i := range r.manifest.Tables {
+		t := &r.manifest.Tables[i]
+		for n := range t.Chunks {
+			if _, err := r.chunk(t, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// chunk returns the uncompressed, checked data of chunk n of t
+func (r *Reader) chunk(t *Table, n int) ([]byte, error) {
+	c := t.Chunks[n]
+	if c.Offset < int64(headerSize) || c.Offset+c.Length > r.size-int64(trailerSize) {
+		return nil, &CorruptError{Table: t.Name, Chunk: n, Reason: "out of bounds"}
+	}
+	raw, err := r.read(c.Offset, c.Length)
+	if err != nil {
+		return nil, &CorruptError{Table: t.Name, Chunk: n, Reason: err.Error()}
+	}
+	if int64(len(raw)) != c.RawLength || checksum(raw) != c.CRC {
+		return nil, &CorruptError{Table: t.Name, Chunk: n, Reason: "checksum mismatch"}
+	}
+	return raw, nil
+}
+
+// read decompresses length bytes at offset
+func (r *Reader) read(offset, length int64) ([]byte, error) {
+	compressed := make([]byte, length)
+	if _, err := r.r.ReadAt(compressed, offset); err != nil {
+		return nil, err
+	}
+	return r.dec.DecodeAll(compressed, nil)
+}
+
+// tableReader streams the chunks of one table
+type tableReader struct {
+	sr    *Reader
+	table *Table
+	next  int
+	buf   []byte
+}
+
+// Read implements io.Reader
+func (t *tableReader) Read(p []byte) (int, error) {
+	for len(t.buf) == 0 {
+		if t.next == len(t.table.Chunks) {
+			return 0, io.EOF
+		}
+		raw, err := t.sr.chunk(t.table, t.next)
+		if err != nil {
+			return 0, err
+		}
+		t.buf = raw
+		t.next++
+	}
+	n := copy(p, t.buf)
+	t.buf = t.buf[n:]
+	return n, nil
+}
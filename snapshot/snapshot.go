@@ -0,0 +1,126 @@
+// Package snapshot defines the backup file format. A snapshot is a header,
+// the data of each table split into zstd-compressed chunks, and a manifest
+// indexing the chunks, followed by a fixed-size trailer locating the
+// manifest:
+//
+//	header   "ADPTSNAP" | version uint32
+//	chunks   zstd frame ...
+//	manifest zstd-compressed JSON Manifest
+//	trailer  manifest offset uint64 | manifest length uint32 |
+//	         manifest CRC uint32 | "ADPTSEND"
+//
+// Integers are big-endian and CRCs are CRC-32C of the uncompressed bytes,
+// so corruption is detected on restore whether it hit the compressed data
+// or the manifest.
+package snapshot
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// Version is the format version written by Writer; Reader opens snapshots
+// up to it
+const Version = 1
+
+const (
+	headerMagic  = "ADPTSNAP"
+	trailerMagic = "ADPTSEND"
+	headerSize   = len(headerMagic) + 4
+	trailerSize  = 8 + 4 + 4 + len(trailerMagic)
+)
+
+// DefaultChunkSize is the uncompressed size of a chunk
+const DefaultChunkSize = 4 << 20
+
+// crcTable is the CRC-32C table chunk checksums use
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupt is wrapped by errors for snapshots that fail their checks
+var ErrCorrupt = errors.New("snapshot is corrupt")
+
+// CorruptError describes where a snapshot failed its checks
+type CorruptError struct {
+	// Table and Chunk locate a bad chunk; both are empty for a bad header,
+	// manifest or trailer
+	Table  string
+	Chunk  int
+	Reason string
+}
+
+// Error implements error
+func (e *CorruptError) Error() string {
+	if e.Table == "" {
+		return fmt.Sprintf("snapshot is corrupt: %s", e.Reason)
+	}
+	return fmt.Sprintf("snapshot is corrupt: table %s chunk %d: %s", e.Table, e.Chunk, e.Reason)
+}
+
+// Unwrap returns ErrCorrupt
+func (e *CorruptError) Unwrap() error {
+	return ErrCorrupt
+}
+
+// Manifest lists the tables of a snapshot and where their chunks are
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Format is the encoding of the table data, e.g. "pgcopy-binary"
+	Format string `json:"format"`
+	// Meta describes the source, e.g. its schema version
+	Meta   map[string]string `json:"meta,omitempty"`
+	Tables []Table           `json:"tables"`
+}
+
+// Table returns the named table, or nil if the snapshot doesn't hold it
+func (m *Manifest) Table(name string) *Table {
+	for i := range m.Tables {
+		if m.Tables[i].Name == name {
+			return &m.Tables[i]
+		}
+	}
+	return nil
+}
+
+// Table is the data of one table in a snapshot
+type Table struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+	// Size is the uncompressed size of the data
+	Size   int64   `json:"size"`
+	Chunks []Chunk `json:"chunks"`
+}
+
+// Chunk locates one compressed chunk of table data
+type Chunk struct {
+	Offset    int64  `json:"offset"`
+	Length    int64  `json:"length"`
+	RawLength int64  `json:"raw_length"`
+	CRC       uint32 `json:"crc"`
+}
+
+// checksum returns the CRC-32C of b
+func checksum(b []byte) uint32 {
+	return crc32.Checksum(b, crcTable)
+}
+
+// header returns the snapshot header for version
+func header(version uint32) []byte {
+	b := make([]byte, headerSize)
+	copy(b, headerMagic)
+	binary.BigEndian.PutUint32(b[len(headerMagic):], version)
+	return b
+}
+
+// trailer returns the trailer locating the manifest
+func trailer(offset int64, length int, crc uint32) []byte {
+	b := make([]byte, trailerSize)
+	binary.BigEndian.PutUint64(b, uint64(offset))
+	binary.BigEndian.PutUint32(b[8:], uint32(length))
+	binary.BigEndian.PutUint32(b[12:], crc)
+	copy(b[16:], trailerMagic)
+	return b
+}
@@ -0,0 +1,142 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Writer writes a snapshot to an io.Writer, one table at a time. It needs
+// no seeking, so it can write to a pipe or an upload.
+type Writer struct {
+	w        io.Writer
+	offset   int64
+	enc      *zstd.Encoder
+	manifest Manifest
+
+	// ChunkSize is the uncompressed size of a chunk
+	ChunkSize int
+}
+
+// NewWriter writes the snapshot header to w and returns a writer for the
+// rest; format names the encoding of the table data
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	sw := &Writer{
+		w:         w,
+		enc:       enc,
+		manifest:  Manifest{Version: Version, Format: format, Meta: make(map[string]string)},
+		ChunkSize: DefaultChunkSize,
+	}
+	if err := sw.write(header(Version)); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// SetMeta records a key and value describing the source in the manifest
+func (w *Writer) SetMeta(key, value string) {
+	w.manifest.Meta[key] = value
+}
+
+// WriteTable adds table name with the data fn writes and the row count it
+// returns. A table failing halfway leaves its chunks in the output, but
+// it isn't listed in the manifest.
+func (w *Writer) WriteTable(name string, fn func(w io.Writer) (rows int64, err error)) error {
+	if w.manifest.Table(name) != nil {
+		return fmt.Errorf("table %s is already in the snapshot", name)
+	}
+
+	cw := &chunkWriter{sw: w, table: Table{Name: name}, buf: make([]byte, 0, w.ChunkSize)}
+	rows, err := fn(cw)
+	if err != nil {
+		return err
+	}
+	if cw.err != nil {
+		return cw.err
+	}
+	if err := cw.flush(); err != nil {
+		return err
+	}
+	cw.table.Rows = rows
+	w.manifest.Tables = append(w.manifest.Tables, cw.table)
+	return nil
+}
+
+// Close writes the manifest and trailer, completing the snapshot. It
+// doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	defer w.enc.Close()
+
+	w.manifest.CreatedAt = time.Now().UTC()
+	raw, err := json.Marshal(w.manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot manifest: %w", err)
+	}
+	offset := w.offset
+	compressed := w.enc.EncodeAll(raw, nil)
+	if err := w.write(compressed); err != nil {
+		return err
+	}
+	return w.write(trailer(offset, len(compressed), checksum(raw)))
+}
+
+// write writes b and advances the offset
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// chunkWriter buffers a table's data and writes it in compressed chunks
+type chunkWriter struct {
+	sw    *Writer
+	table Table
+	buf   []byte
+	err   error
+}
+
+// Write implements io.Writer
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		room := cap(c.buf) - len(c.buf)
+		take := min(room, len(p))
+		c.buf = append(c.buf, p[:take]...)
+		p = p[take:]
+		if len(c.buf) == cap(c.buf) {
+			if c.err = c.flush(); c.err != nil {
+				return n - len(p), c.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush compresses and writes the buffered data as one chunk
+func (c *chunkWriter) flush() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+	compressed := c.sw.enc.EncodeAll(c.buf, nil)
+	chunk := Chunk{Offset: c.sw.offset, Length: int64(len(compressed)), RawLength: int64(len(c.buf)), CRC: checksum(c.buf)}
+	if err := c.sw.write(compressed); err != nil {
+		return err
+	}
+	c.table.Chunks = append(c.table.Chunks, chunk)
+	c.table.Size += chunk.RawLength
+	c.buf = c.buf[:0]
+	return nil
+}
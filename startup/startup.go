@@ -0,0 +1,156 @@
+// Package startup holds the application back from serving traffic until
+// the services it depends on, e.g. databases, brokers and caches, are
+// healthy.
+package startup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check reports whether a dependency is ready to use
+type Check func(ctx context.Context) error
+
+// Gate waits for declared dependencies to pass their checks
+type Gate struct {
+	deps map[string]Check
+
+	// Deadline bounds the whole wait; 30s by default
+	Deadline time.Duration
+	// Interval is the first delay between failed checks of a dependency,
+	// doubled after every failure up to 5s; 250ms by default
+	Interval time.Duration
+	// Logf, when set, reports each dependency as it becomes ready
+	Logf func(format string, args ...any)
+}
+
+// NewGate creates a gate with no dependencies
+func NewGate() *Gate {
+	return &Gate{deps: make(map[string]Check), Deadline: 30 * time.Second, Interval: 250 * time.Millisecond}
+}
+
+// Require declares a dependency the gate waits for
+func (g *Gate) Require(name string, check Check) *Gate {
+	g.deps[name] = check
+	return g
+}
+
+// DependencyError is a dependency that was not ready by the deadline
+type DependencyError struct {
+	Name string
+	// Err is the error of the last check
+	Err      error
+	Attempts int
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("%s: not ready after %d attempts: %v", e.Name, e.Attempts, e.Err)
+}
+
+func (e *DependencyError) Unwrap() error { return e.Err }
+
+// Error lists the dependencies that failed to become ready, by name
+type Error struct {
+	Failed []*DependencyError
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		lines[i] = f.Error()
+	}
+	return "dependencies not ready: " + strings.Join(lines, "; ")
+}
+
+// Unwrap returns the dependency errors, for errors.Is and errors.As
+func (e *Error) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f
+	}
+	return errs
+}
+
+// Wait checks every dependency concurrently, retrying failed checks, and
+// returns once all pass. When the deadline elapses or ctx is cancelled
+// first it returns an *Error describing each dependency still failing.
+func (g *Gate) Wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, g.Deadline)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []*DependencyError
+	)
+	for name, check := range g.deps {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			if err := g.await(ctx, name, check); err != nil {
+				mu.Lock()
+				failed = append(failed, err)
+				mu.Unlock()
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	if len(failed) == 0 {
+		return nil
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Name < failed[j].Name })
+	return &Error{Failed: failed}
+}
+
+// await retries check until it passes or ctx is done
+func (g *Gate) await(ctx context.Context, name string, check Check) *DependencyError {
+	start := time.Now()
+	delay := g.Interval
+	for attempt := 1; ; attempt++ {
+		err := check(ctx)
+		if err == nil {
+			if g.Logf != nil {
+				g.Logf("Dependency %s ready after %d attempts (%s)", name, attempt, time.Since(start).Round(time.Millisecond))
+			}
+			return nil
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return &DependencyError{Name: name, Err: err, Attempts: attempt}
+		case <-t.C:
+		}
+		delay = min(2*delay, 5*time.Second)
+	}
+}
+
+// Ping checks a database by pinging it
+func Ping(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping database: %w", err)
+		}
+		return nil
+	}
+}
+
+// Dial checks a TCP service, e.g. a broker or cache, by connecting to addr
+func Dial(addr string) Check {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %w", addr, err)
+		}
+		return conn.Close()
+	}
+}
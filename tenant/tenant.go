@@ -0,0 +1,32 @@
+// Package tenant carries the current tenant through context
+package tenant
+
+import "context"
+
+// ctxKey stores the tenant in a context
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx scoped to tenant id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant in ctx, or "" if none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// actorKey stores the acting user in a context
+type actorKey struct{}
+
+// WithActor returns a copy of ctx recording the user acting on the tenant
+func WithActor(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, actorKey{}, id)
+}
+
+// ActorFromContext returns the acting user in ctx, or "" if none
+func ActorFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(actorKey{}).(string)
+	return id
+}